@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"log"
 	"maryan_api/config"
+	"maryan_api/internal/infrastructure/clients/payment"
 	"maryan_api/internal/infrastructure/clients/stripe"
 	dataStore "maryan_api/internal/infrastructure/persistence"
 	"maryan_api/internal/infrastructure/router"
+	"maryan_api/internal/infrastructure/scheduler"
+	ginutil "maryan_api/pkg/ginutils"
 	"maryan_api/pkg/timezone"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,21 +20,42 @@ import (
 
 func main() {
 	config.LoadConfig("../../.env")
-	timezone.Load()
 
 	db := dataStore.Init()
 	dataStore.Migrate(db)
 	config.LoadCountries(db)
+	timezone.Load(db)
+	go dataStore.ReloadCountries(context.Background(), db, time.Duration(config.CountryReloadIntervalSeconds())*time.Second)
 
-	stripe.InitStripe()
 	server := gin.Default()
 	server.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{"Authorization", "Content-Type", "X-Email-Access-Token", "X-Customer-Update-Token"},
+		AllowHeaders: []string{"Authorization", "Content-Type", "X-Email-Access-Token", "X-Customer-Update-Token", ginutil.IdempotencyKeyHeader},
 		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 	}))
 	client := http.DefaultClient
-	router.RegisterRoutes(server, db, client)
+
+	var provider payment.Provider
+	switch config.PaymentProvider() {
+	case "fake":
+		// The simulator pages let anyone mark a checkout as paid.
+		if !config.DevMode() {
+			log.Fatal("The fake payment provider is only available with DEV_MODE=true")
+		}
+
+		fake := payment.NewFake(client)
+		fake.RegisterRoutes(server)
+		provider = fake
+	default:
+		provider = stripe.NewProvider()
+	}
+
+	router.RegisterRoutes(server, db, client, provider)
+
+	jobs := scheduler.New(db)
+	router.RegisterJobs(db, jobs, provider)
+	jobs.Start(context.Background())
+
 	server.Static("/imgs", "../../static/imgs")
 	server.GET("", func(ctx *gin.Context) {
 		ctx.JSON(
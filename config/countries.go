@@ -2,15 +2,29 @@ package config
 
 import (
 	"fmt"
+	"maryan_api/pkg/timezone"
+	"sync"
 	"time"
 
 	"github.com/d3code/uuid"
 	"gorm.io/gorm"
 )
 
-var countries = map[string]uuid.UUID{}
+var (
+	countriesMu sync.RWMutex
+	countries   = map[string]uuid.UUID{}
+)
 
 func LoadCountries(db *gorm.DB) {
+	err := ReloadCountries(db)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ReloadCountries replaces the countries with those in the countries table;
+// it runs whenever a country is added or renamed, and periodically.
+func ReloadCountries(db *gorm.DB) error {
 	var countriesSlice []struct {
 		ID   uuid.UUID
 		Name string
@@ -18,43 +32,33 @@ func LoadCountries(db *gorm.DB) {
 
 	err := db.Table("countries").Select("id", "name").Scan(&countriesSlice).Error
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	var loaded = make(map[string]uuid.UUID, len(countriesSlice))
 	for _, country := range countriesSlice {
-		countries[country.Name] = country.ID
+		loaded[country.Name] = country.ID
 	}
+
+	countriesMu.Lock()
+	countries = loaded
+	countriesMu.Unlock()
+	return nil
 }
 
 func CountryExists(country string) (uuid.UUID, bool) {
+	countriesMu.RLock()
+	defer countriesMu.RUnlock()
+
 	id, ok := countries[country]
 	return id, ok
 }
 
 func ParseToLocal(date time.Time, country string) (time.Time, error) {
-	location, err := countryToLocation(country)
-	if err != nil {
-		return time.Time{}, err
+	local, ok := timezone.Transform(date, country)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unsupported country: %s", country)
 	}
 
-	return date.In(location), nil
-}
-
-func countryToLocation(country string) (*time.Location, error) {
-	mapping := map[string]string{
-		"Germany":   "Europe/Berlin",
-		"Poland":    "Europe/Warsaw",
-		"Czechia":   "Europe/Prague",
-		"Estonia":   "Europe/Tallinn",
-		"Latvia":    "Europe/Riga",
-		"Lithuania": "Europe/Vilnius",
-		"Slovakia":  "Europe/Bratislava",
-		"Hungary":   "Europe/Budapest",
-		"Ukraine":   "Europe/Kyiv", // official spelling since Go 1.15+
-	}
-
-	if tz, ok := mapping[country]; ok {
-		return time.LoadLocation(tz)
-	}
-	return nil, fmt.Errorf("unsupported country: %s", country)
+	return local, nil
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -23,6 +24,28 @@ func mustGetEnv(key string) string {
 	return val
 }
 
+// getEnv returns the environment variable value or the fallback if not set
+func getEnv(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+// getEnvInt returns the environment variable as int or the fallback if not set
+func getEnvInt(key string, fallback int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		panic("INVALID " + key)
+	}
+	return n
+}
+
 // mustGetEnvBytes returns the environment variable as []byte or panics if not set
 func mustGetEnvBytes(key string) []byte {
 	return []byte(mustGetEnv(key))
@@ -65,6 +88,10 @@ func SecretKeyCustomerUpdate() []byte {
 	return mustGetEnvBytes("SECRET_KEY_CUSTOMER_UPDATE")
 }
 
+func TicketTransferTokenSecretKey() []byte {
+	return mustGetEnvBytes("TICKET_TRANSFER_TOKEN_SECRET_KEY")
+}
+
 func AdminSecretKey() []byte {
 	return mustGetEnvBytes("ADMIN_SECRET_KEY")
 }
@@ -85,6 +112,83 @@ func StripSekretKey() string {
 	return mustGetEnv("STRIPE_SECRET_KEY")
 }
 
+func StripeWebhookSecretKey() string {
+	return mustGetEnv("STRIPE_WEBHOOK_SECRET_KEY")
+}
+
+func PaymentProvider() string {
+	return getEnv("PAYMENT_PROVIDER", "stripe")
+}
+
+// LegacyTimeZone is the zone, such as Europe/Kyiv, the server stored the
+// times in before they were kept in UTC. When set, they are converted to UTC
+// once at the start-up, which needs the time zone tables of MySQL loaded.
+func LegacyTimeZone() string {
+	return getEnv("LEGACY_TIME_ZONE", "")
+}
+
+// OperatingCountry is the country whose days the cash is reconciled by.
+func OperatingCountry() string {
+	return getEnv("OPERATING_COUNTRY", "Ukraine")
+}
+
+func PaymentCurrency() string {
+	return getEnv("PAYMENT_CURRENCY", "eur")
+}
+
+// DevMode enables what only belongs to development, such as the fake
+// payment provider and its simulator pages.
+func DevMode() bool {
+	return getEnv("DEV_MODE", "false") == "true"
+}
+
+// FakePaymentSecretKey signs the webhooks of the fake payment provider; it
+// has no default, so nobody can forge them with a well-known one.
+func FakePaymentSecretKey() []byte {
+	return mustGetEnvBytes("FAKE_PAYMENT_SECRET_KEY")
+}
+
+// BoardingPassSigningKey signs the boarding pass codes drivers check in.
+func BoardingPassSigningKey() []byte {
+	return mustGetEnvBytes("BOARDING_PASS_SIGNING_KEY")
+}
+
+// WaitlistSigningKey signs the links of waitlist offers, so a leaked offer
+// link cannot be turned into a boarding pass code or the other way round.
+func WaitlistSigningKey() []byte {
+	return mustGetEnvBytes("WAITLIST_SIGNING_KEY")
+}
+
+func RebookingWindowHours() int {
+	return getEnvInt("REBOOKING_WINDOW_HOURS", 24)
+}
+
+func PurchaseExpiryIntervalMinutes() int {
+	return getEnvInt("PURCHASE_EXPIRY_INTERVAL_MINUTES", 5)
+}
+
+func WaitlistOfferIntervalMinutes() int {
+	return getEnvInt("WAITLIST_OFFER_INTERVAL_MINUTES", 5)
+}
+
+// CountryReloadIntervalSeconds is how often every instance reloads the
+// countries and their time zones changed through another instance.
+func CountryReloadIntervalSeconds() int {
+	return getEnvInt("COUNTRY_RELOAD_INTERVAL_SECONDS", 60)
+}
+
+func TransferCutoffHours() int {
+	return getEnvInt("TRANSFER_CUTOFF_HOURS", 12)
+}
+
+func CancellationFullRefundHours() int {
+	return getEnvInt("CANCELLATION_FULL_REFUND_HOURS", 72)
+}
+
+func CancellationPartialRefundPercent() int {
+	return getEnvInt("CANCELLATION_PARTIAL_REFUND_PERCENT", 50)
+}
+
 type db struct {
 	User     string
 	Password string
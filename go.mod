@@ -5,6 +5,8 @@ go 1.23.0
 toolchain go1.23.8
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.30.0
 )
@@ -22,6 +22,7 @@ type Bus interface {
 	GetAvailable(ctx context.Context, dates []time.Time, pagination dbutil.Pagination) ([]entity.Bus, int, error, bool)
 	SetSchedule(ctx context.Context, schedule []entity.BusAvailability) error
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	SetAmenities(ctx context.Context, id uuid.UUID, amenities []entity.BusAmenity) error
 }
 
 type Driver interface {
@@ -76,6 +77,10 @@ func (b *busRepo) SetSchedule(ctx context.Context, schedule []entity.BusAvailabi
 	return b.store.SetSchedule(ctx, schedule)
 }
 
+func (b *busRepo) SetAmenities(ctx context.Context, id uuid.UUID, amenities []entity.BusAmenity) error {
+	return b.store.SetAmenities(ctx, id, amenities)
+}
+
 func (b *busRepo) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
 	return b.store.Exists(ctx, id)
 }
@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type SeatSurcharge interface {
+	Create(ctx context.Context, surcharge *entity.SeatSurcharge) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetSurcharges(ctx context.Context, pagination dbutil.Pagination) ([]entity.SeatSurcharge, int, error, bool)
+	BusExists(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type seatSurchargeRepo struct {
+	store    dataStore.SeatSurcharge
+	busStore dataStore.Bus
+}
+
+func (r *seatSurchargeRepo) Create(ctx context.Context, surcharge *entity.SeatSurcharge) error {
+	return r.store.Create(ctx, surcharge)
+}
+
+func (r *seatSurchargeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.Delete(ctx, id)
+}
+
+func (r *seatSurchargeRepo) GetSurcharges(ctx context.Context, pagination dbutil.Pagination) ([]entity.SeatSurcharge, int, error, bool) {
+	return r.store.GetSurcharges(ctx, pagination)
+}
+
+func (r *seatSurchargeRepo) BusExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	return r.busStore.Exists(ctx, id)
+}
+
+func NewSeatSurchargeRepo(db *gorm.DB) SeatSurcharge {
+	return &seatSurchargeRepo{dataStore.NewSeatSurcharge(db), dataStore.NewBus(db)}
+}
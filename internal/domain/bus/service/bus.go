@@ -27,6 +27,7 @@ type Bus interface {
 	ChangeDriver(driverType driverType) func(ctx context.Context, busIDStr, driverIDStr string) error
 	GetAvailable(ctx context.Context, paginationStr dbutil.PaginationStr, fromStr, toStr string) ([]entity.Bus, hypermedia.Links, error)
 	SetSchedule(ctx context.Context, schedule []entity.BusAvailability) error
+	SetAmenities(ctx context.Context, idStr string, amenities []string) error
 }
 
 type busServiceImpl struct {
@@ -218,6 +219,29 @@ func (b *busServiceImpl) SetSchedule(ctx context.Context, schedule []entity.BusA
 	return b.bus.SetSchedule(ctx, schedule)
 }
 
+func (b *busServiceImpl) SetAmenities(ctx context.Context, idStr string, values []string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	amenities, invalidParams := entity.ParseBusAmenities(id, values)
+	if invalidParams != nil {
+		return rfc7807.BadRequest("invalid-bus-amenities", "Invalid Bus Amenities Error", "Provided params are not valid.", invalidParams...)
+	}
+
+	exists, err := b.bus.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return rfc7807.BadRequest("non-existing-bus", "Non-existring Bus Error", "There is no bus assosiated with provided id.")
+	}
+
+	return b.bus.SetAmenities(ctx, id, amenities)
+}
+
 // --------------------Services Initialization Functions
 
 func NewBusService(bus repo.Bus, driver repo.Driver) Bus {
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/bus/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"strconv"
+	"strings"
+
+	"github.com/d3code/uuid"
+)
+
+type SeatSurcharge interface {
+	Create(ctx context.Context, newSurcharge entity.NewSeatSurcharge) (uuid.UUID, error)
+	Delete(ctx context.Context, idStr string) error
+	GetSurcharges(ctx context.Context, paginationStr dbutil.PaginationStr, busIDStr, lineStr string) ([]entity.SeatSurcharge, hypermedia.Links, error)
+}
+
+type seatSurchargeServiceImpl struct {
+	repo repo.SeatSurcharge
+}
+
+func (s *seatSurchargeServiceImpl) Create(ctx context.Context, newSurcharge entity.NewSeatSurcharge) (uuid.UUID, error) {
+	surcharge, params := newSurcharge.Parse()
+	if params != nil {
+		return uuid.Nil, rfc7807.BadRequest("invalid-seat-surcharge-data", "Invalid Seat Surcharge Data Error", "Invalid params.", params...)
+	}
+
+	if surcharge.BusID.Valid {
+		exists, err := s.repo.BusExists(ctx, surcharge.BusID.UUID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if !exists {
+			return uuid.Nil, rfc7807.BadRequest("non-existing-bus", "Non-existing Bus Error", "There is no bus with such id.")
+		}
+	}
+
+	return surcharge.ID, s.repo.Create(ctx, &surcharge)
+}
+
+func (s *seatSurchargeServiceImpl) Delete(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *seatSurchargeServiceImpl) GetSurcharges(ctx context.Context, paginationStr dbutil.PaginationStr, busIDStr, lineStr string) ([]entity.SeatSurcharge, hypermedia.Links, error) {
+	var conditions []string
+	var values []any
+
+	if busIDStr != "" {
+		busID, err := uuid.Parse(busIDStr)
+		if err != nil {
+			return nil, nil, rfc7807.UUID(err.Error())
+		}
+		conditions = append(conditions, "bus_id = ?")
+		values = append(values, busID)
+	}
+
+	if lineStr != "" {
+		line, err := strconv.Atoi(lineStr)
+		if err != nil {
+			return nil, nil, rfc7807.BadRequest("invalid-line", "Invalid Line Error", err.Error())
+		}
+		conditions = append(conditions, "line = ?")
+		values = append(values, line)
+	}
+
+	var pagination dbutil.Pagination
+	var err error
+	if len(conditions) == 0 {
+		pagination, err = paginationStr.Parse([]string{}, "created_at", "amount")
+	} else {
+		pagination, err = paginationStr.ParseWithCondition(dbutil.Condition{strings.Join(conditions, " AND "), values}, []string{}, "created_at", "amount")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	surcharges, total, err, empty := s.repo.GetSurcharges(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return surcharges, hypermedia.Pagination(paginationStr, total,
+		hypermedia.DefaultParam{"bus_id", "", busIDStr},
+		hypermedia.DefaultParam{"line", "", lineStr},
+	), nil
+}
+
+func NewSeatSurchargeService(repo repo.SeatSurcharge) SeatSurcharge {
+	return &seatSurchargeServiceImpl{repo}
+}
@@ -116,6 +116,32 @@ func (b *busHandler) setBusSchedule(ctx *gin.Context) {
 	})
 }
 
+func (b *busHandler) setBusAmenities(ctx *gin.Context) {
+	var request struct {
+		Amenities []string `json:"amenities"`
+	}
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = b.service.SetAmenities(ctxWithTimeout, ctx.Param("id"), request.Amenities)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The amenities of the bus have successfuly been updated.",
+		hypermedia.Links{},
+	})
+}
+
 func (b *busHandler) createBus(ctx *gin.Context) {
 
 	form, err := ctx.MultipartForm()
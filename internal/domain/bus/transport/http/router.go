@@ -17,7 +17,7 @@ func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
 	handler := newBusHandler(service.NewBusService(repo.NewBusRepo(db), repo.NewDriverRepo(db)))
 
 	//-----------------------Bus Routes------------------------------------
-	adminRouter.POST("/bus", handler.createBus)
+	adminRouter.POST("/bus", ginutil.Idempotency(db), handler.createBus)
 	adminRouter.GET("/bus/:id", handler.getBus)
 	adminRouter.GET("/buses", handler.getBuses)
 	adminRouter.DELETE("/bus", handler.deleteBus)
@@ -25,6 +25,14 @@ func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
 	adminRouter.PATCH("/bus/:id/lead-driver", handler.changeDriver(leadDriverType))
 	adminRouter.PATCH("/bus/:id/assistant-driver", handler.changeDriver(assistantDriverType))
 	adminRouter.GET("/buses/available", handler.getAvailableBuses)
+	adminRouter.PUT("/bus/:id/amenities", handler.setBusAmenities)
+
+	//-----------------------Seat Surcharge Routes-------------------------
+	surchargeHandler := newSeatSurchargeHandler(service.NewSeatSurchargeService(repo.NewSeatSurchargeRepo(db)))
+
+	adminRouter.POST("/seat-surcharge", surchargeHandler.create)
+	adminRouter.GET("/seat-surcharges", surchargeHandler.getSurcharges)
+	adminRouter.DELETE("/seat-surcharge/:id", surchargeHandler.delete)
 }
 
 // -------------Links-----------------
@@ -43,4 +51,9 @@ var (
 		Name: "deleteBus",
 		Data: hypermedia.LinkData{Href: "/bus", Method: "DELETE"},
 	}
+
+	listSeatSurchargesLink = hypermedia.Link{
+		Name: "listSeatSurcharges",
+		Data: hypermedia.LinkData{Href: "/admin/seat-surcharges", Method: "GET"},
+	}
 )
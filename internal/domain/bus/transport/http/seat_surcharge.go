@@ -0,0 +1,97 @@
+package http
+
+import (
+	"maryan_api/internal/domain/bus/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type seatSurchargeHandler struct {
+	service service.SeatSurcharge
+}
+
+func (h *seatSurchargeHandler) create(ctx *gin.Context) {
+	var surcharge entity.NewSeatSurcharge
+
+	err := ctx.ShouldBindJSON(&surcharge)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, surcharge)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		ID string `json:"id"`
+	}{
+		ginutil.Response{
+			"The seat surcharge has successfuly been created.",
+			hypermedia.Links{listSeatSurchargesLink},
+		},
+		id.String(),
+	})
+}
+
+func (h *seatSurchargeHandler) getSurcharges(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	surcharges, urls, err := h.service.GetSurcharges(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/seat-surcharges",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		"",
+	}, ctx.Query("bus_id"), ctx.Query("line"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		SeatSurcharges []entity.SeatSurcharge `json:"seatSurcharges"`
+	}{
+		ginutil.Response{
+			"The seat surcharges have successfuly been found.",
+			urls,
+		},
+		surcharges,
+	})
+}
+
+func (h *seatSurchargeHandler) delete(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Delete(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The seat surcharge has successfuly been deleted.",
+		hypermedia.Links{listSeatSurchargesLink},
+	})
+}
+
+func newSeatSurchargeHandler(service service.SeatSurcharge) *seatSurchargeHandler {
+	return &seatSurchargeHandler{service}
+}
@@ -14,6 +14,7 @@ import (
 
 type Connection interface {
 	GetByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	TakenSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
 	GetConnections(ctx context.Context, pagination dbutil.Pagination) ([]entity.Connection, int, error, bool)
 	ChangeDepartureTime(ctx context.Context, id uuid.UUID, departureTime time.Time) error
 	ChangeGoogleMapsURL(ctx context.Context, id uuid.UUID, url string) error
@@ -22,20 +23,51 @@ type Connection interface {
 	RegisterUpdate(ctx context.Context, update *entity.ConnectionUpdate) error
 	ChangeType(ctx context.Context, id uuid.UUID, connectionType entity.ConnectionType) error
 	FindConnections(ctx context.Context, request entity.FindConnectionsRequest) (dataStore.FoundConnections, error)
+	FindLegs(ctx context.Context, cities []string, after, before time.Time) ([]entity.Connection, error)
+	DepartureDay(ctx context.Context, city string, date time.Time) (time.Time, time.Time, error)
+	SeatsLeft(ctx context.Context, id uuid.UUID, segment entity.Segment, seatType string) (int, error)
+	GetSeatSurcharges(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error)
+	GetWaitlistDepth(ctx context.Context, id uuid.UUID) (entity.WaitlistDepth, error)
 }
 
 type connectionRepo struct {
-	ds dataStore.Connection
+	ds            dataStore.Connection
+	seatSurcharge dataStore.SeatSurcharge
+	waitlist      dataStore.Waitlist
+}
+
+func (r *connectionRepo) GetWaitlistDepth(ctx context.Context, id uuid.UUID) (entity.WaitlistDepth, error) {
+	return r.waitlist.Depth(ctx, id)
+}
+
+func (r *connectionRepo) GetSeatSurcharges(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error) {
+	return r.seatSurcharge.GetForBus(ctx, busID, line)
 }
 
 func (r *connectionRepo) FindConnections(ctx context.Context, request entity.FindConnectionsRequest) (dataStore.FoundConnections, error) {
 	return r.ds.FindConnections(ctx, request)
 }
 
+func (r *connectionRepo) FindLegs(ctx context.Context, cities []string, after, before time.Time) ([]entity.Connection, error) {
+	return r.ds.FindLegs(ctx, cities, after, before)
+}
+
+func (r *connectionRepo) DepartureDay(ctx context.Context, city string, date time.Time) (time.Time, time.Time, error) {
+	return r.ds.DepartureDay(ctx, city, date)
+}
+
+func (r *connectionRepo) SeatsLeft(ctx context.Context, id uuid.UUID, segment entity.Segment, seatType string) (int, error) {
+	return r.ds.SeatsLeft(ctx, id, segment, seatType)
+}
+
 func (r *connectionRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
 	return r.ds.GetByID(ctx, id)
 }
 
+func (r *connectionRepo) TakenSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return r.ds.TakenSeats(ctx, id, segment)
+}
+
 func (r *connectionRepo) GetConnections(ctx context.Context, pagination dbutil.Pagination) ([]entity.Connection, int, error, bool) {
 	return r.ds.GetConnections(ctx, pagination)
 }
@@ -66,5 +98,5 @@ func (r *connectionRepo) ChangeType(ctx context.Context, id uuid.UUID, connectio
 
 // Constructor
 func NewConnectionRepo(db *gorm.DB) Connection {
-	return &connectionRepo{dataStore.NewConnection(db)}
+	return &connectionRepo{dataStore.NewConnection(db), dataStore.NewSeatSurcharge(db), dataStore.NewWaitlist(db)}
 }
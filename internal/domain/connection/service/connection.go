@@ -14,13 +14,13 @@ import (
 )
 
 type AdminConnection interface {
-	GetByID(ctx context.Context, id string) (entity.Connection, error)
+	GetByID(ctx context.Context, id string) (entity.AdminConnection, error)
 	GetConnections(ctx context.Context, pagination dbutil.PaginationStr, complete string) ([]entity.ConnectionSimplified, hypermedia.Links, error)
 	RegisterUpdate(ctx context.Context, update entity.ConnectionUpdate) error
 }
 
 type CustomerConnection interface {
-	GetByID(ctx context.Context, id string) (entity.CustomerConnection, error)
+	GetByID(ctx context.Context, id, from, to string) (entity.CustomerConnection, error)
 	GetConnections(ctx context.Context, userID uuid.UUID, pagination dbutil.PaginationStr, complete string) ([]entity.CustomerConnection, hypermedia.Links, error)
 	FindConnections(ctx context.Context, request entity.FindConnectionsRequestJSON) (entity.FindConnectionsResponse, error)
 }
@@ -50,16 +50,29 @@ func (c *connectionService) FindConnections(ctx context.Context, requestJSON ent
 			return ticketsLeft.ID == connection.ID
 		})]
 
+		segment, ok := connection.FindSegment(request.From, request.To)
+		if !ok {
+			return entity.FindConnectionsResponse{}, rfc7807.DB("internal")
+		}
+		connection = connection.OnSegment(segment)
+
 		response.Connections[i] = entity.FoundConnection{
 			ConnectionSimplified: connection.Simplify(),
 			TicketsLeft:          int(ticketsLeft.Number),
-			Fits:                 int(ticketsLeft.Number)-request.Adults-request.Children-request.Teenagers >= 0,
+			Fits:                 int(ticketsLeft.Number)-request.Passengers() >= 0,
+			TotalPrice:           connection.PartyPrice(request.Adults, request.Teenagers, request.Children),
 		}
 	}
 
 	response.LeftRange = found.LeftRange
 	slices.Reverse(response.LeftRange)
 	response.RigthRange = found.RightRange
+
+	response.Itineraries, err = c.findItineraries(ctx, request)
+	if err != nil {
+		return entity.FindConnectionsResponse{}, err
+	}
+
 	return response, nil
 }
 
@@ -108,9 +121,18 @@ type customerService struct {
 
 //-------------------------Interface implementation--------------------------------
 
-func (c *adminService) GetByID(ctx context.Context, idStr string) (entity.Connection, error) {
+func (c *adminService) GetByID(ctx context.Context, idStr string) (entity.AdminConnection, error) {
 	connection, _, err := c.getByID(ctx, idStr)
-	return connection, err
+	if err != nil {
+		return entity.AdminConnection{}, err
+	}
+
+	waitlist, err := c.repo.GetWaitlistDepth(ctx, connection.ID)
+	if err != nil {
+		return entity.AdminConnection{}, err
+	}
+
+	return entity.AdminConnection{Connection: connection, Waitlist: waitlist}, nil
 }
 
 func (c *adminService) GetConnections(ctx context.Context, paginationStr dbutil.PaginationStr, completed string) ([]entity.ConnectionSimplified, hypermedia.Links, error) {
@@ -137,15 +159,33 @@ func (c *adminService) RegisterUpdate(ctx context.Context, update entity.Connect
 	return c.repo.RegisterUpdate(ctx, &update)
 }
 
-func (c *customerService) GetByID(ctx context.Context, connectionIDStr string) (entity.CustomerConnection, error) {
+// GetByID shows the seats free between the cities; without them, the seats
+// free along the whole connection.
+func (c *customerService) GetByID(ctx context.Context, connectionIDStr, from, to string) (entity.CustomerConnection, error) {
+
+	connection, _, err := c.getByID(ctx, connectionIDStr)
+
+	if err != nil {
+		return entity.CustomerConnection{}, err
+	}
 
-	connection, takedSeatsIDs, err := c.getByID(ctx, connectionIDStr)
+	segment, ok := connection.FindSegment(from, to)
+	if !ok {
+		return entity.CustomerConnection{}, rfc7807.BadRequest("invalid-segment", "Invalid Segment Error", "The connection does not call at the cities in that order.")
+	}
+	connection = connection.OnSegment(segment)
+
+	takedSeatsIDs, err := c.repo.TakenSeats(ctx, connection.ID, segment)
+	if err != nil {
+		return entity.CustomerConnection{}, err
+	}
 
+	surcharges, err := c.repo.GetSeatSurcharges(ctx, connection.BusID, connection.Line)
 	if err != nil {
 		return entity.CustomerConnection{}, err
 	}
 
-	return connection.ToCustomer(takedSeatsIDs), nil
+	return connection.ToCustomer(takedSeatsIDs, surcharges), nil
 }
 
 func (c *customerService) GetConnections(ctx context.Context, userID uuid.UUID, paginationStr dbutil.PaginationStr, completed string) ([]entity.CustomerConnection, hypermedia.Links, error) {
@@ -156,7 +196,7 @@ func (c *customerService) GetConnections(ctx context.Context, userID uuid.UUID,
 
 	var connectionsCustomer = make([]entity.CustomerConnection, len(connections))
 	for i, connection := range connections {
-		connectionsCustomer[i] = connection.ToCustomer(nil)
+		connectionsCustomer[i] = connection.ToCustomer(nil, nil)
 	}
 
 	return connectionsCustomer, urls, nil
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// findItineraries chains up to MaxItineraryLegs connections, changing buses
+// in the cities where one of them arrives and the next one leaves within the
+// transfer window. The direct connections are left to the base search.
+func (c *connectionService) findItineraries(ctx context.Context, request entity.FindConnectionsRequest) ([]entity.Itinerary, error) {
+	dayStart, dayEnd, err := c.repo.DepartureDay(ctx, request.From, request.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := c.repo.FindLegs(ctx, []string{request.From}, dayStart, dayEnd.Add(-time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	var journeys [][]entity.Connection
+	for _, connection := range first {
+		if _, direct := connection.FindSegment(request.From, request.To); direct {
+			continue
+		}
+		journeys = append(journeys, transfers(nil, connection, connection.StationIndex(request.From))...)
+	}
+
+	var itineraries []entity.Itinerary
+	for legs := 2; legs <= entity.MaxItineraryLegs && len(journeys) > 0; legs++ {
+		var cities []string
+		var earliest, latest time.Time
+		for i, journey := range journeys {
+			last := journey[len(journey)-1]
+			if _, city := last.SegmentCities(last.Segment); !slices.Contains(cities, city) {
+				cities = append(cities, city)
+			}
+
+			if i == 0 || last.ArrivalTime.Before(earliest) {
+				earliest = last.ArrivalTime
+			}
+
+			if i == 0 || last.ArrivalTime.After(latest) {
+				latest = last.ArrivalTime
+			}
+		}
+
+		next, err := c.repo.FindLegs(ctx, cities, earliest.Add(request.MinTransfer), latest.Add(request.MaxTransfer))
+		if err != nil {
+			return nil, err
+		}
+
+		var extended [][]entity.Connection
+		for _, journey := range journeys {
+			last := journey[len(journey)-1]
+			_, city := last.SegmentCities(last.Segment)
+
+			for _, connection := range next {
+				if slices.ContainsFunc(journey, func(leg entity.Connection) bool { return leg.ID == connection.ID }) {
+					continue
+				}
+
+				board := connection.StationIndex(city)
+				if board == -1 || board == len(connection.Route.Stations)-1 {
+					continue
+				}
+
+				transfer := connection.StationDeparture(board).Sub(last.ArrivalTime)
+				if transfer < request.MinTransfer || transfer > request.MaxTransfer {
+					continue
+				}
+
+				if segment, ok := connection.FindSegment(city, request.To); ok {
+					chain := append(slices.Clone(journey), connection.OnSegment(segment))
+					itineraries = append(itineraries, entity.NewItinerary(chain, request.Adults, request.Teenagers, request.Children))
+					continue
+				}
+
+				if legs < entity.MaxItineraryLegs {
+					extended = append(extended, transfers(journey, connection, board)...)
+				}
+			}
+		}
+		journeys = extended
+	}
+
+	itineraries = slices.DeleteFunc(itineraries, func(itinerary entity.Itinerary) bool {
+		return !request.Filters.Matches(itinerary)
+	})
+
+	// The seats are counted for all the itineraries before they are sorted,
+	// as they can be sorted by them; the legs they share are counted once.
+	var counted = map[seatsKey]int{}
+	var found = itineraries[:0]
+	for _, itinerary := range itineraries {
+		var ticketsLeft = make([]int, len(itinerary.Legs))
+		var seatType = true
+		for j, leg := range itinerary.Legs {
+			ticketsLeft[j], err = c.seatsLeft(ctx, counted, seatsKey{leg.ID, leg.Segment, ""})
+			if err != nil {
+				return nil, err
+			}
+
+			if request.Filters.SeatType == "" {
+				continue
+			}
+
+			left, err := c.seatsLeft(ctx, counted, seatsKey{leg.ID, leg.Segment, string(request.Filters.SeatType)})
+			if err != nil {
+				return nil, err
+			}
+
+			if left < request.Passengers() {
+				seatType = false
+				break
+			}
+		}
+
+		if seatType {
+			itinerary.SetTicketsLeft(ticketsLeft, request.Passengers())
+			found = append(found, itinerary)
+		}
+	}
+
+	entity.SortItineraries(found, request.Sort)
+	if len(found) > entity.MaxItineraries {
+		found = found[:entity.MaxItineraries]
+	}
+
+	return found, nil
+}
+
+type seatsKey struct {
+	id       uuid.UUID
+	segment  entity.Segment
+	seatType string
+}
+
+// seatsLeft counts the seats left on the segment of the connection once per
+// search.
+func (c *connectionService) seatsLeft(ctx context.Context, counted map[seatsKey]int, key seatsKey) (int, error) {
+	left, ok := counted[key]
+	if ok {
+		return left, nil
+	}
+
+	left, err := c.repo.SeatsLeft(ctx, key.id, key.segment, key.seatType)
+	if err != nil {
+		return 0, err
+	}
+
+	counted[key] = left
+	return left, nil
+}
+
+// transfers extends the journey with the connection boarded at the station,
+// once for every later station not visited yet, where the passengers could
+// change buses again.
+func transfers(journey []entity.Connection, connection entity.Connection, board int) [][]entity.Connection {
+	var journeys [][]entity.Connection
+	for alight := board + 1; alight < len(connection.Route.Stations); alight++ {
+		if visited(journey, connection.Route.Stations[alight].City) {
+			continue
+		}
+		journeys = append(journeys, append(slices.Clone(journey), connection.OnSegment(entity.Segment{From: board, To: alight})))
+	}
+
+	return journeys
+}
+
+func visited(journey []entity.Connection, city string) bool {
+	return slices.ContainsFunc(journey, func(leg entity.Connection) bool {
+		from, to := leg.SegmentCities(leg.Segment)
+		return strings.EqualFold(from, city) || strings.EqualFold(to, city)
+	})
+}
@@ -30,7 +30,7 @@ func (ch *adminHandler) GetByID(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, struct {
-		Connection entity.Connection `json:"connection"`
+		Connection entity.AdminConnection `json:"connection"`
 		ginutil.Response
 	}{
 		connection,
@@ -108,7 +108,7 @@ func (ch *customerHandler) GetByID(ctx *gin.Context) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), time.Second*10)
 	defer cancel()
 
-	connection, err := ch.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	connection, err := ch.service.GetByID(ctxWithTimeout, ctx.Param("id"), ctx.Query("from"), ctx.Query("to"))
 	if err != nil {
 		ginutil.ServiceErrorAbort(ctx, err)
 		return
@@ -166,13 +166,25 @@ func (ch *customerHandler) FindConnections(ctx *gin.Context) {
 	response, err := ch.service.FindConnections(
 		ctxWithTimeout,
 		entity.FindConnectionsRequestJSON{
-			From:      ctx.Param("from"),
-			To:        ctx.Param("to"),
-			Date:      ctx.Param("date"),
-			Adults:    ctx.Param("adults"),
-			Children:  ctx.Param("children"),
-			Teenagers: ctx.Param("teenagers"),
-			Range:     ctx.DefaultQuery("range", "5"),
+			From:        ctx.Param("from"),
+			To:          ctx.Param("to"),
+			Date:        ctx.Param("date"),
+			Adults:      ctx.Param("adults"),
+			Children:    ctx.Param("children"),
+			Teenagers:   ctx.Param("teenagers"),
+			Range:       ctx.DefaultQuery("range", "5"),
+			MinTransfer: ctx.Query("min_transfer"),
+			MaxTransfer: ctx.Query("max_transfer"),
+
+			DepartureFrom: ctx.Query("departure_from"),
+			DepartureTo:   ctx.Query("departure_to"),
+			ArrivalFrom:   ctx.Query("arrival_from"),
+			ArrivalTo:     ctx.Query("arrival_to"),
+			MaxPrice:      ctx.Query("max_price"),
+			MaxDuration:   ctx.Query("max_duration"),
+			Amenities:     ctx.Query("amenities"),
+			SeatType:      ctx.Query("seat_type"),
+			Sort:          ctx.Query("sort"),
 		},
 	)
 
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Country interface {
+	Create(ctx context.Context, country *entity.Country) error
+	Update(ctx context.Context, country *entity.Country) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Country, error)
+	GetAll(ctx context.Context) ([]entity.Country, error)
+	NameTaken(ctx context.Context, name string, id uuid.UUID) (bool, error)
+	Reload(ctx context.Context) error
+}
+
+type countryRepo struct {
+	store dataStore.Country
+}
+
+func (r *countryRepo) Create(ctx context.Context, country *entity.Country) error {
+	return r.store.Create(ctx, country)
+}
+
+func (r *countryRepo) Update(ctx context.Context, country *entity.Country) error {
+	return r.store.Update(ctx, country)
+}
+
+func (r *countryRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Country, error) {
+	return r.store.GetByID(ctx, id)
+}
+
+func (r *countryRepo) GetAll(ctx context.Context) ([]entity.Country, error) {
+	return r.store.GetAll(ctx)
+}
+
+func (r *countryRepo) NameTaken(ctx context.Context, name string, id uuid.UUID) (bool, error) {
+	return r.store.NameTaken(ctx, name, id)
+}
+
+func (r *countryRepo) Reload(ctx context.Context) error {
+	return r.store.Reload(ctx)
+}
+
+func NewCountryRepo(db *gorm.DB) Country {
+	return &countryRepo{dataStore.NewCountry(db)}
+}
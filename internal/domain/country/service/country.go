@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log"
+	"maryan_api/internal/domain/country/repo"
+	"maryan_api/internal/entity"
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+)
+
+type Country interface {
+	Create(ctx context.Context, country entity.Country) (uuid.UUID, error)
+	Update(ctx context.Context, idStr string, country entity.Country) error
+	GetByID(ctx context.Context, idStr string) (entity.Country, error)
+	GetAll(ctx context.Context) ([]entity.Country, error)
+}
+
+type countryServiceImpl struct {
+	repo repo.Country
+}
+
+func (s *countryServiceImpl) Create(ctx context.Context, country entity.Country) (uuid.UUID, error) {
+	params := country.Prepare()
+	if params != nil {
+		return uuid.Nil, rfc7807.BadRequest("invalid-country-data", "Invalid Country Data Error", "Invalid params.", params...)
+	}
+
+	err := s.checkName(ctx, country)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = s.repo.Create(ctx, &country)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	s.reload(ctx)
+	return country.ID, nil
+}
+
+// Update renames the country or changes its time zone; this instance shows
+// the schedules in the new zone right away, the others once they reload the
+// countries.
+func (s *countryServiceImpl) Update(ctx context.Context, idStr string, country entity.Country) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	_, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	params := country.Validate()
+	if params != nil {
+		return rfc7807.BadRequest("invalid-country-data", "Invalid Country Data Error", "Invalid params.", params...)
+	}
+
+	country.ID = id
+	err = s.checkName(ctx, country)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Update(ctx, &country)
+	if err != nil {
+		return err
+	}
+
+	s.reload(ctx)
+	return nil
+}
+
+// reload refreshes the cached countries after a change; the change has been
+// saved by then, so a failure only delays it until the next periodic reload.
+func (s *countryServiceImpl) reload(ctx context.Context) {
+	err := s.repo.Reload(ctx)
+	if err != nil {
+		log.Printf("Failed to reload the countries: %v", err)
+	}
+}
+
+func (s *countryServiceImpl) GetByID(ctx context.Context, idStr string) (entity.Country, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.Country{}, rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *countryServiceImpl) GetAll(ctx context.Context) ([]entity.Country, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *countryServiceImpl) checkName(ctx context.Context, country entity.Country) error {
+	taken, err := s.repo.NameTaken(ctx, country.Name, country.ID)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return rfc7807.BadRequest("country-exists", "Country Exists Error", "There already is a country named "+country.Name+".")
+	}
+
+	return nil
+}
+
+func NewCountryService(repo repo.Country) Country {
+	return &countryServiceImpl{repo}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/country/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type countryHandler struct {
+	service service.Country
+}
+
+// create adds a country with the name and the IANA time zone, such as
+// Europe/Kyiv, its schedules are shown in.
+func (h *countryHandler) create(ctx *gin.Context) {
+	var country entity.Country
+
+	err := ctx.ShouldBindJSON(&country)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, country)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ginutil.Response{
+		"The country has successfuly been created.",
+		hypermedia.Links{
+			hypermedia.Link{
+				"self", hypermedia.LinkData{config.APIURL() + "/admin/countries/" + id.String(), "GET"},
+			},
+			listCountriesLink,
+		},
+	})
+}
+
+func (h *countryHandler) update(ctx *gin.Context) {
+	var country entity.Country
+
+	err := ctx.ShouldBindJSON(&country)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = h.service.Update(ctxWithTimeout, ctx.Param("id"), country)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The country has successfuly been updated.",
+		hypermedia.Links{listCountriesLink},
+	})
+}
+
+func (h *countryHandler) getCountry(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	country, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Country entity.Country `json:"country"`
+	}{
+		ginutil.Response{
+			"The country has successfuly been found.",
+			hypermedia.Links{listCountriesLink},
+		},
+		country,
+	})
+}
+
+func (h *countryHandler) getCountries(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	countries, err := h.service.GetAll(ctxWithTimeout)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Countries []entity.Country `json:"countries"`
+	}{
+		ginutil.Response{
+			"The countries have successfuly been found.",
+			hypermedia.Links{},
+		},
+		countries,
+	})
+}
+
+func newCountryHandler(service service.Country) *countryHandler {
+	return &countryHandler{service}
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"maryan_api/internal/domain/country/repo"
+	"maryan_api/internal/domain/country/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+	handler := newCountryHandler(service.NewCountryService(repo.NewCountryRepo(db)))
+
+	//-----------------------Country Routes----------------------------------
+	adminRouter.POST("/countries", handler.create)
+	adminRouter.GET("/countries", handler.getCountries)
+	adminRouter.GET("/countries/:id", handler.getCountry)
+	adminRouter.PUT("/countries/:id", handler.update)
+}
+
+// -------------Links-----------------
+var (
+	listCountriesLink = hypermedia.Link{
+		Name: "listCountries",
+		Data: hypermedia.LinkData{Href: "/admin/countries", Method: "GET"},
+	}
+)
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Driver interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetConnections(ctx context.Context, driverID uuid.UUID, from, to time.Time) ([]entity.Connection, error)
+	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, error)
+	GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	LockStop(ctx context.Context, id uuid.UUID) (entity.Stop, error)
+	LockPickUp(ctx context.Context, ticketID uuid.UUID) (entity.Stop, error)
+	RegisterStopUpdate(ctx context.Context, update *entity.StopUpdate) error
+	ChangeTicketStatus(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) error
+	GetLuggageManifest(ctx context.Context, connectionID uuid.UUID) ([]entity.LuggageManifestItem, error)
+}
+
+type driverRepo struct {
+	transactor dataStore.Transactor
+	connection dataStore.Connection
+	ticket     dataStore.Ticket
+	stop       dataStore.Stop
+	extra      dataStore.Extra
+}
+
+func (r *driverRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *driverRepo) GetConnections(ctx context.Context, driverID uuid.UUID, from, to time.Time) ([]entity.Connection, error) {
+	return r.connection.GetDriverConnections(ctx, driverID, from, to)
+}
+
+func (r *driverRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, error) {
+	connection, _, err := r.connection.GetByID(ctx, id)
+	return connection, err
+}
+
+func (r *driverRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.GetByID(ctx, id)
+}
+
+func (r *driverRepo) LockStop(ctx context.Context, id uuid.UUID) (entity.Stop, error) {
+	return r.stop.LockByID(ctx, id)
+}
+
+func (r *driverRepo) LockPickUp(ctx context.Context, ticketID uuid.UUID) (entity.Stop, error) {
+	return r.stop.LockPickUp(ctx, ticketID)
+}
+
+func (r *driverRepo) ChangeTicketStatus(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) error {
+	return r.ticket.ChangeStatus(ctx, id, update)
+}
+
+func (r *driverRepo) RegisterStopUpdate(ctx context.Context, update *entity.StopUpdate) error {
+	return r.stop.RegisterUpdate(ctx, update)
+}
+
+func (r *driverRepo) GetLuggageManifest(ctx context.Context, connectionID uuid.UUID) ([]entity.LuggageManifestItem, error) {
+	return r.extra.Manifest(ctx, connectionID)
+}
+
+func NewDriverRepo(db *gorm.DB) Driver {
+	return &driverRepo{
+		dataStore.NewTransactor(db),
+		dataStore.NewConnection(db),
+		dataStore.NewTicket(db),
+		dataStore.NewStop(db),
+		dataStore.NewExtra(db),
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/driver/repo"
+	"maryan_api/internal/entity"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Driver interface {
+	GetTodayConnections(ctx context.Context, driverID uuid.UUID) ([]entity.Connection, error)
+	CheckIn(ctx context.Context, driverID uuid.UUID, checkIn entity.CheckInJSON) (entity.Ticket, error)
+	MarkMissed(ctx context.Context, driverID uuid.UUID, stopIDStr, comment string) error
+	GetLuggageManifest(ctx context.Context, driverID uuid.UUID, connectionIDStr string) (entity.LuggageManifest, error)
+}
+
+type driverServiceImpl struct {
+	repo       repo.Driver
+	signingKey []byte
+}
+
+// GetTodayConnections returns the connections of the driver leaving today in
+// the time zone of the country they leave from.
+func (s *driverServiceImpl) GetTodayConnections(ctx context.Context, driverID uuid.UUID) ([]entity.Connection, error) {
+	// Every time zone's today lies within a day of the UTC now.
+	now := time.Now()
+	connections, err := s.repo.GetConnections(ctx, driverID, now.AddDate(0, 0, -1), now.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(connections, func(connection entity.Connection) bool {
+		departure := connection.LocalDepartureTime
+		return departure.Format("2006-01-02") != now.In(departure.Location()).Format("2006-01-02")
+	}), nil
+}
+
+func (s *driverServiceImpl) CheckIn(ctx context.Context, driverID uuid.UUID, checkIn entity.CheckInJSON) (entity.Ticket, error) {
+	ticketID, ok := entity.ParseBoardingToken(checkIn.Token, s.signingKey)
+	if !ok {
+		return entity.Ticket{}, rfc7807.BadRequest("invalid-ticket-token", "Invalid Ticket Token Error", "The scanned code is not a valid ticket.")
+	}
+
+	err := s.checkConnection(ctx, driverID, checkIn.ConnectionID)
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, ticketID)
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	if ticket.ConnectionID != checkIn.ConnectionID {
+		return entity.Ticket{}, rfc7807.BadRequest("wrong-connection", "Wrong Connection Error", "The ticket is issued for another connection.")
+	}
+
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		stop, err := s.repo.LockPickUp(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		if stop.Status() == entity.CompletedStopStatus {
+			return rfc7807.New(http.StatusConflict, "already-checked-in", "Already Checked In Error", "The ticket has already been scanned.")
+		}
+
+		err = s.repo.RegisterStopUpdate(ctx, &entity.StopUpdate{
+			StopID: stop.ID,
+			Status: entity.CompletedStopStatus,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.repo.ChangeTicketStatus(ctx, ticketID, entity.TicketUpdate{Status: entity.TicketStatusBoarded})
+	})
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	ticket.Status = entity.TicketStatusBoarded
+	return ticket, nil
+}
+
+func (s *driverServiceImpl) MarkMissed(ctx context.Context, driverID uuid.UUID, stopIDStr, comment string) error {
+	stopID, err := uuid.Parse(stopIDStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	if comment == "" || len(comment) > 500 {
+		return rfc7807.BadRequest("invalid-comment", "Invalid Comment Error", "The comment has to contain from 1 to 500 characters.")
+	}
+
+	return s.repo.Transaction(ctx, func(ctx context.Context) error {
+		stop, err := s.repo.LockStop(ctx, stopID)
+		if err != nil {
+			return err
+		}
+
+		err = s.checkConnection(ctx, driverID, stop.ConnectionID)
+		if err != nil {
+			return err
+		}
+
+		switch stop.Status() {
+		case entity.CompletedStopStatus:
+			return rfc7807.New(http.StatusConflict, "completed-stop", "Completed Stop Error", "The stop has already been completed.")
+		case entity.MissedStopStatus:
+			return rfc7807.New(http.StatusConflict, "missed-stop", "Missed Stop Error", "The stop has already been marked as missed.")
+		}
+
+		return s.repo.RegisterStopUpdate(ctx, &entity.StopUpdate{
+			StopID:  stop.ID,
+			Status:  entity.MissedStopStatus,
+			Comment: comment,
+		})
+	})
+}
+
+func (s *driverServiceImpl) GetLuggageManifest(ctx context.Context, driverID uuid.UUID, connectionIDStr string) (entity.LuggageManifest, error) {
+	connectionID, err := uuid.Parse(connectionIDStr)
+	if err != nil {
+		return entity.LuggageManifest{}, rfc7807.UUID(err.Error())
+	}
+
+	connection, err := s.repo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return entity.LuggageManifest{}, err
+	}
+
+	if !connection.IsDrivenBy(driverID) {
+		return entity.LuggageManifest{}, rfc7807.Forbidden("foreign-connection", "Foreign Connection Error", "The connection is not driven by the driver.")
+	}
+
+	items, err := s.repo.GetLuggageManifest(ctx, connectionID)
+	if err != nil {
+		return entity.LuggageManifest{}, err
+	}
+
+	var manifest = entity.LuggageManifest{
+		ConnectionID:    connectionID,
+		LuggageCapacity: connection.Bus.LuggageCapacity,
+		Items:           items,
+	}
+	for _, item := range items {
+		manifest.TotalWeight += item.Weight
+	}
+
+	return manifest, nil
+}
+
+// checkConnection makes sure the driver is assigned to the bus of the connection.
+func (s *driverServiceImpl) checkConnection(ctx context.Context, driverID, connectionID uuid.UUID) error {
+	connection, err := s.repo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return err
+	}
+
+	if !connection.IsDrivenBy(driverID) {
+		return rfc7807.Forbidden("foreign-connection", "Foreign Connection Error", "The connection is not driven by the driver.")
+	}
+
+	return nil
+}
+
+func NewDriverService(repo repo.Driver, signingKey []byte) Driver {
+	return &driverServiceImpl{repo, signingKey}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"maryan_api/internal/domain/driver/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type driverHandler struct {
+	service service.Driver
+}
+
+func (h *driverHandler) getTodayConnections(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	connections, err := h.service.GetTodayConnections(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Connections []entity.Connection `json:"connections"`
+	}{
+		ginutil.Response{
+			"The connections have successfuly been found.",
+			hypermedia.Links{},
+		},
+		connections,
+	})
+}
+
+func (h *driverHandler) checkIn(ctx *gin.Context) {
+	var request entity.CheckInJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	ticket, err := h.service.CheckIn(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Ticket entity.Ticket `json:"ticket"`
+	}{
+		ginutil.Response{
+			"The passenger has successfuly been checked in.",
+			hypermedia.Links{},
+		},
+		ticket,
+	})
+}
+
+func (h *driverHandler) markMissed(ctx *gin.Context) {
+	var request struct {
+		Comment string `json:"comment"`
+	}
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = h.service.MarkMissed(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"), request.Comment)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The stop has successfuly been marked as missed.",
+		hypermedia.Links{},
+	})
+}
+
+func (h *driverHandler) getLuggageManifest(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	manifest, err := h.service.GetLuggageManifest(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Manifest entity.LuggageManifest `json:"manifest"`
+	}{
+		ginutil.Response{
+			"The luggage manifest has successfuly been found.",
+			hypermedia.Links{},
+		},
+		manifest,
+	})
+}
+
+func newDriverHandler(service service.Driver) *driverHandler {
+	return &driverHandler{service}
+}
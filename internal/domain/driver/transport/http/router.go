@@ -0,0 +1,25 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/driver/repo"
+	"maryan_api/internal/domain/driver/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	driverRouter := ginutil.CreateAuthRouter("/driver", auth.Driver.SecretKey(), s)
+	handler := newDriverHandler(service.NewDriverService(repo.NewDriverRepo(db), config.BoardingPassSigningKey()))
+
+	//-----------------------Boarding Routes---------------------------------------
+
+	driverRouter.GET("/connections/today", handler.getTodayConnections)
+	driverRouter.POST("/check-in", handler.checkIn)
+	driverRouter.POST("/stop/:id/missed", handler.markMissed)
+	driverRouter.GET("/connection/:id/luggage", handler.getLuggageManifest)
+}
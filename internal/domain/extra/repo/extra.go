@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Extra interface {
+	Create(ctx context.Context, extra *entity.Extra) error
+	Update(ctx context.Context, extra *entity.Extra) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Extra, error)
+	GetExtras(ctx context.Context, pagination dbutil.Pagination) ([]entity.Extra, int, error, bool)
+}
+
+type extraRepo struct {
+	store dataStore.Extra
+}
+
+func (r *extraRepo) Create(ctx context.Context, extra *entity.Extra) error {
+	return r.store.Create(ctx, extra)
+}
+
+func (r *extraRepo) Update(ctx context.Context, extra *entity.Extra) error {
+	return r.store.Update(ctx, extra)
+}
+
+func (r *extraRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.Delete(ctx, id)
+}
+
+func (r *extraRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Extra, error) {
+	return r.store.GetByID(ctx, id)
+}
+
+func (r *extraRepo) GetExtras(ctx context.Context, pagination dbutil.Pagination) ([]entity.Extra, int, error, bool) {
+	return r.store.GetExtras(ctx, pagination)
+}
+
+func NewExtraRepo(db *gorm.DB) Extra {
+	return &extraRepo{dataStore.NewExtra(db)}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/extra/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+)
+
+type Extra interface {
+	Create(ctx context.Context, extra entity.Extra) (uuid.UUID, error)
+	Update(ctx context.Context, idStr string, extra entity.Extra) error
+	Delete(ctx context.Context, idStr string) error
+	GetByID(ctx context.Context, idStr string) (entity.Extra, error)
+	GetExtras(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Extra, hypermedia.Links, error)
+}
+
+type extraServiceImpl struct {
+	repo repo.Extra
+}
+
+func (s *extraServiceImpl) Create(ctx context.Context, extra entity.Extra) (uuid.UUID, error) {
+	params := extra.Prepare()
+	if params != nil {
+		return uuid.Nil, rfc7807.BadRequest("invalid-extra-data", "Invalid Extra Data Error", "Invalid params.", params...)
+	}
+
+	return extra.ID, s.repo.Create(ctx, &extra)
+}
+
+func (s *extraServiceImpl) Update(ctx context.Context, idStr string, extra entity.Extra) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	_, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	params := extra.Validate()
+	if params != nil {
+		return rfc7807.BadRequest("invalid-extra-data", "Invalid Extra Data Error", "Invalid params.", params...)
+	}
+
+	extra.ID = id
+	return s.repo.Update(ctx, &extra)
+}
+
+func (s *extraServiceImpl) Delete(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *extraServiceImpl) GetByID(ctx context.Context, idStr string) (entity.Extra, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.Extra{}, rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *extraServiceImpl) GetExtras(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Extra, hypermedia.Links, error) {
+	pagination, err := paginationStr.Parse([]string{"name"}, "created_at", "name", "price", "type")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extras, total, err, empty := s.repo.GetExtras(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return extras, hypermedia.Pagination(paginationStr, total), nil
+}
+
+func NewExtraService(repo repo.Extra) Extra {
+	return &extraServiceImpl{repo}
+}
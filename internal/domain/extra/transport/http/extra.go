@@ -0,0 +1,145 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/extra/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type extraHandler struct {
+	service service.Extra
+}
+
+func (h *extraHandler) create(ctx *gin.Context) {
+	var extra entity.Extra
+
+	err := ctx.ShouldBindJSON(&extra)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, extra)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ginutil.Response{
+		"The extra has successfuly been created.",
+		hypermedia.Links{
+			hypermedia.Link{
+				"self", hypermedia.LinkData{config.APIURL() + "/admin/extras/" + id.String(), "GET"},
+			},
+			listExtrasLink,
+		},
+	})
+}
+
+func (h *extraHandler) update(ctx *gin.Context) {
+	var extra entity.Extra
+
+	err := ctx.ShouldBindJSON(&extra)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = h.service.Update(ctxWithTimeout, ctx.Param("id"), extra)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The extra has successfuly been updated.",
+		hypermedia.Links{listExtrasLink},
+	})
+}
+
+func (h *extraHandler) delete(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Delete(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The extra has successfuly been deleted.",
+		hypermedia.Links{createExtraLink},
+	})
+}
+
+func (h *extraHandler) getExtra(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	extra, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Extra entity.Extra `json:"extra"`
+	}{
+		ginutil.Response{
+			"The extra has successfuly been found.",
+			hypermedia.Links{listExtrasLink},
+		},
+		extra,
+	})
+}
+
+// getExtras serves the catalog both to the admins and to the customers
+// choosing the extras of a purchase.
+func (h *extraHandler) getExtras(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	extras, urls, err := h.service.GetExtras(ctxWithTimeout, dbutil.PaginationStr{
+		ctx.FullPath(),
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "name"),
+		ctx.DefaultQuery("order_way", "asc"),
+		ctx.DefaultQuery("search", ""),
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Extras []entity.Extra `json:"extras"`
+	}{
+		ginutil.Response{
+			"The extras have successfuly been found.",
+			urls,
+		},
+		extras,
+	})
+}
+
+func newExtraHandler(service service.Extra) *extraHandler {
+	return &extraHandler{service}
+}
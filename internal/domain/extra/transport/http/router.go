@@ -0,0 +1,40 @@
+package http
+
+import (
+	"maryan_api/internal/domain/extra/repo"
+	"maryan_api/internal/domain/extra/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+	customerRouter := ginutil.CreateAuthRouter("/customer", auth.Customer.SecretKey(), s)
+	handler := newExtraHandler(service.NewExtraService(repo.NewExtraRepo(db)))
+
+	//-----------------------Extra Routes----------------------------------
+	adminRouter.POST("/extras", handler.create)
+	adminRouter.GET("/extras", handler.getExtras)
+	adminRouter.GET("/extras/:id", handler.getExtra)
+	adminRouter.PUT("/extras/:id", handler.update)
+	adminRouter.DELETE("/extras/:id", handler.delete)
+	customerRouter.GET("/extras", handler.getExtras)
+}
+
+// -------------Links-----------------
+var (
+	listExtrasLink = hypermedia.Link{
+		Name: "listExtras",
+		Data: hypermedia.LinkData{Href: "/admin/extras", Method: "GET"},
+	}
+
+	createExtraLink = hypermedia.Link{
+		Name: "createExtra",
+		Data: hypermedia.LinkData{Href: "/admin/extras", Method: "POST"},
+	}
+)
@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Loyalty interface {
+	GetUser(ctx context.Context, id uuid.UUID) (entity.User, error)
+	Record(ctx context.Context, transaction *entity.LoyaltyTransaction) error
+	Balance(ctx context.Context, userID uuid.UUID) (int, error)
+	CountTrips(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	GetHistory(ctx context.Context, pagination dbutil.Pagination) ([]entity.LoyaltyTransaction, int, error, bool)
+}
+
+type loyaltyRepo struct {
+	loyalty dataStore.Loyalty
+	user    dataStore.User
+}
+
+func (r *loyaltyRepo) GetUser(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.user.GetByID(ctx, id)
+}
+
+func (r *loyaltyRepo) Record(ctx context.Context, transaction *entity.LoyaltyTransaction) error {
+	return r.loyalty.Record(ctx, transaction)
+}
+
+func (r *loyaltyRepo) Balance(ctx context.Context, userID uuid.UUID) (int, error) {
+	return r.loyalty.Balance(ctx, userID)
+}
+
+func (r *loyaltyRepo) CountTrips(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	return r.loyalty.CountTrips(ctx, userID, since)
+}
+
+func (r *loyaltyRepo) GetHistory(ctx context.Context, pagination dbutil.Pagination) ([]entity.LoyaltyTransaction, int, error, bool) {
+	return r.loyalty.GetHistory(ctx, pagination)
+}
+
+func NewLoyaltyRepo(db *gorm.DB) Loyalty {
+	return &loyaltyRepo{dataStore.NewLoyalty(db), dataStore.NewUser(db)}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/loyalty/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/auth"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Loyalty interface {
+	GetAccount(ctx context.Context, userID uuid.UUID) (entity.LoyaltyAccount, error)
+	GetHistory(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID) ([]entity.LoyaltyTransaction, hypermedia.Links, error)
+	Adjust(ctx context.Context, adminID uuid.UUID, userIDStr string, adjustment entity.LoyaltyAdjustmentJSON) (uuid.UUID, error)
+}
+
+type loyaltyServiceImpl struct {
+	repo repo.Loyalty
+}
+
+// GetAccount returns the balance of the customer and the tier earned by the
+// trips completed during the last year.
+func (s *loyaltyServiceImpl) GetAccount(ctx context.Context, userID uuid.UUID) (entity.LoyaltyAccount, error) {
+	balance, err := s.repo.Balance(ctx, userID)
+	if err != nil {
+		return entity.LoyaltyAccount{}, err
+	}
+
+	trips, err := s.repo.CountTrips(ctx, userID, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		return entity.LoyaltyAccount{}, err
+	}
+
+	return entity.NewLoyaltyAccount(balance, trips), nil
+}
+
+func (s *loyaltyServiceImpl) GetHistory(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID) ([]entity.LoyaltyTransaction, hypermedia.Links, error) {
+	pagination, err := paginationStr.ParseWithCondition(
+		dbutil.Condition{
+			Where:  "user_id = ?",
+			Values: []any{userID},
+		},
+		[]string{"reason"},
+		"created_at", "points", "type",
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transactions, total, err, empty := s.repo.GetHistory(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return transactions, hypermedia.Pagination(paginationStr, total), nil
+}
+
+func (s *loyaltyServiceImpl) Adjust(ctx context.Context, adminID uuid.UUID, userIDStr string, adjustment entity.LoyaltyAdjustmentJSON) (uuid.UUID, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, rfc7807.UUID(err.Error())
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if user.Role.Val == nil || user.Role.Val.Name() != auth.Customer.Name() {
+		return uuid.Nil, rfc7807.BadRequest("non-customer", "Non-customer Error", "Only customers collect loyalty points.")
+	}
+
+	transaction, err := adjustment.Parse(userID, adminID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	balance, err := s.repo.Balance(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if balance+transaction.Points < 0 {
+		return uuid.Nil, rfc7807.BadRequest("insufficient-loyalty-points", "Insufficient Loyalty Points Error", "The adjustment would leave the balance below 0.")
+	}
+
+	return transaction.ID, s.repo.Record(ctx, &transaction)
+}
+
+func NewLoyaltyService(repo repo.Loyalty) Loyalty {
+	return &loyaltyServiceImpl{repo}
+}
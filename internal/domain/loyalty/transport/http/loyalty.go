@@ -0,0 +1,92 @@
+package http
+
+import (
+	"maryan_api/internal/domain/loyalty/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type loyaltyHandler struct {
+	service service.Loyalty
+}
+
+func (h *loyaltyHandler) getLoyalty(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	userID := ctx.MustGet("userID").(uuid.UUID)
+
+	account, err := h.service.GetAccount(ctxWithTimeout, userID)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	history, urls, err := h.service.GetHistory(ctxWithTimeout, dbutil.PaginationStr{
+		"/customer/loyalty",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		ctx.DefaultQuery("search", ""),
+	}, userID)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Account entity.LoyaltyAccount       `json:"account"`
+		History []entity.LoyaltyTransaction `json:"history"`
+	}{
+		ginutil.Response{
+			"The loyalty account has successfuly been found.",
+			urls,
+		},
+		account,
+		history,
+	})
+}
+
+func (h *loyaltyHandler) adjust(ctx *gin.Context) {
+	var adjustment entity.LoyaltyAdjustmentJSON
+
+	err := ctx.ShouldBindJSON(&adjustment)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Adjust(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"), adjustment)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		ID uuid.UUID `json:"id"`
+	}{
+		ginutil.Response{
+			"The loyalty points have successfuly been adjusted.",
+			hypermedia.Links{},
+		},
+		id,
+	})
+}
+
+func newLoyaltyHandler(service service.Loyalty) *loyaltyHandler {
+	return &loyaltyHandler{service}
+}
@@ -0,0 +1,22 @@
+package http
+
+import (
+	"maryan_api/internal/domain/loyalty/repo"
+	"maryan_api/internal/domain/loyalty/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+	customerRouter := ginutil.CreateAuthRouter("/customer", auth.Customer.SecretKey(), s)
+	handler := newLoyaltyHandler(service.NewLoyaltyService(repo.NewLoyaltyRepo(db)))
+
+	//-----------------------Loyalty Routes----------------------------------
+	customerRouter.GET("/loyalty", handler.getLoyalty)
+	adminRouter.POST("/user/:id/loyalty-adjustment", handler.adjust)
+}
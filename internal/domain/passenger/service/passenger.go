@@ -40,6 +40,7 @@ func (p *passengerServiceImpl) Update(ctx context.Context, passenger entity.Pass
 	if params != nil {
 		return uuid.Nil, rfc7807.BadRequest("passenger-invalid-data", "Passenger Data Error", "Provided data is not valid.", params...)
 	}
+	passenger.SetDefaultCategory()
 
 	exists, usedByTicket, err := p.repo.Status(ctx, passenger.ID)
 	if err != nil {
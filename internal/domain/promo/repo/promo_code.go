@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type PromoCode interface {
+	Create(ctx context.Context, promoCode *entity.PromoCode) error
+	Update(ctx context.Context, promoCode *entity.PromoCode) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.PromoCode, error)
+	GetPromoCodes(ctx context.Context, pagination dbutil.Pagination) ([]entity.PromoCode, int, error, bool)
+}
+
+type promoCodeRepo struct {
+	store dataStore.PromoCode
+}
+
+func (r *promoCodeRepo) Create(ctx context.Context, promoCode *entity.PromoCode) error {
+	return r.store.Create(ctx, promoCode)
+}
+
+func (r *promoCodeRepo) Update(ctx context.Context, promoCode *entity.PromoCode) error {
+	return r.store.Update(ctx, promoCode)
+}
+
+func (r *promoCodeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.Delete(ctx, id)
+}
+
+func (r *promoCodeRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.PromoCode, error) {
+	return r.store.GetByID(ctx, id)
+}
+
+func (r *promoCodeRepo) GetPromoCodes(ctx context.Context, pagination dbutil.Pagination) ([]entity.PromoCode, int, error, bool) {
+	return r.store.GetPromoCodes(ctx, pagination)
+}
+
+func NewPromoCodeRepo(db *gorm.DB) PromoCode {
+	return &promoCodeRepo{dataStore.NewPromoCode(db)}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/promo/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+)
+
+type PromoCode interface {
+	Create(ctx context.Context, promoCode entity.PromoCode) (uuid.UUID, error)
+	Update(ctx context.Context, idStr string, promoCode entity.PromoCode) error
+	Delete(ctx context.Context, idStr string) error
+	GetByID(ctx context.Context, idStr string) (entity.PromoCode, error)
+	GetPromoCodes(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.PromoCode, hypermedia.Links, error)
+}
+
+type promoCodeServiceImpl struct {
+	repo repo.PromoCode
+}
+
+func (s *promoCodeServiceImpl) Create(ctx context.Context, promoCode entity.PromoCode) (uuid.UUID, error) {
+	params := promoCode.Prepare()
+	if params != nil {
+		return uuid.Nil, rfc7807.BadRequest("invalid-promo-code-data", "Invalid Promo Code Data Error", "Invalid params.", params...)
+	}
+
+	return promoCode.ID, s.repo.Create(ctx, &promoCode)
+}
+
+func (s *promoCodeServiceImpl) Update(ctx context.Context, idStr string, promoCode entity.PromoCode) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	_, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	params := promoCode.Validate()
+	if params != nil {
+		return rfc7807.BadRequest("invalid-promo-code-data", "Invalid Promo Code Data Error", "Invalid params.", params...)
+	}
+
+	promoCode.ID = id
+	for i := range promoCode.Restrictions {
+		promoCode.Restrictions[i].PromoCodeID = id
+	}
+
+	return s.repo.Update(ctx, &promoCode)
+}
+
+func (s *promoCodeServiceImpl) Delete(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *promoCodeServiceImpl) GetByID(ctx context.Context, idStr string) (entity.PromoCode, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.PromoCode{}, rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *promoCodeServiceImpl) GetPromoCodes(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.PromoCode, hypermedia.Links, error) {
+	pagination, err := paginationStr.Parse([]string{"code"}, "created_at", "valid_from", "valid_until", "code")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	promoCodes, total, err, empty := s.repo.GetPromoCodes(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return promoCodes, hypermedia.Pagination(paginationStr, total), nil
+}
+
+func NewPromoCodeService(repo repo.PromoCode) PromoCode {
+	return &promoCodeServiceImpl{repo}
+}
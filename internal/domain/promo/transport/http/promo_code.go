@@ -0,0 +1,143 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/promo/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type promoCodeHandler struct {
+	service service.PromoCode
+}
+
+func (h *promoCodeHandler) create(ctx *gin.Context) {
+	var promoCode entity.PromoCode
+
+	err := ctx.ShouldBindJSON(&promoCode)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, promoCode)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ginutil.Response{
+		"The promo code has successfuly been created.",
+		hypermedia.Links{
+			hypermedia.Link{
+				"self", hypermedia.LinkData{config.APIURL() + "/admin/promo-codes/" + id.String(), "GET"},
+			},
+			listPromoCodesLink,
+		},
+	})
+}
+
+func (h *promoCodeHandler) update(ctx *gin.Context) {
+	var promoCode entity.PromoCode
+
+	err := ctx.ShouldBindJSON(&promoCode)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = h.service.Update(ctxWithTimeout, ctx.Param("id"), promoCode)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The promo code has successfuly been updated.",
+		hypermedia.Links{listPromoCodesLink},
+	})
+}
+
+func (h *promoCodeHandler) delete(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Delete(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The promo code has successfuly been deleted.",
+		hypermedia.Links{createPromoCodeLink},
+	})
+}
+
+func (h *promoCodeHandler) getPromoCode(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	promoCode, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		PromoCode entity.PromoCode `json:"promoCode"`
+	}{
+		ginutil.Response{
+			"The promo code has successfuly been found.",
+			hypermedia.Links{listPromoCodesLink},
+		},
+		promoCode,
+	})
+}
+
+func (h *promoCodeHandler) getPromoCodes(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	promoCodes, urls, err := h.service.GetPromoCodes(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/promo-codes",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		ctx.DefaultQuery("search", ""),
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		PromoCodes []entity.PromoCode `json:"promoCodes"`
+	}{
+		ginutil.Response{
+			"The promo codes have successfuly been found.",
+			urls,
+		},
+		promoCodes,
+	})
+}
+
+func newPromoCodeHandler(service service.PromoCode) *promoCodeHandler {
+	return &promoCodeHandler{service}
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"maryan_api/internal/domain/promo/repo"
+	"maryan_api/internal/domain/promo/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+	handler := newPromoCodeHandler(service.NewPromoCodeService(repo.NewPromoCodeRepo(db)))
+
+	//-----------------------Promo Code Routes-----------------------------
+	adminRouter.POST("/promo-codes", handler.create)
+	adminRouter.GET("/promo-codes", handler.getPromoCodes)
+	adminRouter.GET("/promo-codes/:id", handler.getPromoCode)
+	adminRouter.PUT("/promo-codes/:id", handler.update)
+	adminRouter.DELETE("/promo-codes/:id", handler.delete)
+}
+
+// -------------Links-----------------
+var (
+	listPromoCodesLink = hypermedia.Link{
+		Name: "listPromoCodes",
+		Data: hypermedia.LinkData{Href: "/admin/promo-codes", Method: "GET"},
+	}
+
+	createPromoCodeLink = hypermedia.Link{
+		Name: "createPromoCode",
+		Data: hypermedia.LinkData{Href: "/admin/promo-codes", Method: "POST"},
+	}
+)
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Route interface {
+	Create(ctx context.Context, route *entity.Route) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error)
+	GetRoutes(ctx context.Context, pagination dbutil.Pagination) ([]entity.Route, int, error, bool)
+}
+
+type routeRepo struct {
+	store dataStore.Route
+}
+
+func (r *routeRepo) Create(ctx context.Context, route *entity.Route) error {
+	return r.store.Create(ctx, route)
+}
+
+func (r *routeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.Delete(ctx, id)
+}
+
+func (r *routeRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error) {
+	return r.store.GetByID(ctx, id)
+}
+
+func (r *routeRepo) GetRoutes(ctx context.Context, pagination dbutil.Pagination) ([]entity.Route, int, error, bool) {
+	return r.store.GetRoutes(ctx, pagination)
+}
+
+func NewRouteRepo(db *gorm.DB) Route {
+	return &routeRepo{dataStore.NewRoute(db)}
+}
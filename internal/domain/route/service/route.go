@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/route/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+)
+
+type Route interface {
+	Create(ctx context.Context, newRoute entity.NewRouteJSON) (uuid.UUID, error)
+	Delete(ctx context.Context, idStr string) error
+	GetByID(ctx context.Context, idStr string) (entity.Route, error)
+	GetRoutes(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Route, hypermedia.Links, error)
+}
+
+type routeServiceImpl struct {
+	repo repo.Route
+}
+
+func (s *routeServiceImpl) Create(ctx context.Context, newRoute entity.NewRouteJSON) (uuid.UUID, error) {
+	route, err := newRoute.Parse()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return route.ID, s.repo.Create(ctx, &route)
+}
+
+func (s *routeServiceImpl) Delete(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *routeServiceImpl) GetByID(ctx context.Context, idStr string) (entity.Route, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.Route{}, rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *routeServiceImpl) GetRoutes(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Route, hypermedia.Links, error) {
+	pagination, err := paginationStr.Parse([]string{"name"}, "created_at", "name")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	routes, total, err, empty := s.repo.GetRoutes(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return routes, hypermedia.Pagination(paginationStr, total), nil
+}
+
+func NewRouteService(repo repo.Route) Route {
+	return &routeServiceImpl{repo}
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/route/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type routeHandler struct {
+	service service.Route
+}
+
+func (h *routeHandler) create(ctx *gin.Context) {
+	var newRoute entity.NewRouteJSON
+
+	err := ctx.ShouldBindJSON(&newRoute)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, newRoute)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ginutil.Response{
+		"The route has successfuly been created.",
+		hypermedia.Links{
+			hypermedia.Link{
+				"self", hypermedia.LinkData{config.APIURL() + "/admin/routes/" + id.String(), "GET"},
+			},
+			listRoutesLink,
+		},
+	})
+}
+
+func (h *routeHandler) delete(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Delete(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The route has successfuly been deleted.",
+		hypermedia.Links{createRouteLink},
+	})
+}
+
+func (h *routeHandler) getRoute(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	route, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Route entity.Route `json:"route"`
+	}{
+		ginutil.Response{
+			"The route has successfuly been found.",
+			hypermedia.Links{listRoutesLink},
+		},
+		route,
+	})
+}
+
+func (h *routeHandler) getRoutes(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	routes, urls, err := h.service.GetRoutes(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/routes",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "name"),
+		ctx.DefaultQuery("order_way", "asc"),
+		ctx.DefaultQuery("search", ""),
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Routes []entity.Route `json:"routes"`
+	}{
+		ginutil.Response{
+			"The routes have successfuly been found.",
+			urls,
+		},
+		routes,
+	})
+}
+
+func newRouteHandler(service service.Route) *routeHandler {
+	return &routeHandler{service}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"maryan_api/internal/domain/route/repo"
+	"maryan_api/internal/domain/route/service"
+	"maryan_api/pkg/auth"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+	handler := newRouteHandler(service.NewRouteService(repo.NewRouteRepo(db)))
+
+	//-----------------------Route Routes----------------------------------
+	adminRouter.POST("/routes", handler.create)
+	adminRouter.GET("/routes", handler.getRoutes)
+	adminRouter.GET("/routes/:id", handler.getRoute)
+	adminRouter.DELETE("/routes/:id", handler.delete)
+}
+
+// -------------Links-----------------
+var (
+	listRoutesLink = hypermedia.Link{
+		Name: "listRoutes",
+		Data: hypermedia.LinkData{Href: "/admin/routes", Method: "GET"},
+	}
+
+	createRouteLink = hypermedia.Link{
+		Name: "createRoute",
+		Data: hypermedia.LinkData{Href: "/admin/routes", Method: "POST"},
+	}
+)
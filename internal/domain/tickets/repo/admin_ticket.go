@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminTicket interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetHistory(ctx context.Context, id uuid.UUID) (entity.Ticket, []entity.Stop, error)
+	GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	GetRefunds(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error)
+	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
+	ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error
+	CreatePassenger(ctx context.Context, passenger *entity.Passenger) error
+	ChangePassenger(ctx context.Context, id, passengerID uuid.UUID) error
+	Cancel(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error)
+}
+
+type adminTicketRepo struct {
+	transactor dataStore.Transactor
+	ticket     dataStore.Ticket
+	connection dataStore.Connection
+	passenger  dataStore.Passenger
+	refaund    dataStore.Refaund
+}
+
+func (r *adminTicketRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *adminTicketRepo) GetHistory(ctx context.Context, id uuid.UUID) (entity.Ticket, []entity.Stop, error) {
+	return r.ticket.GetHistory(ctx, id)
+}
+
+func (r *adminTicketRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.GetByID(ctx, id)
+}
+
+func (r *adminTicketRepo) LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.LockByID(ctx, id)
+}
+
+func (r *adminTicketRepo) GetRefunds(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error) {
+	return r.refaund.GetByTicket(ctx, ticketID)
+}
+
+func (r *adminTicketRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.connection.GetByID(ctx, id)
+}
+
+func (r *adminTicketRepo) LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return r.connection.LockSeats(ctx, connectionID, segment)
+}
+
+func (r *adminTicketRepo) ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error {
+	return r.ticket.ChangeConnection(ctx, id, connectionID, seatID, segment, price)
+}
+
+func (r *adminTicketRepo) CreatePassenger(ctx context.Context, passenger *entity.Passenger) error {
+	return r.passenger.Create(ctx, passenger)
+}
+
+func (r *adminTicketRepo) ChangePassenger(ctx context.Context, id, passengerID uuid.UUID) error {
+	return r.ticket.ChangePassenger(ctx, id, passengerID)
+}
+
+func (r *adminTicketRepo) Cancel(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return r.ticket.Cancel(ctx, id, comment)
+}
+
+func NewAdminTicketRepo(db *gorm.DB) AdminTicket {
+	return &adminTicketRepo{
+		dataStore.NewTransactor(db),
+		dataStore.NewTicket(db), dataStore.NewConnection(db), dataStore.NewPassenger(db), dataStore.NewRefaund(db),
+	}
+}
@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Cash interface {
+	GetUser(ctx context.Context, id uuid.UUID) (entity.User, error)
+	Record(ctx context.Context, transaction *entity.CashTransaction) error
+	GetTripLedger(ctx context.Context, tripID, employeeID uuid.UUID) ([]entity.CashTransaction, error)
+	Reconciliation(ctx context.Context, from, to time.Time) ([]entity.CashReconciliation, error)
+}
+
+type cashRepo struct {
+	cash dataStore.Cash
+	user dataStore.User
+}
+
+func (r *cashRepo) GetUser(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.user.GetByID(ctx, id)
+}
+
+func (r *cashRepo) Record(ctx context.Context, transaction *entity.CashTransaction) error {
+	return r.cash.Record(ctx, []*entity.CashTransaction{transaction})
+}
+
+func (r *cashRepo) GetTripLedger(ctx context.Context, tripID, employeeID uuid.UUID) ([]entity.CashTransaction, error) {
+	return r.cash.GetTripLedger(ctx, tripID, employeeID)
+}
+
+func (r *cashRepo) Reconciliation(ctx context.Context, from, to time.Time) ([]entity.CashReconciliation, error) {
+	return r.cash.Reconciliation(ctx, from, to)
+}
+
+func NewCashRepo(db *gorm.DB) Cash {
+	return &cashRepo{dataStore.NewCash(db), dataStore.NewUser(db)}
+}
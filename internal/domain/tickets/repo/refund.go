@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Refund interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	Create(ctx context.Context, refund *entity.Refaund) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Refaund, error)
+	GetRefunds(ctx context.Context, pagination dbutil.Pagination) ([]entity.Refaund, int, error, bool)
+	GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error)
+	HasActive(ctx context.Context, ticketID uuid.UUID) (bool, error)
+	StartProcessing(ctx context.Context, id uuid.UUID) error
+	AttachProviderRefund(ctx context.Context, id uuid.UUID, providerRefundID string) error
+	StopProcessing(ctx context.Context, id uuid.UUID) error
+	Reject(ctx context.Context, id uuid.UUID) error
+	CompleteWithoutPayment(ctx context.Context, id uuid.UUID, comment string) error
+	CancelTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error)
+	RefundTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error)
+	RecordCash(ctx context.Context, transaction *entity.CashTransaction) error
+}
+
+type refundRepo struct {
+	transactor dataStore.Transactor
+	refaund    dataStore.Refaund
+	ticket     dataStore.Ticket
+	connection dataStore.Connection
+	cash       dataStore.Cash
+}
+
+func (r *refundRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *refundRepo) LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.LockByID(ctx, id)
+}
+
+func (r *refundRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.GetByID(ctx, id)
+}
+
+func (r *refundRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.connection.GetByID(ctx, id)
+}
+
+func (r *refundRepo) Create(ctx context.Context, refund *entity.Refaund) error {
+	return r.refaund.Create(ctx, refund)
+}
+
+func (r *refundRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Refaund, error) {
+	return r.refaund.GetByID(ctx, id)
+}
+
+func (r *refundRepo) GetRefunds(ctx context.Context, pagination dbutil.Pagination) ([]entity.Refaund, int, error, bool) {
+	return r.refaund.GetRefaunds(ctx, pagination)
+}
+
+func (r *refundRepo) GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error) {
+	return r.refaund.GetByTicket(ctx, ticketID)
+}
+
+func (r *refundRepo) HasActive(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	return r.refaund.HasActive(ctx, ticketID)
+}
+
+func (r *refundRepo) StartProcessing(ctx context.Context, id uuid.UUID) error {
+	return r.refaund.StartProcessing(ctx, id)
+}
+
+func (r *refundRepo) AttachProviderRefund(ctx context.Context, id uuid.UUID, providerRefundID string) error {
+	return r.refaund.AttachProviderRefund(ctx, id, providerRefundID)
+}
+
+func (r *refundRepo) StopProcessing(ctx context.Context, id uuid.UUID) error {
+	return r.refaund.StopProcessing(ctx, id)
+}
+
+func (r *refundRepo) Reject(ctx context.Context, id uuid.UUID) error {
+	return r.refaund.Reject(ctx, id)
+}
+
+func (r *refundRepo) CompleteWithoutPayment(ctx context.Context, id uuid.UUID, comment string) error {
+	return r.refaund.CompleteWithoutPayment(ctx, id, comment)
+}
+
+func (r *refundRepo) CancelTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return r.ticket.Cancel(ctx, id, comment)
+}
+
+func (r *refundRepo) RefundTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return r.ticket.Refund(ctx, id, comment)
+}
+
+func (r *refundRepo) RecordCash(ctx context.Context, transaction *entity.CashTransaction) error {
+	return r.cash.Record(ctx, []*entity.CashTransaction{transaction})
+}
+
+func NewRefundRepo(db *gorm.DB) Refund {
+	return &refundRepo{
+		dataStore.NewTransactor(db),
+		dataStore.NewRefaund(db), dataStore.NewTicket(db), dataStore.NewConnection(db), dataStore.NewCash(db),
+	}
+}
@@ -5,58 +5,219 @@ import (
 	"maryan_api/internal/entity"
 	dataStore "maryan_api/internal/infrastructure/persistence"
 	"maryan_api/pkg/dbutil"
+	"time"
 
 	"github.com/d3code/uuid"
 	"gorm.io/gorm"
 )
 
 type Ticket interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
-	CreateAdress(ctx context.Context, a *entity.Address) error
-	CreatePassenger(ctx context.Context, p *entity.Passenger) error
-	SaveTickets(ctx context.Context, tickets []*entity.Ticket) error
-	DeleteTickets(ctx context.Context, paymentSessionID string) error
-	AddTickets(ctx context.Context, paymentSessionID string) error
+	LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
+	TakenSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
+	GetSeatSurcharges(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error)
+	CreateAddress(ctx context.Context, address *entity.Address) error
+	CreatePassenger(ctx context.Context, passenger *entity.Passenger) error
+	HoldSeats(ctx context.Context, holds []*entity.SeatHold) error
+	LockPromoCode(ctx context.Context, code string) (entity.PromoCode, error)
+	CountPromoCodeRedemptions(ctx context.Context, promoCodeID, userID uuid.UUID) (int, int, error)
+	RedeemPromoCode(ctx context.Context, redemption *entity.PromoCodeRedemption) error
+	LockLoyaltyBalance(ctx context.Context, userID uuid.UUID) (int, error)
+	RecordLoyalty(ctx context.Context, transaction *entity.LoyaltyTransaction) error
+	GetExtras(ctx context.Context, ids []uuid.UUID) ([]entity.Extra, error)
+	GetBookedExtras(ctx context.Context, connectionID uuid.UUID) ([]entity.BookedExtra, error)
+	LockWaitlistEntry(ctx context.Context, id uuid.UUID) (entity.WaitlistEntry, error)
+	ReservedSeats(ctx context.Context, connectionID, exceptEntryID uuid.UUID) (int, error)
+	FulfillWaitlistEntry(ctx context.Context, id uuid.UUID) error
+	IsWaitlistOfferHeld(ctx context.Context, id uuid.UUID) (bool, error)
+	AttachPaymentSession(ctx context.Context, holdIDs []uuid.UUID, paymentSessionID string) error
+	ReleaseSeats(ctx context.Context, holdIDs []uuid.UUID) error
+	ReleaseSeatsBySession(ctx context.Context, paymentSessionID string) ([]uuid.UUID, error)
+	ReleaseExpiredSeats(ctx context.Context, now time.Time) (int, []uuid.UUID, error)
+	Rehold(ctx context.Context, paymentSessionID string) (bool, error)
+	RecordUnfulfilledPayment(ctx context.Context, payment *entity.UnfulfilledPayment) error
+	AttachUnfulfilledRefund(ctx context.Context, paymentSessionID, providerRefundID string) error
+	HasPendingRebooking(ctx context.Context, ticketID uuid.UUID) (bool, error)
+	AddTickets(ctx context.Context, paymentSessionID, paymentIntentID string) ([]uuid.UUID, error)
+	IssueTickets(ctx context.Context, tickets []*entity.Ticket) error
+	RecordCash(ctx context.Context, transactions []*entity.CashTransaction) error
+	GetUser(ctx context.Context, id uuid.UUID) (entity.User, error)
+	ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error
+	CompleteRefund(ctx context.Context, paymentIntentID, providerRefundID string) ([]uuid.UUID, error)
+	RegisterPaymentEvent(ctx context.Context, event *entity.PaymentEvent) (bool, error)
 	GetTickets(ctx context.Context, pagination dbutil.Pagination) ([]entity.Ticket, []entity.Connection, int, error, bool)
+	GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
 }
 
 type ticketRepo struct {
-	ticket     dataStore.Ticket
-	adress     dataStore.Address
-	passenger  dataStore.Passenger
-	connection dataStore.Connection
+	transactor   dataStore.Transactor
+	ticket       dataStore.Ticket
+	seatHold     dataStore.SeatHold
+	connection   dataStore.Connection
+	paymentEvent dataStore.PaymentEvent
+	refaund      dataStore.Refaund
+	address      dataStore.Address
+	passenger    dataStore.Passenger
+	surcharge    dataStore.SeatSurcharge
+	promoCode    dataStore.PromoCode
+	waitlist     dataStore.Waitlist
+	cash         dataStore.Cash
+	user         dataStore.User
+	extra        dataStore.Extra
+	loyalty      dataStore.Loyalty
+}
+
+func (r *ticketRepo) LockLoyaltyBalance(ctx context.Context, userID uuid.UUID) (int, error) {
+	return r.loyalty.LockBalance(ctx, userID)
+}
+
+func (r *ticketRepo) RecordLoyalty(ctx context.Context, transaction *entity.LoyaltyTransaction) error {
+	return r.loyalty.Record(ctx, transaction)
+}
+
+func (r *ticketRepo) IssueTickets(ctx context.Context, tickets []*entity.Ticket) error {
+	return r.ticket.Issue(ctx, tickets)
+}
+
+func (r *ticketRepo) RecordCash(ctx context.Context, transactions []*entity.CashTransaction) error {
+	return r.cash.Record(ctx, transactions)
+}
+
+func (r *ticketRepo) GetUser(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.user.GetByID(ctx, id)
+}
+
+func (r *ticketRepo) LockWaitlistEntry(ctx context.Context, id uuid.UUID) (entity.WaitlistEntry, error) {
+	return r.waitlist.LockByID(ctx, id)
+}
+
+func (r *ticketRepo) ReservedSeats(ctx context.Context, connectionID, exceptEntryID uuid.UUID) (int, error) {
+	return r.waitlist.ReservedSeats(ctx, connectionID, exceptEntryID)
+}
+
+func (r *ticketRepo) FulfillWaitlistEntry(ctx context.Context, id uuid.UUID) error {
+	return r.waitlist.Fulfill(ctx, id)
+}
+
+func (r *ticketRepo) IsWaitlistOfferHeld(ctx context.Context, id uuid.UUID) (bool, error) {
+	return r.seatHold.HasWaitlistEntry(ctx, id)
+}
+
+func (r *ticketRepo) LockPromoCode(ctx context.Context, code string) (entity.PromoCode, error) {
+	return r.promoCode.LockByCode(ctx, code)
+}
+
+func (r *ticketRepo) CountPromoCodeRedemptions(ctx context.Context, promoCodeID, userID uuid.UUID) (int, int, error) {
+	return r.promoCode.CountRedemptions(ctx, promoCodeID, userID)
+}
+
+func (r *ticketRepo) RedeemPromoCode(ctx context.Context, redemption *entity.PromoCodeRedemption) error {
+	return r.promoCode.Redeem(ctx, redemption)
+}
+
+func (r *ticketRepo) GetExtras(ctx context.Context, ids []uuid.UUID) ([]entity.Extra, error) {
+	return r.extra.GetByIDs(ctx, ids)
+}
+
+func (r *ticketRepo) GetBookedExtras(ctx context.Context, connectionID uuid.UUID) ([]entity.BookedExtra, error) {
+	return r.extra.Booked(ctx, connectionID, time.Now())
 }
 
 func (r *ticketRepo) GetTickets(ctx context.Context, pagination dbutil.Pagination) ([]entity.Ticket, []entity.Connection, int, error, bool) {
 	return r.ticket.GetTickets(ctx, pagination)
 }
 
+func (r *ticketRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.GetByID(ctx, id)
+}
+
 func (r *ticketRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
 	return r.connection.GetByID(ctx, id)
 }
 
-func (r *ticketRepo) DeleteTickets(ctx context.Context, paymentSessionID string) error {
-	return r.ticket.DeleteTickets(ctx, paymentSessionID)
+func (r *ticketRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *ticketRepo) TakenSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return r.connection.TakenSeats(ctx, connectionID, segment)
+}
+
+func (r *ticketRepo) LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return r.connection.LockSeats(ctx, connectionID, segment)
+}
+
+func (r *ticketRepo) GetSeatSurcharges(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error) {
+	return r.surcharge.GetForBus(ctx, busID, line)
+}
+
+func (r *ticketRepo) CreateAddress(ctx context.Context, address *entity.Address) error {
+	return r.address.Create(ctx, address)
+}
+
+func (r *ticketRepo) CreatePassenger(ctx context.Context, passenger *entity.Passenger) error {
+	return r.passenger.Create(ctx, passenger)
+}
+
+func (r *ticketRepo) HoldSeats(ctx context.Context, holds []*entity.SeatHold) error {
+	return r.seatHold.Hold(ctx, holds)
+}
+
+func (r *ticketRepo) AttachPaymentSession(ctx context.Context, holdIDs []uuid.UUID, paymentSessionID string) error {
+	return r.seatHold.AttachSession(ctx, holdIDs, paymentSessionID)
+}
+
+func (r *ticketRepo) ReleaseSeats(ctx context.Context, holdIDs []uuid.UUID) error {
+	return r.seatHold.Release(ctx, holdIDs)
+}
+
+func (r *ticketRepo) ReleaseSeatsBySession(ctx context.Context, paymentSessionID string) ([]uuid.UUID, error) {
+	return r.seatHold.ReleaseBySession(ctx, paymentSessionID)
+}
+
+func (r *ticketRepo) ReleaseExpiredSeats(ctx context.Context, now time.Time) (int, []uuid.UUID, error) {
+	return r.seatHold.ReleaseExpired(ctx, now)
+}
+
+func (r *ticketRepo) Rehold(ctx context.Context, paymentSessionID string) (bool, error) {
+	return r.seatHold.Rehold(ctx, paymentSessionID)
+}
+
+func (r *ticketRepo) RecordUnfulfilledPayment(ctx context.Context, payment *entity.UnfulfilledPayment) error {
+	return r.paymentEvent.RecordUnfulfilled(ctx, payment)
+}
+
+func (r *ticketRepo) AttachUnfulfilledRefund(ctx context.Context, paymentSessionID, providerRefundID string) error {
+	return r.paymentEvent.AttachUnfulfilledRefund(ctx, paymentSessionID, providerRefundID)
+}
+
+func (r *ticketRepo) HasPendingRebooking(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	return r.seatHold.HasRebooking(ctx, ticketID)
 }
 
-func (r *ticketRepo) AddTickets(ctx context.Context, paymentSessionID string) error {
-	return r.ticket.AddTickets(ctx, paymentSessionID)
+func (r *ticketRepo) AddTickets(ctx context.Context, paymentSessionID, paymentIntentID string) ([]uuid.UUID, error) {
+	return r.ticket.AddTickets(ctx, paymentSessionID, paymentIntentID)
 }
 
-func (r *ticketRepo) CreateAdress(ctx context.Context, a *entity.Address) error {
-	return r.adress.Create(ctx, a)
+func (r *ticketRepo) ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error {
+	return r.ticket.ChangeConnection(ctx, id, connectionID, seatID, segment, price)
 }
 
-func (r *ticketRepo) CreatePassenger(ctx context.Context, p *entity.Passenger) error {
-	return r.passenger.Create(ctx, p)
+func (r *ticketRepo) CompleteRefund(ctx context.Context, paymentIntentID, providerRefundID string) ([]uuid.UUID, error) {
+	return r.refaund.Complete(ctx, paymentIntentID, providerRefundID)
 }
 
-func (r *ticketRepo) SaveTickets(ctx context.Context, tickets []*entity.Ticket) error {
-	return r.ticket.Create(ctx, tickets)
+func (r *ticketRepo) RegisterPaymentEvent(ctx context.Context, event *entity.PaymentEvent) (bool, error) {
+	return r.paymentEvent.Register(ctx, event)
 }
 
 func NewTicketRepo(db *gorm.DB) Ticket {
 	return &ticketRepo{
-		dataStore.NewTicket(db), dataStore.NewAddress(db), dataStore.NewPassenger(db), dataStore.NewConnection(db),
+		dataStore.NewTransactor(db),
+		dataStore.NewTicket(db), dataStore.NewSeatHold(db), dataStore.NewConnection(db), dataStore.NewPaymentEvent(db), dataStore.NewRefaund(db),
+		dataStore.NewAddress(db), dataStore.NewPassenger(db), dataStore.NewSeatSurcharge(db), dataStore.NewPromoCode(db),
+		dataStore.NewWaitlist(db), dataStore.NewCash(db), dataStore.NewUser(db), dataStore.NewExtra(db),
+		dataStore.NewLoyalty(db),
 	}
 }
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Transfer interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
+	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	GetUser(ctx context.Context, id uuid.UUID) (entity.User, error)
+	HasActiveRefund(ctx context.Context, ticketID uuid.UUID) (bool, error)
+	Create(ctx context.Context, transfer *entity.TicketTransfer) error
+	LockByID(ctx context.Context, id uuid.UUID) (entity.TicketTransfer, error)
+	GetByUser(ctx context.Context, userID uuid.UUID, email string) ([]entity.TicketTransfer, error)
+	Accept(ctx context.Context, id, userID uuid.UUID) error
+	ChangeOwner(ctx context.Context, ticketID, userID uuid.UUID, email, phoneNumber string) error
+}
+
+type transferRepo struct {
+	transactor dataStore.Transactor
+	transfer   dataStore.TicketTransfer
+	ticket     dataStore.Ticket
+	connection dataStore.Connection
+	user       dataStore.User
+	refaund    dataStore.Refaund
+}
+
+func (r *transferRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *transferRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.GetByID(ctx, id)
+}
+
+func (r *transferRepo) LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.ticket.LockByID(ctx, id)
+}
+
+func (r *transferRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.connection.GetByID(ctx, id)
+}
+
+func (r *transferRepo) GetUser(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.user.GetByID(ctx, id)
+}
+
+func (r *transferRepo) HasActiveRefund(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	return r.refaund.HasActive(ctx, ticketID)
+}
+
+func (r *transferRepo) Create(ctx context.Context, transfer *entity.TicketTransfer) error {
+	return r.transfer.Create(ctx, transfer)
+}
+
+func (r *transferRepo) LockByID(ctx context.Context, id uuid.UUID) (entity.TicketTransfer, error) {
+	return r.transfer.LockByID(ctx, id)
+}
+
+func (r *transferRepo) GetByUser(ctx context.Context, userID uuid.UUID, email string) ([]entity.TicketTransfer, error) {
+	return r.transfer.GetByUser(ctx, userID, email)
+}
+
+func (r *transferRepo) Accept(ctx context.Context, id, userID uuid.UUID) error {
+	return r.transfer.Accept(ctx, id, userID)
+}
+
+func (r *transferRepo) ChangeOwner(ctx context.Context, ticketID, userID uuid.UUID, email, phoneNumber string) error {
+	return r.ticket.ChangeOwner(ctx, ticketID, userID, email, phoneNumber)
+}
+
+func NewTransferRepo(db *gorm.DB) Transfer {
+	return &transferRepo{
+		dataStore.NewTransactor(db),
+		dataStore.NewTicketTransfer(db), dataStore.NewTicket(db), dataStore.NewConnection(db), dataStore.NewUser(db), dataStore.NewRefaund(db),
+	}
+}
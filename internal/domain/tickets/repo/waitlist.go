@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Waitlist interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
+	Create(ctx context.Context, entry *entity.WaitlistEntry) error
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error)
+	HasActive(ctx context.Context, connectionID, userID uuid.UUID) (bool, error)
+	LockWaiting(ctx context.Context, connectionID uuid.UUID) ([]entity.WaitlistEntry, error)
+	ConnectionsWithWaiting(ctx context.Context) ([]uuid.UUID, error)
+	ReservedSeats(ctx context.Context, connectionID uuid.UUID) (int, error)
+	Offer(ctx context.Context, id uuid.UUID, until time.Time) error
+	Expire(ctx context.Context, now time.Time) error
+}
+
+type waitlistRepo struct {
+	transactor dataStore.Transactor
+	connection dataStore.Connection
+	waitlist   dataStore.Waitlist
+}
+
+func (r *waitlistRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.transactor.Transaction(ctx, fn)
+}
+
+func (r *waitlistRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.connection.GetByID(ctx, id)
+}
+
+func (r *waitlistRepo) LockSeats(ctx context.Context, connectionID uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return r.connection.LockSeats(ctx, connectionID, segment)
+}
+
+func (r *waitlistRepo) Create(ctx context.Context, entry *entity.WaitlistEntry) error {
+	return r.waitlist.Create(ctx, entry)
+}
+
+func (r *waitlistRepo) GetByUser(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error) {
+	return r.waitlist.GetByUser(ctx, userID)
+}
+
+func (r *waitlistRepo) HasActive(ctx context.Context, connectionID, userID uuid.UUID) (bool, error) {
+	return r.waitlist.HasActive(ctx, connectionID, userID)
+}
+
+func (r *waitlistRepo) LockWaiting(ctx context.Context, connectionID uuid.UUID) ([]entity.WaitlistEntry, error) {
+	return r.waitlist.LockWaiting(ctx, connectionID)
+}
+
+func (r *waitlistRepo) ConnectionsWithWaiting(ctx context.Context) ([]uuid.UUID, error) {
+	return r.waitlist.ConnectionsWithWaiting(ctx)
+}
+
+func (r *waitlistRepo) ReservedSeats(ctx context.Context, connectionID uuid.UUID) (int, error) {
+	return r.waitlist.ReservedSeats(ctx, connectionID, uuid.Nil)
+}
+
+func (r *waitlistRepo) Offer(ctx context.Context, id uuid.UUID, until time.Time) error {
+	return r.waitlist.Offer(ctx, id, until)
+}
+
+func (r *waitlistRepo) Expire(ctx context.Context, now time.Time) error {
+	return r.waitlist.Expire(ctx, now)
+}
+
+func NewWaitlistRepo(db *gorm.DB) Waitlist {
+	return &waitlistRepo{
+		dataStore.NewTransactor(db),
+		dataStore.NewConnection(db),
+		dataStore.NewWaitlist(db),
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"maryan_api/config"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/notification"
+	"maryan_api/internal/infrastructure/clients/payment"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"slices"
+
+	"github.com/d3code/uuid"
+)
+
+type AdminTicket interface {
+	GetByID(ctx context.Context, idStr string) (entity.AdminTicket, error)
+	ChangeSeat(ctx context.Context, idStr string, change entity.ChangeSeatJSON) error
+	ChangePassenger(ctx context.Context, idStr string, newPassenger entity.NewPassenger) (entity.Passenger, error)
+	Cancel(ctx context.Context, idStr string, cancel entity.CancelTicketJSON) ([]entity.Refaund, error)
+	ResendConfirmation(ctx context.Context, idStr string) error
+}
+
+type adminTicketServiceImpl struct {
+	repo     repo.AdminTicket
+	refunds  repo.Refund
+	provider payment.Provider
+	waitlist Waitlist
+}
+
+func (s *adminTicketServiceImpl) GetByID(ctx context.Context, idStr string) (entity.AdminTicket, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.AdminTicket{}, rfc7807.UUID(err.Error())
+	}
+
+	ticket, stops, err := s.repo.GetHistory(ctx, id)
+	if err != nil {
+		return entity.AdminTicket{}, err
+	}
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return entity.AdminTicket{}, err
+	}
+
+	refunds, err := s.repo.GetRefunds(ctx, id)
+	if err != nil {
+		return entity.AdminTicket{}, err
+	}
+
+	return entity.AdminTicket{
+		Ticket:     ticket,
+		Connection: connection.Simplify(),
+		Stops:      stops,
+		Refunds:    refunds,
+	}, nil
+}
+
+func (s *adminTicketServiceImpl) ChangeSeat(ctx context.Context, idStr string, change entity.ChangeSeatJSON) error {
+	ticket, err := s.getChangeableTicket(ctx, idStr)
+	if err != nil {
+		return err
+	}
+
+	if ticket.SeatID == change.SeatID {
+		return nil
+	}
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(connection.Bus.Seats, func(seat entity.Seat) bool { return seat.ID == change.SeatID }) {
+		return rfc7807.BadRequest("non-existing-seat", "Non-existing Seat Error", change.SeatID.String()+" does not belong to the connection bus.")
+	}
+
+	return s.repo.Transaction(ctx, func(ctx context.Context) error {
+		// The ticket may have been cancelled or moved since it was read.
+		locked, err := s.repo.LockTicket(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		err = checkChangeable(locked)
+		if err != nil {
+			return err
+		}
+
+		if locked.ConnectionID != ticket.ConnectionID || locked.Segment() != ticket.Segment() {
+			return rfc7807.New(http.StatusConflict, "changed-ticket", "Changed Ticket Error", "The ticket has been moved to another connection meanwhile.")
+		}
+
+		takenSeats, err := s.repo.LockSeats(ctx, connection.ID, ticket.Segment())
+		if err != nil {
+			return err
+		}
+
+		if slices.Contains(takenSeats, change.SeatID) {
+			return rfc7807.BadRequest("taken-seat", "Taken Seat Error", change.SeatID.String()+" is already taken.")
+		}
+
+		return s.repo.ChangeConnection(ctx, ticket.ID, connection.ID, change.SeatID, ticket.Segment(), ticket.TicketPayment.Price)
+	})
+}
+
+// ChangePassenger puts another passenger of the same fare category on the
+// ticket, so the price stays valid.
+func (s *adminTicketServiceImpl) ChangePassenger(ctx context.Context, idStr string, newPassenger entity.NewPassenger) (entity.Passenger, error) {
+	ticket, err := s.getChangeableTicket(ctx, idStr)
+	if err != nil {
+		return entity.Passenger{}, err
+	}
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return entity.Passenger{}, err
+	}
+
+	passenger, err := preparePassenger(newPassenger, ticket.UserID, connection.DepartureTime)
+	if err != nil {
+		return entity.Passenger{}, err
+	}
+
+	if passenger.Category != ticket.Passenger.Category {
+		return entity.Passenger{}, rfc7807.BadRequest("passenger-category", "Passenger Category Error", "The new passenger has to be of the same category as the current one.")
+	}
+
+	return *passenger, s.repo.Transaction(ctx, func(ctx context.Context) error {
+		err := s.repo.CreatePassenger(ctx, passenger)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.ChangePassenger(ctx, ticket.ID, passenger.ID)
+	})
+}
+
+// Cancel either cancels the ticket right away or refunds its full price
+// through the provider, in which case the ticket is cancelled once the refund
+// completes. A ticket paid in cash is refunded right away by the employee who
+// took the cash, whose ledger records it; cancelled without a refund, the
+// cash of the sale stays due.
+func (s *adminTicketServiceImpl) Cancel(ctx context.Context, idStr string, cancel entity.CancelTicketJSON) ([]entity.Refaund, error) {
+	if len(cancel.Comment) > 500 {
+		return nil, rfc7807.BadRequest("invalid-comment", "Invalid Comment Error", "The comment must not be longer than 500 characters.")
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, rfc7807.UUID(err.Error())
+	}
+
+	if !cancel.Refund {
+		freed, err := s.repo.Cancel(ctx, id, cancel.Comment)
+		if err != nil {
+			return nil, err
+		}
+
+		s.waitlist.OfferFreedSeats(ctx, freed)
+		return nil, nil
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ticket.Status.CanChangeTo(entity.TicketStatusRefunded) {
+		return nil, rfc7807.New(http.StatusConflict, "invalid-ticket-status", "Invalid Ticket Status Error", "A ticket that is "+string(ticket.Status)+" cannot be refunded.")
+	}
+
+	if ticket.TicketPayment.Method == entity.PaymentMethodCash && ticket.TicketPayment.CollectedByID.Valid {
+		return nil, s.refundCash(ctx, ticket, cancel.Comment)
+	}
+
+	if ticket.TicketPayment.PaymentIntentID == "" {
+		return nil, rfc7807.BadRequest("non-refundable-payment", "Non-refundable Payment Error", "The ticket has not been paid through the payment provider, cancel it without a refund.")
+	}
+
+	var refunds []entity.Refaund
+	err = s.refunds.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.refunds.LockTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		active, err := s.refunds.HasActive(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if active {
+			return rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket has already been cancelled.")
+		}
+
+		existing, err := s.refunds.GetByTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		refunds = entity.SplitRefund(ticket, ticket.TicketPayment.Price, existing, entity.RefaundReasonCancellation)
+		return createRefunds(ctx, s.refunds, refunds)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range refunds {
+		err = processRefund(ctx, s.refunds, s.provider, refunds[i])
+		if err != nil {
+			return nil, err
+		}
+		refunds[i].Status = entity.RefaundStatusProcessing
+	}
+
+	return refunds, nil
+}
+
+// refundCash gives the full price of the cash ticket back through the
+// employee who collected it.
+func (s *adminTicketServiceImpl) refundCash(ctx context.Context, ticket entity.Ticket, comment string) error {
+	if comment == "" {
+		comment = "Cancelled and refunded in cash."
+	}
+
+	var freed []uuid.UUID
+	err := s.refunds.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.refunds.LockTicket(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		active, err := s.refunds.HasActive(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		if active {
+			return rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket has already been cancelled.")
+		}
+
+		transaction := entity.NewCashRefund(ticket.TicketPayment.CollectedByID.UUID, &ticket, ticket.TicketPayment.Price)
+		transaction.Comment = comment
+		freed, err = refundInCash(ctx, s.refunds, &transaction, comment)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.waitlist.OfferFreedSeats(ctx, freed)
+	return nil
+}
+
+func (s *adminTicketServiceImpl) ResendConfirmation(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = notification.TicketConfirmation(ticket.Email, ticket.ID.String(), config.APIURL()+"/customer/ticket/"+ticket.ID.String()+"/pdf")
+	if err != nil {
+		return rfc7807.BadGateway("notification", "Notification Error", err.Error())
+	}
+
+	return nil
+}
+
+// getChangeableTicket returns the ticket if its passenger has not boarded yet.
+func (s *adminTicketServiceImpl) getChangeableTicket(ctx context.Context, idStr string) (entity.Ticket, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.Ticket{}, rfc7807.UUID(err.Error())
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, id)
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	return ticket, checkChangeable(ticket)
+}
+
+func checkChangeable(ticket entity.Ticket) error {
+	if ticket.Status != entity.TicketStatusPaid {
+		return rfc7807.New(http.StatusConflict, "invalid-ticket-status", "Invalid Ticket Status Error", "A ticket that is "+string(ticket.Status)+" cannot be changed.")
+	}
+
+	return nil
+}
+
+func NewAdminTicketService(repo repo.AdminTicket, refunds repo.Refund, provider payment.Provider, waitlist Waitlist) AdminTicket {
+	return &adminTicketServiceImpl{repo, refunds, provider, waitlist}
+}
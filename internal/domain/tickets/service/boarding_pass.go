@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/timezone"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+const boardingPassTimeLayout = "02.01.2006 15:04 MST"
+
+func renderBoardingPass(ticket entity.Ticket, connection entity.Connection, token string) ([]byte, error) {
+	qr, err := qrcode.Encode(token, qrcode.Medium, 512)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "mm", "A5", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("Ticket "+ticket.ID.String(), true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr(fmt.Sprintf("Line %d", connection.Line)), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr(connection.DepartureCountry.Name+" - "+connection.DestinationCountry.Name), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	rows := [][2]string{
+		{"Passenger", ticket.Passenger.FirstName + " " + ticket.Passenger.LastName},
+		{"Category", string(ticket.Passenger.Category)},
+		{"Seat", fmt.Sprintf("%d", ticket.Seat.Number)},
+		{"Departure", localTime(connection.DepartureTime, connection.DepartureCountry.Name)},
+		{"Arrival", localTime(connection.ArrivalTime, connection.DestinationCountry.Name)},
+		{"Pick-up", formatAddress(ticket.PickUpAdress)},
+		{"Drop-off", formatAddress(ticket.DropOffAdress)},
+		{"Ticket", ticket.ID.String()},
+	}
+
+	for _, extra := range ticket.Extras {
+		rows = append(rows, [2]string{"Extra", fmt.Sprintf("%d x %s", extra.Quantity, extra.Name)})
+	}
+
+	for _, row := range rows {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(30, 7, tr(row[0]), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 7, tr(row[1]), "", "L", false)
+	}
+
+	pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qr))
+	width, _ := pdf.GetPageSize()
+	pdf.ImageOptions("qr", (width-60)/2, pdf.GetY()+8, 60, 60, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+	var buf bytes.Buffer
+	err = pdf.Output(&buf)
+	return buf.Bytes(), err
+}
+
+// localTime formats t in the time zone of the country, falling back to UTC
+// for countries without a known zone.
+func localTime(t time.Time, country string) string {
+	local, ok := timezone.Transform(t, country)
+	if !ok {
+		local = t.UTC()
+	}
+
+	return local.Format(boardingPassTimeLayout)
+}
+
+func formatAddress(address entity.Address) string {
+	street := address.Street + " " + address.HouseNumber
+	if address.ApartmentNumber != "" {
+		street += "/" + address.ApartmentNumber
+	}
+
+	return fmt.Sprintf("%s, %s, %s", street, address.City, address.Country.Name)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"maryan_api/config"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/auth"
+	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/timezone"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// SellForCash issues confirmed tickets paid in cash to the employee; a driver
+// can only sell seats of the connections they drive.
+func (s *serviceImpl) SellForCash(ctx context.Context, employeeID uuid.UUID, byDriver bool, sale entity.CashSaleJSON) ([]*entity.Ticket, error) {
+	if byDriver {
+		connection, _, err := s.repo.GetConnectionByID(ctx, sale.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !connection.IsDrivenBy(employeeID) {
+			return nil, rfc7807.Forbidden("foreign-connection", "Foreign Connection Error", "You are not assigned to the connection.")
+		}
+	}
+
+	userID := employeeID
+	if sale.CustomerID.Valid {
+		customer, err := s.repo.GetUser(ctx, sale.CustomerID.UUID)
+		if err != nil {
+			return nil, err
+		}
+
+		if customer.Role.Val == nil || customer.Role.Val.Name() != auth.Customer.Name() {
+			return nil, rfc7807.BadRequest("non-existing-customer", "Non-existing Customer Error", "There is no customer with such id.")
+		}
+		userID = customer.ID
+	}
+
+	var tickets []*entity.Ticket
+	_, _, err := s.book(ctx, userID, sale.NewTicketJSON, func(ctx context.Context, holds []*entity.SeatHold) error {
+		tickets = make([]*entity.Ticket, len(holds))
+		var transactions = make([]*entity.CashTransaction, len(holds))
+		for i, hold := range holds {
+			ticket := hold.ToTicket()
+			ticket.TicketPayment.Method = entity.PaymentMethodCash
+			ticket.TicketPayment.CollectedByID = uuid.NullUUID{UUID: employeeID, Valid: true}
+			tickets[i] = &ticket
+
+			transaction := entity.NewCashSale(employeeID, &ticket)
+			transactions[i] = &transaction
+		}
+
+		err := s.repo.IssueTickets(ctx, tickets)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.RecordCash(ctx, transactions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// CancelForCash cancels a ticket paid in cash and records the cash the
+// employee gives back under the cancellation policy; a driver can only cancel
+// tickets of the connections they drive.
+func (s *refundServiceImpl) CancelForCash(ctx context.Context, employeeID uuid.UUID, byDriver bool, ticketIDStr string) (entity.CashTransaction, error) {
+	ticketID, err := uuid.Parse(ticketIDStr)
+	if err != nil {
+		return entity.CashTransaction{}, rfc7807.UUID(err.Error())
+	}
+
+	var transaction entity.CashTransaction
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.repo.LockTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		ticket, err := s.repo.GetTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		if ticket.TicketPayment.Method != entity.PaymentMethodCash {
+			return rfc7807.BadRequest("non-cash-payment", "Non-cash Payment Error", "The ticket was not paid in cash, it is refunded through the payment provider.")
+		}
+
+		connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+		if err != nil {
+			return err
+		}
+
+		if byDriver && !connection.IsDrivenBy(employeeID) {
+			return rfc7807.Forbidden("foreign-connection", "Foreign Connection Error", "You are not assigned to the connection.")
+		}
+
+		active, err := s.repo.HasActive(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		if active {
+			return rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket has already been cancelled.")
+		}
+
+		amount, ok := s.policy.RefundableAmount(ticket.TicketPayment.Price, connection.DepartureTime, time.Now())
+		if !ok {
+			return rfc7807.BadRequest("non-refundable-ticket", "Non-refundable Ticket Error", "The ticket can not be cancelled after the departure.")
+		}
+
+		transaction = entity.NewCashRefund(employeeID, &ticket, amount)
+		_, err = refundInCash(ctx, s.repo, &transaction, "Cancelled and refunded in cash.")
+		return err
+	})
+	if err != nil {
+		return entity.CashTransaction{}, err
+	}
+
+	return transaction, nil
+}
+
+// refundInCash cancels the ticket of the cash refund and records the refund;
+// the ticket counts as refunded only if some cash is given back.
+func refundInCash(ctx context.Context, refunds repo.Refund, transaction *entity.CashTransaction, comment string) ([]uuid.UUID, error) {
+	cancel := refunds.RefundTicket
+	if transaction.Amount == 0 {
+		cancel = refunds.CancelTicket
+	}
+
+	freed, err := cancel(ctx, transaction.TicketID.UUID, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return freed, refunds.RecordCash(ctx, transaction)
+}
+
+type Cash interface {
+	GetTripLedger(ctx context.Context, driverID uuid.UUID, tripIDStr string) (entity.CashLedger, error)
+	RecordHandover(ctx context.Context, adminID uuid.UUID, handover entity.CashHandoverJSON) (entity.CashTransaction, error)
+	Reconciliation(ctx context.Context, dateStr string) ([]entity.CashReconciliation, error)
+}
+
+type cashServiceImpl struct {
+	repo repo.Cash
+}
+
+func (s *cashServiceImpl) GetTripLedger(ctx context.Context, driverID uuid.UUID, tripIDStr string) (entity.CashLedger, error) {
+	tripID, err := uuid.Parse(tripIDStr)
+	if err != nil {
+		return entity.CashLedger{}, rfc7807.UUID(err.Error())
+	}
+
+	entries, err := s.repo.GetTripLedger(ctx, tripID, driverID)
+	if err != nil {
+		return entity.CashLedger{}, err
+	}
+
+	var ledger = entity.CashLedger{TripID: tripID, Entries: entries}
+	for _, entry := range entries {
+		ledger.Total += entry.Amount
+	}
+
+	return ledger, nil
+}
+
+func (s *cashServiceImpl) RecordHandover(ctx context.Context, adminID uuid.UUID, handover entity.CashHandoverJSON) (entity.CashTransaction, error) {
+	transaction, err := handover.Parse(adminID)
+	if err != nil {
+		return entity.CashTransaction{}, err
+	}
+
+	employee, err := s.repo.GetUser(ctx, handover.EmployeeID)
+	if err != nil {
+		return entity.CashTransaction{}, err
+	}
+
+	if employee.Role.Val == nil || (employee.Role.Val.Name() != auth.Driver.Name() && employee.Role.Val.Name() != auth.Support.Name()) {
+		return entity.CashTransaction{}, rfc7807.BadRequest("non-existing-employee", "Non-existing Employee Error", "There is no driver or support employee with such id.")
+	}
+
+	return transaction, s.repo.Record(ctx, &transaction)
+}
+
+// Reconciliation reports the cash of the day in the operating country, today
+// if no date is given.
+func (s *cashServiceImpl) Reconciliation(ctx context.Context, dateStr string) ([]entity.CashReconciliation, error) {
+	location, ok := timezone.Location(config.OperatingCountry())
+	if !ok {
+		location = time.UTC
+	}
+
+	if dateStr == "" {
+		dateStr = time.Now().In(location).Format("2006-01-02")
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", dateStr, location)
+	if err != nil {
+		return nil, rfc7807.BadRequest("invalid-date", "Invalid Date Error", "The date has to be in the YYYY-MM-DD format.")
+	}
+
+	report, err := s.repo.Reconciliation(ctx, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report {
+		report[i].Difference = report[i].Expected - report[i].HandedIn
+	}
+
+	return report, nil
+}
+
+func NewCashService(repo repo.Cash) Cash {
+	return &cashServiceImpl{repo}
+}
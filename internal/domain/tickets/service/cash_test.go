@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"testing"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// cashTicket adds a ticket the driver sold for cash on the connection.
+func (pt *paymentTest) cashTicket(connection entity.Connection, driverID uuid.UUID, price int) entity.Ticket {
+	ticket := pt.paidTicket(connection, price, "")
+	stored := pt.store.tickets[ticket.ID]
+	stored.TicketPayment.Method = entity.PaymentMethodCash
+	stored.TicketPayment.CollectedByID = uuid.NullUUID{UUID: driverID, Valid: true}
+	return *stored
+}
+
+func (pt *paymentTest) drivenConnection(departsIn time.Duration, driverID uuid.UUID) entity.Connection {
+	connection := pt.addConnection(departsIn)
+	connection.Bus.LeadDriverID = uuid.NullUUID{UUID: driverID, Valid: true}
+	pt.store.connections[connection.ID] = connection
+	return connection
+}
+
+func TestCancelForCash(t *testing.T) {
+	pt := newPaymentTest(t)
+	ctx := context.Background()
+	driverID := uuid.New()
+
+	ticket := pt.cashTicket(pt.drivenConnection(72*time.Hour, driverID), driverID, 1000)
+
+	_, err := pt.refunds.CancelForCash(ctx, uuid.New(), true, ticket.ID.String())
+	assertProblem(t, err, "foreign-connection")
+
+	refund, err := pt.refunds.CancelForCash(ctx, driverID, true, ticket.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if refund.Type != entity.CashTransactionRefund || refund.Amount != -1000 || refund.EmployeeID != driverID || refund.TicketID.UUID != ticket.ID {
+		t.Errorf("unexpected cash refund %+v", refund)
+	}
+
+	if len(pt.store.cash) != 1 || pt.store.cash[0].ID != refund.ID {
+		t.Errorf("the cash refund was not recorded: %+v", pt.store.cash)
+	}
+
+	if cancelled, _ := pt.store.GetTicket(ctx, ticket.ID); cancelled.Status != entity.TicketStatusRefunded {
+		t.Errorf("ticket status = %s, want %s", cancelled.Status, entity.TicketStatusRefunded)
+	}
+
+	if _, err := pt.refunds.CancelForCash(ctx, driverID, true, ticket.ID.String()); err == nil {
+		t.Error("a refunded ticket was refunded again")
+	}
+}
+
+func TestCancelForCashUnderPolicy(t *testing.T) {
+	pt := newPaymentTest(t)
+	ctx := context.Background()
+	supportID := uuid.New()
+
+	ticket := pt.cashTicket(pt.addConnection(time.Hour), uuid.New(), 1000)
+	refund, err := pt.refunds.CancelForCash(ctx, supportID, false, ticket.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if refund.Amount != -500 || refund.EmployeeID != supportID {
+		t.Errorf("unexpected cash refund %+v", refund)
+	}
+
+	departed := pt.cashTicket(pt.addConnection(-time.Hour), uuid.New(), 1000)
+	_, err = pt.refunds.CancelForCash(ctx, supportID, false, departed.ID.String())
+	assertProblem(t, err, "non-refundable-ticket")
+
+	paid := pt.paidTicket(pt.connection, 1000, "pi_card")
+	_, err = pt.refunds.CancelForCash(ctx, supportID, false, paid.ID.String())
+	assertProblem(t, err, "non-cash-payment")
+}
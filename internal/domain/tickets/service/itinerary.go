@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"maryan_api/config"
+	"maryan_api/internal/entity"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// PurchaseItinerary holds the seats of every leg in one transaction and pays
+// for all of them in one checkout, so the tickets of the legs are issued
+// together or not at all.
+func (s *serviceImpl) PurchaseItinerary(ctx context.Context, userID uuid.UUID, newTicket entity.NewItineraryTicketJSON) (string, error) {
+	err := newTicket.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	email, phoneNumber, _ := entity.NewTicketJSON{Email: newTicket.Email, PhoneNumber: newTicket.PhoneNumber}.ParseContaanctInfo()
+
+	var legs = make([]entity.Connection, len(newTicket.Legs))
+	for i, leg := range newTicket.Legs {
+		legs[i], err = s.findLeg(ctx, leg.ConnectionID, leg.From, leg.To, leg.SeatIDs, len(newTicket.Passengers))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = entity.CheckTransfers(legs)
+	if err != nil {
+		return "", err
+	}
+
+	// The passengers are dropped off and picked up again at the address of
+	// every transfer.
+	var adresses = make([]*entity.Address, len(legs)+1)
+	adresses[0], err = s.prepareAdress(newTicket.PickUpAdress, userID, legs[0].DepartureCountryID)
+	if err != nil {
+		return "", err
+	}
+
+	for i, transferAdress := range newTicket.TransferAdresses {
+		adresses[i+1], err = s.prepareAdress(transferAdress, userID, legs[i+1].DepartureCountryID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	adresses[len(legs)], err = s.prepareAdress(newTicket.DropOffAdress, userID, legs[len(legs)-1].DestinationCountryID)
+	if err != nil {
+		return "", err
+	}
+
+	var passengers = make([]*entity.Passenger, len(newTicket.Passengers))
+	for i, newPassenger := range newTicket.Passengers {
+		passengers[i], err = preparePassenger(newPassenger, userID, legs[0].DepartureTime)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if newTicket.LoyaltyPoints < 0 {
+		return "", rfc7807.BadRequest("loyalty-points", "Loyalty Points Error", "The number of loyalty points cannot be negative.")
+	}
+
+	extras, err := s.getExtras(ctx, newTicket.Extras, len(passengers))
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(entity.SeatHoldDuration)
+
+	var legHolds = make([][]*entity.SeatHold, len(legs))
+	var holdPassengers []*entity.Passenger
+	var segments []string
+	for i, leg := range legs {
+		legHolds[i], err = s.prepareHolds(ctx, leg, newTicket.Legs[i].SeatIDs, userID, passengers, adresses[i], adresses[i+1], phoneNumber, email, expiresAt)
+		if err != nil {
+			return "", err
+		}
+
+		from, to := leg.SegmentCities(leg.Segment)
+		for _, passenger := range passengers {
+			holdPassengers = append(holdPassengers, passenger)
+			segments = append(segments, from+" - "+to)
+		}
+	}
+	holds := slices.Concat(legHolds...)
+
+	// The connections are locked in the same order by every purchase, so two
+	// itineraries sharing legs cannot deadlock.
+	var order = make([]int, len(legs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(legs[a].ID.String(), legs[b].ID.String()) })
+
+	var free bool
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		for _, i := range order {
+			takenSeats, err := s.lockSeats(ctx, legs[i], newTicket.Legs[i].SeatIDs)
+			if err != nil {
+				return err
+			}
+
+			err = s.checkReserved(ctx, legs[i], takenSeats, len(newTicket.Legs[i].SeatIDs), uuid.Nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, adress := range adresses {
+			if err := s.repo.CreateAddress(ctx, adress); err != nil {
+				return err
+			}
+		}
+
+		for _, passenger := range passengers {
+			if err := s.repo.CreatePassenger(ctx, passenger); err != nil {
+				return err
+			}
+		}
+
+		if newTicket.LoyaltyPoints > 0 {
+			if err := s.redeemLoyaltyPoints(ctx, newTicket.LoyaltyPoints, userID, holds); err != nil {
+				return err
+			}
+		}
+
+		// The extras take room on every leg, but the passengers pay for
+		// them once, with the first one.
+		if len(newTicket.Extras) > 0 {
+			for i := range legs {
+				if err := s.addExtras(ctx, &legs[i], extras, newTicket.Extras, legHolds[i], i == 0); err != nil {
+					return err
+				}
+			}
+		}
+
+		free, err = s.holdOrIssue(ctx, holds)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if free {
+		return config.FrontendURL() + "/profile/tickets", nil
+	}
+
+	return s.checkout(ctx, holds, holdPassengers, "", segments)
+}
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// memoryStore keeps the holds, tickets, refunds and payment events of the
+// tests in memory. A transaction holds the lock for its whole run, as the row
+// locks of the database would; it does not roll back.
+type memoryStore struct {
+	mu          sync.Mutex
+	connections map[uuid.UUID]entity.Connection
+	holds       map[uuid.UUID]*entity.SeatHold
+	released    map[uuid.UUID]entity.ReleasedSeatHold
+	tickets     map[uuid.UUID]*entity.Ticket
+	refunds     map[uuid.UUID]*entity.Refaund
+	events      map[string]entity.PaymentEvent
+	unfulfilled map[string]*entity.UnfulfilledPayment
+	cash        []entity.CashTransaction
+}
+
+type inTransaction struct{}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		connections: map[uuid.UUID]entity.Connection{},
+		holds:       map[uuid.UUID]*entity.SeatHold{},
+		released:    map[uuid.UUID]entity.ReleasedSeatHold{},
+		tickets:     map[uuid.UUID]*entity.Ticket{},
+		refunds:     map[uuid.UUID]*entity.Refaund{},
+		events:      map[string]entity.PaymentEvent{},
+		unfulfilled: map[string]*entity.UnfulfilledPayment{},
+	}
+}
+
+func (m *memoryStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(inTransaction{}) != nil {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(context.WithValue(ctx, inTransaction{}, true))
+}
+
+// lock locks the store for a call made outside of a transaction.
+func (m *memoryStore) lock(ctx context.Context) func() {
+	if ctx.Value(inTransaction{}) != nil {
+		return func() {}
+	}
+
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *memoryStore) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	defer m.lock(ctx)()
+
+	connection, ok := m.connections[id]
+	if !ok {
+		return entity.Connection{}, nil, rfc7807.BadRequest("non-existing-connection", "Non-existing Connection Error", "There is no connection with such id.")
+	}
+
+	return connection, nil, nil
+}
+
+func (m *memoryStore) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	defer m.lock(ctx)()
+
+	ticket, ok := m.tickets[id]
+	if !ok {
+		return entity.Ticket{}, rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+	}
+
+	return *ticket, nil
+}
+
+// userTickets returns the tickets of the user.
+func (m *memoryStore) userTickets(userID uuid.UUID) []entity.Ticket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tickets []entity.Ticket
+	for _, ticket := range m.tickets {
+		if ticket.UserID == userID {
+			tickets = append(tickets, *ticket)
+		}
+	}
+
+	return tickets
+}
+
+func (m *memoryStore) refund(id uuid.UUID) entity.Refaund {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return *m.refunds[id]
+}
+
+func (m *memoryStore) holdCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.holds)
+}
+
+func (m *memoryStore) releasedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.released)
+}
+
+func (m *memoryStore) unfulfilledPayment(sessionID string) (entity.UnfulfilledPayment, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, ok := m.unfulfilled[sessionID]
+	if !ok {
+		return entity.UnfulfilledPayment{}, false
+	}
+
+	return *payment, true
+}
+
+// isTaken reports whether a paid ticket or an active hold overlaps the hold on
+// its seat.
+func (m *memoryStore) isTaken(hold entity.SeatHold, now time.Time) bool {
+	for _, held := range m.holds {
+		if held.ConnectionID == hold.ConnectionID && held.SeatID == hold.SeatID && held.ExpiresAt.After(now) && held.Segment().Overlaps(hold.Segment()) {
+			return true
+		}
+	}
+
+	for _, ticket := range m.tickets {
+		if ticket.ConnectionID == hold.ConnectionID && ticket.SeatID == hold.SeatID && ticket.Status == entity.TicketStatusPaid && ticket.Segment().Overlaps(hold.Segment()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// releaseHolds deletes the holds matching the predicate and returns the
+// connections they were on.
+func (m *memoryStore) releaseHolds(match func(hold *entity.SeatHold) bool) (int, []uuid.UUID) {
+	var released int
+	var freed []uuid.UUID
+	for id, hold := range m.holds {
+		if !match(hold) {
+			continue
+		}
+
+		delete(m.holds, id)
+		released++
+		if !slices.Contains(freed, hold.ConnectionID) {
+			freed = append(freed, hold.ConnectionID)
+		}
+	}
+
+	return released, freed
+}
+
+// completeRefund completes the processing refund of the payment the provider
+// gave the id to and refunds its ticket once none of its refunds is pending.
+func (m *memoryStore) completeRefund(paymentIntentID, providerRefundID string) []uuid.UUID {
+	var completed *entity.Refaund
+	for _, refund := range m.refunds {
+		if providerRefundID != "" && refund.PaymentIntentID == paymentIntentID && refund.ProviderRefundID == providerRefundID && refund.Status == entity.RefaundStatusProcessing {
+			completed = refund
+			break
+		}
+	}
+
+	if completed == nil {
+		return nil
+	}
+
+	now := time.Now()
+	completed.Status = entity.RefaundStatusCompleted
+	completed.CompletedAt = &now
+
+	for _, refund := range m.refunds {
+		if refund.TicketID == completed.TicketID && (refund.Status == entity.RefaundStatusRequested || refund.Status == entity.RefaundStatusProcessing) {
+			return nil
+		}
+	}
+
+	ticket := m.tickets[completed.TicketID]
+	ticket.Status = entity.TicketStatusRefunded
+	return []uuid.UUID{ticket.ConnectionID}
+}
+
+// memoryTicketRepo implements the part of repo.Ticket the payment flow uses;
+// anything else panics on the nil embedded interface.
+type memoryTicketRepo struct {
+	repo.Ticket
+	*memoryStore
+}
+
+func (r memoryTicketRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.memoryStore.Transaction(ctx, fn)
+}
+
+func (r memoryTicketRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.memoryStore.GetConnectionByID(ctx, id)
+}
+
+func (r memoryTicketRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.memoryStore.GetTicket(ctx, id)
+}
+
+func (r memoryTicketRepo) HoldSeats(ctx context.Context, holds []*entity.SeatHold) error {
+	defer r.lock(ctx)()
+
+	for _, hold := range holds {
+		for _, held := range r.holds {
+			if held.ConnectionID == hold.ConnectionID && held.SeatID == hold.SeatID && held.Segment().Overlaps(hold.Segment()) {
+				return rfc7807.BadRequest("taken-seat", "Taken Seat Error", "The seat is already taken.")
+			}
+		}
+
+		copied := *hold
+		r.holds[hold.ID] = &copied
+	}
+
+	return nil
+}
+
+func (r memoryTicketRepo) AttachPaymentSession(ctx context.Context, holdIDs []uuid.UUID, paymentSessionID string) error {
+	defer r.lock(ctx)()
+
+	for _, id := range holdIDs {
+		r.holds[id].SessionID = paymentSessionID
+	}
+
+	return nil
+}
+
+func (r memoryTicketRepo) ReleaseSeats(ctx context.Context, holdIDs []uuid.UUID) error {
+	defer r.lock(ctx)()
+
+	r.releaseHolds(func(hold *entity.SeatHold) bool { return slices.Contains(holdIDs, hold.ID) })
+	return nil
+}
+
+func (r memoryTicketRepo) ReleaseSeatsBySession(ctx context.Context, paymentSessionID string) ([]uuid.UUID, error) {
+	defer r.lock(ctx)()
+
+	_, freed := r.releaseHolds(func(hold *entity.SeatHold) bool { return hold.SessionID == paymentSessionID })
+	for id, released := range r.released {
+		if released.SessionID == paymentSessionID {
+			delete(r.released, id)
+		}
+	}
+
+	return freed, nil
+}
+
+// ReleaseExpiredSeats keeps the expired holds of opened checkouts, all of a
+// checkout at once, as released seat holds, as the database does.
+func (r memoryTicketRepo) ReleaseExpiredSeats(ctx context.Context, now time.Time) (int, []uuid.UUID, error) {
+	defer r.lock(ctx)()
+
+	var sessionIDs []string
+	for _, hold := range r.holds {
+		if !hold.ExpiresAt.After(now) && hold.SessionID != "" {
+			sessionIDs = append(sessionIDs, hold.SessionID)
+		}
+	}
+
+	for _, hold := range r.holds {
+		if slices.Contains(sessionIDs, hold.SessionID) {
+			r.released[hold.ID] = entity.NewReleasedSeatHold(*hold, now)
+		}
+	}
+
+	released, freed := r.releaseHolds(func(hold *entity.SeatHold) bool {
+		return !hold.ExpiresAt.After(now) || slices.Contains(sessionIDs, hold.SessionID)
+	})
+	return released, freed, nil
+}
+
+func (r memoryTicketRepo) Rehold(ctx context.Context, paymentSessionID string) (bool, error) {
+	defer r.lock(ctx)()
+
+	var held, released bool
+	for _, hold := range r.holds {
+		held = held || hold.SessionID == paymentSessionID
+	}
+
+	now := time.Now()
+	for _, parked := range r.released {
+		if parked.SessionID != paymentSessionID {
+			continue
+		}
+
+		released = true
+		if r.isTaken(parked.Hold, now) {
+			return false, nil
+		}
+	}
+
+	if !released {
+		return held, nil
+	}
+
+	for id, parked := range r.released {
+		if parked.SessionID == paymentSessionID {
+			hold := parked.Hold
+			hold.ExpiresAt = now.Add(entity.SeatHoldGrace)
+			r.holds[id] = &hold
+			delete(r.released, id)
+		}
+	}
+
+	return true, nil
+}
+
+func (r memoryTicketRepo) RecordUnfulfilledPayment(ctx context.Context, payment *entity.UnfulfilledPayment) error {
+	defer r.lock(ctx)()
+
+	copied := *payment
+	r.unfulfilled[payment.SessionID] = &copied
+	return nil
+}
+
+func (r memoryTicketRepo) AttachUnfulfilledRefund(ctx context.Context, paymentSessionID, providerRefundID string) error {
+	defer r.lock(ctx)()
+
+	r.unfulfilled[paymentSessionID].ProviderRefundID = providerRefundID
+	return nil
+}
+
+func (r memoryTicketRepo) AddTickets(ctx context.Context, paymentSessionID, paymentIntentID string) ([]uuid.UUID, error) {
+	defer r.lock(ctx)()
+
+	var found bool
+	for id, hold := range r.holds {
+		if hold.SessionID != paymentSessionID {
+			continue
+		}
+
+		ticket := hold.ToTicket()
+		ticket.TicketPayment.PaymentIntentID = paymentIntentID
+		r.tickets[ticket.ID] = &ticket
+		delete(r.holds, id)
+		found = true
+	}
+
+	if !found {
+		return nil, rfc7807.BadRequest("non-existing-session", "Non-existing Session Error", "There is no checkout with such session.")
+	}
+
+	return nil, nil
+}
+
+func (r memoryTicketRepo) RegisterPaymentEvent(ctx context.Context, event *entity.PaymentEvent) (bool, error) {
+	defer r.lock(ctx)()
+
+	if _, ok := r.events[event.ID]; ok {
+		return false, nil
+	}
+
+	r.events[event.ID] = *event
+	return true, nil
+}
+
+func (r memoryTicketRepo) CompleteRefund(ctx context.Context, paymentIntentID, providerRefundID string) ([]uuid.UUID, error) {
+	defer r.lock(ctx)()
+
+	return r.completeRefund(paymentIntentID, providerRefundID), nil
+}
+
+// memoryRefundRepo implements the part of repo.Refund cancellations use.
+type memoryRefundRepo struct {
+	repo.Refund
+	*memoryStore
+}
+
+func (r memoryRefundRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.memoryStore.Transaction(ctx, fn)
+}
+
+func (r memoryRefundRepo) GetConnectionByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
+	return r.memoryStore.GetConnectionByID(ctx, id)
+}
+
+func (r memoryRefundRepo) GetTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.memoryStore.GetTicket(ctx, id)
+}
+
+func (r memoryRefundRepo) LockTicket(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	return r.memoryStore.GetTicket(ctx, id)
+}
+
+func (r memoryRefundRepo) Create(ctx context.Context, refund *entity.Refaund) error {
+	defer r.lock(ctx)()
+
+	copied := *refund
+	r.refunds[refund.ID] = &copied
+	return nil
+}
+
+func (r memoryRefundRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Refaund, error) {
+	defer r.lock(ctx)()
+
+	refund, ok := r.refunds[id]
+	if !ok {
+		return entity.Refaund{}, rfc7807.BadRequest("non-existing-refund", "Non-existing Refund Error", "There is no refund with such id.")
+	}
+
+	return *refund, nil
+}
+
+func (r memoryRefundRepo) GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error) {
+	defer r.lock(ctx)()
+
+	var refunds []entity.Refaund
+	for _, refund := range r.refunds {
+		if refund.TicketID == ticketID {
+			refunds = append(refunds, *refund)
+		}
+	}
+
+	return refunds, nil
+}
+
+func (r memoryRefundRepo) HasActive(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	defer r.lock(ctx)()
+
+	for _, refund := range r.refunds {
+		if refund.TicketID == ticketID && refund.Reason == entity.RefaundReasonCancellation && refund.Status != entity.RefaundStatusRejected {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r memoryRefundRepo) StartProcessing(ctx context.Context, id uuid.UUID) error {
+	return r.changeStatus(ctx, id, func(refund *entity.Refaund) bool {
+		return setStatus(&refund.Status, entity.RefaundStatusRequested, entity.RefaundStatusProcessing)
+	})
+}
+
+func (r memoryRefundRepo) StopProcessing(ctx context.Context, id uuid.UUID) error {
+	return r.changeStatus(ctx, id, func(refund *entity.Refaund) bool {
+		return setStatus(&refund.Status, entity.RefaundStatusProcessing, entity.RefaundStatusRequested)
+	})
+}
+
+func (r memoryRefundRepo) Reject(ctx context.Context, id uuid.UUID) error {
+	return r.changeStatus(ctx, id, func(refund *entity.Refaund) bool {
+		return setStatus(&refund.Status, entity.RefaundStatusRequested, entity.RefaundStatusRejected)
+	})
+}
+
+// AttachProviderRefund completes the refund right away when its webhook came
+// before the provider's response did, as the database does.
+func (r memoryRefundRepo) AttachProviderRefund(ctx context.Context, id uuid.UUID, providerRefundID string) error {
+	defer r.lock(ctx)()
+
+	r.refunds[id].ProviderRefundID = providerRefundID
+	for _, event := range r.events {
+		if event.Type == entity.PaymentEventRefundSucceeded && event.RefundID == providerRefundID {
+			r.completeRefund(r.refunds[id].PaymentIntentID, providerRefundID)
+		}
+	}
+
+	return nil
+}
+
+func (r memoryRefundRepo) CancelTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return r.cancelTicket(ctx, id, entity.TicketUpdate{Status: entity.TicketStatusCancelled, Comment: comment})
+}
+
+func (r memoryRefundRepo) RefundTicket(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return r.cancelTicket(ctx, id, entity.TicketUpdate{Status: entity.TicketStatusRefunded, Comment: comment})
+}
+
+func (r memoryRefundRepo) cancelTicket(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) ([]uuid.UUID, error) {
+	defer r.lock(ctx)()
+
+	ticket, ok := r.tickets[id]
+	if !ok {
+		return nil, rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+	}
+
+	_, err := ticket.ChangeStatus(update.Status, update.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return []uuid.UUID{ticket.ConnectionID}, nil
+}
+
+func (r memoryRefundRepo) RecordCash(ctx context.Context, transaction *entity.CashTransaction) error {
+	defer r.lock(ctx)()
+
+	r.cash = append(r.cash, *transaction)
+	return nil
+}
+
+func (r memoryRefundRepo) changeStatus(ctx context.Context, id uuid.UUID, change func(refund *entity.Refaund) bool) error {
+	defer r.lock(ctx)()
+
+	refund, ok := r.refunds[id]
+	if !ok || !change(refund) {
+		return rfc7807.BadRequest("invalid-refund-status", "Invalid Refund Status Error", "The refund is not in the required status.")
+	}
+
+	return nil
+}
+
+// setStatus moves the status from one value to another and reports whether it
+// was in the first one.
+func setStatus[S comparable](status *S, from, to S) bool {
+	if *status != from {
+		return false
+	}
+
+	*status = to
+	return true
+}
+
+// memoryWaitlist records the connections seats were freed on.
+type memoryWaitlist struct {
+	Waitlist
+	mu    sync.Mutex
+	freed []uuid.UUID
+}
+
+func (w *memoryWaitlist) OfferFreedSeats(ctx context.Context, connectionIDs []uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.freed = append(w.freed, connectionIDs...)
+}
+
+func (w *memoryWaitlist) offered() []uuid.UUID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return slices.Clone(w.freed)
+}
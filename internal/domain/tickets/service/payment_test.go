@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/payment"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// paymentTest runs the ticket and refund services against the in-memory store
+// and the fake payment provider, whose webhooks are delivered to the ticket
+// service the way the API would.
+type paymentTest struct {
+	store      *memoryStore
+	fake       *payment.Fake
+	waitlist   *memoryWaitlist
+	tickets    *serviceImpl
+	refunds    Refund
+	webhooks   chan error
+	connection entity.Connection
+	userID     uuid.UUID
+	apiURL     string
+}
+
+func newPaymentTest(t *testing.T) *paymentTest {
+	t.Helper()
+
+	pt := &paymentTest{
+		store:    newMemoryStore(),
+		waitlist: &memoryWaitlist{},
+		webhooks: make(chan error, 10),
+		userID:   uuid.New(),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		err := pt.tickets.ProcessWebhook(r.Context(), payload, r.Header)
+		pt.webhooks <- err
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("API_URL", server.URL)
+	t.Setenv("FAKE_PAYMENT_SECRET_KEY", "test-secret")
+	pt.apiURL = server.URL
+	pt.fake = payment.NewFake(server.Client())
+
+	pt.tickets = NewTicketService(memoryTicketRepo{memoryStore: pt.store}, pt.fake, pt.waitlist, nil, nil).(*serviceImpl)
+	pt.refunds = NewRefundService(memoryRefundRepo{memoryStore: pt.store}, pt.fake, entity.CancellationPolicy{
+		FullRefundBefore:     24 * time.Hour,
+		PartialRefundPercent: 50,
+	})
+
+	pt.connection = pt.addConnection(72 * time.Hour)
+	return pt
+}
+
+func (pt *paymentTest) addConnection(departsIn time.Duration) entity.Connection {
+	connection := entity.Connection{ID: uuid.New(), DepartureTime: time.Now().Add(departsIn)}
+	pt.store.connections[connection.ID] = connection
+	return connection
+}
+
+// purchase holds a seat of the connection for every price and opens the
+// checkout for them, returning the payment URL and the holds.
+func (pt *paymentTest) purchase(t *testing.T, connection entity.Connection, prices ...int) (string, []*entity.SeatHold) {
+	t.Helper()
+
+	var holds = make([]*entity.SeatHold, len(prices))
+	var passengers = make([]*entity.Passenger, len(prices))
+	for i, price := range prices {
+		holds[i] = &entity.SeatHold{
+			ID:           uuid.New(),
+			ConnectionID: connection.ID,
+			SeatID:       uuid.New(),
+			ToStation:    1,
+			UserID:       pt.userID,
+			PassengerID:  uuid.New(),
+			Price:        price,
+			ExpiresAt:    time.Now().Add(entity.SeatHoldDuration),
+		}
+		passengers[i] = &entity.Passenger{ID: holds[i].PassengerID, FirstName: "Taras", LastName: "Shevchenko", Category: "Adult"}
+	}
+
+	ctx := context.Background()
+	free, err := pt.tickets.holdOrIssue(ctx, holds)
+	if err != nil || free {
+		t.Fatalf("holdOrIssue() = %v, %v", free, err)
+	}
+
+	url, err := pt.tickets.checkout(ctx, holds, passengers, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return url, holds
+}
+
+func (pt *paymentTest) sessionID(t *testing.T, url string) string {
+	t.Helper()
+
+	sessionID, ok := strings.CutPrefix(url, pt.apiURL+"/payments/fake/")
+	if !ok {
+		t.Fatalf("unexpected payment URL %s", url)
+	}
+
+	return sessionID
+}
+
+func (pt *paymentTest) waitWebhook(t *testing.T) {
+	t.Helper()
+
+	select {
+	case err := <-pt.webhooks:
+		if err != nil {
+			t.Fatalf("the webhook failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivered")
+	}
+}
+
+// settleRefunds has the provider pay out the refunds of the checkout and waits
+// for their webhook.
+func (pt *paymentTest) settleRefunds(t *testing.T, sessionID string) {
+	t.Helper()
+
+	if err := pt.fake.SettleRefunds(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+}
+
+// paidTicket adds a ticket paid with the payment intent, which the fake
+// provider knows nothing about.
+func (pt *paymentTest) paidTicket(connection entity.Connection, price int, paymentIntentID string) entity.Ticket {
+	ticket := entity.SeatHold{
+		ID:           uuid.New(),
+		ConnectionID: connection.ID,
+		SeatID:       uuid.New(),
+		ToStation:    1,
+		UserID:       pt.userID,
+		Price:        price,
+	}.ToTicket()
+	ticket.TicketPayment.PaymentIntentID = paymentIntentID
+
+	pt.store.tickets[ticket.ID] = &ticket
+	return ticket
+}
+
+func assertProblem(t *testing.T, err error, problemType string) {
+	t.Helper()
+
+	var problem rfc7807.Problem
+	if !errors.As(err, &problem) || !strings.HasSuffix(problem.Type, "/problems/"+problemType) {
+		t.Errorf("got %v, want a %s problem", err, problemType)
+	}
+}
+
+func TestPurchaseWebhookAndRefund(t *testing.T) {
+	pt := newPaymentTest(t)
+	ctx := context.Background()
+
+	url, _ := pt.purchase(t, pt.connection, 1000, 800)
+	sessionID := pt.sessionID(t, url)
+	if err := pt.fake.Complete(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+
+	if pt.store.holdCount() != 0 {
+		t.Errorf("%d holds left after the payment", pt.store.holdCount())
+	}
+
+	tickets := pt.store.userTickets(pt.userID)
+	if len(tickets) != 2 {
+		t.Fatalf("got %d tickets, want 2", len(tickets))
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Status != entity.TicketStatusPaid || ticket.TicketPayment.PaymentIntentID == "" {
+			t.Errorf("unexpected ticket %+v", ticket)
+		}
+	}
+
+	ticket := tickets[0]
+	refunds, err := pt.refunds.Cancel(ctx, pt.userID, ticket.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refunds) != 1 || refunds[0].Amount != ticket.TicketPayment.Price || refunds[0].Status != entity.RefaundStatusProcessing {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+
+	if refund := pt.store.refund(refunds[0].ID); refund.ProviderRefundID == "" {
+		t.Fatalf("the provider refund id was not recorded: %+v", refund)
+	}
+
+	// The provider confirms the refund with a webhook of its own.
+	pt.settleRefunds(t, sessionID)
+
+	if refund := pt.store.refund(refunds[0].ID); refund.Status != entity.RefaundStatusCompleted || refund.CompletedAt == nil {
+		t.Errorf("unexpected refund %+v", refund)
+	}
+
+	if refunded, _ := pt.store.GetTicket(ctx, ticket.ID); refunded.Status != entity.TicketStatusRefunded {
+		t.Errorf("ticket status = %s, want %s", refunded.Status, entity.TicketStatusRefunded)
+	}
+
+	if !slices.Contains(pt.waitlist.offered(), pt.connection.ID) {
+		t.Error("the refunded seat was not offered to the waitlist")
+	}
+
+	_, err = pt.refunds.Cancel(ctx, pt.userID, ticket.ID.String())
+	assertProblem(t, err, "refund-exists")
+
+	err = pt.refunds.Approve(ctx, refunds[0].ID.String())
+	assertProblem(t, err, "invalid-refund-status")
+}
+
+func TestCancelWithinFullRefundWindow(t *testing.T) {
+	pt := newPaymentTest(t)
+	connection := pt.addConnection(time.Hour)
+
+	url, _ := pt.purchase(t, connection, 1000)
+	sessionID := pt.sessionID(t, url)
+	if err := pt.fake.Complete(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+
+	ticket := pt.store.userTickets(pt.userID)[0]
+	refunds, err := pt.refunds.Cancel(context.Background(), pt.userID, ticket.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt.settleRefunds(t, sessionID)
+
+	if len(refunds) != 1 || refunds[0].Amount != 500 {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+
+	if refund := pt.store.refund(refunds[0].ID); refund.Status != entity.RefaundStatusCompleted {
+		t.Errorf("refund status = %s, want %s", refund.Status, entity.RefaundStatusCompleted)
+	}
+}
+
+func TestRefundStateMachine(t *testing.T) {
+	pt := newPaymentTest(t)
+	ctx := context.Background()
+
+	// The fake knows nothing about the payment, so every refund of it fails.
+	ticket := pt.paidTicket(pt.connection, 1000, "pi_unknown")
+
+	refunds, err := pt.refunds.Cancel(ctx, pt.userID, ticket.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := refunds[0].ID
+	if refund := pt.store.refund(id); refund.Status != entity.RefaundStatusRequested {
+		t.Fatalf("refund status after a failed provider call = %s, want %s", refund.Status, entity.RefaundStatusRequested)
+	}
+
+	_, err = pt.refunds.Cancel(ctx, pt.userID, ticket.ID.String())
+	assertProblem(t, err, "refund-exists")
+
+	err = pt.refunds.Approve(ctx, id.String())
+	assertProblem(t, err, "payment")
+
+	if refund := pt.store.refund(id); refund.Status != entity.RefaundStatusRequested {
+		t.Fatalf("refund status after a failed approval = %s, want %s", refund.Status, entity.RefaundStatusRequested)
+	}
+
+	if err := pt.refunds.Reject(ctx, id.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if refund := pt.store.refund(id); refund.Status != entity.RefaundStatusRejected {
+		t.Fatalf("refund status = %s, want %s", refund.Status, entity.RefaundStatusRejected)
+	}
+
+	err = pt.refunds.Approve(ctx, id.String())
+	assertProblem(t, err, "invalid-refund-status")
+
+	err = pt.refunds.Reject(ctx, id.String())
+	assertProblem(t, err, "invalid-refund-status")
+
+	// A rejected refund does not stop the ticket from being cancelled again.
+	if _, err := pt.refunds.Cancel(ctx, pt.userID, ticket.ID.String()); err != nil {
+		t.Errorf("cancelling after a rejection: %v", err)
+	}
+
+	_, err = pt.refunds.Cancel(ctx, uuid.New(), ticket.ID.String())
+	assertProblem(t, err, "non-existing-ticket")
+}
+
+func TestCancelAfterDeparture(t *testing.T) {
+	pt := newPaymentTest(t)
+	ticket := pt.paidTicket(pt.addConnection(-time.Hour), 1000, "pi_unknown")
+
+	_, err := pt.refunds.Cancel(context.Background(), pt.userID, ticket.ID.String())
+	assertProblem(t, err, "non-refundable-ticket")
+}
+
+func TestCheckoutExpiry(t *testing.T) {
+	pt := newPaymentTest(t)
+
+	url, holds := pt.purchase(t, pt.connection, 1000)
+	sessionID := pt.sessionID(t, url)
+
+	if status, _ := pt.fake.FetchStatus(context.Background(), sessionID); status != payment.StatusOpen {
+		t.Fatalf("status = %s, want %s", status, payment.StatusOpen)
+	}
+
+	if err := pt.fake.Expire(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+
+	if pt.store.holdCount() != 0 {
+		t.Errorf("%d holds left after the checkout expired", pt.store.holdCount())
+	}
+
+	if !slices.Contains(pt.waitlist.offered(), holds[0].ConnectionID) {
+		t.Error("the released seat was not offered to the waitlist")
+	}
+
+	if err := pt.fake.Complete(sessionID); err == nil {
+		t.Error("an expired checkout was paid")
+	}
+}
+
+func TestExpireAbandonedPurchases(t *testing.T) {
+	pt := newPaymentTest(t)
+
+	_, abandoned := pt.purchase(t, pt.connection, 1000)
+	_, open := pt.purchase(t, pt.connection, 1000)
+
+	// The provider never reported back on the first checkout and its grace
+	// period is over.
+	pt.store.holds[abandoned[0].ID].ExpiresAt = time.Now().Add(-time.Second)
+
+	released, err := pt.tickets.ExpireAbandonedPurchases(context.Background())
+	if err != nil || released != 1 {
+		t.Fatalf("ExpireAbandonedPurchases() = %d, %v, want 1", released, err)
+	}
+
+	if _, ok := pt.store.holds[open[0].ID]; !ok || pt.store.holdCount() != 1 {
+		t.Error("an active hold was released")
+	}
+
+	if !slices.Contains(pt.waitlist.offered(), pt.connection.ID) {
+		t.Error("the released seat was not offered to the waitlist")
+	}
+
+	released, err = pt.tickets.ExpireAbandonedPurchases(context.Background())
+	if err != nil || released != 0 {
+		t.Errorf("ExpireAbandonedPurchases() = %d, %v, want 0", released, err)
+	}
+}
+
+// expireHolds lets the holds run out and the job release them, as happens
+// when the provider reports the payment after the grace period.
+func (pt *paymentTest) expireHolds(t *testing.T, holds []*entity.SeatHold) {
+	t.Helper()
+
+	for _, hold := range holds {
+		pt.store.holds[hold.ID].ExpiresAt = time.Now().Add(-time.Second)
+	}
+
+	released, err := pt.tickets.ExpireAbandonedPurchases(context.Background())
+	if err != nil || released != len(holds) {
+		t.Fatalf("ExpireAbandonedPurchases() = %d, %v, want %d", released, err, len(holds))
+	}
+}
+
+func TestLatePaymentGetsFreeSeats(t *testing.T) {
+	pt := newPaymentTest(t)
+
+	url, holds := pt.purchase(t, pt.connection, 1000, 800)
+	pt.expireHolds(t, holds)
+
+	if pt.store.holdCount() != 0 || pt.store.releasedCount() != 2 {
+		t.Fatalf("got %d holds and %d released holds, want 0 and 2", pt.store.holdCount(), pt.store.releasedCount())
+	}
+
+	if err := pt.fake.Complete(pt.sessionID(t, url)); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+
+	if tickets := pt.store.userTickets(pt.userID); len(tickets) != 2 {
+		t.Fatalf("got %d tickets, want 2", len(tickets))
+	}
+
+	if pt.store.holdCount() != 0 || pt.store.releasedCount() != 0 {
+		t.Errorf("got %d holds and %d released holds left", pt.store.holdCount(), pt.store.releasedCount())
+	}
+}
+
+func TestLatePaymentOfTakenSeatIsRefunded(t *testing.T) {
+	pt := newPaymentTest(t)
+	ctx := context.Background()
+
+	url, holds := pt.purchase(t, pt.connection, 1000, 800)
+	sessionID := pt.sessionID(t, url)
+	pt.expireHolds(t, holds)
+
+	// Someone else takes one of the seats meanwhile.
+	taken := *holds[0]
+	taken.ID = uuid.New()
+	taken.UserID = uuid.New()
+	taken.SessionID = ""
+	taken.ExpiresAt = time.Now().Add(entity.SeatHoldDuration)
+	if err := pt.tickets.repo.HoldSeats(ctx, []*entity.SeatHold{&taken}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pt.fake.Complete(sessionID); err != nil {
+		t.Fatalf("the payment was not accepted: %v", err)
+	}
+	pt.waitWebhook(t)
+
+	// The provider confirms the refund with a webhook of its own.
+	pt.settleRefunds(t, sessionID)
+
+	if tickets := pt.store.userTickets(pt.userID); len(tickets) != 0 {
+		t.Errorf("got %d tickets for the taken seats", len(tickets))
+	}
+
+	if pt.store.releasedCount() != 0 || pt.store.holdCount() != 1 {
+		t.Errorf("got %d holds and %d released holds, want only the new hold", pt.store.holdCount(), pt.store.releasedCount())
+	}
+
+	unfulfilled, ok := pt.store.unfulfilledPayment(sessionID)
+	if !ok || unfulfilled.Amount != 1800 || unfulfilled.ProviderRefundID == "" {
+		t.Errorf("unexpected unfulfilled payment %+v", unfulfilled)
+	}
+
+	if status, _ := pt.fake.FetchStatus(ctx, sessionID); status != payment.StatusRefunded {
+		t.Errorf("status = %s, want %s", status, payment.StatusRefunded)
+	}
+}
+
+func TestCheckoutExpiresBeforeHold(t *testing.T) {
+	pt := newPaymentTest(t)
+
+	url, holds := pt.purchase(t, pt.connection, 1000)
+	sessionID := pt.sessionID(t, url)
+
+	// The hold outlives the checkout, so a late payment still finds it.
+	if !holds[0].CheckoutExpiresAt().Before(holds[0].ExpiresAt) {
+		t.Error("the checkout expires after the hold")
+	}
+
+	if err := pt.fake.Complete(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	pt.waitWebhook(t)
+
+	if len(pt.store.userTickets(pt.userID)) != 1 {
+		t.Error("the payment did not issue the ticket")
+	}
+}
+
+func TestForgedWebhook(t *testing.T) {
+	pt := newPaymentTest(t)
+
+	url, _ := pt.purchase(t, pt.connection, 1000)
+	payload := []byte(`{"id":"evt_forged","type":"checkout.session.completed","sessionId":"` + pt.sessionID(t, url) + `"}`)
+
+	header := http.Header{}
+	header.Set("X-Fake-Signature", "00")
+	err := pt.tickets.ProcessWebhook(context.Background(), payload, header)
+	assertProblem(t, err, "invalid-webhook-signature")
+
+	if len(pt.store.userTickets(pt.userID)) != 0 || pt.store.holdCount() != 1 {
+		t.Error("a forged webhook issued the tickets")
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/payment"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Rebooking interface {
+	Rebook(ctx context.Context, userID uuid.UUID, ticketIDStr string, request entity.RebookJSON) (entity.Rebooking, error)
+}
+
+type rebookingServiceImpl struct {
+	repo     repo.Ticket
+	refunds  repo.Refund
+	provider payment.Provider
+	waitlist Waitlist
+	policy   entity.RebookingPolicy
+}
+
+func (s *rebookingServiceImpl) Rebook(ctx context.Context, userID uuid.UUID, ticketIDStr string, request entity.RebookJSON) (entity.Rebooking, error) {
+	ticketID, err := uuid.Parse(ticketIDStr)
+	if err != nil {
+		return entity.Rebooking{}, rfc7807.UUID(err.Error())
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, ticketID)
+	if err != nil {
+		return entity.Rebooking{}, err
+	}
+
+	if ticket.UserID != userID {
+		return entity.Rebooking{}, rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+	}
+
+	if ticket.ConnectionID == request.ConnectionID {
+		return entity.Rebooking{}, rfc7807.BadRequest("same-connection", "Same Connection Error", "The ticket is already issued for this connection.")
+	}
+
+	current, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return entity.Rebooking{}, err
+	}
+	current = current.OnSegment(ticket.Segment())
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, request.ConnectionID)
+	if err != nil {
+		return entity.Rebooking{}, err
+	}
+
+	// The ticket keeps its cities on the new connection.
+	segment, ok := connection.FindSegment(current.SegmentCities(current.Segment))
+	if !ok {
+		return entity.Rebooking{}, rfc7807.BadRequest("different-route", "Different Route Error", "The connection does not call at the stations of the ticket.")
+	}
+	connection = connection.OnSegment(segment)
+
+	now := time.Now()
+	if !s.policy.CanChange(current.DepartureTime, now) || !s.policy.CanChange(connection.DepartureTime, now) {
+		return entity.Rebooking{}, rfc7807.BadRequest("change-window-closed", "Change Window Closed Error",
+			fmt.Sprintf("Tickets can only be changed more than %s before the departure.", s.policy.ChangeBefore))
+	}
+
+	if connection.DepartureCountryID != current.DepartureCountryID || connection.DestinationCountryID != current.DestinationCountryID {
+		return entity.Rebooking{}, rfc7807.BadRequest("different-route", "Different Route Error", "The ticket can only be moved to a connection between the same countries.")
+	}
+
+	if !slices.ContainsFunc(connection.Bus.Seats, func(seat entity.Seat) bool { return seat.ID == request.SeatID }) {
+		return entity.Rebooking{}, rfc7807.BadRequest("non-existing-seat", "Non-existing Seat Error", request.SeatID.String()+" does not belong to the connection bus.")
+	}
+
+	surcharges, err := s.repo.GetSeatSurcharges(ctx, connection.BusID, connection.Line)
+	if err != nil {
+		return entity.Rebooking{}, err
+	}
+
+	// The discount and the extras of the original purchase carry over to the new seat.
+	price := max(connection.Fare(ticket.Passenger.Category)+connection.Bus.SeatSurcharges(surcharges)[request.SeatID]-ticket.TicketPayment.Discount, 0) +
+		ticket.ExtrasPrice()
+
+	rebooking := entity.Rebooking{
+		TicketID:     ticket.ID,
+		ConnectionID: connection.ID,
+		SeatID:       request.SeatID,
+		Price:        price,
+		Difference:   price - ticket.TicketPayment.Price,
+	}
+
+	if rebooking.Difference > 0 {
+		return rebooking, s.rebookWithPayment(ctx, ticket, &connection, &rebooking)
+	}
+
+	err = s.rebookWithRefund(ctx, ticket, &connection, &rebooking)
+	if err != nil {
+		return entity.Rebooking{}, err
+	}
+
+	s.waitlist.OfferFreedSeats(ctx, []uuid.UUID{ticket.ConnectionID})
+	return rebooking, nil
+}
+
+// rebookWithPayment holds the new seat until the fare difference is paid; the
+// ticket moves when the payment webhook converts the hold.
+func (s *rebookingServiceImpl) rebookWithPayment(ctx context.Context, ticket entity.Ticket, connection *entity.Connection, rebooking *entity.Rebooking) error {
+	expiresAt := time.Now().Add(entity.SeatHoldDuration)
+
+	hold := &entity.SeatHold{
+		ID:               uuid.New(),
+		ConnectionID:     rebooking.ConnectionID,
+		FromStation:      connection.Segment.From,
+		ToStation:        connection.Segment.To,
+		SeatID:           rebooking.SeatID,
+		UserID:           ticket.UserID,
+		PassengerID:      ticket.PassengerID,
+		PickUpAdressID:   ticket.PickUpAdressID,
+		DropOffAdressID:  ticket.DropOffAdressID,
+		PhoneNumber:      ticket.PhoneNumber,
+		Email:            ticket.Email,
+		Price:            rebooking.Price,
+		Discount:         ticket.TicketPayment.Discount,
+		ExpiresAt:        expiresAt,
+		RebookedTicketID: uuid.NullUUID{UUID: ticket.ID, Valid: true},
+	}
+	hold.CarryExtras(ticket)
+
+	err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+		err := s.lockTicket(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.lockSeat(ctx, connection, rebooking.SeatID)
+		if err != nil {
+			return err
+		}
+
+		err = s.checkExtras(ctx, connection, hold)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.HoldSeats(ctx, []*entity.SeatHold{hold})
+	})
+	if err != nil {
+		return err
+	}
+
+	session, err := s.provider.CreateSession(ctx, payment.Checkout{
+		LineItems: []payment.LineItem{{
+			Name:     fmt.Sprintf("Ticket change: %s %s", ticket.Passenger.FirstName, ticket.Passenger.LastName),
+			Amount:   int64(rebooking.Difference),
+			Quantity: 1,
+		}},
+		ExpiresAt: hold.CheckoutExpiresAt(),
+	})
+	if err != nil {
+		s.repo.ReleaseSeats(ctx, []uuid.UUID{hold.ID})
+		return rfc7807.BadGateway("payment", "Payment Error", err.Error())
+	}
+
+	err = s.repo.AttachPaymentSession(ctx, []uuid.UUID{hold.ID}, session.ID)
+	if err != nil {
+		s.repo.ReleaseSeats(ctx, []uuid.UUID{hold.ID})
+		return err
+	}
+
+	rebooking.PaymentURL = session.URL
+	return nil
+}
+
+// rebookWithRefund moves the ticket right away and returns the fare
+// difference, if any, through rebooking refunds split between the payments of
+// the ticket.
+func (s *rebookingServiceImpl) rebookWithRefund(ctx context.Context, ticket entity.Ticket, connection *entity.Connection, rebooking *entity.Rebooking) error {
+	var refunds []entity.Refaund
+	err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+		err := s.lockTicket(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.lockSeat(ctx, connection, rebooking.SeatID)
+		if err != nil {
+			return err
+		}
+
+		var hold entity.SeatHold
+		hold.CarryExtras(ticket)
+		err = s.checkExtras(ctx, connection, &hold)
+		if err != nil {
+			return err
+		}
+
+		err = s.repo.ChangeConnection(ctx, ticket.ID, rebooking.ConnectionID, rebooking.SeatID, connection.Segment, rebooking.Price)
+		if err != nil || rebooking.Difference == 0 {
+			return err
+		}
+
+		existing, err := s.refunds.GetByTicket(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+
+		refunds = entity.SplitRefund(ticket, -rebooking.Difference, existing, entity.RefaundReasonRebooking)
+
+		// Cash can only be given back in person, so the change is left to
+		// the office or the driver.
+		if slices.ContainsFunc(refunds, func(refund entity.Refaund) bool { return refund.PaymentIntentID == "" }) {
+			return rfc7807.BadRequest("non-refundable-payment", "Non-refundable Payment Error", "The ticket was paid in cash, ask the office or the driver to cancel it instead.")
+		}
+
+		return createRefunds(ctx, s.refunds, refunds)
+	})
+	if err != nil || refunds == nil {
+		return err
+	}
+
+	processRefunds(ctx, s.refunds, s.provider, refunds)
+	rebooking.Refunds = refunds
+	return nil
+}
+
+// lockTicket locks the ticket for the rebooking and checks that nothing else
+// is changing it: a cancellation or another rebooking waiting for payment.
+func (s *rebookingServiceImpl) lockTicket(ctx context.Context, ticketID uuid.UUID) error {
+	_, err := s.refunds.LockTicket(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+
+	cancelled, err := s.refunds.HasActive(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		return rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket has already been cancelled.")
+	}
+
+	pending, err := s.repo.HasPendingRebooking(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+
+	if pending {
+		return rfc7807.New(http.StatusConflict, "rebooking-pending", "Rebooking Pending Error", "The ticket is already being moved; pay for that change or let it expire first.")
+	}
+
+	return nil
+}
+
+func (s *rebookingServiceImpl) lockSeat(ctx context.Context, connection *entity.Connection, seatID uuid.UUID) error {
+	takenSeats, err := s.repo.LockSeats(ctx, connection.ID, connection.Segment)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(takenSeats, seatID) {
+		return rfc7807.BadRequest("taken-seat", "Taken Seat Error", seatID.String()+" is already taken.")
+	}
+
+	reserved, err := s.repo.ReservedSeats(ctx, connection.ID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+
+	if len(connection.Bus.Seats)-len(takenSeats)-reserved < 1 {
+		return rfc7807.BadRequest("reserved-seats", "Reserved Seats Error", "The remaining seats are reserved for the waitlist.")
+	}
+
+	return nil
+}
+
+func NewRebookingService(repo repo.Ticket, refunds repo.Refund, provider payment.Provider, waitlist Waitlist, policy entity.RebookingPolicy) Rebooking {
+	return &rebookingServiceImpl{
+		repo,
+		refunds,
+		provider,
+		waitlist,
+		policy,
+	}
+}
+
+// checkExtras makes sure the new connection has room for the extras the
+// ticket brings along. It runs after the seat is locked, so the connection is
+// locked as well.
+func (s *rebookingServiceImpl) checkExtras(ctx context.Context, connection *entity.Connection, hold *entity.SeatHold) error {
+	if len(hold.Extras) == 0 {
+		return nil
+	}
+
+	var ids = make([]uuid.UUID, 0, len(hold.Extras))
+	for _, extra := range hold.Extras {
+		if !slices.Contains(ids, extra.ExtraID) {
+			ids = append(ids, extra.ExtraID)
+		}
+	}
+
+	catalog, err := s.repo.GetExtras(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	booked, err := s.repo.GetBookedExtras(ctx, connection.ID)
+	if err != nil {
+		return err
+	}
+
+	return entity.CheckExtrasCapacity(connection.Bus.LuggageCapacity, catalog, booked, []*entity.SeatHold{hold})
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/payment"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Refund interface {
+	Cancel(ctx context.Context, userID uuid.UUID, ticketIDStr string) ([]entity.Refaund, error)
+	CancelForCash(ctx context.Context, employeeID uuid.UUID, byDriver bool, ticketIDStr string) (entity.CashTransaction, error)
+	GetRefunds(ctx context.Context, paginationStr dbutil.PaginationStr, filter RefundFilter) ([]entity.Refaund, hypermedia.Links, error)
+	Approve(ctx context.Context, idStr string) error
+	Reject(ctx context.Context, idStr string) error
+}
+
+type RefundFilter struct {
+	Status   string
+	TicketID string
+}
+
+type refundServiceImpl struct {
+	repo     repo.Refund
+	provider payment.Provider
+	policy   entity.CancellationPolicy
+}
+
+// Cancel requests the refund of the ticket under the cancellation policy; the
+// refund is split between the payments the ticket was paid with.
+func (s *refundServiceImpl) Cancel(ctx context.Context, userID uuid.UUID, ticketIDStr string) ([]entity.Refaund, error) {
+	ticketID, err := uuid.Parse(ticketIDStr)
+	if err != nil {
+		return nil, rfc7807.UUID(err.Error())
+	}
+
+	var refunds []entity.Refaund
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		// The ticket stays locked until the refund is stored, so concurrent
+		// cancellations cannot both find it without an active refund.
+		_, err := s.repo.LockTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		ticket, err := s.repo.GetTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		if ticket.UserID != userID {
+			return rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+		}
+
+		active, err := s.repo.HasActive(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		if active {
+			return rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket has already been cancelled.")
+		}
+
+		connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+		if err != nil {
+			return err
+		}
+
+		amount, ok := s.policy.RefundableAmount(ticket.TicketPayment.Price, connection.DepartureTime, time.Now())
+		if !ok {
+			return rfc7807.BadRequest("non-refundable-ticket", "Non-refundable Ticket Error", "The ticket can not be cancelled after the departure.")
+		}
+
+		// Cash is given back in person, where the ticket has to be cancelled.
+		if amount > 0 && ticket.TicketPayment.PaymentIntentID == "" {
+			return rfc7807.BadRequest("non-refundable-payment", "Non-refundable Payment Error", "The ticket was paid in cash, ask the office or the driver to cancel it.")
+		}
+
+		existing, err := s.repo.GetByTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		refunds = entity.SplitRefund(ticket, amount, existing, entity.RefaundReasonCancellation)
+		err = createRefunds(ctx, s.repo, refunds)
+		if err != nil {
+			return err
+		}
+
+		// Nothing is given back, so there is nothing for the provider to do.
+		if amount == 0 {
+			refunds[0].Status = entity.RefaundStatusCompleted
+			return s.repo.CompleteWithoutPayment(ctx, refunds[0].ID, "Cancelled without a refund under the cancellation policy.")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	processRefunds(ctx, s.repo, s.provider, refunds)
+	return refunds, nil
+}
+
+func createRefunds(ctx context.Context, refunds repo.Refund, parts []entity.Refaund) error {
+	for i := range parts {
+		err := refunds.Create(ctx, &parts[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processRefunds starts the refunds still requested; a failed provider call
+// leaves the refund requested, so an admin can approve it again later.
+func processRefunds(ctx context.Context, refunds repo.Refund, provider payment.Provider, parts []entity.Refaund) {
+	for i := range parts {
+		if parts[i].Status == entity.RefaundStatusRequested && processRefund(ctx, refunds, provider, parts[i]) == nil {
+			parts[i].Status = entity.RefaundStatusProcessing
+		}
+	}
+}
+
+func (s *refundServiceImpl) Approve(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	refund, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if refund.Status != entity.RefaundStatusRequested {
+		return rfc7807.BadRequest("invalid-refund-status", "Invalid Refund Status Error", "Only requested refunds can be approved.")
+	}
+
+	return processRefund(ctx, s.repo, s.provider, refund)
+}
+
+func (s *refundServiceImpl) Reject(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.repo.Reject(ctx, id)
+}
+
+// processRefund marks the refund as processing before calling the provider, so
+// a second approval cannot refund it again. Completion happens on the webhook
+// of the provider refund, whichever of it and the attached id comes last.
+func processRefund(ctx context.Context, refunds repo.Refund, provider payment.Provider, refund entity.Refaund) error {
+	if refund.PaymentIntentID == "" {
+		return rfc7807.BadRequest("non-refundable-payment", "Non-refundable Payment Error", "The ticket has not been paid through the payment provider.")
+	}
+
+	err := refunds.StartProcessing(ctx, refund.ID)
+	if err != nil {
+		return err
+	}
+
+	providerRefundID, err := provider.Refund(ctx, refund.PaymentIntentID, int64(refund.Amount))
+	if err != nil {
+		refunds.StopProcessing(ctx, refund.ID)
+		return rfc7807.BadGateway("payment", "Payment Error", err.Error())
+	}
+
+	return refunds.AttachProviderRefund(ctx, refund.ID, providerRefundID)
+}
+
+func (s *refundServiceImpl) GetRefunds(ctx context.Context, paginationStr dbutil.PaginationStr, filter RefundFilter) ([]entity.Refaund, hypermedia.Links, error) {
+	var conditions []string
+	var values []any
+
+	if filter.Status != "" {
+		status, ok := entity.ParseRefaundStatus(filter.Status)
+		if !ok {
+			return nil, nil, rfc7807.BadRequest("invalid-refund-status", "Invalid Refund Status Error", "Non-existing refund status.")
+		}
+		conditions = append(conditions, "status = ?")
+		values = append(values, status)
+	}
+
+	if filter.TicketID != "" {
+		ticketID, err := uuid.Parse(filter.TicketID)
+		if err != nil {
+			return nil, nil, rfc7807.UUID(err.Error())
+		}
+		conditions = append(conditions, "ticket_id = ?")
+		values = append(values, ticketID)
+	}
+
+	var pagination dbutil.Pagination
+	var err error
+	if len(conditions) == 0 {
+		pagination, err = paginationStr.Parse([]string{}, "created_at", "amount")
+	} else {
+		pagination, err = paginationStr.ParseWithCondition(dbutil.Condition{strings.Join(conditions, " AND "), values}, []string{}, "created_at", "amount")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refunds, total, err, empty := s.repo.GetRefunds(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return refunds, hypermedia.Pagination(paginationStr, total,
+		hypermedia.DefaultParam{"status", "", filter.Status},
+		hypermedia.DefaultParam{"ticket_id", "", filter.TicketID},
+	), nil
+}
+
+func NewRefundService(repo repo.Refund, provider payment.Provider, policy entity.CancellationPolicy) Refund {
+	return &refundServiceImpl{
+		repo,
+		provider,
+		policy,
+	}
+}
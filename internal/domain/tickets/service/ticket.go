@@ -2,32 +2,174 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"maryan_api/config"
 	"maryan_api/internal/domain/tickets/repo"
 	"maryan_api/internal/entity"
-	"maryan_api/internal/infrastructure/clients/stripe"
+	"maryan_api/internal/infrastructure/clients/payment"
 	"maryan_api/pkg/dbutil"
 	"maryan_api/pkg/hypermedia"
 	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/d3code/uuid"
 )
 
 type Ticket interface {
 	Purchase(ctx context.Context, userID uuid.UUID, newTicket entity.NewTicketJSON) (string, error)
-	PurchaseFailed(ctx context.Context, id string) error
-	PurchaseSucceded(ctx context.Context, id string) error
-	GetTickets(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID) ([]entity.CustomerTicket, hypermedia.Links, error)
+	PurchaseItinerary(ctx context.Context, userID uuid.UUID, newTicket entity.NewItineraryTicketJSON) (string, error)
+	ProcessWebhook(ctx context.Context, payload []byte, header http.Header) error
+	GetTickets(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID, status string) ([]entity.CustomerTicket, hypermedia.Links, error)
+	GetAdminTickets(ctx context.Context, paginationStr dbutil.PaginationStr, filter TicketFilter) ([]entity.CustomerTicket, hypermedia.Links, error)
+	BoardingPass(ctx context.Context, userID uuid.UUID, ticketIDStr string) ([]byte, error)
+	SellForCash(ctx context.Context, employeeID uuid.UUID, byDriver bool, sale entity.CashSaleJSON) ([]*entity.Ticket, error)
+	ExpireAbandonedPurchases(ctx context.Context) (int, error)
 }
 
 type serviceImpl struct {
-	repo repo.Ticket
+	repo            repo.Ticket
+	provider        payment.Provider
+	waitlist        Waitlist
+	boardingPassKey []byte
+	waitlistKey     []byte
 }
 
-func (s *serviceImpl) GetTickets(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID) ([]entity.CustomerTicket, hypermedia.Links, error) {
-	pagination, err := paginationStr.ParseWithCondition(dbutil.Condition{"user_id = ?", []any{userID}}, []string{}, "created_at")
+func (s *serviceImpl) BoardingPass(ctx context.Context, userID uuid.UUID, ticketIDStr string) ([]byte, error) {
+	ticketID, err := uuid.Parse(ticketIDStr)
 	if err != nil {
+		return nil, rfc7807.UUID(err.Error())
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.UserID != userID {
+		return nil, rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+	}
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf, err := renderBoardingPass(ticket, connection, ticket.BoardingToken(s.boardingPassKey))
+	if err != nil {
+		return nil, rfc7807.New(http.StatusInternalServerError, "boarding-pass", "Boarding Pass Error", err.Error())
+	}
+
+	return pdf, nil
+}
+
+type TicketFilter struct {
+	Status        string
+	UserID        string
+	ConnectionID  string
+	DepartureFrom string
+	DepartureTo   string
+}
+
+// ticketSearch matches the passenger name, the contact info and the payment
+// session of a ticket.
+var ticketSearch = []string{
+	"(SELECT CONCAT(first_name, ' ', last_name) FROM passengers WHERE passengers.id = tickets.passenger_id)",
+	"email",
+	"phone_number",
+	"(SELECT session_id FROM ticket_payments WHERE ticket_payments.ticket_id = tickets.id)",
+}
+
+// GetTickets lists the tickets of the customer; without a status filter only
+// the tickets still holding a seat are returned.
+func (s *serviceImpl) GetTickets(ctx context.Context, paginationStr dbutil.PaginationStr, userID uuid.UUID, status string) ([]entity.CustomerTicket, hypermedia.Links, error) {
+	var conditions = []string{"user_id = ?"}
+	var values = []any{userID}
+
+	if status == "" {
+		conditions = append(conditions, "status IN (?)")
+		values = append(values, entity.ActiveTicketStatuses)
+	} else {
+		parsed, ok := entity.ParseTicketStatus(status)
+		if !ok {
+			return nil, nil, rfc7807.BadRequest("invalid-ticket-status", "Invalid Ticket Status Error", "Non-existing ticket status.")
+		}
+		conditions = append(conditions, "status = ?")
+		values = append(values, parsed)
+	}
+
+	return s.findTickets(ctx, paginationStr, dbutil.Condition{strings.Join(conditions, " AND "), values}, []string{},
+		hypermedia.DefaultParam{"status", "", status},
+	)
+}
+
+func (s *serviceImpl) GetAdminTickets(ctx context.Context, paginationStr dbutil.PaginationStr, filter TicketFilter) ([]entity.CustomerTicket, hypermedia.Links, error) {
+	var conditions []string
+	var values []any
+
+	if filter.Status != "" {
+		status, ok := entity.ParseTicketStatus(filter.Status)
+		if !ok {
+			return nil, nil, rfc7807.BadRequest("invalid-ticket-status", "Invalid Ticket Status Error", "Non-existing ticket status.")
+		}
+		conditions = append(conditions, "status = ?")
+		values = append(values, status)
+	}
+
+	for _, idFilter := range []struct {
+		column string
+		value  string
+	}{{"user_id", filter.UserID}, {"connection_id", filter.ConnectionID}} {
+		if idFilter.value == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(idFilter.value)
+		if err != nil {
+			return nil, nil, rfc7807.UUID(err.Error())
+		}
+		conditions = append(conditions, idFilter.column+" = ?")
+		values = append(values, id)
+	}
+
+	for _, dateFilter := range []struct {
+		condition string
+		value     string
+		days      int
+	}{{"departure_time >= ?", filter.DepartureFrom, 0}, {"departure_time < ?", filter.DepartureTo, 1}} {
+		if dateFilter.value == "" {
+			continue
+		}
 
+		date, err := time.Parse("2006-01-02", dateFilter.value)
+		if err != nil {
+			return nil, nil, rfc7807.BadRequest("invalid-date", "Invalid Date Error", "The departure dates have to be in the YYYY-MM-DD format.")
+		}
+		conditions = append(conditions, "connection_id IN (SELECT id FROM connections WHERE "+dateFilter.condition+")")
+		values = append(values, date.AddDate(0, 0, dateFilter.days))
+	}
+
+	return s.findTickets(ctx, paginationStr, dbutil.Condition{strings.Join(conditions, " AND "), values}, ticketSearch,
+		hypermedia.DefaultParam{"status", "", filter.Status},
+		hypermedia.DefaultParam{"user_id", "", filter.UserID},
+		hypermedia.DefaultParam{"connection_id", "", filter.ConnectionID},
+		hypermedia.DefaultParam{"departure_from", "", filter.DepartureFrom},
+		hypermedia.DefaultParam{"departure_to", "", filter.DepartureTo},
+	)
+}
+
+func (s *serviceImpl) findTickets(ctx context.Context, paginationStr dbutil.PaginationStr, condition dbutil.Condition, search []string, params ...hypermedia.DefaultParam) ([]entity.CustomerTicket, hypermedia.Links, error) {
+	var pagination dbutil.Pagination
+	var err error
+	if condition.Where == "" {
+		pagination, err = paginationStr.Parse(search, "created_at")
+	} else {
+		pagination, err = paginationStr.ParseWithCondition(condition, search, "created_at")
+	}
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -53,111 +195,658 @@ func (s *serviceImpl) GetTickets(ctx context.Context, paginationStr dbutil.Pagin
 		}
 	}
 
-	return respose, hypermedia.Pagination(paginationStr, total), nil
+	return respose, hypermedia.Pagination(paginationStr, total, params...), nil
+}
+
+func (s *serviceImpl) ProcessWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	event, err := s.provider.ParseWebhook(payload, header)
+	if err != nil {
+		return rfc7807.BadRequest("invalid-webhook-signature", "Invalid Webhook Signature Error", err.Error())
+	}
+
+	freed, err := s.processPaymentEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	s.waitlist.OfferFreedSeats(ctx, freed)
+	return nil
+}
+
+// processPaymentEvent records the event and applies it in one transaction, so a
+// failed attempt leaves nothing behind and the provider's retry starts over.
+// It returns the connections the event freed seats on.
+func (s *serviceImpl) processPaymentEvent(ctx context.Context, event entity.PaymentEvent) ([]uuid.UUID, error) {
+	var freed []uuid.UUID
+	err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+		registered, err := s.repo.RegisterPaymentEvent(ctx, &event)
+		if err != nil || !registered {
+			return err
+		}
+
+		switch event.Type {
+		case entity.PaymentEventCheckoutCompleted:
+			freed, err = s.purchaseSucceded(ctx, event)
+		case entity.PaymentEventCheckoutExpired:
+			freed, err = s.purchaseFailed(ctx, event.SessionID)
+		case entity.PaymentEventRefundSucceeded:
+			freed, err = s.refunded(ctx, event)
+		}
+
+		return err
+	})
+
+	return freed, err
+}
+
+// refunded completes the refund requested through the API the provider gave
+// the id to. A refund issued directly at the provider matches none of them and
+// leaves the tickets alone, as it does not tell which of them it pays back.
+func (s *serviceImpl) refunded(ctx context.Context, event entity.PaymentEvent) ([]uuid.UUID, error) {
+	return s.repo.CompleteRefund(ctx, event.PaymentIntentID, event.RefundID)
+}
+
+// ExpireAbandonedPurchases releases the seats of checkouts that ran out of
+// time without the provider reporting back and offers them to the waitlist.
+func (s *serviceImpl) ExpireAbandonedPurchases(ctx context.Context) (int, error) {
+	released, freed, err := s.repo.ReleaseExpiredSeats(ctx, time.Now())
+	if err != nil || released == 0 {
+		return released, err
+	}
+
+	s.waitlist.OfferFreedSeats(ctx, freed)
+	return released, nil
 }
 
-func (s *serviceImpl) PurchaseFailed(ctx context.Context, id string) error {
-	return s.repo.DeleteTickets(ctx, id)
+func (s *serviceImpl) purchaseFailed(ctx context.Context, sessionID string) ([]uuid.UUID, error) {
+	return s.repo.ReleaseSeatsBySession(ctx, sessionID)
 }
 
-func (s *serviceImpl) PurchaseSucceded(ctx context.Context, id string) error {
-	return s.repo.AddTickets(ctx, id)
+// purchaseSucceded issues the tickets paid for in the checkout. Its holds may
+// have run out before the payment was reported; the seats are then held again
+// if they are still free, or else the payment is refunded, as no retry of the
+// event would bring them back.
+func (s *serviceImpl) purchaseSucceded(ctx context.Context, event entity.PaymentEvent) ([]uuid.UUID, error) {
+	held, err := s.repo.Rehold(ctx, event.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !held {
+		return s.refundUnfulfilled(ctx, event)
+	}
+
+	return s.repo.AddTickets(ctx, event.SessionID, event.PaymentIntentID)
 }
 
-func (s *serviceImpl) Purchase(ctx context.Context, userID uuid.UUID, newTicket entity.NewTicketJSON) (string, error) {
-	email, phoneNumber, err := newTicket.ParseContaanctInfo()
+// refundUnfulfilled records the payment whose seats went to someone else,
+// releases what is left of its checkout and refunds the payment in full.
+func (s *serviceImpl) refundUnfulfilled(ctx context.Context, event entity.PaymentEvent) ([]uuid.UUID, error) {
+	log.Printf("checkout %s was paid after its seats had been taken, refunding %d", event.SessionID, event.AmountTotal)
 
-	connection, takenSeats, err := s.repo.GetConnectionByID(ctx, newTicket.ConnectionID)
+	unfulfilled := entity.UnfulfilledPayment{
+		SessionID:       event.SessionID,
+		PaymentIntentID: event.PaymentIntentID,
+		Amount:          event.AmountTotal,
+	}
+
+	err := s.repo.RecordUnfulfilledPayment(ctx, &unfulfilled)
+	if err != nil {
+		return nil, err
+	}
+
+	freed, err := s.repo.ReleaseSeatsBySession(ctx, event.SessionID)
+	if err != nil || unfulfilled.Amount == 0 {
+		return freed, err
+	}
+
+	providerRefundID, err := s.provider.Refund(ctx, unfulfilled.PaymentIntentID, unfulfilled.Amount)
+	if err != nil {
+		return nil, rfc7807.BadGateway("payment", "Payment Error", err.Error())
+	}
+
+	return freed, s.repo.AttachUnfulfilledRefund(ctx, event.SessionID, providerRefundID)
+}
+
+func (s *serviceImpl) Purchase(ctx context.Context, userID uuid.UUID, newTicket entity.NewTicketJSON) (string, error) {
+	var free bool
+	holds, passengers, err := s.book(ctx, userID, newTicket, func(ctx context.Context, holds []*entity.SeatHold) error {
+		var err error
+		free, err = s.holdOrIssue(ctx, holds)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if len(newTicket.SeatIDs) != len(newTicket.Passengers) {
-		return "", rfc7807.BadRequest("seats-passengers", "Seats Passengers Error", "The seats number and the passengers number have to be equal.")
+	if free {
+		return config.FrontendURL() + "/profile/tickets", nil
 	}
 
-	for _, seat := range newTicket.SeatIDs {
-		if slices.Contains(takenSeats, seat) {
-			return "", rfc7807.BadRequest("taken-seat", "Taken Seat Error", seat.String()+" is already taken.")
+	return s.checkout(ctx, holds, passengers, newTicket.PromoCode, nil)
+}
+
+// holdOrIssue holds the seats until the checkout is paid. An order the promo
+// code and the loyalty points brought down to nothing has nothing to pay for
+// and the provider would reject it, so its tickets are issued right away,
+// fulfilling the waitlist offer they were bought with, and holdOrIssue
+// reports it.
+func (s *serviceImpl) holdOrIssue(ctx context.Context, holds []*entity.SeatHold) (bool, error) {
+	var total int
+	for _, hold := range holds {
+		total += hold.Price
+	}
+
+	if total > 0 {
+		return false, s.repo.HoldSeats(ctx, holds)
+	}
+
+	var tickets = make([]*entity.Ticket, len(holds))
+	for i, hold := range holds {
+		ticket := hold.ToTicket()
+		tickets[i] = &ticket
+	}
+
+	err := s.repo.IssueTickets(ctx, tickets)
+	if err != nil || !holds[0].WaitlistEntryID.Valid {
+		return true, err
+	}
+
+	return true, s.repo.FulfillWaitlistEntry(ctx, holds[0].WaitlistEntryID.UUID)
+}
+
+// checkout opens the payment session for the holds, with a line item for
+// every ticket and extra that costs anything, and releases the seats if it
+// cannot be opened. The
+// passengers and the segments, if given, are those of the holds at the same
+// index.
+func (s *serviceImpl) checkout(ctx context.Context, holds []*entity.SeatHold, passengers []*entity.Passenger, promoCode string, segments []string) (string, error) {
+	var holdIDs = make([]uuid.UUID, len(holds))
+	var lineItems = make([]payment.LineItem, 0, len(holds))
+	for i, hold := range holds {
+		holdIDs[i] = hold.ID
+		ticketItem := payment.LineItem{
+			Name:     fmt.Sprintf("%s ticket: %s %s", passengers[i].Category, passengers[i].FirstName, passengers[i].LastName),
+			Amount:   int64(hold.Price - hold.ExtrasPrice()),
+			Quantity: 1,
+		}
+
+		if segments != nil {
+			ticketItem.Name += fmt.Sprintf(" (%s)", segments[i])
+		}
+
+		if hold.PromoCodeRedemptionID.Valid {
+			ticketItem.Name += fmt.Sprintf(" (promo code %s)", strings.ToUpper(promoCode))
+		}
+
+		if hold.LoyaltyPoints > 0 {
+			ticketItem.Name += fmt.Sprintf(" (%d loyalty points)", hold.LoyaltyPoints)
+		}
+
+		// The provider rejects free line items; the ticket is paid for by
+		// the rest of the checkout.
+		if ticketItem.Amount > 0 {
+			lineItems = append(lineItems, ticketItem)
+		}
+
+		for _, extra := range hold.Extras {
+			if extra.Price == 0 {
+				continue
+			}
+
+			lineItems = append(lineItems, payment.LineItem{
+				Name:     fmt.Sprintf("%s: %s %s", extra.Name, passengers[i].FirstName, passengers[i].LastName),
+				Amount:   int64(extra.Price),
+				Quantity: int64(extra.Quantity),
+			})
 		}
 	}
 
-	pickUpAdressID, err := s.CreateAdress(ctx, newTicket.PickUpAdress, userID, connection.DepartureCountryID)
+	session, err := s.provider.CreateSession(ctx, payment.Checkout{
+		LineItems: lineItems,
+		ExpiresAt: holds[0].CheckoutExpiresAt(),
+	})
 	if err != nil {
-		return "", err
+		s.repo.ReleaseSeats(ctx, holdIDs)
+		return "", rfc7807.BadGateway("payment", "Payment Error", err.Error())
 	}
 
-	dropOffAdressID, err := s.CreateAdress(ctx, newTicket.DropOffAdress, userID, connection.DestinationCountryID)
+	err = s.repo.AttachPaymentSession(ctx, holdIDs, session.ID)
 	if err != nil {
+		s.repo.ReleaseSeats(ctx, holdIDs)
 		return "", err
 	}
 
-	var passengerIDs []uuid.UUID
-	for _, passenger := range newTicket.Passengers {
-		id, err := s.CreatePassenger(ctx, passenger, userID)
+	return session.URL, nil
+}
+
+// book validates the order and, in one transaction, locks the seats, stores
+// the addresses and passengers and applies the promo code, the loyalty points,
+// the waitlist offer and the extras; complete decides what the prepared holds
+// turn into.
+func (s *serviceImpl) book(ctx context.Context, userID uuid.UUID, newTicket entity.NewTicketJSON, complete func(ctx context.Context, holds []*entity.SeatHold) error) ([]*entity.SeatHold, []*entity.Passenger, error) {
+	email, phoneNumber, err := newTicket.ParseContaanctInfo()
+
+	connection, err := s.findLeg(ctx, newTicket.ConnectionID, newTicket.From, newTicket.To, newTicket.SeatIDs, len(newTicket.Passengers))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pickUpAdress, err := s.prepareAdress(newTicket.PickUpAdress, userID, connection.DepartureCountryID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dropOffAdress, err := s.prepareAdress(newTicket.DropOffAdress, userID, connection.DestinationCountryID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var passengers = make([]*entity.Passenger, len(newTicket.Passengers))
+	for i, newPassenger := range newTicket.Passengers {
+		passengers[i], err = preparePassenger(newPassenger, userID, connection.DepartureTime)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
-		passengerIDs = append(passengerIDs, id)
 	}
 
-	redirectURL, sessionID, err := stripe.CreateStripeCheckoutSession(int64(connection.Price) * int64(len(newTicket.SeatIDs)))
+	if newTicket.LoyaltyPoints < 0 {
+		return nil, nil, rfc7807.BadRequest("loyalty-points", "Loyalty Points Error", "The number of loyalty points cannot be negative.")
+	}
+
+	extras, err := s.getExtras(ctx, newTicket.Extras, len(passengers))
 	if err != nil {
-		return "", rfc7807.BadGateway("payment", "Payment Error", err.Error())
+		return nil, nil, err
+	}
+
+	holds, err := s.prepareHolds(ctx, connection, newTicket.SeatIDs, userID, passengers, pickUpAdress, dropOffAdress, phoneNumber, email, time.Now().Add(entity.SeatHoldDuration))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		takenSeats, err := s.lockSeats(ctx, connection, newTicket.SeatIDs)
+		if err != nil {
+			return err
+		}
+
+		var waitlistEntryID uuid.UUID
+		if newTicket.WaitlistToken != "" {
+			waitlistEntryID, err = s.useWaitlistOffer(ctx, newTicket.WaitlistToken, userID, connection.ID, len(newTicket.SeatIDs))
+			if err != nil {
+				return err
+			}
+
+			for _, hold := range holds {
+				hold.WaitlistEntryID = uuid.NullUUID{UUID: waitlistEntryID, Valid: true}
+			}
+		}
+
+		err = s.checkReserved(ctx, connection, takenSeats, len(newTicket.SeatIDs), waitlistEntryID)
+		if err != nil {
+			return err
+		}
+
+		for _, adress := range []*entity.Address{pickUpAdress, dropOffAdress} {
+			if err := s.repo.CreateAddress(ctx, adress); err != nil {
+				return err
+			}
+		}
+
+		for _, passenger := range passengers {
+			if err := s.repo.CreatePassenger(ctx, passenger); err != nil {
+				return err
+			}
+		}
+
+		if newTicket.PromoCode != "" {
+			if err := s.redeemPromoCode(ctx, newTicket.PromoCode, userID, &connection, holds); err != nil {
+				return err
+			}
+		}
+
+		if newTicket.LoyaltyPoints > 0 {
+			if err := s.redeemLoyaltyPoints(ctx, newTicket.LoyaltyPoints, userID, holds); err != nil {
+				return err
+			}
+		}
+
+		if len(newTicket.Extras) > 0 {
+			if err := s.addExtras(ctx, &connection, extras, newTicket.Extras, holds, true); err != nil {
+				return err
+			}
+		}
+
+		return complete(ctx, holds)
+	})
+
+	return holds, passengers, err
+}
+
+// findLeg returns the connection as seen on the segment between the cities
+// and checks that the seats chosen on it belong to its bus and are free.
+func (s *serviceImpl) findLeg(ctx context.Context, connectionID uuid.UUID, from, to string, seatIDs []uuid.UUID, passengers int) (entity.Connection, error) {
+	connection, _, err := s.repo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return entity.Connection{}, err
+	}
+
+	segment, ok := connection.FindSegment(from, to)
+	if !ok {
+		return entity.Connection{}, rfc7807.BadRequest("invalid-segment", "Invalid Segment Error", "The connection does not call at the cities in that order.")
+	}
+	connection = connection.OnSegment(segment)
+
+	takenSeats, err := s.repo.TakenSeats(ctx, connection.ID, segment)
+	if err != nil {
+		return entity.Connection{}, err
+	}
+
+	if len(seatIDs) == 0 {
+		return entity.Connection{}, rfc7807.BadRequest("no-seats", "No Seats Error", "At least one seat has to be chosen.")
+	}
+
+	if len(seatIDs) != passengers {
+		return entity.Connection{}, rfc7807.BadRequest("seats-passengers", "Seats Passengers Error", "The seats number and the passengers number have to be equal.")
+	}
+
+	for _, seat := range seatIDs {
+		if !slices.ContainsFunc(connection.Bus.Seats, func(busSeat entity.Seat) bool { return busSeat.ID == seat }) {
+			return entity.Connection{}, rfc7807.BadRequest("non-existing-seat", "Non-existing Seat Error", seat.String()+" does not belong to the connection bus.")
+		}
+
+		if slices.Contains(takenSeats, seat) {
+			return entity.Connection{}, rfc7807.BadRequest("taken-seat", "Taken Seat Error", seat.String()+" is already taken.")
+		}
+	}
+
+	return connection, nil
+}
+
+// prepareHolds prices a hold of the seat at the same index for every
+// passenger on the segment of the connection.
+func (s *serviceImpl) prepareHolds(ctx context.Context, connection entity.Connection, seatIDs []uuid.UUID, userID uuid.UUID, passengers []*entity.Passenger, pickUpAdress, dropOffAdress *entity.Address, phoneNumber, email string, expiresAt time.Time) ([]*entity.SeatHold, error) {
+	surcharges, err := s.repo.GetSeatSurcharges(ctx, connection.BusID, connection.Line)
+	if err != nil {
+		return nil, err
 	}
+	seatSurcharges := connection.Bus.SeatSurcharges(surcharges)
 
-	var tickets = make([]*entity.Ticket, len(passengerIDs))
-	for i, passengerID := range passengerIDs {
-		ticketID := uuid.New()
-		tickets[i] = &entity.Ticket{
-			ID:              ticketID,
+	var holds = make([]*entity.SeatHold, len(passengers))
+	for i, passenger := range passengers {
+		holds[i] = &entity.SeatHold{
+			ID:              uuid.New(),
+			ConnectionID:    connection.ID,
+			FromStation:     connection.Segment.From,
+			ToStation:       connection.Segment.To,
+			SeatID:          seatIDs[i],
 			UserID:          userID,
+			PassengerID:     passenger.ID,
+			PickUpAdressID:  pickUpAdress.ID,
+			DropOffAdressID: dropOffAdress.ID,
 			PhoneNumber:     phoneNumber,
 			Email:           email,
-			ConnectionID:    connection.ID,
-			SeatID:          newTicket.SeatIDs[i],
-			PassengerID:     passengerID,
-			PickUpAdressID:  pickUpAdressID,
-			DropOffAdressID: dropOffAdressID,
-			TicketPayment: entity.TicketPayment{
-				TicketID:  ticketID,
-				Price:     connection.Price,
-				Method:    entity.PaymentMethodCard,
-				SessionID: sessionID,
-			},
+			Price:           connection.Fare(passenger.Category) + seatSurcharges[seatIDs[i]],
+			ExpiresAt:       expiresAt,
 		}
 	}
 
-	err = s.repo.SaveTickets(ctx, tickets)
+	return holds, nil
+}
+
+// lockSeats locks the connection and checks the seats are still free on its
+// segment; it has to run inside the purchase transaction.
+func (s *serviceImpl) lockSeats(ctx context.Context, connection entity.Connection, seatIDs []uuid.UUID) ([]uuid.UUID, error) {
+	takenSeats, err := s.repo.LockSeats(ctx, connection.ID, connection.Segment)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	for _, seat := range seatIDs {
+		if slices.Contains(takenSeats, seat) {
+			return nil, rfc7807.BadRequest("taken-seat", "Taken Seat Error", seat.String()+" is already taken.")
+		}
+	}
+
+	return takenSeats, nil
+}
+
+// checkReserved makes sure the purchase leaves the seats reserved for the
+// waitlist alone, unless it uses the offer of the waitlist entry.
+func (s *serviceImpl) checkReserved(ctx context.Context, connection entity.Connection, takenSeats []uuid.UUID, seats int, waitlistEntryID uuid.UUID) error {
+	reserved, err := s.repo.ReservedSeats(ctx, connection.ID, waitlistEntryID)
+	if err != nil {
+		return err
+	}
+
+	if len(connection.Bus.Seats)-len(takenSeats)-reserved < seats {
+		return rfc7807.BadRequest("reserved-seats", "Reserved Seats Error", "The remaining seats are reserved for the waitlist.")
+	}
+
+	return nil
+}
+
+// useWaitlistOffer checks the offer sent to the user, so the reserved seats
+// can be bought; the offer stays open until the tickets are issued, and
+// again once their checkout is abandoned. It has to run inside the purchase
+// transaction.
+func (s *serviceImpl) useWaitlistOffer(ctx context.Context, token string, userID, connectionID uuid.UUID, seats int) (uuid.UUID, error) {
+	entryID, ok := entity.ParseWaitlistOfferToken(token, s.waitlistKey)
+	if !ok {
+		return uuid.Nil, rfc7807.BadRequest("invalid-waitlist-token", "Invalid Waitlist Token Error", "The waitlist offer is not valid.")
+	}
+
+	entry, err := s.repo.LockWaitlistEntry(ctx, entryID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if entry.UserID != userID || entry.ConnectionID != connectionID {
+		return uuid.Nil, rfc7807.BadRequest("invalid-waitlist-token", "Invalid Waitlist Token Error", "The waitlist offer is not valid.")
+	}
+
+	if !entry.IsOffered(time.Now()) {
+		return uuid.Nil, rfc7807.BadRequest("expired-waitlist-offer", "Expired Waitlist Offer Error", "The waitlist offer is no longer available.")
+	}
+
+	if seats > entry.PartySize {
+		return uuid.Nil, rfc7807.BadRequest("waitlist-party-size", "Waitlist Party Size Error", "The waitlist offer does not cover that many seats.")
+	}
+
+	held, err := s.repo.IsWaitlistOfferHeld(ctx, entry.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if held {
+		return uuid.Nil, rfc7807.BadRequest("waitlist-offer-in-use", "Waitlist Offer In Use Error", "The waitlist offer is already being paid for.")
 	}
 
-	return redirectURL, nil
+	return entry.ID, nil
 }
 
-func (s *serviceImpl) CreateAdress(ctx context.Context, newAdress entity.NewAddress, userID uuid.UUID, countryID uuid.UUID) (uuid.UUID, error) {
+// redeemPromoCode validates the code under a row lock and applies its
+// discount to the holds; it has to run inside the purchase transaction.
+func (s *serviceImpl) redeemPromoCode(ctx context.Context, code string, userID uuid.UUID, connection *entity.Connection, holds []*entity.SeatHold) error {
+	promoCode, err := s.repo.LockPromoCode(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return err
+	}
+
+	redemptions, userRedemptions, err := s.repo.CountPromoCodeRedemptions(ctx, promoCode.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = promoCode.CheckRedeemable(connection, time.Now(), redemptions, userRedemptions)
+	if err != nil {
+		return err
+	}
+
+	redemption := entity.PromoCodeRedemption{
+		ID:          uuid.New(),
+		PromoCodeID: promoCode.ID,
+		UserID:      userID,
+	}
+
+	err = s.repo.RedeemPromoCode(ctx, &redemption)
+	if err != nil {
+		return err
+	}
+
+	var prices = make([]int, len(holds))
+	for i, hold := range holds {
+		prices[i] = hold.Price
+	}
+
+	for i, discount := range promoCode.Discounts(prices) {
+		holds[i].Price -= discount
+		holds[i].Discount = discount
+		holds[i].PromoCodeRedemptionID = uuid.NullUUID{UUID: redemption.ID, Valid: true}
+	}
+
+	return nil
+}
+
+// redeemLoyaltyPoints spends the points on the holds under a lock of the
+// user's balance; it has to run inside the purchase transaction.
+func (s *serviceImpl) redeemLoyaltyPoints(ctx context.Context, points int, userID uuid.UUID, holds []*entity.SeatHold) error {
+	balance, err := s.repo.LockLoyaltyBalance(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if points > balance {
+		return rfc7807.BadRequest("insufficient-loyalty-points", "Insufficient Loyalty Points Error", fmt.Sprintf("Only %d loyalty points are available.", balance))
+	}
+
+	var prices = make([]int, len(holds))
+	var total int
+	for i, hold := range holds {
+		prices[i] = hold.Price
+		total += hold.Price / entity.LoyaltyPointValue
+	}
+
+	if points > total {
+		return rfc7807.BadRequest("loyalty-points", "Loyalty Points Error", fmt.Sprintf("At most %d loyalty points can be spent on the tickets.", total))
+	}
+
+	redemption := entity.NewLoyaltyRedemption(userID, points)
+	err = s.repo.RecordLoyalty(ctx, &redemption)
+	if err != nil {
+		return err
+	}
+
+	for i, spent := range entity.LoyaltyDiscounts(points, prices) {
+		if spent == 0 {
+			continue
+		}
+
+		holds[i].Price -= spent * entity.LoyaltyPointValue
+		holds[i].Discount += spent * entity.LoyaltyPointValue
+		holds[i].LoyaltyPoints = spent
+		holds[i].LoyaltyTransactionID = uuid.NullUUID{UUID: redemption.ID, Valid: true}
+	}
+
+	return nil
+}
+
+// getExtras validates the ordered extras and returns them from the catalog.
+func (s *serviceImpl) getExtras(ctx context.Context, newExtras []entity.NewTicketExtra, passengers int) ([]entity.Extra, error) {
+	if len(newExtras) == 0 {
+		return nil, nil
+	}
+
+	var params rfc7807.InvalidParams
+	var ids = make([]uuid.UUID, 0, len(newExtras))
+	for i, newExtra := range newExtras {
+		if newExtra.Quantity < 1 || newExtra.Quantity > 20 {
+			params.SetInvalidParam(fmt.Sprintf("extras[%d].quantity", i), "Must be between 1 and 20.")
+		}
+
+		if newExtra.Passenger < 0 || newExtra.Passenger >= passengers {
+			params.SetInvalidParam(fmt.Sprintf("extras[%d].passenger", i), "Must be the index of one of the passengers.")
+		}
+
+		if !slices.Contains(ids, newExtra.ExtraID) {
+			ids = append(ids, newExtra.ExtraID)
+		}
+	}
+
+	if params != nil {
+		return nil, rfc7807.BadRequest("extras-invalid-data", "Extras Data Error", "Provided data is not valid.", params...)
+	}
+
+	extras, err := s.repo.GetExtras(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if !slices.ContainsFunc(extras, func(extra entity.Extra) bool { return extra.ID == id }) {
+			return nil, rfc7807.BadRequest("non-existing-extra", "Non-existing Extra Error", id.String()+" is not in the extras catalog.")
+		}
+	}
+
+	return extras, nil
+}
+
+// addExtras reserves the extras with the seats of their passengers and checks
+// that the connection can still carry them; it has to run inside the purchase
+// transaction, after the seats are locked. Unless charged, the extras are
+// reserved without adding their prices to the holds.
+func (s *serviceImpl) addExtras(ctx context.Context, connection *entity.Connection, extras []entity.Extra, newExtras []entity.NewTicketExtra, holds []*entity.SeatHold, charged bool) error {
+	for _, newExtra := range newExtras {
+		extra := extras[slices.IndexFunc(extras, func(extra entity.Extra) bool { return extra.ID == newExtra.ExtraID })]
+		if charged {
+			holds[newExtra.Passenger].AddExtra(extra, newExtra.Quantity)
+		} else {
+			holds[newExtra.Passenger].ReserveExtra(extra, newExtra.Quantity)
+		}
+	}
+
+	booked, err := s.repo.GetBookedExtras(ctx, connection.ID)
+	if err != nil {
+		return err
+	}
+
+	return entity.CheckExtrasCapacity(connection.Bus.LuggageCapacity, extras, booked, holds)
+}
+
+func (s *serviceImpl) prepareAdress(newAdress entity.NewAddress, userID uuid.UUID, countryID uuid.UUID) (*entity.Address, error) {
 	adress := newAdress.ToAddress(countryID)
 	err := adress.Prepare(userID)
 
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	return adress.ID, s.repo.CreateAdress(ctx, &adress)
+	return &adress, nil
 }
-func (s *serviceImpl) CreatePassenger(ctx context.Context, newPassenger entity.NewPassenger, userID uuid.UUID) (uuid.UUID, error) {
-	passenger := newPassenger.Parse()
-	params := passenger.Prepare(userID)
+
+func preparePassenger(newPassenger entity.NewPassenger, userID uuid.UUID, departure time.Time) (*entity.Passenger, error) {
+	passenger, params := newPassenger.Parse()
+	if params == nil {
+		params = passenger.Prepare(userID)
+	}
+
+	if params == nil {
+		params = passenger.ValidateCategoryAt(departure)
+	}
+
 	if params != nil {
-		return uuid.Nil, rfc7807.BadRequest("passenger-invalid-data", "Passenger Data Error", "Provided data is not valid.", params...)
+		return nil, rfc7807.BadRequest("passenger-invalid-data", "Passenger Data Error", "Provided data is not valid.", params...)
 	}
 
-	return passenger.ID, s.repo.CreatePassenger(ctx, &passenger)
+	return &passenger, nil
 }
 
-func NewTicketService(repo repo.Ticket) Ticket {
+func NewTicketService(repo repo.Ticket, provider payment.Provider, waitlist Waitlist, boardingPassKey, waitlistKey []byte) Ticket {
 	return &serviceImpl{
 		repo,
+		provider,
+		waitlist,
+		boardingPassKey,
+		waitlistKey,
 	}
 }
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"maryan_api/config"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/notification"
+	"maryan_api/pkg/auth"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Transfer interface {
+	Invite(ctx context.Context, userID uuid.UUID, ticketIDStr string, invitation entity.TicketTransferJSON) (entity.TicketTransfer, error)
+	Accept(ctx context.Context, userID uuid.UUID, token string) (entity.Ticket, error)
+	GetTransfers(ctx context.Context, userID uuid.UUID) ([]entity.TicketTransfer, error)
+}
+
+type transferServiceImpl struct {
+	repo      repo.Transfer
+	policy    entity.TransferPolicy
+	secretKey []byte
+}
+
+func (s *transferServiceImpl) Invite(ctx context.Context, userID uuid.UUID, ticketIDStr string, invitation entity.TicketTransferJSON) (entity.TicketTransfer, error) {
+	ticketID, err := uuid.Parse(ticketIDStr)
+	if err != nil {
+		return entity.TicketTransfer{}, rfc7807.UUID(err.Error())
+	}
+
+	ticket, err := s.repo.GetTicket(ctx, ticketID)
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	if ticket.UserID != userID {
+		return entity.TicketTransfer{}, rfc7807.BadRequest("non-existing-ticket", "Non-existing Ticket Error", "There is no ticket with such id.")
+	}
+
+	connection, err := s.checkTransferable(ctx, ticket)
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	sender, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	if strings.EqualFold(strings.TrimSpace(invitation.Email), sender.Email) {
+		return entity.TicketTransfer{}, rfc7807.BadRequest("self-transfer", "Self Transfer Error", "The ticket can not be transferred to its owner.")
+	}
+
+	now := time.Now()
+	transfer, err := entity.NewTicketTransfer(ticketID, userID, invitation.Email, s.policy.InvitationExpiry(connection.DepartureTime, now))
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	err = s.repo.Create(ctx, &transfer)
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	token, err := auth.GenerateAccessTokenFor(s.secretKey, jwt.MapClaims{"id": transfer.ID.String(), "email": transfer.ToEmail}, transfer.ExpiresAt.Sub(now))
+	if err != nil {
+		return entity.TicketTransfer{}, err
+	}
+
+	link := config.FrontendURL() + "/ticket-transfer?token=" + url.QueryEscape(token)
+	if err := notification.TicketTransferInvitation(transfer.ToEmail, link, transfer.ExpiresAt); err != nil {
+		return entity.TicketTransfer{}, rfc7807.BadGateway("notification", "Notification Error", err.Error())
+	}
+
+	return transfer, nil
+}
+
+// Accept moves the ticket and its contact info to the recipient; the token is
+// only valid for the account registered with the invited email.
+func (s *transferServiceImpl) Accept(ctx context.Context, userID uuid.UUID, token string) (entity.Ticket, error) {
+	claims, err := auth.VerifyAccessToken(token, s.secretKey, []auth.ClaimValidation{
+		{Name: "id", Returnable: true, Type: auth.ClaimUUID},
+		{Name: "email", Returnable: true, Type: auth.ClaimString},
+	})
+	if err != nil {
+		return entity.Ticket{}, rfc7807.BadRequest("invalid-transfer-token", "Invalid Transfer Token Error", err.Error())
+	}
+	transferID, email := claims[0].(uuid.UUID), claims[1].(string)
+
+	recipient, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	if !strings.EqualFold(recipient.Email, email) {
+		return entity.Ticket{}, rfc7807.Forbidden("foreign-transfer", "Foreign Transfer Error", "The invitation has been sent to another email.")
+	}
+
+	var ticket entity.Ticket
+	err = s.repo.Transaction(ctx, func(ctx context.Context) error {
+		transfer, err := s.repo.LockByID(ctx, transferID)
+		if err != nil {
+			return err
+		}
+
+		if !transfer.IsPending(time.Now()) {
+			return rfc7807.New(http.StatusConflict, "invalid-ticket-transfer-status", "Invalid Ticket Transfer Status Error", "The invitation is no longer valid.")
+		}
+
+		ticket, err = s.repo.LockTicket(ctx, transfer.TicketID)
+		if err != nil {
+			return err
+		}
+
+		if ticket.UserID != transfer.FromUserID {
+			return rfc7807.New(http.StatusConflict, "invalid-ticket-transfer-status", "Invalid Ticket Transfer Status Error", "The ticket has changed hands since the invitation.")
+		}
+
+		if _, err := s.checkTransferable(ctx, ticket); err != nil {
+			return err
+		}
+
+		err = s.repo.ChangeOwner(ctx, ticket.ID, recipient.ID, recipient.Email, recipient.PhoneNumber)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.Accept(ctx, transfer.ID, recipient.ID)
+	})
+	if err != nil {
+		return entity.Ticket{}, err
+	}
+
+	return s.repo.GetTicket(ctx, ticket.ID)
+}
+
+func (s *transferServiceImpl) GetTransfers(ctx context.Context, userID uuid.UUID) ([]entity.TicketTransfer, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByUser(ctx, userID, strings.ToLower(user.Email))
+}
+
+// checkTransferable blocks transfers of boarded, cancelled or refunding
+// tickets and of tickets departing within the cutoff.
+func (s *transferServiceImpl) checkTransferable(ctx context.Context, ticket entity.Ticket) (entity.Connection, error) {
+	if ticket.Status != entity.TicketStatusPaid {
+		return entity.Connection{}, rfc7807.New(http.StatusConflict, "invalid-ticket-status", "Invalid Ticket Status Error", "A ticket that is "+string(ticket.Status)+" cannot be transferred.")
+	}
+
+	active, err := s.repo.HasActiveRefund(ctx, ticket.ID)
+	if err != nil {
+		return entity.Connection{}, err
+	}
+
+	if active {
+		return entity.Connection{}, rfc7807.BadRequest("refund-exists", "Refund Exists Error", "The ticket is being cancelled.")
+	}
+
+	connection, _, err := s.repo.GetConnectionByID(ctx, ticket.ConnectionID)
+	if err != nil {
+		return entity.Connection{}, err
+	}
+
+	if !s.policy.CanTransfer(connection.DepartureTime, time.Now()) {
+		return entity.Connection{}, rfc7807.BadRequest("transfer-cutoff", "Transfer Cutoff Error", "The ticket can no longer be transferred before the departure.")
+	}
+
+	return connection, nil
+}
+
+func NewTransferService(repo repo.Transfer, policy entity.TransferPolicy, secretKey []byte) Transfer {
+	return &transferServiceImpl{repo, policy, secretKey}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"log"
+	"maryan_api/config"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/notification"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/url"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Waitlist interface {
+	Join(ctx context.Context, userID uuid.UUID, connectionIDStr string, partySize int) (entity.WaitlistEntry, error)
+	GetEntries(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error)
+	OfferFreedSeats(ctx context.Context, connectionIDs []uuid.UUID)
+	OfferWaitingSeats(ctx context.Context) (int, error)
+}
+
+type waitlistServiceImpl struct {
+	repo       repo.Waitlist
+	signingKey []byte
+}
+
+func (s *waitlistServiceImpl) Join(ctx context.Context, userID uuid.UUID, connectionIDStr string, partySize int) (entity.WaitlistEntry, error) {
+	connectionID, err := uuid.Parse(connectionIDStr)
+	if err != nil {
+		return entity.WaitlistEntry{}, rfc7807.UUID(err.Error())
+	}
+
+	connection, takenSeats, err := s.repo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return entity.WaitlistEntry{}, err
+	}
+
+	entry, err := entity.NewWaitlistEntry(&connection, userID, partySize)
+	if err != nil {
+		return entity.WaitlistEntry{}, err
+	}
+
+	reserved, err := s.repo.ReservedSeats(ctx, connectionID)
+	if err != nil {
+		return entity.WaitlistEntry{}, err
+	}
+
+	if len(connection.Bus.Seats)-len(takenSeats)-reserved >= partySize {
+		return entity.WaitlistEntry{}, rfc7807.BadRequest("seats-available", "Seats Available Error", "There are enough free seats to purchase the tickets right away.")
+	}
+
+	active, err := s.repo.HasActive(ctx, connectionID, userID)
+	if err != nil {
+		return entity.WaitlistEntry{}, err
+	}
+
+	if active {
+		return entity.WaitlistEntry{}, rfc7807.BadRequest("waitlist-entry-exists", "Waitlist Entry Exists Error", "You are already on the waitlist of this connection.")
+	}
+
+	return entry, s.repo.Create(ctx, &entry)
+}
+
+func (s *waitlistServiceImpl) GetEntries(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error) {
+	return s.repo.GetByUser(ctx, userID)
+}
+
+// OfferFreedSeats gives the free seats of the connections to their earliest
+// fitting entries, each connection in its own transaction. It runs after
+// seats may have been freed, so a failure is only logged and
+// OfferWaitingSeats catches up.
+func (s *waitlistServiceImpl) OfferFreedSeats(ctx context.Context, connectionIDs []uuid.UUID) {
+	if len(connectionIDs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	err := s.repo.Expire(ctx, now)
+	if err != nil {
+		log.Printf("waitlist offers failed: %v", err)
+		return
+	}
+
+	s.offerConnections(ctx, connectionIDs, now)
+}
+
+// OfferWaitingSeats offers the free seats of every connection with a waiting
+// entry, catching up on the seats freed without an offer being made, and
+// returns how many entries it made an offer to.
+func (s *waitlistServiceImpl) OfferWaitingSeats(ctx context.Context) (int, error) {
+	now := time.Now()
+	err := s.repo.Expire(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	connectionIDs, err := s.repo.ConnectionsWithWaiting(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.offerConnections(ctx, connectionIDs, now), nil
+}
+
+// offerConnections makes the offers of the connections, notifies the entries
+// and returns how many of them it made.
+func (s *waitlistServiceImpl) offerConnections(ctx context.Context, connectionIDs []uuid.UUID, now time.Time) int {
+	var offers []entity.WaitlistEntry
+	for _, connectionID := range connectionIDs {
+		var offered []entity.WaitlistEntry
+		err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			offered, err = s.offer(ctx, connectionID, now)
+			return err
+		})
+		if err != nil {
+			log.Printf("waitlist offers of connection %s failed: %v", connectionID, err)
+			continue
+		}
+
+		offers = append(offers, offered...)
+	}
+
+	for _, entry := range offers {
+		link := config.FrontendURL() + "/connection/" + entry.ConnectionID.String() + "?waitlistToken=" + url.QueryEscape(entry.OfferToken(s.signingKey))
+		if err := notification.WaitlistOffer(entry.User.Email, link, *entry.OfferExpiresAt); err != nil {
+			log.Printf("waitlist offer %s could not be sent: %v", entry.ID, err)
+		}
+	}
+
+	return len(offers)
+}
+
+func (s *waitlistServiceImpl) offer(ctx context.Context, connectionID uuid.UUID, now time.Time) ([]entity.WaitlistEntry, error) {
+	connection, _, err := s.repo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Waitlist entries wait for the whole connection.
+	takenSeats, err := s.repo.LockSeats(ctx, connectionID, connection.FullSegment())
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := s.repo.ReservedSeats(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.LockWaiting(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	free := len(connection.Bus.Seats) - len(takenSeats) - reserved
+
+	var offered []entity.WaitlistEntry
+	for _, entry := range entries {
+		if free <= 0 {
+			break
+		}
+
+		if entry.PartySize > free {
+			continue
+		}
+
+		until := now.Add(entity.WaitlistOfferDuration)
+		if until.After(entry.ExpiresAt) {
+			until = entry.ExpiresAt
+		}
+
+		err = s.repo.Offer(ctx, entry.ID, until)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Status = entity.WaitlistStatusOffered
+		entry.OfferExpiresAt = &until
+		offered = append(offered, entry)
+		free -= entry.PartySize
+	}
+
+	return offered, nil
+}
+
+func NewWaitlistService(repo repo.Waitlist, signingKey []byte) Waitlist {
+	return &waitlistServiceImpl{repo, signingKey}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type adminTicketHandler struct {
+	service service.AdminTicket
+}
+
+func newAdminTicketHandler(service service.AdminTicket) *adminTicketHandler {
+	return &adminTicketHandler{service}
+}
+
+func (h *adminTicketHandler) getByID(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	ticket, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Ticket entity.AdminTicket `json:"ticket"`
+	}{
+		ginutil.Response{
+			"The ticket has successfuly been found.",
+			hypermedia.Links{adminTicketsLink},
+		},
+		ticket,
+	})
+}
+
+func (h *adminTicketHandler) changeSeat(ctx *gin.Context) {
+	var request entity.ChangeSeatJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err = h.service.ChangeSeat(ctxWithTimeout, ctx.Param("id"), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The seat has successfuly been changed.",
+		hypermedia.Links{adminTicketLink(ctx.Param("id"))},
+	})
+}
+
+func (h *adminTicketHandler) changePassenger(ctx *gin.Context) {
+	var request entity.NewPassenger
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	passenger, err := h.service.ChangePassenger(ctxWithTimeout, ctx.Param("id"), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Passenger entity.Passenger `json:"passenger"`
+	}{
+		ginutil.Response{
+			"The passenger has successfuly been changed.",
+			hypermedia.Links{adminTicketLink(ctx.Param("id"))},
+		},
+		passenger,
+	})
+}
+
+func (h *adminTicketHandler) cancel(ctx *gin.Context) {
+	var request entity.CancelTicketJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	refunds, err := h.service.Cancel(ctxWithTimeout, ctx.Param("id"), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	message := "The ticket has successfuly been cancelled."
+	if refunds != nil {
+		message = "The refund has successfuly been started, the ticket will be cancelled once it completes."
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Refunds []entity.Refaund `json:"refunds,omitempty"`
+	}{
+		ginutil.Response{
+			message,
+			hypermedia.Links{adminTicketLink(ctx.Param("id"))},
+		},
+		refunds,
+	})
+}
+
+func (h *adminTicketHandler) resendConfirmation(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.ResendConfirmation(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The confirmation has successfuly been sent.",
+		hypermedia.Links{adminTicketLink(ctx.Param("id"))},
+	})
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type cashHandler struct {
+	ticket service.Ticket
+	refund service.Refund
+	cash   service.Cash
+}
+
+func newCashHandler(ticket service.Ticket, refund service.Refund, cash service.Cash) *cashHandler {
+	return &cashHandler{ticket, refund, cash}
+}
+
+func (h *cashHandler) driverSale(ctx *gin.Context) {
+	h.sell(ctx, true)
+}
+
+func (h *cashHandler) supportSale(ctx *gin.Context) {
+	h.sell(ctx, false)
+}
+
+func (h *cashHandler) sell(ctx *gin.Context, byDriver bool) {
+	var request entity.CashSaleJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	tickets, err := h.ticket.SellForCash(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), byDriver, request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		Tickets []*entity.Ticket `json:"tickets"`
+	}{
+		ginutil.Response{
+			"The tickets have successfuly been sold for cash.",
+			hypermedia.Links{},
+		},
+		tickets,
+	})
+}
+
+func (h *cashHandler) driverCancel(ctx *gin.Context) {
+	h.cancel(ctx, true)
+}
+
+func (h *cashHandler) supportCancel(ctx *gin.Context) {
+	h.cancel(ctx, false)
+}
+
+func (h *cashHandler) cancel(ctx *gin.Context, byDriver bool) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	refund, err := h.refund.CancelForCash(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), byDriver, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Refund entity.CashTransaction `json:"refund"`
+	}{
+		ginutil.Response{
+			"The ticket has successfuly been cancelled, give the refund back in cash.",
+			hypermedia.Links{},
+		},
+		refund,
+	})
+}
+
+func (h *cashHandler) getTripLedger(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	ledger, err := h.cash.GetTripLedger(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Ledger entity.CashLedger `json:"ledger"`
+	}{
+		ginutil.Response{
+			"The cash ledger has successfuly been found.",
+			hypermedia.Links{},
+		},
+		ledger,
+	})
+}
+
+func (h *cashHandler) recordHandover(ctx *gin.Context) {
+	var request entity.CashHandoverJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	handover, err := h.cash.RecordHandover(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		Handover entity.CashTransaction `json:"handover"`
+	}{
+		ginutil.Response{
+			"The cash handover has successfuly been recorded.",
+			hypermedia.Links{getCashReconciliationLink},
+		},
+		handover,
+	})
+}
+
+func (h *cashHandler) reconciliation(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	report, err := h.cash.Reconciliation(ctxWithTimeout, ctx.Query("date"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Report []entity.CashReconciliation `json:"report"`
+	}{
+		ginutil.Response{
+			"The cash reconciliation has successfuly been prepared.",
+			hypermedia.Links{},
+		},
+		report,
+	})
+}
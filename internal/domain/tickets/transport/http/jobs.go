@@ -0,0 +1,31 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/tickets/repo"
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/infrastructure/clients/payment"
+	"maryan_api/internal/infrastructure/scheduler"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func RegisterJobs(db *gorm.DB, s *scheduler.Scheduler, provider payment.Provider) {
+	waitlist := service.NewWaitlistService(repo.NewWaitlistRepo(db), config.WaitlistSigningKey())
+	tickets := service.NewTicketService(repo.NewTicketRepo(db), provider, waitlist, config.BoardingPassSigningKey(), config.WaitlistSigningKey())
+
+	s.Register(scheduler.Job{
+		Name:     "expire-abandoned-purchases",
+		Interval: time.Duration(config.PurchaseExpiryIntervalMinutes()) * time.Minute,
+		Timeout:  time.Minute,
+		Run:      tickets.ExpireAbandonedPurchases,
+	})
+
+	s.Register(scheduler.Job{
+		Name:     "offer-waiting-seats",
+		Interval: time.Duration(config.WaitlistOfferIntervalMinutes()) * time.Minute,
+		Timeout:  time.Minute,
+		Run:      waitlist.OfferWaitingSeats,
+	})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type rebookingHandler struct {
+	service service.Rebooking
+}
+
+func newRebookingHandler(service service.Rebooking) *rebookingHandler {
+	return &rebookingHandler{service}
+}
+
+func (h *rebookingHandler) rebook(ctx *gin.Context) {
+	var request entity.RebookJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	rebooking, err := h.service.Rebook(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	response := ginutil.Response{
+		"The ticket has successfuly been changed.",
+		hypermedia.Links{},
+	}
+
+	if rebooking.PaymentURL != "" {
+		response.Message = "The ticket change waits for the payment of the fare difference."
+		response.Links = hypermedia.Links{
+			{"redirect", hypermedia.LinkData{
+				Href:   rebooking.PaymentURL,
+				Method: "",
+			}},
+		}
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Rebooking entity.Rebooking `json:"rebooking"`
+	}{
+		response,
+		rebooking,
+	})
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type refundHandler struct {
+	service service.Refund
+}
+
+func newRefundHandler(service service.Refund) *refundHandler {
+	return &refundHandler{service}
+}
+
+func (h *refundHandler) cancelTicket(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	refunds, err := h.service.Cancel(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Refunds []entity.Refaund `json:"refunds"`
+	}{
+		ginutil.Response{
+			"The ticket cancellation has successfuly been requested.",
+			hypermedia.Links{},
+		},
+		refunds,
+	})
+}
+
+func (h *refundHandler) getRefunds(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	refunds, links, err := h.service.GetRefunds(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/refunds",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		"",
+	}, service.RefundFilter{
+		Status:   ctx.Query("status"),
+		TicketID: ctx.Query("ticket_id"),
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Refunds []entity.Refaund `json:"refunds"`
+	}{
+		ginutil.Response{
+			"The refunds have successfuly been found.",
+			links,
+		},
+		refunds,
+	})
+}
+
+func (h *refundHandler) approve(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Approve(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The refund has successfuly been approved.",
+		hypermedia.Links{refundsLink},
+	})
+}
+
+func (h *refundHandler) reject(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	err := h.service.Reject(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The refund has successfuly been rejected.",
+		hypermedia.Links{refundsLink},
+	})
+}
+
+var refundsLink = hypermedia.Link{
+	Name: "refunds",
+	Data: hypermedia.LinkData{Href: "/admin/refunds", Method: "GET"},
+}
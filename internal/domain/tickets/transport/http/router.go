@@ -2,10 +2,12 @@ package http
 
 import (
 	"context"
+	"io"
 	"maryan_api/config"
 	"maryan_api/internal/domain/tickets/repo"
 	"maryan_api/internal/domain/tickets/service"
 	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/payment"
 	"maryan_api/pkg/auth"
 	"maryan_api/pkg/dbutil"
 	ginutil "maryan_api/pkg/ginutils"
@@ -19,17 +21,103 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
+func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client, provider payment.Provider) {
 	customerRouter := ginutil.CreateAuthRouter("/customer", auth.Customer.SecretKey(), s)
 
-	customerHandler := newHandler(service.NewTicketService(repo.NewTicketRepo(db)))
+	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
+
+	driverRouter := ginutil.CreateAuthRouter("/driver", auth.Driver.SecretKey(), s)
+
+	supportRouter := ginutil.CreateAuthRouter("/support", auth.Support.SecretKey(), s)
+
+	waitlist := service.NewWaitlistService(repo.NewWaitlistRepo(db), config.WaitlistSigningKey())
+	ticketService := service.NewTicketService(repo.NewTicketRepo(db), provider, waitlist, config.BoardingPassSigningKey(), config.WaitlistSigningKey())
+	customerHandler := newHandler(ticketService)
+	refundService := service.NewRefundService(repo.NewRefundRepo(db), provider, entity.CancellationPolicy{
+		FullRefundBefore:     time.Duration(config.CancellationFullRefundHours()) * time.Hour,
+		PartialRefundPercent: config.CancellationPartialRefundPercent(),
+	})
+	refundHandler := newRefundHandler(refundService)
+	rebookingHandler := newRebookingHandler(service.NewRebookingService(repo.NewTicketRepo(db), repo.NewRefundRepo(db), provider, waitlist, entity.RebookingPolicy{
+		ChangeBefore: time.Duration(config.RebookingWindowHours()) * time.Hour,
+	}))
+	waitlistHandler := newWaitlistHandler(waitlist)
+	transferHandler := newTransferHandler(service.NewTransferService(repo.NewTransferRepo(db), entity.TransferPolicy{
+		CutoffBefore: time.Duration(config.TransferCutoffHours()) * time.Hour,
+	}, config.TicketTransferTokenSecretKey()))
+	adminTicketHandler := newAdminTicketHandler(service.NewAdminTicketService(repo.NewAdminTicketRepo(db), repo.NewRefundRepo(db), provider, waitlist))
+	cashHandler := newCashHandler(ticketService, refundService, service.NewCashService(repo.NewCashRepo(db)))
 
 	//-----------------------Ticket Routes---------------------------------------
 
-	customerRouter.POST("/connection/purchase-ticket", customerHandler.purchase)
+	customerRouter.POST("/connection/purchase-ticket", ginutil.Idempotency(db), customerHandler.purchase)
+	customerRouter.POST("/itinerary/purchase-ticket", ginutil.Idempotency(db), customerHandler.purchaseItinerary)
 	customerRouter.GET("/tickets", customerHandler.getTickets)
-	s.GET("/connection/purchase-ticket/failed/:id", customerHandler.purchaseFailed)
-	s.GET("/connection/purchase-ticket/succeded/:id", customerHandler.purchaseSucceded)
+	customerRouter.GET("/ticket/:id/pdf", customerHandler.getBoardingPass)
+	adminRouter.GET("/tickets", customerHandler.getAdminTickets)
+	adminRouter.GET("/ticket/:id", adminTicketHandler.getByID)
+	adminRouter.PUT("/ticket/:id/seat", adminTicketHandler.changeSeat)
+	adminRouter.PUT("/ticket/:id/passenger", adminTicketHandler.changePassenger)
+	adminRouter.POST("/ticket/:id/cancel", ginutil.Idempotency(db), adminTicketHandler.cancel)
+	adminRouter.POST("/ticket/:id/resend-confirmation", adminTicketHandler.resendConfirmation)
+	customerRouter.POST("/ticket/:id/rebook", ginutil.Idempotency(db), rebookingHandler.rebook)
+	s.POST("/webhooks/"+provider.Name(), customerHandler.paymentWebhook)
+
+	//-----------------------Transfer Routes-------------------------------------
+
+	customerRouter.POST("/ticket/:id/transfer", ginutil.Idempotency(db), transferHandler.invite)
+	customerRouter.POST("/ticket-transfer/accept", transferHandler.accept)
+	customerRouter.GET("/ticket-transfers", transferHandler.getTransfers)
+
+	//-----------------------Waitlist Routes-------------------------------------
+
+	customerRouter.POST("/connection/:id/waitlist", waitlistHandler.join)
+	customerRouter.GET("/waitlist", waitlistHandler.getEntries)
+
+	//-----------------------Cash Routes---------------------------------------
+
+	driverRouter.POST("/sale", ginutil.Idempotency(db), cashHandler.driverSale)
+	supportRouter.POST("/sale", ginutil.Idempotency(db), cashHandler.supportSale)
+	driverRouter.POST("/ticket/:id/cancel", ginutil.Idempotency(db), cashHandler.driverCancel)
+	supportRouter.POST("/ticket/:id/cancel", ginutil.Idempotency(db), cashHandler.supportCancel)
+	driverRouter.GET("/trip/:id/cash", cashHandler.getTripLedger)
+	adminRouter.POST("/cash-handover", cashHandler.recordHandover)
+	adminRouter.GET("/cash-reconciliation", cashHandler.reconciliation)
+
+	//-----------------------Refund Routes---------------------------------------
+
+	customerRouter.DELETE("/ticket/:id", refundHandler.cancelTicket)
+	adminRouter.GET("/refunds", refundHandler.getRefunds)
+	adminRouter.POST("/refund/:id/approve", refundHandler.approve)
+	adminRouter.POST("/refund/:id/reject", refundHandler.reject)
+}
+
+// -------------Links-----------------
+var getWaitlistLink = hypermedia.Link{
+	Name: "getWaitlist",
+	Data: hypermedia.LinkData{Href: "/customer/waitlist", Method: "GET"},
+}
+
+var getTicketTransfersLink = hypermedia.Link{
+	Name: "getTicketTransfers",
+	Data: hypermedia.LinkData{Href: "/customer/ticket-transfers", Method: "GET"},
+}
+
+var adminTicketsLink = hypermedia.Link{
+	Name: "getTickets",
+	Data: hypermedia.LinkData{Href: "/admin/tickets", Method: "GET"},
+}
+
+func adminTicketLink(id string) hypermedia.Link {
+	return hypermedia.Link{
+		Name: "getTicket",
+		Data: hypermedia.LinkData{Href: "/admin/ticket/" + id, Method: "GET"},
+	}
+}
+
+var getCashReconciliationLink = hypermedia.Link{
+	Name: "getCashReconciliation",
+	Data: hypermedia.LinkData{Href: "/admin/cash-reconciliation", Method: "GET"},
 }
 
 type passengerHandler struct {
@@ -52,7 +140,7 @@ func (p *passengerHandler) getTickets(ctx *gin.Context) {
 		ctx.DefaultQuery("order_by", "created_at"),
 		ctx.DefaultQuery("order_way", "desc"),
 		ctx.DefaultQuery("search", ""),
-	}, ctx.MustGet("userID").(uuid.UUID))
+	}, ctx.MustGet("userID").(uuid.UUID), ctx.Query("status"))
 
 	if err != nil {
 		ginutil.ServiceErrorAbort(ctx, err)
@@ -74,6 +162,57 @@ func (p *passengerHandler) getTickets(ctx *gin.Context) {
 
 }
 
+func (p *passengerHandler) getAdminTickets(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	tickets, links, err := p.service.GetAdminTickets(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/tickets",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		ctx.DefaultQuery("search", ""),
+	}, service.TicketFilter{
+		Status:        ctx.Query("status"),
+		UserID:        ctx.Query("user_id"),
+		ConnectionID:  ctx.Query("connection_id"),
+		DepartureFrom: ctx.Query("departure_from"),
+		DepartureTo:   ctx.Query("departure_to"),
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Links   hypermedia.Links        `json:"links"`
+		Tickets []entity.CustomerTicket `json:"tickets"`
+	}{
+		ginutil.Response{
+			"The tickets have successfuly been found.",
+			hypermedia.Links{},
+		},
+		links,
+		tickets,
+	})
+}
+
+func (p *passengerHandler) getBoardingPass(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), time.Second*10)
+	defer cancel()
+
+	pdf, err := p.service.BoardingPass(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", `inline; filename="ticket-`+ctx.Param("id")+`.pdf"`)
+	ctx.Data(http.StatusOK, "application/pdf", pdf)
+}
+
 func (p *passengerHandler) purchase(ctx *gin.Context) {
 	var request entity.NewTicketJSON
 
@@ -104,40 +243,52 @@ func (p *passengerHandler) purchase(ctx *gin.Context) {
 	})
 }
 
-func (p *passengerHandler) purchaseFailed(ctx *gin.Context) {
-	var sessionID = ctx.Param("id")
-	if sessionID == "" {
-		ginutil.HandlerProblemAbort(ctx, rfc7807.Unauthorized("unauthorized", "Unauthorized Error", "Unauthorized."))
+func (p *passengerHandler) purchaseItinerary(ctx *gin.Context) {
+	var request entity.NewItineraryTicketJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
 		return
 	}
 
 	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), time.Second*10)
 	defer cancel()
 
-	err := p.service.PurchaseFailed(ctxWithTimeout, sessionID)
+	redirectURL, err := p.service.PurchaseItinerary(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), request)
 	if err != nil {
 		ginutil.ServiceErrorAbort(ctx, err)
 		return
 	}
 
-	ctx.Redirect(http.StatusFound, config.FrontendURL()+"/connections")
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		"The purchase procces has started",
+		hypermedia.Links{
+			{"redirect", hypermedia.LinkData{
+				Href:   redirectURL,
+				Method: "",
+			}},
+		},
+	})
 }
 
-func (p *passengerHandler) purchaseSucceded(ctx *gin.Context) {
-	var sessionID = ctx.Param("id")
-	if sessionID == "" {
-		ginutil.HandlerProblemAbort(ctx, rfc7807.Unauthorized("unauthorized", "Unauthorized Error", "Unauthorized."))
+func (p *passengerHandler) paymentWebhook(ctx *gin.Context) {
+	payload, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
 		return
 	}
 
 	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), time.Second*10)
 	defer cancel()
 
-	err := p.service.PurchaseSucceded(ctxWithTimeout, sessionID)
+	err = p.service.ProcessWebhook(ctxWithTimeout, payload, ctx.Request.Header)
 	if err != nil {
 		ginutil.ServiceErrorAbort(ctx, err)
 		return
 	}
 
-	ctx.Redirect(http.StatusFound, config.FrontendURL()+"/profile/tickets")
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		Message: "The event has successfuly been processed.",
+	})
 }
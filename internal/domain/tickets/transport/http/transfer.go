@@ -0,0 +1,104 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type transferHandler struct {
+	service service.Transfer
+}
+
+func newTransferHandler(service service.Transfer) *transferHandler {
+	return &transferHandler{service}
+}
+
+func (h *transferHandler) invite(ctx *gin.Context) {
+	var request entity.TicketTransferJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	transfer, err := h.service.Invite(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"), request)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		Transfer entity.TicketTransfer `json:"transfer"`
+	}{
+		ginutil.Response{
+			"The transfer invitation has successfuly been sent.",
+			hypermedia.Links{getTicketTransfersLink},
+		},
+		transfer,
+	})
+}
+
+func (h *transferHandler) accept(ctx *gin.Context) {
+	var request entity.AcceptTicketTransferJSON
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	ticket, err := h.service.Accept(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), request.Token)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Ticket entity.Ticket `json:"ticket"`
+	}{
+		ginutil.Response{
+			"The ticket has successfuly been transferred to your account.",
+			hypermedia.Links{getTicketTransfersLink},
+		},
+		ticket,
+	})
+}
+
+func (h *transferHandler) getTransfers(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	transfers, err := h.service.GetTransfers(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Transfers []entity.TicketTransfer `json:"transfers"`
+	}{
+		ginutil.Response{
+			"The ticket transfers have successfuly been found.",
+			hypermedia.Links{},
+		},
+		transfers,
+	})
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"maryan_api/internal/domain/tickets/service"
+	"maryan_api/internal/entity"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+type waitlistHandler struct {
+	service service.Waitlist
+}
+
+func newWaitlistHandler(service service.Waitlist) *waitlistHandler {
+	return &waitlistHandler{service}
+}
+
+func (h *waitlistHandler) join(ctx *gin.Context) {
+	var request struct {
+		PartySize int `json:"partySize"`
+	}
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.JSON(err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	entry, err := h.service.Join(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID), ctx.Param("id"), request.PartySize)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		ginutil.Response
+		Entry entity.WaitlistEntry `json:"entry"`
+	}{
+		ginutil.Response{
+			"You have successfuly joined the waitlist.",
+			hypermedia.Links{getWaitlistLink},
+		},
+		entry,
+	})
+}
+
+func (h *waitlistHandler) getEntries(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	entries, err := h.service.GetEntries(ctxWithTimeout, ctx.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ginutil.Response
+		Entries []entity.WaitlistEntry `json:"entries"`
+	}{
+		ginutil.Response{
+			"The waitlist entries have successfuly been found.",
+			hypermedia.Links{},
+		},
+		entries,
+	})
+}
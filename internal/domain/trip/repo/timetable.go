@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Timetable interface {
+	Create(ctx context.Context, timetable *entity.Timetable) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Timetable, error)
+	GetTimetables(ctx context.Context, pagination dbutil.Pagination) ([]entity.Timetable, int, error, bool)
+	GeneratedDates(ctx context.Context, id uuid.UUID, from, to time.Time) ([]time.Time, error)
+}
+
+type timetableRepo struct {
+	ds dataStore.Timetable
+}
+
+func (r *timetableRepo) Create(ctx context.Context, timetable *entity.Timetable) error {
+	return r.ds.Create(ctx, timetable)
+}
+
+func (r *timetableRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.ds.Delete(ctx, id)
+}
+
+func (r *timetableRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Timetable, error) {
+	return r.ds.GetByID(ctx, id)
+}
+
+func (r *timetableRepo) GetTimetables(ctx context.Context, pagination dbutil.Pagination) ([]entity.Timetable, int, error, bool) {
+	return r.ds.GetTimetables(ctx, pagination)
+}
+
+func (r *timetableRepo) GeneratedDates(ctx context.Context, id uuid.UUID, from, to time.Time) ([]time.Time, error) {
+	return r.ds.GeneratedDates(ctx, id, from, to)
+}
+
+func NewTimetable(db *gorm.DB) Timetable {
+	return &timetableRepo{dataStore.NewTimetable(db)}
+}
+
+type Drivers interface {
+	IsBusy(ctx context.Context, id, busID uuid.UUID, from, to time.Time) (bool, error)
+}
+
+type driversRepo struct {
+	ds dataStore.Driver
+}
+
+func (r driversRepo) IsBusy(ctx context.Context, id, busID uuid.UUID, from, to time.Time) (bool, error) {
+	return r.ds.IsBusy(ctx, id, busID, from, to)
+}
+
+func NewDrivers(db *gorm.DB) Drivers {
+	return &driversRepo{dataStore.NewDriver(db)}
+}
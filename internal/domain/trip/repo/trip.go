@@ -56,7 +56,9 @@ func NewTrip(db *gorm.DB) Trip {
 
 type Bus interface {
 	IsAvailable(ctx context.Context, id uuid.UUID, dates []time.Time) (bool, error)
+	IsBusy(ctx context.Context, id uuid.UUID, from, to time.Time) (bool, error)
 	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Bus, error)
 	GetAll(ctx context.Context) ([]entity.Bus, error)
 }
 
@@ -72,6 +74,14 @@ func (r busRepo) IsAvailable(ctx context.Context, id uuid.UUID, dates []time.Tim
 	return r.ds.IsAvailable(ctx, id, dates)
 }
 
+func (r busRepo) IsBusy(ctx context.Context, id uuid.UUID, from, to time.Time) (bool, error) {
+	return r.ds.IsBusy(ctx, id, from, to)
+}
+
+func (r busRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Bus, error) {
+	return r.ds.GetByID(ctx, id)
+}
+
 func (r busRepo) GetAll(ctx context.Context) ([]entity.Bus, error) {
 	return r.ds.GetAll(ctx)
 }
@@ -95,3 +105,19 @@ func (c countreisRepo) GetAll(ctx context.Context) ([]entity.Country, error) {
 func NewCountry(db *gorm.DB) Countries {
 	return &countreisRepo{dataStore.NewCountry(db)}
 }
+
+type Routes interface {
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error)
+}
+
+type routesRepo struct {
+	ds dataStore.Route
+}
+
+func (r routesRepo) GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error) {
+	return r.ds.GetByID(ctx, id)
+}
+
+func NewRoutes(db *gorm.DB) Routes {
+	return &routesRepo{dataStore.NewRoute(db)}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maryan_api/internal/domain/trip/repo"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/timeutil"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type Timetable interface {
+	Create(ctx context.Context, newTimetable entity.NewTimetableJSON) (uuid.UUID, error)
+	Delete(ctx context.Context, idStr string) error
+	GetByID(ctx context.Context, idStr string) (entity.Timetable, error)
+	GetTimetables(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Timetable, hypermedia.Links, error)
+	Generate(ctx context.Context, idStr, weeksStr string) (entity.TimetableGeneration, error)
+}
+
+type timetableService struct {
+	timetables repo.Timetable
+	tripRepo   repo.Trip
+	busRepo    repo.Bus
+	drivers    repo.Drivers
+	routes     repo.Routes
+}
+
+func (s *timetableService) Create(ctx context.Context, newTimetable entity.NewTimetableJSON) (uuid.UUID, error) {
+	timetable, err := newTimetable.Parse()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	busExists, err := s.busRepo.Exists(ctx, timetable.BusID)
+	if err != nil {
+		return uuid.Nil, err
+	} else if !busExists {
+		return uuid.Nil, rfc7807.BadRequest("non-existing-bus", "Non-existing Bus Error", "There is no bus assosiated with provided id.")
+	}
+
+	timetable.Route, err = s.routes.GetByID(ctx, timetable.RouteID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	timetable.ReturnRoute, err = s.routes.GetByID(ctx, timetable.ReturnRouteID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if timetable.ReturnOffset < int(timetable.Route.Duration().Minutes()) {
+		return uuid.Nil, rfc7807.BadRequest("timetable-invalid-data", "Timetable Data Error", "The return connection cannot leave before the outbound one arrives.")
+	}
+
+	// The generated trips have to pass the checks of the trips created one by
+	// one, which a trip leaving tomorrow tells.
+	trip := timetable.Trip(time.Now().AddDate(0, 0, 1))
+	params := trip.Validate()
+	if params != nil {
+		return uuid.Nil, rfc7807.BadRequest("timetable-invalid-data", "Timetable Data Error", "The trips of the timetable would not be valid.", params...)
+	}
+
+	return timetable.ID, s.timetables.Create(ctx, &timetable)
+}
+
+func (s *timetableService) Delete(ctx context.Context, idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rfc7807.UUID(err.Error())
+	}
+
+	return s.timetables.Delete(ctx, id)
+}
+
+func (s *timetableService) GetByID(ctx context.Context, idStr string) (entity.Timetable, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.Timetable{}, rfc7807.UUID(err.Error())
+	}
+
+	return s.timetables.GetByID(ctx, id)
+}
+
+func (s *timetableService) GetTimetables(ctx context.Context, paginationStr dbutil.PaginationStr) ([]entity.Timetable, hypermedia.Links, error) {
+	pagination, err := paginationStr.Parse([]string{}, "created_at", "line", "valid_from")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timetables, total, err, empty := s.timetables.GetTimetables(ctx, pagination)
+	if err != nil || empty {
+		return nil, nil, err
+	}
+
+	return timetables, hypermedia.Pagination(paginationStr, total), nil
+}
+
+// Generate creates the trips of the timetable for the following weeks. The
+// dates generated before are skipped, so it can be run again any time, and
+// the dates the bus or its drivers are taken on are reported as conflicts.
+func (s *timetableService) Generate(ctx context.Context, idStr, weeksStr string) (entity.TimetableGeneration, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return entity.TimetableGeneration{}, rfc7807.UUID(err.Error())
+	}
+
+	weeks, err := strconv.Atoi(weeksStr)
+	if err != nil || weeks < 1 || weeks > entity.MaxTimetableWeeks {
+		return entity.TimetableGeneration{}, rfc7807.BadRequest("timetable-weeks", "Timetable Weeks Error", fmt.Sprintf("The number of weeks has to be from 1 to %d.", entity.MaxTimetableWeeks))
+	}
+
+	timetable, err := s.timetables.GetByID(ctx, id)
+	if err != nil {
+		return entity.TimetableGeneration{}, err
+	}
+
+	bus, err := s.busRepo.GetByID(ctx, timetable.BusID)
+	if err != nil {
+		return entity.TimetableGeneration{}, err
+	}
+
+	now := time.Now().In(timetable.Location())
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7*weeks-1)
+
+	generated, err := s.timetables.GeneratedDates(ctx, id, from, to)
+	if err != nil {
+		return entity.TimetableGeneration{}, err
+	}
+
+	var generation = entity.TimetableGeneration{Created: []uuid.UUID{}, Conflicts: []entity.TimetableConflict{}}
+	for _, date := range timetable.Dates(from, to) {
+		if slices.ContainsFunc(generated, date.Equal) {
+			generation.Existing++
+			continue
+		}
+
+		trip := timetable.Trip(date)
+		if trip.OutboundConnection.DepartureTime.Before(time.Now()) {
+			continue
+		}
+
+		reason, err := s.conflict(ctx, bus, trip)
+		if err != nil {
+			return entity.TimetableGeneration{}, err
+		}
+
+		if reason != "" {
+			generation.Conflicts = append(generation.Conflicts, entity.TimetableConflict{Date: date, Reason: reason})
+			continue
+		}
+
+		trip.PreapareNew()
+		if err := s.tripRepo.Create(ctx, &trip); err != nil {
+			return entity.TimetableGeneration{}, err
+		}
+		generation.Created = append(generation.Created, trip.ID)
+	}
+
+	return generation, nil
+}
+
+// conflict tells why the bus cannot drive the trip, if it cannot.
+func (s *timetableService) conflict(ctx context.Context, bus entity.Bus, trip entity.Trip) (string, error) {
+	from, to := trip.OutboundConnection.DepartureTime, trip.ReturnConnection.ArrivalTime
+
+	available, err := s.busRepo.IsAvailable(ctx, bus.ID, timeutil.DatesBetween(from, to))
+	if err != nil {
+		return "", err
+	} else if !available {
+		return entity.TimetableConflictBusUnavailable, nil
+	}
+
+	busy, err := s.busRepo.IsBusy(ctx, bus.ID, from, to)
+	if err != nil {
+		return "", err
+	} else if busy {
+		return entity.TimetableConflictBusBusy, nil
+	}
+
+	if !bus.LeadDriverID.Valid {
+		return entity.TimetableConflictNoDriver, nil
+	}
+
+	for _, driverID := range []uuid.NullUUID{bus.LeadDriverID, bus.AssistantDriverID} {
+		if !driverID.Valid {
+			continue
+		}
+
+		busy, err := s.drivers.IsBusy(ctx, driverID.UUID, bus.ID, from, to)
+		if err != nil {
+			return "", err
+		} else if busy {
+			return entity.TimetableConflictDriverBusy, nil
+		}
+	}
+
+	return "", nil
+}
+
+func NewTimetableService(timetables repo.Timetable, trip repo.Trip, bus repo.Bus, drivers repo.Drivers, routes repo.Routes) Timetable {
+	return &timetableService{timetables, trip, bus, drivers, routes}
+}
@@ -26,6 +26,7 @@ type tripService struct {
 	tripRepo  repo.Trip
 	busRepo   repo.Bus
 	countries repo.Countries
+	routes    repo.Routes
 }
 
 func (s *tripService) CreateTestTrips(ctx context.Context) error {
@@ -44,7 +45,7 @@ func (s *tripService) CreateTestTrips(ctx context.Context) error {
 		Name: "Ukraine",
 	}
 
-	countries = slices.Delete(countries, slices.Index(countries, ukraine), slices.Index(countries, ukraine)+1)
+	countries = slices.DeleteFunc(countries, func(country entity.Country) bool { return country.ID == ukraine.ID })
 
 	buses, err := s.busRepo.GetAll(ctx)
 	if err != nil {
@@ -56,6 +57,8 @@ func (s *tripService) CreateTestTrips(ctx context.Context) error {
 	tomorrow := time.Now().Add(time.Hour*24 - time.Hour*time.Duration(time.Now().Hour()))
 
 	for i, country := range countries {
+		outboundRoute := entity.CountryRoute(ukraine, country, 48*time.Hour)
+		returnRoute := entity.CountryRoute(country, ukraine, 48*time.Hour)
 		for j := 0; j < 53; j++ {
 			tripID := uuid.New()
 			outbondConnectionID := uuid.New()
@@ -71,6 +74,8 @@ func (s *tripService) CreateTestTrips(ctx context.Context) error {
 					Line:                 (i + 1) * 100,
 					DepartureCountryID:   ukraine.ID,
 					DestinationCountryID: country.ID,
+					RouteID:              uuid.NullUUID{UUID: outboundRoute.ID, Valid: true},
+					Route:                outboundRoute,
 					DepartureTime:        tomorrow.Add(time.Duration(int(time.Hour) * 24 * j * 7)),
 					ArrivalTime:          tomorrow.Add(time.Duration(int(time.Hour)*24*j*7 + int(time.Hour)*24*2)),
 					GoogleMapsURL:        "",
@@ -88,6 +93,8 @@ func (s *tripService) CreateTestTrips(ctx context.Context) error {
 					Line:                 (i + 1) * 100,
 					DepartureCountryID:   country.ID,
 					DestinationCountryID: ukraine.ID,
+					RouteID:              uuid.NullUUID{UUID: returnRoute.ID, Valid: true},
+					Route:                returnRoute,
 					DepartureTime:        tomorrow.Add(time.Duration(int(time.Hour)*24*j*7 + int(time.Hour)*24*3)),
 					ArrivalTime:          tomorrow.Add(time.Duration(int(time.Hour)*24*j*7 + int(time.Hour)*24*5)),
 					GoogleMapsURL:        "",
@@ -108,6 +115,12 @@ func (s *tripService) CreateTestTrips(ctx context.Context) error {
 }
 
 func (s *tripService) Create(ctx context.Context, trip entity.Trip) (uuid.UUID, error) {
+	for _, connection := range []*entity.Connection{&trip.OutboundConnection, &trip.ReturnConnection} {
+		if err := s.applyRoute(ctx, connection); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
 	params := trip.Validate()
 	if params != nil {
 		return uuid.Nil, rfc7807.BadRequest("trip-data", "Trip Data Error", "Provided data is not valid.", params...)
@@ -136,6 +149,22 @@ func (s *tripService) Create(ctx context.Context, trip entity.Trip) (uuid.UUID,
 	return trip.ID, nil
 }
 
+// applyRoute takes the countries and the arrival time of the connection from
+// its route; a connection without one is rejected by the validation.
+func (s *tripService) applyRoute(ctx context.Context, connection *entity.Connection) error {
+	if !connection.RouteID.Valid {
+		return nil
+	}
+
+	route, err := s.routes.GetByID(ctx, connection.RouteID.UUID)
+	if err != nil {
+		return err
+	}
+
+	connection.ApplyRoute(route)
+	return nil
+}
+
 func (s *tripService) GetByID(ctx context.Context, idStr string) (entity.Trip, error) {
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -177,6 +206,6 @@ func (s *tripService) RegisterUpdate(ctx context.Context, update entity.TripUpda
 	return s.tripRepo.RegisterUpdate(ctx, &update)
 }
 
-func NewTripService(trip repo.Trip, bus repo.Bus, countries repo.Countries) Trip {
-	return &tripService{trip, bus, countries}
+func NewTripService(trip repo.Trip, bus repo.Bus, countries repo.Countries, routes repo.Routes) Trip {
+	return &tripService{trip, bus, countries, routes}
 }
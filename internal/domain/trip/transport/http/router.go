@@ -14,11 +14,19 @@ import (
 func RegisterRoutes(db *gorm.DB, s *gin.Engine, client *http.Client) {
 	adminRouter := ginutil.CreateAuthRouter("/admin", auth.Admin.SecretKey(), s)
 
-	handler := newTripHandler(service.NewTripService(repo.NewTrip(db), repo.NewBus(db), repo.NewCountry(db)))
+	handler := newTripHandler(service.NewTripService(repo.NewTrip(db), repo.NewBus(db), repo.NewCountry(db), repo.NewRoutes(db)))
 	//-----------------------Trip Routes---------------------------------------
-	adminRouter.POST("/trip", handler.Create)
+	adminRouter.POST("/trip", ginutil.Idempotency(db), handler.Create)
 	adminRouter.POST("/trip/test", handler.CreateTest)
 	adminRouter.GET("/trip/:id", handler.GetByID)
 	adminRouter.GET("/trips", handler.GetTrips)
 	adminRouter.POST("/trip/update", handler.RegisterUpdate)
+
+	timetables := newTimetableHandler(service.NewTimetableService(repo.NewTimetable(db), repo.NewTrip(db), repo.NewBus(db), repo.NewDrivers(db), repo.NewRoutes(db)))
+	//-----------------------Timetable Routes----------------------------------
+	adminRouter.POST("/timetables", ginutil.Idempotency(db), timetables.Create)
+	adminRouter.GET("/timetables", timetables.GetTimetables)
+	adminRouter.GET("/timetables/:id", timetables.GetByID)
+	adminRouter.DELETE("/timetables/:id", timetables.Delete)
+	adminRouter.POST("/timetables/:id/generate", timetables.Generate)
 }
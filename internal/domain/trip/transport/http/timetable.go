@@ -0,0 +1,144 @@
+package http
+
+import (
+	"maryan_api/config"
+	"maryan_api/internal/domain/trip/service"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	ginutil "maryan_api/pkg/ginutils"
+	"maryan_api/pkg/hypermedia"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type timetableHandler struct {
+	service service.Timetable
+}
+
+func (h timetableHandler) Create(ctx *gin.Context) {
+	var newTimetable entity.NewTimetableJSON
+
+	err := ctx.ShouldBindJSON(&newTimetable)
+	if err != nil {
+		ginutil.HandlerProblemAbort(ctx, rfc7807.BadRequest("timetable-data", "Timetable Data Error", err.Error()))
+		return
+	}
+
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	id, err := h.service.Create(ctxWithTimeout, newTimetable)
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ginutil.Response{
+		"The timetable has successfuly been created.",
+		hypermedia.Links{
+			hypermedia.Link{
+				"self", hypermedia.LinkData{config.APIURL() + "/admin/timetables/" + id.String(), "GET"},
+			},
+			hypermedia.Link{
+				"generate", hypermedia.LinkData{config.APIURL() + "/admin/timetables/" + id.String() + "/generate", "POST"},
+			},
+		},
+	})
+}
+
+func (h timetableHandler) Delete(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	err := h.service.Delete(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ginutil.Response{
+		Message: "The timetable has successfuly been deleted.",
+	})
+}
+
+func (h timetableHandler) GetByID(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	timetable, err := h.service.GetByID(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Timetable entity.Timetable `json:"timetable"`
+		ginutil.Response
+	}{
+		timetable,
+		ginutil.Response{
+			Message: "The timetable has successfuly been found.",
+		},
+	})
+}
+
+func (h timetableHandler) GetTimetables(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*20)
+	defer cancel()
+
+	timetables, urls, err := h.service.GetTimetables(ctxWithTimeout, dbutil.PaginationStr{
+		"/admin/timetables",
+		ctx.DefaultQuery("page", "1"),
+		ctx.DefaultQuery("size", "10"),
+		ctx.DefaultQuery("order_by", "created_at"),
+		ctx.DefaultQuery("order_way", "desc"),
+		"",
+	})
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Timetables []entity.Timetable `json:"timetables"`
+		Urls       hypermedia.Links   `json:"urls"`
+		ginutil.Response
+	}{
+		timetables,
+		urls,
+		ginutil.Response{
+			Message: "The timetables have successfuly been found.",
+		},
+	})
+}
+
+// Generate creates the trips of the timetable for the weeks given by the
+// weeks query parameter.
+func (h timetableHandler) Generate(ctx *gin.Context) {
+	ctxWithTimeout, cancel := ginutil.ContextWithTimeout(ctx, time.Second*60)
+	defer cancel()
+
+	generation, err := h.service.Generate(ctxWithTimeout, ctx.Param("id"), ctx.DefaultQuery("weeks", strconv.Itoa(entity.DefaultTimetableWeeks)))
+	if err != nil {
+		ginutil.ServiceErrorAbort(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Generation entity.TimetableGeneration `json:"generation"`
+		ginutil.Response
+	}{
+		generation,
+		ginutil.Response{
+			Message: "The trips of the timetable have successfuly been generated.",
+		},
+	})
+}
+
+func newTimetableHandler(service service.Timetable) timetableHandler {
+	return timetableHandler{service}
+}
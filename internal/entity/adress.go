@@ -1,8 +1,10 @@
 package entity
 
 import (
+	"log"
 	googleMaps "maryan_api/internal/infrastructure/clients/google/maps"
 	rfc7807 "maryan_api/pkg/problem"
+	"strings"
 	"time"
 
 	"github.com/d3code/uuid"
@@ -26,6 +28,39 @@ type Address struct {
 type Country struct {
 	ID   uuid.UUID `gorm:"type:binary(16);primaryKey"                       `
 	Name string    `gorm:"type:varchar(50);not null"`
+	// Timezone is the IANA name of the zone the schedules of the country are
+	// shown in; the times themselves are stored in UTC. The admins set it
+	// when they add or update the country.
+	Timezone string `gorm:"type:varchar(64);not null;default:'UTC'"`
+}
+
+func (c *Country) Validate() rfc7807.InvalidParams {
+	var params rfc7807.InvalidParams
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || len(c.Name) > 50 {
+		params.SetInvalidParam("name", "Must contain from 1 to 50 characters.")
+	}
+
+	// LoadLocation takes an empty name for UTC and Local for the zone of the
+	// server, neither of which is the zone of a country.
+	c.Timezone = strings.TrimSpace(c.Timezone)
+	if c.Timezone == "" || c.Timezone == "Local" {
+		params.SetInvalidParam("timezone", "Has to be an IANA time zone such as Europe/Kyiv.")
+	} else if _, err := time.LoadLocation(c.Timezone); err != nil {
+		params.SetInvalidParam("timezone", "Has to be an IANA time zone such as Europe/Kyiv.")
+	}
+
+	return params
+}
+
+func (c *Country) Prepare() rfc7807.InvalidParams {
+	params := c.Validate()
+	if params == nil {
+		c.ID = uuid.New()
+	}
+
+	return params
 }
 
 type NewAddress struct {
@@ -78,8 +113,88 @@ func (a *Address) Prepare(userID uuid.UUID) error {
 }
 
 func MigrateAddress(db *gorm.DB) error {
-	return db.AutoMigrate(
+	backfill := db.Migrator().HasTable(&Country{}) && !db.Migrator().HasColumn(&Country{}, "Timezone")
+
+	err := db.AutoMigrate(
 		&Country{},
 		&Address{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// The countries added before the time zones were stored get theirs once,
+	// when the column is added; later on UTC may be the admins' choice.
+	if backfill {
+		for name, timezone := range countryTimezones {
+			err := db.Model(&Country{}).
+				Where("name = ?", name).
+				Update("timezone", timezone).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var utc []string
+	err = db.Model(&Country{}).Where("timezone = ?", "UTC").Pluck("name", &utc).Error
+	if err != nil {
+		return err
+	}
+
+	for _, name := range utc {
+		log.Printf("WARNING: the schedules of %s are shown in UTC; set the time zone of the country through the admin API", name)
+	}
+
+	return nil
+}
+
+var countryTimezones = map[string]string{
+	"Albania":                "Europe/Tirane",
+	"Andorra":                "Europe/Andorra",
+	"Austria":                "Europe/Vienna",
+	"Belarus":                "Europe/Minsk",
+	"Belgium":                "Europe/Brussels",
+	"Bosnia and Herzegovina": "Europe/Sarajevo",
+	"Bulgaria":               "Europe/Sofia",
+	"Croatia":                "Europe/Zagreb",
+	"Cyprus":                 "Asia/Nicosia",
+	"Czech Republic":         "Europe/Prague",
+	"Czechia":                "Europe/Prague",
+	"Denmark":                "Europe/Copenhagen",
+	"Estonia":                "Europe/Tallinn",
+	"Finland":                "Europe/Helsinki",
+	"France":                 "Europe/Paris",
+	"Germany":                "Europe/Berlin",
+	"Greece":                 "Europe/Athens",
+	"Hungary":                "Europe/Budapest",
+	"Iceland":                "Atlantic/Reykjavik",
+	"Ireland":                "Europe/Dublin",
+	"Italy":                  "Europe/Rome",
+	"Latvia":                 "Europe/Riga",
+	"Liechtenstein":          "Europe/Vaduz",
+	"Lithuania":              "Europe/Vilnius",
+	"Luxembourg":             "Europe/Luxembourg",
+	"Malta":                  "Europe/Malta",
+	"Moldova":                "Europe/Chisinau",
+	"Monaco":                 "Europe/Monaco",
+	"Montenegro":             "Europe/Podgorica",
+	"Netherlands":            "Europe/Amsterdam",
+	"North Macedonia":        "Europe/Skopje",
+	"Norway":                 "Europe/Oslo",
+	"Poland":                 "Europe/Warsaw",
+	"Portugal":               "Europe/Lisbon",
+	"Romania":                "Europe/Bucharest",
+	"Russia":                 "Europe/Moscow",
+	"San Marino":             "Europe/San_Marino",
+	"Serbia":                 "Europe/Belgrade",
+	"Slovakia":               "Europe/Bratislava",
+	"Slovenia":               "Europe/Ljubljana",
+	"Spain":                  "Europe/Madrid",
+	"Sweden":                 "Europe/Stockholm",
+	"Switzerland":            "Europe/Zurich",
+	"Turkey":                 "Europe/Istanbul",
+	"Ukraine":                "Europe/Kyiv",
+	"United Kingdom":         "Europe/London",
+	"Vatican City":           "Europe/Vatican",
 }
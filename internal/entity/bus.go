@@ -24,6 +24,8 @@ type Bus struct {
 	AssistantDriverID  uuid.NullUUID  `gorm:"type:binary(16);unique"                  `
 	Seats              []Seat         `gorm:"foreignKey:BusID"                           `
 	Structure          []Row          `gorm:"foreignKey:BusID"                                   `
+	LuggageCapacity    int            `gorm:"type:SMALLINT;not null;default:0"           `
+	Amenities          []BusAmenity   `gorm:"foreignKey:BusID"                           `
 	CreatedAt          time.Time      `gorm:"not null"                                   `
 	UpdatedAt          time.Time      `gorm:"not null"                                   `
 	DeletedAt          gorm.DeletedAt `gorm:"index"                                      `
@@ -132,6 +134,55 @@ func (b BusImage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(b.Url)
 }
 
+type BusAmenity struct {
+	BusID   uuid.UUID  `gorm:"type:binary(16);primaryKey"                                                                          `
+	Amenity busAmenity `gorm:"type:enum('WiFi','Toilet','Air Conditioning','Power Outlets','Reclining Seats','Entertainment');primaryKey" `
+}
+
+func (a BusAmenity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Amenity)
+}
+
+type busAmenity string
+
+const (
+	BusAmenityWiFi            busAmenity = "WiFi"
+	BusAmenityToilet          busAmenity = "Toilet"
+	BusAmenityAirConditioning busAmenity = "Air Conditioning"
+	BusAmenityPowerOutlets    busAmenity = "Power Outlets"
+	BusAmenityRecliningSeats  busAmenity = "Reclining Seats"
+	BusAmenityEntertainment   busAmenity = "Entertainment"
+)
+
+func ParseBusAmenity(v string) (busAmenity, bool) {
+	switch busAmenity(v) {
+	case BusAmenityWiFi, BusAmenityToilet, BusAmenityAirConditioning, BusAmenityPowerOutlets, BusAmenityRecliningSeats, BusAmenityEntertainment:
+		return busAmenity(v), true
+	default:
+		return "", false
+	}
+}
+
+// ParseBusAmenities parses the amenities of a bus, leaving out the repeated
+// ones.
+func ParseBusAmenities(busID uuid.UUID, values []string) ([]BusAmenity, rfc7807.InvalidParams) {
+	var params rfc7807.InvalidParams
+	var amenities = make([]BusAmenity, 0, len(values))
+	for i, v := range values {
+		amenity, ok := ParseBusAmenity(v)
+		if !ok {
+			params.SetInvalidParam(fmt.Sprintf("amenities[%d]", i), "Non-existing amenity '"+v+"'.")
+			continue
+		}
+
+		if !slices.ContainsFunc(amenities, func(a BusAmenity) bool { return a.Amenity == amenity }) {
+			amenities = append(amenities, BusAmenity{BusID: busID, Amenity: amenity})
+		}
+	}
+
+	return amenities, params
+}
+
 type BusAvailability struct {
 	BusID   uuid.UUID             `gorm:"type:binary(16); not null"                                                  json:"-"`
 	Status  busAvailabilityStatus `gorm:"type:enum('Other','Broken','Busy'); not null"                         json:"status"`
@@ -179,6 +230,10 @@ func (b *Bus) Prepare() rfc7807.InvalidParams {
 	}
 
 	b.ID = uuid.New()
+	for i := range b.Amenities {
+		b.Amenities[i].BusID = b.ID
+	}
+
 	var seatNumbers = map[int]int{}
 
 	for i, seat := range b.Seats {
@@ -239,6 +294,7 @@ func MigrateBus(db *gorm.DB) error {
 		&Row{},
 		&SeatPosition{},
 		&BusImage{},
+		&BusAmenity{},
 	)
 }
 
@@ -247,12 +303,15 @@ type CustomerBus struct {
 	Images             []string                 `json:"imageURLs"`
 	RegistrationNumber string                   `json:"registrationNumber"`
 	Year               int                      `json:"year"`
+	Amenities          []BusAmenity             `json:"amenities"`
 	Structure          [][]ResponseCustomerSeat `json:"structure"`
 }
 
 type ResponseCustomerSeat struct {
 	ResponseSeat
-	Taken bool `json:"taken"`
+	Taken     bool `json:"taken"`
+	Surcharge int  `json:"surcharge"`
+	Price     int  `json:"price"`
 }
 
 type ResponseSeat struct {
@@ -262,7 +321,7 @@ type ResponseSeat struct {
 	Direction string    `json:"direction"`
 }
 
-func (b Bus) ToCustomerBus(takenSeatsIDs []uuid.UUID) CustomerBus {
+func (b Bus) ToCustomerBus(takenSeatsIDs []uuid.UUID, basePrice int, seatSurcharges map[uuid.UUID]int) CustomerBus {
 	var imageUrls = make([]string, len(b.Images))
 	for i, image := range b.Images {
 		imageUrls[i] = image.Url
@@ -273,11 +332,12 @@ func (b Bus) ToCustomerBus(takenSeatsIDs []uuid.UUID) CustomerBus {
 		Images:             imageUrls,
 		RegistrationNumber: b.RegistrationNumber,
 		Year:               b.Year,
-		Structure:          b.responseCustomerStructure(takenSeatsIDs),
+		Amenities:          b.Amenities,
+		Structure:          b.responseCustomerStructure(takenSeatsIDs, basePrice, seatSurcharges),
 	}
 }
 
-func (b Bus) responseCustomerStructure(takenSeatsIDs []uuid.UUID) [][]ResponseCustomerSeat {
+func (b Bus) responseCustomerStructure(takenSeatsIDs []uuid.UUID, basePrice int, seatSurcharges map[uuid.UUID]int) [][]ResponseCustomerSeat {
 	var structure = make([][]ResponseCustomerSeat, len(b.Structure))
 
 	for _, row := range b.Structure {
@@ -298,7 +358,9 @@ func (b Bus) responseCustomerStructure(takenSeatsIDs []uuid.UUID) [][]ResponseCu
 						Number:    b.Seats[seatIndex].Number,
 						ID:        b.Seats[seatIndex].ID,
 						Direction: string(b.Seats[seatIndex].Direction)},
-					Taken: slices.ContainsFunc(takenSeatsIDs, func(id uuid.UUID) bool { return id == b.Seats[seatIndex].ID }),
+					Taken:     slices.ContainsFunc(takenSeatsIDs, func(id uuid.UUID) bool { return id == b.Seats[seatIndex].ID }),
+					Surcharge: seatSurcharges[b.Seats[seatIndex].ID],
+					Price:     basePrice + seatSurcharges[b.Seats[seatIndex].ID],
 				}
 			}
 
@@ -346,6 +408,8 @@ type EmployeeBus struct {
 	LeadDriver         User             `json:"leadDriver"`
 	AssistantDriver    User             `json:"assistantDriver"`
 	Structure          [][]ResponseSeat `json:"structure"`
+	LuggageCapacity    int              `json:"luggageCapacity"`
+	Amenities          []BusAmenity     `json:"amenities"`
 	CreatedAt          time.Time        `json:"createdAt"`
 	UpdatedAt          time.Time        `json:"updatedAt"`
 	DeletedAt          gorm.DeletedAt   `json:"deletedAt"`
@@ -364,6 +428,8 @@ func (b Bus) ToEmployeeBus() EmployeeBus {
 		RegistrationNumber: b.RegistrationNumber,
 		Year:               b.Year,
 		Structure:          b.responseStructure(),
+		LuggageCapacity:    b.LuggageCapacity,
+		Amenities:          b.Amenities,
 		LeadDriver:         b.LeadDriver,
 		AssistantDriver:    b.AssistantDriver,
 		CreatedAt:          b.CreatedAt,
@@ -380,6 +446,8 @@ type NewBus struct {
 	LeadDriverID       uuid.NullUUID `gorm:"type:uuid;not null"                           json:"leadDriverID"`
 	AssistantDriverID  uuid.NullUUID `gorm:"type:uuid;not null"                           json:"assistantDriverID"`
 	Structure          [][]NewSeat   `gorm:"not null"                                     json:"structure"`
+	LuggageCapacity    int           `                                                    json:"luggageCapacity"`
+	Amenities          []string      `                                                    json:"amenities"`
 }
 
 type NewSeat struct {
@@ -397,6 +465,7 @@ func (nb NewBus) Parse() (Bus, rfc7807.InvalidParams) {
 		LeadDriverID:       nb.LeadDriverID,
 		AssistantDriverID:  nb.AssistantDriverID,
 		Structure:          make([]Row, len(nb.Structure)),
+		LuggageCapacity:    nb.LuggageCapacity,
 	}
 
 	var InvalidParams rfc7807.InvalidParams
@@ -449,6 +518,14 @@ func (nb NewBus) Parse() (Bus, rfc7807.InvalidParams) {
 
 	}
 
+	if nb.LuggageCapacity < 0 {
+		InvalidParams.SetInvalidParam("luggageCapacity", "Cannot be less than 0.")
+	}
+
+	amenities, params := ParseBusAmenities(uuid.Nil, nb.Amenities)
+	InvalidParams = append(InvalidParams, params...)
+	bus.Amenities = amenities
+
 	return bus, InvalidParams
 }
 
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	rfc7807 "maryan_api/pkg/problem"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// CashTransaction is one line of an employee's cash ledger: money taken for a
+// ticket sold on board or at the office, money given back for a cancelled
+// one, or money handed in to the company. A refund has a negative amount, so
+// the lines of a ledger add up to the cash the employee should hold.
+type CashTransaction struct {
+	ID           uuid.UUID           `gorm:"type:binary(16);primaryKey"                     json:"id"`
+	EmployeeID   uuid.UUID           `gorm:"type:binary(16);not null;index"                 json:"employeeId"`
+	Type         cashTransactionType `gorm:"type:enum('Sale','Refund','Handover');not null" json:"type"`
+	Amount       int                 `gorm:"type:MEDIUMINT;not null"                        json:"amount"`
+	TicketID     uuid.NullUUID       `gorm:"type:binary(16);index"                          json:"ticketId"`
+	ConnectionID uuid.NullUUID       `gorm:"type:binary(16);index"                          json:"connectionId"`
+	RecordedByID uuid.NullUUID       `gorm:"type:binary(16)"                                json:"recordedById"`
+	Comment      string              `gorm:"type:varchar(500)"                              json:"comment"`
+	CreatedAt    time.Time           `gorm:"not null;index"                                 json:"createdAt"`
+}
+
+type cashTransactionType string
+
+const (
+	CashTransactionSale     cashTransactionType = "Sale"
+	CashTransactionRefund   cashTransactionType = "Refund"
+	CashTransactionHandover cashTransactionType = "Handover"
+)
+
+func NewCashSale(employeeID uuid.UUID, ticket *Ticket) CashTransaction {
+	return CashTransaction{
+		ID:           uuid.New(),
+		EmployeeID:   employeeID,
+		Type:         CashTransactionSale,
+		Amount:       ticket.TicketPayment.Price,
+		TicketID:     uuid.NullUUID{UUID: ticket.ID, Valid: true},
+		ConnectionID: uuid.NullUUID{UUID: ticket.ConnectionID, Valid: true},
+	}
+}
+
+// NewCashRefund records the amount the employee gives back for the ticket.
+func NewCashRefund(employeeID uuid.UUID, ticket *Ticket, amount int) CashTransaction {
+	return CashTransaction{
+		ID:           uuid.New(),
+		EmployeeID:   employeeID,
+		Type:         CashTransactionRefund,
+		Amount:       -amount,
+		TicketID:     uuid.NullUUID{UUID: ticket.ID, Valid: true},
+		ConnectionID: uuid.NullUUID{UUID: ticket.ConnectionID, Valid: true},
+	}
+}
+
+// CashSaleJSON is a purchase made by an employee for cash; without a customer
+// the tickets stay on the employee's account.
+type CashSaleJSON struct {
+	NewTicketJSON
+	CustomerID uuid.NullUUID `json:"customerId"`
+}
+
+type CashHandoverJSON struct {
+	EmployeeID uuid.UUID `json:"employeeId"`
+	Amount     int       `json:"amount"`
+	Comment    string    `json:"comment"`
+}
+
+func (h CashHandoverJSON) Parse(adminID uuid.UUID) (CashTransaction, error) {
+	var params rfc7807.InvalidParams
+
+	if h.Amount <= 0 {
+		params.SetInvalidParam("amount", "Must be greater than zero.")
+	}
+
+	if len(h.Comment) > 500 {
+		params.SetInvalidParam("comment", "Must not be longer than 500 characters.")
+	}
+
+	if params != nil {
+		return CashTransaction{}, rfc7807.BadRequest("cash-handover-invalid-data", "Cash Handover Data Error", "Provided data is not valid.", params...)
+	}
+
+	return CashTransaction{
+		ID:           uuid.New(),
+		EmployeeID:   h.EmployeeID,
+		Type:         CashTransactionHandover,
+		Amount:       h.Amount,
+		RecordedByID: uuid.NullUUID{UUID: adminID, Valid: true},
+		Comment:      h.Comment,
+	}, nil
+}
+
+type CashLedger struct {
+	TripID  uuid.UUID         `json:"tripId"`
+	Entries []CashTransaction `json:"entries"`
+	Total   int               `json:"total"`
+}
+
+// CashReconciliation compares the cash an employee collected during a day
+// with the cash handed in; a positive difference is still missing.
+type CashReconciliation struct {
+	EmployeeID uuid.UUID `json:"employeeId"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	Expected   int       `json:"expected"`
+	HandedIn   int       `json:"handedIn"`
+	Difference int       `json:"difference"`
+}
+
+func MigrateCash(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&CashTransaction{},
+	)
+}
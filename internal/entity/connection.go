@@ -1,9 +1,12 @@
 package entity
 
 import (
-	"maryan_api/config"
+	"fmt"
 	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/timezone"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/d3code/uuid"
@@ -15,7 +18,9 @@ type Connection struct {
 	ID   uuid.UUID `gorm:"type:binary(16);primaryKey" json:"-"`
 	Line int       `gorm:"type:SMALLINT;not null" json:"line"`
 
-	Price int `gorm:"type:MEDIUMINT;not null" json:"price"`
+	Price            int `gorm:"type:MEDIUMINT;not null" json:"price"`
+	TeenagerDiscount int `gorm:"type:TINYINT;not null;default:0" json:"teenagerDiscount"`
+	ChildDiscount    int `gorm:"type:TINYINT;not null;default:0" json:"childDiscount"`
 
 	DepartureCountryID uuid.UUID `gorm:"type:binary(16);not null" json:"-"`
 	DepartureCountry   Country   `gorm:"foreignKey:DepartureCountryID;references:ID" json:"departureCountry"`
@@ -23,10 +28,19 @@ type Connection struct {
 	DestinationCountryID uuid.UUID `gorm:"type:binary(16);not null" json:"-"`
 	DestinationCountry   Country   `gorm:"foreignKey:DestinationCountryID;references:ID" json:"destinationCountry"`
 
+	RouteID uuid.NullUUID `gorm:"type:binary(16);index" json:"routeId"`
+	Route   Route         `gorm:"foreignKey:RouteID;references:ID" json:"route"`
+	// Segment is the part of the route the connection has been narrowed down
+	// to by OnSegment.
+	Segment Segment `gorm:"-" json:"segment"`
+
 	DepartureTime     time.Time `gorm:"not null" json:"departureTime"`
 	ArrivalTime       time.Time `gorm:"not null" json:"arrivalTime"`
 	EstimatedDuration int       `gorm:"-" json:"estimatedDuration"`
-	GoogleMapsURL     string    `gorm:"not null" json:"googleMapsConnectionURL"`
+	// The departure and the arrival in the time zones of their countries.
+	LocalDepartureTime time.Time `gorm:"-" json:"localDepartureTime"`
+	LocalArrivalTime   time.Time `gorm:"-" json:"localArrivalTime"`
+	GoogleMapsURL      string    `gorm:"not null" json:"googleMapsConnectionURL"`
 
 	BusID uuid.UUID `gorm:"type:binary(16);not null" json:"-"`
 	Bus   Bus       `gorm:"foreignKey:BusID" json:"bus"`
@@ -40,9 +54,28 @@ type Connection struct {
 
 func (c *Connection) AfterFind(tx *gorm.DB) (err error) {
 	c.EstimatedDuration = int(c.ArrivalTime.Sub(c.DepartureTime).Minutes())
+	c.LocalDepartureTime, c.LocalArrivalTime = c.LocalTimes()
 	return
 }
 
+// LocalTimes returns the departure and the arrival in the time zones of their
+// countries, or in UTC for the countries without a known zone.
+func (c *Connection) LocalTimes() (time.Time, time.Time) {
+	return localTime(c.DepartureTime, c.DepartureCountry), localTime(c.ArrivalTime, c.DestinationCountry)
+}
+
+func localTime(t time.Time, country Country) time.Time {
+	return t.In(countryLocation(country))
+}
+
+func countryLocation(country Country) *time.Location {
+	location, ok := timezone.Location(country.Name)
+	if !ok {
+		return time.UTC
+	}
+	return location
+}
+
 type connectionType string
 type ConnectionType struct {
 	Val connectionType
@@ -100,13 +133,16 @@ const (
 	CouldNotBeFinishConnectionStatus     = "Could Not Be Finished"
 )
 
+// Stop is a pick-up or a drop-off of a ticket; the stops of a cancelled ticket
+// are soft-deleted, so they stay in its history but leave the manifests.
 type Stop struct {
-	ID           uuid.UUID    `gorm:"type:binary(16);primaryKey"                         json:"id"`
-	TicketID     uuid.UUID    `gorm:"type:binary(16);not null"                                     json:"-"`
-	Ticket       Ticket       `gorm:"foreignKey:TicketID"                          json:"ticket"`
-	ConnectionID uuid.UUID    `gorm:"type:binary(16);not null"                                     json:"-"`
-	Type         stopType     `gorm:"type:enum('Pick-up','Drop-off')"              json:"type"`
-	Updates      []StopUpdate `                                                    json:"updates"`
+	ID           uuid.UUID      `gorm:"type:binary(16);primaryKey"                         json:"id"`
+	TicketID     uuid.UUID      `gorm:"type:binary(16);not null"                                     json:"-"`
+	Ticket       Ticket         `gorm:"foreignKey:TicketID"                          json:"ticket"`
+	ConnectionID uuid.UUID      `gorm:"type:binary(16);not null"                                     json:"-"`
+	Type         stopType       `gorm:"type:enum('Pick-up','Drop-off')"              json:"type"`
+	Updates      []StopUpdate   `                                                    json:"updates"`
+	DeletedAt    gorm.DeletedAt `gorm:"index"                                        json:"-"`
 }
 
 type stopType string
@@ -127,14 +163,83 @@ const (
 	CompletedStopStatus stopStatus = "Completed"
 )
 
+// Status returns the status of the latest update of the stop.
+func (s Stop) Status() stopStatus {
+	var latest StopUpdate
+	for _, update := range s.Updates {
+		if !update.CreatedAt.Before(latest.CreatedAt) {
+			latest = update
+		}
+	}
+
+	return latest.Status
+}
+
+type CheckInJSON struct {
+	ConnectionID uuid.UUID `json:"connectionId"`
+	Token        string    `json:"token"`
+}
+
 func MigrateConnection(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&Connection{},
 		&ConnectionUpdate{},
 		&Stop{},
 		&StopUpdate{},
 	)
+	if err != nil {
+		return err
+	}
 
+	return migrateCountryRoutes(db)
+}
+
+// migrateCountryRoutes gives the connections created before routes, which
+// only know their countries, a CountryRoute; the search joins the stations of
+// the routes, so it would not find them otherwise. The connections between the
+// same countries taking the same time share a route.
+func migrateCountryRoutes(db *gorm.DB) error {
+	var connections []Connection
+	err := db.
+		Preload("DepartureCountry").
+		Preload("DestinationCountry").
+		Where("route_id IS NULL").
+		Find(&connections).Error
+	if err != nil || len(connections) == 0 {
+		return err
+	}
+
+	type routeKey struct {
+		departure, destination uuid.UUID
+		duration               time.Duration
+	}
+
+	var routes = map[routeKey]uuid.UUID{}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, connection := range connections {
+			duration := connection.ArrivalTime.Sub(connection.DepartureTime)
+			key := routeKey{connection.DepartureCountryID, connection.DestinationCountryID, duration}
+
+			routeID, ok := routes[key]
+			if !ok {
+				route := CountryRoute(connection.DepartureCountry, connection.DestinationCountry, duration)
+				err := tx.Create(&route).Error
+				if err != nil {
+					return err
+				}
+
+				routeID = route.ID
+				routes[key] = routeID
+			}
+
+			err := tx.Model(&Connection{}).Where("id = ?", connection.ID).Update("route_id", routeID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
 func (c *Connection) Validate() rfc7807.InvalidParams {
@@ -144,6 +249,18 @@ func (c *Connection) Validate() rfc7807.InvalidParams {
 		params.SetInvalidParam("DepartureTime", "Past time.")
 	}
 
+	if !c.RouteID.Valid {
+		params.SetInvalidParam("routeId", "Required.")
+	}
+
+	if c.TeenagerDiscount < 0 || c.TeenagerDiscount > 100 {
+		params.SetInvalidParam("teenagerDiscount", "Must be a percentage between 0 and 100.")
+	}
+
+	if c.ChildDiscount < 0 || c.ChildDiscount > 100 {
+		params.SetInvalidParam("childDiscount", "Must be a percentage between 0 and 100.")
+	}
+
 	return params
 }
 
@@ -165,16 +282,55 @@ func (c *Connection) PrepareNew() {
 //
 //
 
+func (c *Connection) IsDrivenBy(driverID uuid.UUID) bool {
+	return (c.Bus.LeadDriverID.Valid && c.Bus.LeadDriverID.UUID == driverID) ||
+		(c.Bus.AssistantDriverID.Valid && c.Bus.AssistantDriverID.UUID == driverID)
+}
+
+// Fare returns the price of one seat for the passenger category; the
+// connection price is the adult fare and the others are discounted from it.
+func (c *Connection) Fare(category passengerCategory) int {
+	switch category {
+	case PassengerCategoryTeenager:
+		return c.Price * (100 - c.TeenagerDiscount) / 100
+	case PassengerCategoryChild:
+		return c.Price * (100 - c.ChildDiscount) / 100
+	default:
+		return c.Price
+	}
+}
+
+func (c *Connection) PartyPrice(adults, teenagers, children int) int {
+	return adults*c.Fare(PassengerCategoryAdult) +
+		teenagers*c.Fare(PassengerCategoryTeenager) +
+		children*c.Fare(PassengerCategoryChild)
+}
+
 func (c *Connection) Simplify() ConnectionSimplified {
+	segment := c.Segment
+	if segment.To == 0 {
+		segment = c.FullSegment()
+	}
+	departureCity, destinationCity := c.SegmentCities(segment)
+	localDepartureTime, localArrivalTime := c.LocalTimes()
+
 	return ConnectionSimplified{
 		ID:                 c.ID,
 		Price:              c.Price,
+		TeenagerPrice:      c.Fare(PassengerCategoryTeenager),
+		ChildPrice:         c.Fare(PassengerCategoryChild),
 		DepartureCountry:   c.DepartureCountry.Name,
 		DestinationCountry: c.DestinationCountry.Name,
+		DepartureCity:      departureCity,
+		DestinationCity:    destinationCity,
 		DepartureTime:      c.DepartureTime,
 		ArrivalTime:        c.ArrivalTime,
+		LocalDepartureTime: localDepartureTime,
+		LocalArrivalTime:   localArrivalTime,
 		Line:               c.Line,
 		EstimatedDuration:  c.EstimatedDuration,
+		Segment:            segment,
+		Amenities:          c.Bus.Amenities,
 	}
 }
 
@@ -185,11 +341,11 @@ type CustomerConnection struct {
 	Stops                   []Stop      `json:"stops"`
 }
 
-func (c *Connection) ToCustomer(takenSeatsIDs []uuid.UUID) CustomerConnection {
+func (c *Connection) ToCustomer(takenSeatsIDs []uuid.UUID, surcharges []SeatSurcharge) CustomerConnection {
 	return CustomerConnection{
 		ConnectionSimplified:    c.Simplify(),
 		GoogleMapsConnectionURL: c.GoogleMapsURL,
-		Bus:                     c.Bus.ToCustomerBus(takenSeatsIDs),
+		Bus:                     c.Bus.ToCustomerBus(takenSeatsIDs, c.Price, c.Bus.SeatSurcharges(surcharges)),
 		Stops:                   c.Stops,
 	}
 }
@@ -202,11 +358,24 @@ func PreloadConnection() []string {
 		"Bus.LeadDriver",
 		"Bus.AssistantDriver",
 		"Bus.Seats",
+		"Bus.Amenities",
 		"Bus.Structure",
 		"Bus.Structure.Positions",
+		"Route.Stations",
+		"Route.Stations.Country",
 	}
 }
 
+func PreloadDriverConnection() []string {
+	return append(PreloadConnection(),
+		"Stops.Updates",
+		"Stops.Ticket.Seat",
+		"Stops.Ticket.Passenger",
+		"Stops.Ticket.PickUpAdress",
+		"Stops.Ticket.DropOffAdress",
+	)
+}
+
 type FindConnectionsRequestJSON struct {
 	From      string `json:"from"`
 	To        string `json:"to"`
@@ -215,6 +384,22 @@ type FindConnectionsRequestJSON struct {
 	Children  string `json:"children"`
 	Teenagers string `json:"teenagers"`
 	Range     string `json:"range"`
+	// MinTransfer and MaxTransfer bound the minutes the itineraries wait
+	// for their next legs.
+	MinTransfer string `json:"minTransfer"`
+	MaxTransfer string `json:"maxTransfer"`
+	// The filters below are optional. The windows are clock times such as
+	// 06:30 at the searched cities, the maximum duration is in minutes and
+	// the amenities are separated by commas.
+	DepartureFrom string `json:"departureFrom"`
+	DepartureTo   string `json:"departureTo"`
+	ArrivalFrom   string `json:"arrivalFrom"`
+	ArrivalTo     string `json:"arrivalTo"`
+	MaxPrice      string `json:"maxPrice"`
+	MaxDuration   string `json:"maxDuration"`
+	Amenities     string `json:"amenities"`
+	SeatType      string `json:"seatType"`
+	Sort          string `json:"sort"`
 }
 
 func (r FindConnectionsRequestJSON) Parse() (FindConnectionsRequest, rfc7807.InvalidParams) {
@@ -256,62 +441,250 @@ func (r FindConnectionsRequestJSON) Parse() (FindConnectionsRequest, rfc7807.Inv
 		invalidParams.SetInvalidParam("range", "cannot be less that 0")
 	}
 
-	if invalidParams != nil {
-		return FindConnectionsRequest{}, invalidParams
+	minTransfer, maxTransfer := DefaultMinTransferTime, DefaultMaxTransferTime
+	if r.MinTransfer != "" {
+		minutes, err := strconv.Atoi(r.MinTransfer)
+		if err != nil {
+			invalidParams.SetInvalidParam("minTransfer", err.Error())
+		}
+		minTransfer = time.Duration(minutes) * time.Minute
+	}
+
+	if r.MaxTransfer != "" {
+		minutes, err := strconv.Atoi(r.MaxTransfer)
+		if err != nil {
+			invalidParams.SetInvalidParam("maxTransfer", err.Error())
+		}
+		maxTransfer = time.Duration(minutes) * time.Minute
 	}
 
-	fromID, exists := config.CountryExists(r.From)
-	if !exists {
-		invalidParams.SetInvalidParam("from", "Non-existing country")
+	if minTransfer < MinTransferTime {
+		invalidParams.SetInvalidParam("minTransfer", fmt.Sprintf("cannot be less that %d.", int(MinTransferTime.Minutes())))
+	} else if maxTransfer < minTransfer || maxTransfer > MaxTransferTime {
+		invalidParams.SetInvalidParam("maxTransfer", "Has to be between minTransfer and 1440.")
 	}
 
-	toID, exists := config.CountryExists(r.To)
-	if !exists {
-		invalidParams.SetInvalidParam("to", "Non-existing country")
+	departureWindow := parseClockWindow(r.DepartureFrom, r.DepartureTo, "departureFrom", "departureTo", &invalidParams)
+	arrivalWindow := parseClockWindow(r.ArrivalFrom, r.ArrivalTo, "arrivalFrom", "arrivalTo", &invalidParams)
+
+	var maxPrice, maxDuration int
+	if r.MaxPrice != "" {
+		maxPrice, err = strconv.Atoi(r.MaxPrice)
+		if err != nil {
+			invalidParams.SetInvalidParam("maxPrice", err.Error())
+		} else if maxPrice < 1 {
+			invalidParams.SetInvalidParam("maxPrice", "cannot be less that 1.")
+		}
+	}
+
+	if r.MaxDuration != "" {
+		maxDuration, err = strconv.Atoi(r.MaxDuration)
+		if err != nil {
+			invalidParams.SetInvalidParam("maxDuration", err.Error())
+		} else if maxDuration < 1 {
+			invalidParams.SetInvalidParam("maxDuration", "cannot be less that 1.")
+		}
+	}
+
+	var amenities []busAmenity
+	if r.Amenities != "" {
+		for _, v := range strings.Split(r.Amenities, ",") {
+			amenity, ok := ParseBusAmenity(strings.TrimSpace(v))
+			if !ok {
+				invalidParams.SetInvalidParam("amenities", "Non-existing amenity '"+v+"'.")
+			} else if !slices.Contains(amenities, amenity) {
+				amenities = append(amenities, amenity)
+			}
+		}
+	}
+
+	var seatType seatType
+	if r.SeatType != "" {
+		var ok bool
+		seatType, ok = defineSeatType(r.SeatType)
+		if !ok {
+			invalidParams.SetInvalidParam("seatType", "Non-existing seat type '"+r.SeatType+"'.")
+		}
+	}
+
+	sort := ConnectionsSortDeparture
+	if r.Sort != "" {
+		var ok bool
+		sort, ok = parseConnectionsSort(r.Sort)
+		if !ok {
+			invalidParams.SetInvalidParam("sort", "Must be one of: price, departure, duration, seats_left.")
+		}
+	}
+
+	from, to := strings.TrimSpace(r.From), strings.TrimSpace(r.To)
+	if from == "" {
+		invalidParams.SetInvalidParam("from", "The city cannot be empty.")
+	}
+
+	if to == "" {
+		invalidParams.SetInvalidParam("to", "The city cannot be empty.")
+	} else if strings.EqualFold(from, to) {
+		invalidParams.SetInvalidParam("to", "Has to be another city than the departure one.")
+	}
+
+	if invalidParams != nil {
+		return FindConnectionsRequest{}, invalidParams
 	}
 
 	return FindConnectionsRequest{
-		From:      fromID,
-		To:        toID,
-		Date:      date,
-		Adults:    adults,
-		Children:  children,
-		Teenagers: teenagers,
-		Range:     connectionsRange,
+		From:        from,
+		To:          to,
+		Date:        date,
+		Adults:      adults,
+		Children:    children,
+		Teenagers:   teenagers,
+		Range:       connectionsRange,
+		MinTransfer: minTransfer,
+		MaxTransfer: maxTransfer,
+		Filters: ConnectionFilters{
+			Departure:   departureWindow,
+			Arrival:     arrivalWindow,
+			MaxPrice:    maxPrice,
+			MaxDuration: maxDuration,
+			Amenities:   amenities,
+			SeatType:    seatType,
+		},
+		Sort: sort,
 	}, nil
 
 }
 
+// FindConnectionsRequest looks for the connections whose routes call at the
+// From city and later at the To city.
 type FindConnectionsRequest struct {
-	From      uuid.UUID
-	To        uuid.UUID
+	From      string
+	To        string
 	Date      time.Time
 	Adults    int
 	Children  int
 	Teenagers int
 	Range     int
+	// MinTransfer and MaxTransfer bound the waits between the legs of the
+	// itineraries.
+	MinTransfer time.Duration
+	MaxTransfer time.Duration
+	Filters     ConnectionFilters
+	Sort        connectionsSort
+}
+
+// ConnectionFilters narrow the found connections down; the zero value of a
+// filter leaves it out. The prices and the durations are those of the
+// searched segment and the seat type has to be free on it for every
+// passenger.
+type ConnectionFilters struct {
+	Departure   *ClockWindow
+	Arrival     *ClockWindow
+	MaxPrice    int
+	MaxDuration int
+	Amenities   []busAmenity
+	SeatType    seatType
+}
+
+// ClockWindow is the part of a day between two clock times, formatted as
+// 15:04:05. A window ending before it starts goes on past midnight.
+type ClockWindow struct {
+	From string
+	To   string
+}
+
+func (w ClockWindow) PastMidnight() bool {
+	return w.To < w.From
+}
+
+// Contains reports whether the clock time of t falls into the window.
+func (w ClockWindow) Contains(t time.Time) bool {
+	clock := t.Format("15:04:05")
+	if w.PastMidnight() {
+		return clock >= w.From || clock <= w.To
+	}
+
+	return clock >= w.From && clock <= w.To
+}
+
+func parseClockWindow(fromStr, toStr, fromParam, toParam string, invalidParams *rfc7807.InvalidParams) *ClockWindow {
+	if fromStr == "" && toStr == "" {
+		return nil
+	}
+
+	var window = ClockWindow{"00:00:00", "23:59:59"}
+	if fromStr != "" {
+		from, err := time.Parse("15:04", fromStr)
+		if err != nil {
+			invalidParams.SetInvalidParam(fromParam, "Has to be a clock time such as 06:30.")
+		}
+		window.From = from.Format("15:04:05")
+	}
+
+	if toStr != "" {
+		to, err := time.Parse("15:04", toStr)
+		if err != nil {
+			invalidParams.SetInvalidParam(toParam, "Has to be a clock time such as 06:30.")
+		}
+		window.To = to.Format("15:04") + ":59"
+	}
+
+	return &window
+}
+
+type connectionsSort string
+
+const (
+	ConnectionsSortDeparture connectionsSort = "departure"
+	ConnectionsSortPrice     connectionsSort = "price"
+	ConnectionsSortDuration  connectionsSort = "duration"
+	ConnectionsSortSeatsLeft connectionsSort = "seats_left"
+)
+
+func parseConnectionsSort(v string) (connectionsSort, bool) {
+	switch connectionsSort(v) {
+	case ConnectionsSortDeparture, ConnectionsSortPrice, ConnectionsSortDuration, ConnectionsSortSeatsLeft:
+		return connectionsSort(v), true
+	default:
+		return "", false
+	}
+}
+
+func (r FindConnectionsRequest) Passengers() int {
+	return r.Adults + r.Children + r.Teenagers
 }
 
 type FindConnectionsResponse struct {
 	Connections []FoundConnection  `json:"connections"`
 	LeftRange   []ConnectionsRange `json:"leftRange"`
 	RigthRange  []ConnectionsRange `json:"rightRange"`
+	// Itineraries change buses on the way; they are filtered and sorted
+	// like the connections.
+	Itineraries []Itinerary `json:"itineraries"`
 }
 
 type FoundConnection struct {
 	ConnectionSimplified
 	TicketsLeft int  `json:"ticketsLeft"`
 	Fits        bool `json:"fits"`
+	TotalPrice  int  `json:"totalPrice"`
 }
 type ConnectionSimplified struct {
-	ID                 uuid.UUID `json:"id"`
-	Price              int       `json:"price"`
-	Line               int       `json:"line"`
-	DepartureCountry   string    `json:"departureCountry"`
-	DestinationCountry string    `json:"destinationCountry"`
-	DepartureTime      time.Time `json:"departureTime"`
-	ArrivalTime        time.Time `json:"arrivalTime"`
-	EstimatedDuration  int       `json:"estimatedDuration"`
+	ID                 uuid.UUID    `json:"id"`
+	Price              int          `json:"price"`
+	TeenagerPrice      int          `json:"teenagerPrice"`
+	ChildPrice         int          `json:"childPrice"`
+	Line               int          `json:"line"`
+	DepartureCountry   string       `json:"departureCountry"`
+	DestinationCountry string       `json:"destinationCountry"`
+	DepartureCity      string       `json:"departureCity,omitempty"`
+	DestinationCity    string       `json:"destinationCity,omitempty"`
+	DepartureTime      time.Time    `json:"departureTime"`
+	ArrivalTime        time.Time    `json:"arrivalTime"`
+	LocalDepartureTime time.Time    `json:"localDepartureTime"`
+	LocalArrivalTime   time.Time    `json:"localArrivalTime"`
+	EstimatedDuration  int          `json:"estimatedDuration"`
+	Segment            Segment      `json:"segment"`
+	Amenities          []BusAmenity `json:"amenities,omitempty"`
 }
 
 type ConnectionsRange struct {
@@ -0,0 +1,252 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// Extra is an add-on sold together with a ticket. Weight is counted against
+// the luggage capacity of the bus and MaxPerConnection caps how many units a
+// connection carries; zero means no limit for both.
+type Extra struct {
+	ID               uuid.UUID      `gorm:"type:binary(16);primaryKey"                               json:"id"`
+	Name             string         `gorm:"type:varchar(100);not null"                               json:"name"`
+	Type             extraType      `gorm:"type:enum('Suitcase','Oversized','Pet','Parcel');not null" json:"type"`
+	Price            int            `gorm:"type:MEDIUMINT;not null"                                  json:"price"`
+	Weight           int            `gorm:"type:SMALLINT;not null;default:0"                         json:"weight"`
+	MaxPerConnection int            `gorm:"type:SMALLINT;not null;default:0"                         json:"maxPerConnection"`
+	CreatedAt        time.Time      `gorm:"not null"                                                 json:"createdAt"`
+	UpdatedAt        time.Time      `gorm:"not null"                                                 json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt `                                                                json:"-"`
+}
+
+type extraType string
+
+const (
+	ExtraTypeSuitcase  extraType = "Suitcase"
+	ExtraTypeOversized extraType = "Oversized"
+	ExtraTypePet       extraType = "Pet"
+	ExtraTypeParcel    extraType = "Parcel"
+)
+
+func (e *Extra) Validate() rfc7807.InvalidParams {
+	var params rfc7807.InvalidParams
+
+	e.Name = strings.TrimSpace(e.Name)
+	if e.Name == "" || len(e.Name) > 100 {
+		params.SetInvalidParam("name", "Must contain from 1 to 100 characters.")
+	}
+
+	switch e.Type {
+	case ExtraTypeSuitcase, ExtraTypeOversized, ExtraTypePet, ExtraTypeParcel:
+	default:
+		params.SetInvalidParam("type", "Must be one of: Suitcase, Oversized, Pet, Parcel.")
+	}
+
+	if e.Price < 0 {
+		params.SetInvalidParam("price", "Cannot be less than 0.")
+	}
+
+	if e.Weight < 0 {
+		params.SetInvalidParam("weight", "Cannot be less than 0.")
+	}
+
+	if e.MaxPerConnection < 0 {
+		params.SetInvalidParam("maxPerConnection", "Cannot be less than 0.")
+	}
+
+	return params
+}
+
+func (e *Extra) Prepare() rfc7807.InvalidParams {
+	params := e.Validate()
+	if params == nil {
+		e.ID = uuid.New()
+	}
+
+	return params
+}
+
+// TicketExtra is an extra bought with the ticket; the name, the price and
+// the weight are copied from the catalog at the time of the purchase.
+type TicketExtra struct {
+	ID       uuid.UUID `gorm:"type:binary(16);primaryKey"     json:"id"`
+	TicketID uuid.UUID `gorm:"type:binary(16);not null;index" json:"-"`
+	ExtraID  uuid.UUID `gorm:"type:binary(16);not null;index" json:"extraId"`
+	Name     string    `gorm:"type:varchar(100);not null"     json:"name"`
+	Type     extraType `gorm:"type:varchar(20);not null"      json:"type"`
+	Quantity int       `gorm:"type:TINYINT;not null"          json:"quantity"`
+	Price    int       `gorm:"type:MEDIUMINT;not null"        json:"price"`
+	Weight   int       `gorm:"type:SMALLINT;not null"         json:"weight"`
+}
+
+// SeatHoldExtra is an extra reserved with a seat during checkout.
+type SeatHoldExtra struct {
+	ID         uuid.UUID `gorm:"type:binary(16);primaryKey"     json:"id"`
+	SeatHoldID uuid.UUID `gorm:"type:binary(16);not null;index" json:"-"`
+	ExtraID    uuid.UUID `gorm:"type:binary(16);not null;index" json:"extraId"`
+	Name       string    `gorm:"type:varchar(100);not null"     json:"name"`
+	Type       extraType `gorm:"type:varchar(20);not null"      json:"type"`
+	Quantity   int       `gorm:"type:TINYINT;not null"          json:"quantity"`
+	Price      int       `gorm:"type:MEDIUMINT;not null"        json:"price"`
+	Weight     int       `gorm:"type:SMALLINT;not null"         json:"weight"`
+}
+
+func (e SeatHoldExtra) toTicketExtra(ticketID uuid.UUID) TicketExtra {
+	return TicketExtra{
+		ID:       uuid.New(),
+		TicketID: ticketID,
+		ExtraID:  e.ExtraID,
+		Name:     e.Name,
+		Type:     e.Type,
+		Quantity: e.Quantity,
+		Price:    e.Price,
+		Weight:   e.Weight,
+	}
+}
+
+// AddExtra reserves the extra with the seat and adds its price to the hold.
+func (h *SeatHold) AddExtra(extra Extra, quantity int) {
+	h.reserveExtra(extra, quantity, extra.Price)
+	h.Price += extra.Price * quantity
+}
+
+// ReserveExtra reserves the extra with the seat without charging for it; the
+// later legs of an itinerary carry the extras paid for with the first one.
+func (h *SeatHold) ReserveExtra(extra Extra, quantity int) {
+	h.reserveExtra(extra, quantity, 0)
+}
+
+func (h *SeatHold) reserveExtra(extra Extra, quantity, price int) {
+	h.Extras = append(h.Extras, SeatHoldExtra{
+		ID:         uuid.New(),
+		SeatHoldID: h.ID,
+		ExtraID:    extra.ID,
+		Name:       extra.Name,
+		Type:       extra.Type,
+		Quantity:   quantity,
+		Price:      price,
+		Weight:     extra.Weight,
+	})
+}
+
+// CarryExtras reserves the extras of a ticket being moved with its new seat.
+// They are already paid for, so the price of the hold stays the same.
+func (h *SeatHold) CarryExtras(ticket Ticket) {
+	for _, extra := range ticket.Extras {
+		h.Extras = append(h.Extras, SeatHoldExtra{
+			ID:         uuid.New(),
+			SeatHoldID: h.ID,
+			ExtraID:    extra.ExtraID,
+			Name:       extra.Name,
+			Type:       extra.Type,
+			Quantity:   extra.Quantity,
+			Price:      extra.Price,
+			Weight:     extra.Weight,
+		})
+	}
+}
+
+func (h SeatHold) ExtrasPrice() int {
+	var price int
+	for _, extra := range h.Extras {
+		price += extra.Price * extra.Quantity
+	}
+	return price
+}
+
+func (t Ticket) ExtrasPrice() int {
+	var price int
+	for _, extra := range t.Extras {
+		price += extra.Price * extra.Quantity
+	}
+	return price
+}
+
+// NewTicketExtra orders an extra for the passenger at the given index of the
+// purchase.
+type NewTicketExtra struct {
+	ExtraID   uuid.UUID `json:"extraId"`
+	Quantity  int       `json:"quantity"`
+	Passenger int       `json:"passenger"`
+}
+
+// BookedExtra sums up the units of an extra sold or held on a connection.
+type BookedExtra struct {
+	ExtraID  uuid.UUID
+	Quantity int
+	Weight   int
+}
+
+// CheckExtrasCapacity reports whether the connection can still carry the
+// extras of the holds, given the extras already booked on it and the luggage
+// capacity of its bus.
+func CheckExtrasCapacity(luggageCapacity int, catalog []Extra, booked []BookedExtra, holds []*SeatHold) error {
+	var quantities = map[uuid.UUID]int{}
+	var weight int
+	for _, extra := range booked {
+		quantities[extra.ExtraID] += extra.Quantity
+		weight += extra.Weight
+	}
+
+	var requested bool
+	for _, hold := range holds {
+		for _, extra := range hold.Extras {
+			quantities[extra.ExtraID] += extra.Quantity
+			weight += extra.Weight * extra.Quantity
+			requested = true
+		}
+	}
+
+	if !requested {
+		return nil
+	}
+
+	for _, extra := range catalog {
+		if extra.MaxPerConnection > 0 && quantities[extra.ID] > extra.MaxPerConnection {
+			return rfc7807.BadRequest("extra-sold-out", "Extra Sold Out Error",
+				fmt.Sprintf("The connection cannot take more of '%s'.", extra.Name))
+		}
+	}
+
+	if luggageCapacity > 0 && weight > luggageCapacity {
+		return rfc7807.BadRequest("luggage-capacity", "Luggage Capacity Error", "The bus has no room left for that much luggage.")
+	}
+
+	return nil
+}
+
+// LuggageManifest lists the extras carried on a connection, so the driver
+// knows what to load at every pick-up.
+type LuggageManifest struct {
+	ConnectionID    uuid.UUID             `json:"connectionId"`
+	LuggageCapacity int                   `json:"luggageCapacity"`
+	TotalWeight     int                   `json:"totalWeight"`
+	Items           []LuggageManifestItem `json:"items"`
+}
+
+type LuggageManifestItem struct {
+	TicketID   uuid.UUID `json:"ticketId"`
+	SeatNumber int       `json:"seatNumber"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	PickUp     string    `json:"pickUp"`
+	Name       string    `json:"name"`
+	Type       extraType `json:"type"`
+	Quantity   int       `json:"quantity"`
+	Weight     int       `json:"weight"`
+}
+
+func MigrateExtra(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&Extra{},
+		&TicketExtra{},
+		&SeatHoldExtra{},
+	)
+}
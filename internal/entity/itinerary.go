@@ -0,0 +1,185 @@
+package entity
+
+import (
+	"cmp"
+	"fmt"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+// An itinerary changes buses at most twice and the search returns the best
+// MaxItineraries of them. The transfer time is the wait between arriving at
+// a city and leaving it on the next leg; the passengers need at least
+// MinTransferTime to change buses.
+const (
+	MaxItineraryLegs       = 3
+	MaxItineraries         = 20
+	MinTransferTime        = 15 * time.Minute
+	DefaultMinTransferTime = 30 * time.Minute
+	DefaultMaxTransferTime = 6 * time.Hour
+	MaxTransferTime        = 24 * time.Hour
+)
+
+// Itinerary is a journey made of connections narrowed down to the segments
+// the passengers travel, changing buses between them.
+type Itinerary struct {
+	Legs               []ItineraryLeg `json:"legs"`
+	DepartureTime      time.Time      `json:"departureTime"`
+	ArrivalTime        time.Time      `json:"arrivalTime"`
+	LocalDepartureTime time.Time      `json:"localDepartureTime"`
+	LocalArrivalTime   time.Time      `json:"localArrivalTime"`
+	Duration           int            `json:"duration"`
+	TotalPrice         int            `json:"totalPrice"`
+	TicketsLeft        int            `json:"ticketsLeft"`
+	Fits               bool           `json:"fits"`
+}
+
+type ItineraryLeg struct {
+	ConnectionSimplified
+	TicketsLeft int `json:"ticketsLeft"`
+	// TransferTime is the wait in minutes for the next leg.
+	TransferTime int `json:"transferTime"`
+}
+
+// NewItinerary builds the itinerary of the legs, each already narrowed down
+// to its segment; the tickets left are set by SetTicketsLeft.
+func NewItinerary(legs []Connection, adults, teenagers, children int) Itinerary {
+	itinerary := Itinerary{
+		Legs:          make([]ItineraryLeg, len(legs)),
+		DepartureTime: legs[0].DepartureTime,
+		ArrivalTime:   legs[len(legs)-1].ArrivalTime,
+	}
+	itinerary.Duration = int(itinerary.ArrivalTime.Sub(itinerary.DepartureTime).Minutes())
+	itinerary.LocalDepartureTime, _ = legs[0].LocalTimes()
+	_, itinerary.LocalArrivalTime = legs[len(legs)-1].LocalTimes()
+
+	for i, leg := range legs {
+		itinerary.Legs[i] = ItineraryLeg{ConnectionSimplified: leg.Simplify()}
+		if i < len(legs)-1 {
+			itinerary.Legs[i].TransferTime = int(legs[i+1].DepartureTime.Sub(leg.ArrivalTime).Minutes())
+		}
+		itinerary.TotalPrice += leg.PartyPrice(adults, teenagers, children)
+	}
+
+	return itinerary
+}
+
+// SetTicketsLeft sets the seats free on every leg; the itinerary has as many
+// as its fullest leg.
+func (i *Itinerary) SetTicketsLeft(ticketsLeft []int, passengers int) {
+	for j, left := range ticketsLeft {
+		i.Legs[j].TicketsLeft = left
+		if j == 0 || left < i.TicketsLeft {
+			i.TicketsLeft = left
+		}
+	}
+	i.Fits = i.TicketsLeft >= passengers
+}
+
+// Matches reports whether the itinerary passes the filters: its departure,
+// arrival, adult price and duration are those of the whole journey and every
+// leg has to offer the amenities. The seat type is checked against the seats
+// left on the legs by the search.
+func (f ConnectionFilters) Matches(i Itinerary) bool {
+	if f.Departure != nil && !f.Departure.Contains(i.LocalDepartureTime) {
+		return false
+	}
+
+	if f.Arrival != nil && !f.Arrival.Contains(i.LocalArrivalTime) {
+		return false
+	}
+
+	if f.MaxDuration > 0 && i.Duration > f.MaxDuration {
+		return false
+	}
+
+	var price int
+	for _, leg := range i.Legs {
+		price += leg.Price
+		for _, amenity := range f.Amenities {
+			if !slices.ContainsFunc(leg.Amenities, func(a BusAmenity) bool { return a.Amenity == amenity }) {
+				return false
+			}
+		}
+	}
+
+	return f.MaxPrice == 0 || price <= f.MaxPrice
+}
+
+// SortItineraries orders the itineraries like the connections of the search;
+// the ones departing first come first among the equal ones.
+func SortItineraries(itineraries []Itinerary, sort connectionsSort) {
+	slices.SortFunc(itineraries, func(a, b Itinerary) int {
+		var order int
+		switch sort {
+		case ConnectionsSortPrice:
+			order = cmp.Compare(a.TotalPrice, b.TotalPrice)
+		case ConnectionsSortDuration:
+			order = cmp.Compare(a.Duration, b.Duration)
+		case ConnectionsSortSeatsLeft:
+			order = cmp.Compare(b.TicketsLeft, a.TicketsLeft)
+		}
+
+		return cmp.Or(order, a.DepartureTime.Compare(b.DepartureTime))
+	})
+}
+
+// NewItineraryTicketJSON buys seats on every leg of an itinerary for the same
+// passengers. They change buses at the transfer addresses, one for every
+// transfer, which have to be in the cities the legs meet at.
+type NewItineraryTicketJSON struct {
+	Legs             []NewItineraryLeg `json:"legs"`
+	Passengers       []NewPassenger    `json:"passengers"`
+	PickUpAdress     NewAddress        `json:"pickUpAdress"`
+	DropOffAdress    NewAddress        `json:"dropOffAdress"`
+	TransferAdresses []NewAddress      `json:"transferAdresses"`
+	Email            string            `json:"email"`
+	PhoneNumber      string            `json:"phoneNumber"`
+	// Extras travel with their passengers on every leg and are paid for
+	// once.
+	Extras        []NewTicketExtra `json:"extras"`
+	LoyaltyPoints int              `json:"loyaltyPoints"`
+}
+
+type NewItineraryLeg struct {
+	ConnectionID uuid.UUID   `json:"connectionId"`
+	From         string      `json:"from"`
+	To           string      `json:"to"`
+	SeatIDs      []uuid.UUID `json:"seatIDs"`
+}
+
+func (t NewItineraryTicketJSON) Validate() error {
+	if len(t.Legs) < 2 || len(t.Legs) > MaxItineraryLegs {
+		return rfc7807.BadRequest("itinerary-legs", "Itinerary Legs Error", fmt.Sprintf("An itinerary has from 2 to %d legs.", MaxItineraryLegs))
+	}
+
+	if len(t.TransferAdresses) != len(t.Legs)-1 {
+		return rfc7807.BadRequest("transfer-adresses", "Transfer Adresses Error", "There has to be one address for every transfer.")
+	}
+
+	for i, leg := range t.Legs[1:] {
+		if !strings.EqualFold(strings.TrimSpace(t.Legs[i].To), strings.TrimSpace(leg.From)) {
+			return rfc7807.BadRequest("itinerary-legs", "Itinerary Legs Error", "Every leg has to leave from the city the previous one arrives at.")
+		}
+	}
+
+	return nil
+}
+
+// CheckTransfers reports whether the passengers can make it from every leg
+// to the next one.
+func CheckTransfers(legs []Connection) error {
+	for i, leg := range legs[1:] {
+		transfer := leg.DepartureTime.Sub(legs[i].ArrivalTime)
+		if transfer < MinTransferTime || transfer > MaxTransferTime {
+			return rfc7807.BadRequest("transfer-time", "Transfer Time Error",
+				fmt.Sprintf("The next leg has to leave from %d minutes to a day after the previous one arrives.", int(MinTransferTime.Minutes())))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// JobRun records one execution of a background job together with the number
+// of records it reclaimed.
+type JobRun struct {
+	ID         uuid.UUID `gorm:"type:binary(16);primaryKey"     json:"id"`
+	Job        string    `gorm:"type:varchar(100);not null;index" json:"job"`
+	Instance   string    `gorm:"type:varchar(255);not null"     json:"instance"`
+	StartedAt  time.Time `gorm:"not null;index"                 json:"startedAt"`
+	FinishedAt time.Time `gorm:"not null"                       json:"finishedAt"`
+	Reclaimed  int       `gorm:"not null"                       json:"reclaimed"`
+	Failed     bool      `gorm:"not null"                       json:"failed"`
+	Error      string    `gorm:"type:varchar(500)"              json:"error"`
+}
+
+func MigrateJobRun(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&JobRun{},
+	)
+}
@@ -0,0 +1,155 @@
+package entity
+
+import (
+	rfc7807 "maryan_api/pkg/problem"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// A completed ticket earns one point per full currency unit paid, multiplied
+// by the tier of the customer, and every point is worth one minor unit at the
+// next purchase.
+const (
+	LoyaltyPointsPerUnit = 100
+	LoyaltyPointValue    = 1
+)
+
+// LoyaltyTransaction is one line of a customer's points ledger; the balance
+// is the sum of the points of all of them.
+type LoyaltyTransaction struct {
+	ID          uuid.UUID              `gorm:"type:binary(16);primaryKey"                                                  json:"id"`
+	UserID      uuid.UUID              `gorm:"type:binary(16);not null;index"                                              json:"-"`
+	Type        loyaltyTransactionType `gorm:"type:enum('Earned','Redeemed','Reversed','Returned','Adjusted');not null"     json:"type"`
+	Points      int                    `gorm:"type:INT;not null"                                                           json:"points"`
+	TicketID    uuid.NullUUID          `gorm:"type:binary(16);index"                                                       json:"ticketId"`
+	Reason      string                 `gorm:"type:varchar(500)"                                                           json:"reason"`
+	CreatedByID uuid.NullUUID          `gorm:"type:binary(16)"                                                             json:"createdById"`
+	CreatedAt   time.Time              `gorm:"not null;index"                                                              json:"createdAt"`
+}
+
+// Earned points are reversed when their ticket is refunded and redeemed
+// points are returned when the tickets they paid for are refunded.
+type loyaltyTransactionType string
+
+const (
+	LoyaltyTransactionEarned   loyaltyTransactionType = "Earned"
+	LoyaltyTransactionRedeemed loyaltyTransactionType = "Redeemed"
+	LoyaltyTransactionReversed loyaltyTransactionType = "Reversed"
+	LoyaltyTransactionReturned loyaltyTransactionType = "Returned"
+	LoyaltyTransactionAdjusted loyaltyTransactionType = "Adjusted"
+)
+
+type LoyaltyTier struct {
+	Name string `json:"name"`
+	// MinTrips is the number of trips completed during the last year needed
+	// for the tier.
+	MinTrips int `json:"minTrips"`
+	// Multiplier is the percentage of the base points earned per ticket.
+	Multiplier int `json:"multiplier"`
+}
+
+// LoyaltyTiers are ordered from the lowest to the highest.
+var LoyaltyTiers = []LoyaltyTier{
+	{Name: "Bronze", MinTrips: 0, Multiplier: 100},
+	{Name: "Silver", MinTrips: 5, Multiplier: 125},
+	{Name: "Gold", MinTrips: 12, Multiplier: 150},
+}
+
+func LoyaltyTierFor(trips int) LoyaltyTier {
+	tier := LoyaltyTiers[0]
+	for _, next := range LoyaltyTiers[1:] {
+		if trips >= next.MinTrips {
+			tier = next
+		}
+	}
+
+	return tier
+}
+
+// EarnedPoints returns the points a ticket paid with price earns in the tier.
+func (t LoyaltyTier) EarnedPoints(price int) int {
+	return price / LoyaltyPointsPerUnit * t.Multiplier / 100
+}
+
+func NewLoyaltyRedemption(userID uuid.UUID, points int) LoyaltyTransaction {
+	return LoyaltyTransaction{
+		ID:     uuid.New(),
+		UserID: userID,
+		Type:   LoyaltyTransactionRedeemed,
+		Points: -points,
+	}
+}
+
+// LoyaltyDiscounts uses the points up price by price and returns the points
+// spent on each of the prices.
+func LoyaltyDiscounts(points int, prices []int) []int {
+	var spent = make([]int, len(prices))
+	for i, price := range prices {
+		spent[i] = min(points, price/LoyaltyPointValue)
+		points -= spent[i]
+	}
+
+	return spent
+}
+
+type LoyaltyAdjustmentJSON struct {
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
+func (a LoyaltyAdjustmentJSON) Parse(userID, adminID uuid.UUID) (LoyaltyTransaction, error) {
+	var params rfc7807.InvalidParams
+
+	if a.Points == 0 {
+		params.SetInvalidParam("points", "Cannot be 0.")
+	}
+
+	if a.Reason == "" || len(a.Reason) > 500 {
+		params.SetInvalidParam("reason", "Must contain from 1 to 500 characters.")
+	}
+
+	if params != nil {
+		return LoyaltyTransaction{}, rfc7807.BadRequest("loyalty-adjustment-invalid-data", "Loyalty Adjustment Data Error", "Provided data is not valid.", params...)
+	}
+
+	return LoyaltyTransaction{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Type:        LoyaltyTransactionAdjusted,
+		Points:      a.Points,
+		Reason:      a.Reason,
+		CreatedByID: uuid.NullUUID{UUID: adminID, Valid: true},
+	}, nil
+}
+
+type LoyaltyAccount struct {
+	Balance       int          `json:"balance"`
+	Tier          LoyaltyTier  `json:"tier"`
+	NextTier      *LoyaltyTier `json:"nextTier"`
+	TripsThisYear int          `json:"tripsThisYear"`
+}
+
+func NewLoyaltyAccount(balance, trips int) LoyaltyAccount {
+	account := LoyaltyAccount{
+		Balance:       balance,
+		Tier:          LoyaltyTierFor(trips),
+		TripsThisYear: trips,
+	}
+
+	for _, tier := range LoyaltyTiers {
+		if tier.MinTrips > trips {
+			account.NextTier = &tier
+			break
+		}
+	}
+
+	return account
+}
+
+func MigrateLoyalty(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&LoyaltyTransaction{},
+	)
+}
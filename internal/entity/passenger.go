@@ -9,12 +9,63 @@ import (
 )
 
 type Passenger struct {
-	ID        uuid.UUID      `gorm:"type:binary(16); primaryKey;"         json:"id"`
-	UserID    uuid.UUID      `gorm:"type:binary(16);"                     json:"-"`
-	FirstName string         `gorm:"type:varchar(255); not null"    json:"firstName"`
-	LastName  string         `gorm:"type:varchar(255); not null"    json:"lastName"`
-	CreatedAt time.Time      `gorm:"not null"                       json:"-"`
-	DeletedAt gorm.DeletedAt `                                      json:"-"`
+	ID          uuid.UUID         `gorm:"type:binary(16); primaryKey;"         json:"id"`
+	UserID      uuid.UUID         `gorm:"type:binary(16);"                     json:"-"`
+	FirstName   string            `gorm:"type:varchar(255); not null"    json:"firstName"`
+	LastName    string            `gorm:"type:varchar(255); not null"    json:"lastName"`
+	Category    passengerCategory `gorm:"type:enum('Adult','Teenager','Child');not null;default:'Adult'" json:"category"`
+	DateOfBirth *time.Time        `gorm:"type:date"                      json:"dateOfBirth"`
+	CreatedAt   time.Time         `gorm:"not null"                       json:"-"`
+	DeletedAt   gorm.DeletedAt    `                                      json:"-"`
+}
+
+type passengerCategory string
+
+const (
+	PassengerCategoryAdult    passengerCategory = "Adult"
+	PassengerCategoryTeenager passengerCategory = "Teenager"
+	PassengerCategoryChild    passengerCategory = "Child"
+
+	TeenagerMinAge = 12
+	AdultMinAge    = 18
+)
+
+func ParsePassengerCategory(v string) (passengerCategory, bool) {
+	switch passengerCategory(v) {
+	case PassengerCategoryAdult, PassengerCategoryTeenager, PassengerCategoryChild:
+		return passengerCategory(v), true
+	default:
+		return "", false
+	}
+}
+
+// AgeCategory returns the category of someone born on dateOfBirth at the given moment.
+func AgeCategory(dateOfBirth, at time.Time) passengerCategory {
+	age := at.Year() - dateOfBirth.Year()
+	if at.Month() < dateOfBirth.Month() || at.Month() == dateOfBirth.Month() && at.Day() < dateOfBirth.Day() {
+		age--
+	}
+
+	switch {
+	case age >= AdultMinAge:
+		return PassengerCategoryAdult
+	case age >= TeenagerMinAge:
+		return PassengerCategoryTeenager
+	default:
+		return PassengerCategoryChild
+	}
+}
+
+// ValidateCategoryAt reports whether the passenger still belongs to their
+// category at the given moment, e.g. at the departure of a connection.
+func (p *Passenger) ValidateCategoryAt(at time.Time) rfc7807.InvalidParams {
+	var params rfc7807.InvalidParams
+
+	if p.DateOfBirth != nil && AgeCategory(*p.DateOfBirth, at) != p.Category {
+		params.SetInvalidParam("category", "Does not match the date of birth.")
+	}
+
+	return params
 }
 
 type PassengerSimplified struct {
@@ -24,15 +75,31 @@ type PassengerSimplified struct {
 }
 
 type NewPassenger struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Category    string `json:"category"`
+	DateOfBirth string `json:"dateOfBirth"`
 }
 
-func (p NewPassenger) Parse() Passenger {
-	return Passenger{
-		FirstName: p.FirstName,
-		LastName:  p.LastName,
+func (p NewPassenger) Parse() (Passenger, rfc7807.InvalidParams) {
+	var params rfc7807.InvalidParams
+
+	category, ok := ParsePassengerCategory(p.Category)
+	if !ok {
+		params.SetInvalidParam("category", "Must be one of: Adult, Teenager, Child.")
+	}
+
+	dateOfBirth, err := time.Parse("2006-01-02", p.DateOfBirth)
+	if err != nil {
+		params.SetInvalidParam("dateOfBirth", err.Error())
 	}
+
+	return Passenger{
+		FirstName:   p.FirstName,
+		LastName:    p.LastName,
+		Category:    category,
+		DateOfBirth: &dateOfBirth,
+	}, params
 }
 
 func (p *Passenger) Prepare(userID uuid.UUID) rfc7807.InvalidParams {
@@ -41,11 +108,19 @@ func (p *Passenger) Prepare(userID uuid.UUID) rfc7807.InvalidParams {
 	if params == nil {
 		p.ID = uuid.New()
 		p.UserID = userID
+		p.SetDefaultCategory()
 	}
 
 	return params
 }
 
+// SetDefaultCategory makes a passenger saved without a category an adult.
+func (p *Passenger) SetDefaultCategory() {
+	if p.Category == "" {
+		p.Category = PassengerCategoryAdult
+	}
+}
+
 func (p *Passenger) Validate() rfc7807.InvalidParams {
 	var params rfc7807.InvalidParams
 
@@ -57,6 +132,16 @@ func (p *Passenger) Validate() rfc7807.InvalidParams {
 		params.SetInvalidParam("surname", "Must not be empty.")
 	}
 
+	// The category and the date of birth are optional for saved passengers;
+	// they are checked against each other at the departure of a ticket.
+	if _, ok := ParsePassengerCategory(string(p.Category)); p.Category != "" && !ok {
+		params.SetInvalidParam("category", "Must be one of: Adult, Teenager, Child.")
+	}
+
+	if p.DateOfBirth != nil && p.DateOfBirth.After(time.Now()) {
+		params.SetInvalidParam("dateOfBirth", "Future date.")
+	}
+
 	return params
 }
 
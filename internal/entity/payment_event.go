@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PaymentEvent is a webhook event of the payment provider. A completed
+// checkout also tells how much was paid, and a succeeded refund which refund
+// of the provider it reports and how much that refund gave back.
+type PaymentEvent struct {
+	ID              string           `gorm:"type:varchar(255);primaryKey"   json:"id"`
+	Type            paymentEventType `gorm:"type:varchar(100);not null"     json:"type"`
+	SessionID       string           `gorm:"type:varchar(500)"              json:"sessionId"`
+	PaymentIntentID string           `gorm:"type:varchar(255)"              json:"paymentIntentId"`
+	AmountTotal     int64            `gorm:"type:BIGINT;not null;default:0" json:"amountTotal"`
+	RefundID        string           `gorm:"type:varchar(255)"              json:"refundId"`
+	AmountRefunded  int64            `gorm:"type:BIGINT;not null;default:0" json:"amountRefunded"`
+	CreatedAt       time.Time        `gorm:"not null"                       json:"createdAt"`
+}
+
+type paymentEventType string
+
+const (
+	PaymentEventCheckoutCompleted paymentEventType = "checkout.session.completed"
+	PaymentEventCheckoutExpired   paymentEventType = "checkout.session.expired"
+	PaymentEventRefundSucceeded   paymentEventType = "refund.succeeded"
+)
+
+func NewPaymentEvent(id, eventType, sessionID, paymentIntentID string) PaymentEvent {
+	return PaymentEvent{
+		ID:              id,
+		Type:            paymentEventType(eventType),
+		SessionID:       sessionID,
+		PaymentIntentID: paymentIntentID,
+	}
+}
+
+// NewRefundEvent is the event of a refund the provider has paid out.
+func NewRefundEvent(id, paymentIntentID, refundID string, amountRefunded int64) PaymentEvent {
+	event := NewPaymentEvent(id, string(PaymentEventRefundSucceeded), "", paymentIntentID)
+	event.RefundID = refundID
+	event.AmountRefunded = amountRefunded
+	return event
+}
+
+// UnfulfilledPayment records a checkout paid after its seats had been given
+// to someone else; the payment is refunded in full.
+type UnfulfilledPayment struct {
+	SessionID        string    `gorm:"type:varchar(500);primaryKey" json:"sessionId"`
+	PaymentIntentID  string    `gorm:"type:varchar(255);not null"   json:"-"`
+	Amount           int64     `gorm:"type:BIGINT;not null"         json:"amount"`
+	ProviderRefundID string    `gorm:"type:varchar(255)"            json:"-"`
+	CreatedAt        time.Time `gorm:"not null"                     json:"createdAt"`
+}
+
+func MigratePaymentEvent(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&PaymentEvent{},
+		&UnfulfilledPayment{},
+	)
+}
@@ -0,0 +1,177 @@
+package entity
+
+import (
+	"strings"
+	"time"
+
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type PromoCode struct {
+	ID             uuid.UUID              `gorm:"type:binary(16);primaryKey"                 json:"id"`
+	Code           string                 `gorm:"type:varchar(50);not null;unique"           json:"code"`
+	DiscountType   discountType           `gorm:"type:enum('Percentage','Fixed');not null"   json:"discountType"`
+	DiscountValue  int                    `gorm:"type:MEDIUMINT;not null"                    json:"discountValue"`
+	ValidFrom      time.Time              `gorm:"not null"                                   json:"validFrom"`
+	ValidUntil     time.Time              `gorm:"not null"                                   json:"validUntil"`
+	MaxRedemptions int                    `gorm:"type:INT;not null;default:0"                json:"maxRedemptions"`
+	PerUserLimit   int                    `gorm:"type:INT;not null;default:0"                json:"perUserLimit"`
+	Restrictions   []PromoCodeRestriction `gorm:"foreignKey:PromoCodeID"                     json:"restrictions"`
+	CreatedAt      time.Time              `gorm:"not null"                                   json:"createdAt"`
+	UpdatedAt      time.Time              `gorm:"not null"                                   json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt         `                                                  json:"-"`
+}
+
+// PromoCodeRestriction limits a code to a line or to a pair of countries. A
+// code without restrictions applies to every connection.
+type PromoCodeRestriction struct {
+	PromoCodeID          uuid.UUID     `gorm:"type:binary(16);not null;index" json:"-"`
+	Line                 *int          `gorm:"type:SMALLINT"                  json:"line"`
+	DepartureCountryID   uuid.NullUUID `gorm:"type:binary(16)"                json:"departureCountryId"`
+	DestinationCountryID uuid.NullUUID `gorm:"type:binary(16)"                json:"destinationCountryId"`
+}
+
+type PromoCodeRedemption struct {
+	ID          uuid.UUID `gorm:"type:binary(16);primaryKey"     json:"id"`
+	PromoCodeID uuid.UUID `gorm:"type:binary(16);not null;index" json:"promoCodeId"`
+	UserID      uuid.UUID `gorm:"type:binary(16);not null;index" json:"userId"`
+	CreatedAt   time.Time `gorm:"not null"                       json:"createdAt"`
+}
+
+type discountType string
+
+const (
+	DiscountTypePercentage discountType = "Percentage"
+	DiscountTypeFixed      discountType = "Fixed"
+)
+
+func (r PromoCodeRestriction) matches(c *Connection) bool {
+	if r.Line != nil && *r.Line != c.Line {
+		return false
+	}
+
+	if r.DepartureCountryID.Valid && r.DepartureCountryID.UUID != c.DepartureCountryID {
+		return false
+	}
+
+	if r.DestinationCountryID.Valid && r.DestinationCountryID.UUID != c.DestinationCountryID {
+		return false
+	}
+
+	return true
+}
+
+func (p *PromoCode) Validate() rfc7807.InvalidParams {
+	var params rfc7807.InvalidParams
+
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if p.Code == "" {
+		params.SetInvalidParam("code", "Must not be empty.")
+	}
+
+	switch p.DiscountType {
+	case DiscountTypePercentage:
+		if p.DiscountValue < 1 || p.DiscountValue > 100 {
+			params.SetInvalidParam("discountValue", "Must be a percentage between 1 and 100.")
+		}
+	case DiscountTypeFixed:
+		if p.DiscountValue < 1 {
+			params.SetInvalidParam("discountValue", "Must be greater than 0.")
+		}
+	default:
+		params.SetInvalidParam("discountType", "Must be one of: Percentage, Fixed.")
+	}
+
+	if !p.ValidUntil.After(p.ValidFrom) {
+		params.SetInvalidParam("validUntil", "Must be after validFrom.")
+	}
+
+	if p.MaxRedemptions < 0 {
+		params.SetInvalidParam("maxRedemptions", "Cannot be less than 0.")
+	}
+
+	if p.PerUserLimit < 0 {
+		params.SetInvalidParam("perUserLimit", "Cannot be less than 0.")
+	}
+
+	for _, restriction := range p.Restrictions {
+		if restriction.Line == nil && !restriction.DepartureCountryID.Valid && !restriction.DestinationCountryID.Valid {
+			params.SetInvalidParam("restrictions", "Every restriction needs a line or a country.")
+			break
+		}
+	}
+
+	return params
+}
+
+func (p *PromoCode) Prepare() rfc7807.InvalidParams {
+	params := p.Validate()
+	if params == nil {
+		p.ID = uuid.New()
+	}
+
+	for i := range p.Restrictions {
+		p.Restrictions[i].PromoCodeID = p.ID
+	}
+
+	return params
+}
+
+// CheckRedeemable reports why the code cannot be used for the connection, given
+// how many times it has been redeemed overall and by the user.
+func (p *PromoCode) CheckRedeemable(c *Connection, at time.Time, redemptions, userRedemptions int) error {
+	invalid := func(detail string) error {
+		return rfc7807.BadRequest("invalid-promo-code", "Invalid Promo Code Error", detail)
+	}
+
+	switch {
+	case at.Before(p.ValidFrom) || !at.Before(p.ValidUntil):
+		return invalid("The promo code is not valid at the moment.")
+	case p.MaxRedemptions > 0 && redemptions >= p.MaxRedemptions:
+		return invalid("The promo code has been used up.")
+	case p.PerUserLimit > 0 && userRedemptions >= p.PerUserLimit:
+		return invalid("The promo code has already been used the allowed number of times.")
+	}
+
+	if len(p.Restrictions) == 0 {
+		return nil
+	}
+
+	for _, restriction := range p.Restrictions {
+		if restriction.matches(c) {
+			return nil
+		}
+	}
+
+	return invalid("The promo code does not apply to this connection.")
+}
+
+// Discounts splits the discount among the prices: a percentage applies to each
+// of them, a fixed amount is used up price by price.
+func (p *PromoCode) Discounts(prices []int) []int {
+	var discounts = make([]int, len(prices))
+	left := p.DiscountValue
+
+	for i, price := range prices {
+		switch p.DiscountType {
+		case DiscountTypePercentage:
+			discounts[i] = price * p.DiscountValue / 100
+		case DiscountTypeFixed:
+			discounts[i] = min(price, left)
+			left -= discounts[i]
+		}
+	}
+
+	return discounts
+}
+
+func MigratePromoCode(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&PromoCode{},
+		&PromoCodeRestriction{},
+		&PromoCodeRedemption{},
+	)
+}
@@ -1,22 +1,144 @@
 package entity
 
 import (
+	"slices"
 	"time"
 
 	"github.com/d3code/uuid"
+	"gorm.io/gorm"
 )
 
 type Refaund struct {
-	ID          uuid.UUID `gorm:"type:binary(16);primaryKey" json:"id"`
-	TicketID    uuid.UUID `gorm:"type:binary(16);not null"   json:"-"`
-	Ticket      Ticket    `gorm:"foreignKey:TicketID"  json:"ticket"`
-	CreatedAt   time.Time `gorm:"not null"             json:"createdAt"`
-	CompletedAt time.Time `                            json:"completedAt"`
+	ID               uuid.UUID     `gorm:"type:binary(16);primaryKey" json:"id"`
+	TicketID         uuid.UUID     `gorm:"type:binary(16);not null;index" json:"ticketId"`
+	Ticket           Ticket        `gorm:"foreignKey:TicketID"  json:"ticket"`
+	Amount           int           `gorm:"type:MEDIUMINT;not null" json:"amount"`
+	Status           refaundStatus `gorm:"type:enum('Requested','Processing','Completed','Rejected');not null;index" json:"status"`
+	Reason           refaundReason `gorm:"type:enum('Cancellation','Rebooking');not null;default:'Cancellation'" json:"reason"`
+	PaymentIntentID  string        `gorm:"type:varchar(255);index" json:"-"`
+	ProviderRefundID string        `gorm:"type:varchar(255);index" json:"-"`
+	CreatedAt        time.Time     `gorm:"not null"             json:"createdAt"`
+	UpdatedAt        time.Time     `gorm:"not null"             json:"updatedAt"`
+	CompletedAt      *time.Time    `                            json:"completedAt"`
 }
 
-func NewRefaund(ticketID uuid.UUID) Refaund {
+type refaundStatus string
+
+const (
+	RefaundStatusRequested  refaundStatus = "Requested"
+	RefaundStatusProcessing refaundStatus = "Processing"
+	RefaundStatusCompleted  refaundStatus = "Completed"
+	RefaundStatusRejected   refaundStatus = "Rejected"
+)
+
+type refaundReason string
+
+// A cancellation refund removes the ticket once completed, while a rebooking
+// refund only returns the fare difference of a ticket moved to a cheaper seat.
+const (
+	RefaundReasonCancellation refaundReason = "Cancellation"
+	RefaundReasonRebooking    refaundReason = "Rebooking"
+)
+
+func ParseRefaundStatus(v string) (refaundStatus, bool) {
+	switch refaundStatus(v) {
+	case RefaundStatusRequested, RefaundStatusProcessing, RefaundStatusCompleted, RefaundStatusRejected:
+		return refaundStatus(v), true
+	default:
+		return "", false
+	}
+}
+
+func NewRefaund(ticketID uuid.UUID, amount int) Refaund {
 	return Refaund{
 		ID:       uuid.New(),
 		TicketID: ticketID,
+		Amount:   amount,
+		Status:   RefaundStatusRequested,
+		Reason:   RefaundReasonCancellation,
+	}
+}
+
+type RebookingPolicy struct {
+	ChangeBefore time.Duration
+}
+
+// CanChange reports whether a ticket departing at departure may still be moved.
+func (p RebookingPolicy) CanChange(departure, now time.Time) bool {
+	return departure.Sub(now) > p.ChangeBefore
+}
+
+type CancellationPolicy struct {
+	FullRefundBefore     time.Duration
+	PartialRefundPercent int
+}
+
+// RefundableAmount returns how much of the price goes back to the customer,
+// or false when the ticket can no longer be cancelled.
+func (p CancellationPolicy) RefundableAmount(price int, departure, now time.Time) (int, bool) {
+	switch {
+	case !now.Before(departure):
+		return 0, false
+	case departure.Sub(now) > p.FullRefundBefore:
+		return price, true
+	default:
+		return price * p.PartialRefundPercent / 100, true
 	}
 }
+
+// SplitRefund divides the amount given back for the ticket between its
+// payments, the latest charge first, each giving back at most what is left of
+// it after the earlier refunds; whatever does not fit goes to the purchase
+// payment. The ticket has to be loaded with its charges.
+func SplitRefund(ticket Ticket, amount int, refunds []Refaund, reason refaundReason) []Refaund {
+	var refunded = map[string]int{}
+	for _, refund := range refunds {
+		if refund.Status == RefaundStatusRejected {
+			continue
+		}
+
+		refunded[refund.PaymentIntentID] += refund.Amount
+	}
+
+	var charges = slices.Clone(ticket.Charges)
+	slices.SortFunc(charges, func(a, b TicketCharge) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	var parts []Refaund
+	for _, charge := range charges {
+		part := min(amount, charge.Amount-refunded[charge.PaymentIntentID])
+		if part <= 0 {
+			continue
+		}
+
+		parts = append(parts, newRefaundPart(ticket.ID, part, charge.PaymentIntentID, reason))
+		amount -= part
+	}
+
+	if amount > 0 || len(parts) == 0 {
+		parts = append(parts, newRefaundPart(ticket.ID, amount, ticket.TicketPayment.PaymentIntentID, reason))
+	}
+
+	return parts
+}
+
+func newRefaundPart(ticketID uuid.UUID, amount int, paymentIntentID string, reason refaundReason) Refaund {
+	refaund := NewRefaund(ticketID, amount)
+	refaund.PaymentIntentID = paymentIntentID
+	refaund.Reason = reason
+	return refaund
+}
+
+func MigrateRefaund(db *gorm.DB) error {
+	err := db.AutoMigrate(
+		&Refaund{},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Refunds made before a ticket could have several payments all went
+	// through the purchase payment.
+	return db.Model(&Refaund{}).
+		Where("payment_intent_id IS NULL OR payment_intent_id = ''").
+		Update("payment_intent_id", gorm.Expr("(SELECT payment_intent_id FROM ticket_payments WHERE ticket_payments.ticket_id = refaunds.ticket_id LIMIT 1)")).Error
+}
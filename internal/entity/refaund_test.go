@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+func TestRefundableAmount(t *testing.T) {
+	policy := CancellationPolicy{FullRefundBefore: 24 * time.Hour, PartialRefundPercent: 50}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		departure time.Time
+		amount    int
+		ok        bool
+	}{
+		{"before the full refund window", now.Add(48 * time.Hour), 1000, true},
+		{"within the full refund window", now.Add(time.Hour), 500, true},
+		{"at the departure", now, 0, false},
+		{"after the departure", now.Add(-time.Hour), 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount, ok := policy.RefundableAmount(1000, test.departure, now)
+			if amount != test.amount || ok != test.ok {
+				t.Errorf("RefundableAmount() = %d, %v, want %d, %v", amount, ok, test.amount, test.ok)
+			}
+		})
+	}
+}
+
+func TestSplitRefund(t *testing.T) {
+	now := time.Now()
+	ticket := Ticket{
+		ID:            uuid.New(),
+		TicketPayment: TicketPayment{Price: 1500, PaymentIntentID: "pi_purchase"},
+		Charges: []TicketCharge{
+			{Amount: 1000, PaymentIntentID: "pi_purchase", CreatedAt: now.Add(-time.Hour)},
+			{Amount: 500, PaymentIntentID: "pi_rebooking", CreatedAt: now},
+		},
+	}
+
+	t.Run("latest charge first", func(t *testing.T) {
+		parts := SplitRefund(ticket, 1200, nil, RefaundReasonCancellation)
+		assertParts(t, parts, map[string]int{"pi_rebooking": 500, "pi_purchase": 700})
+
+		for _, part := range parts {
+			if part.Status != RefaundStatusRequested || part.Reason != RefaundReasonCancellation || part.TicketID != ticket.ID {
+				t.Errorf("unexpected part %+v", part)
+			}
+		}
+	})
+
+	t.Run("earlier refunds are taken off", func(t *testing.T) {
+		existing := []Refaund{{PaymentIntentID: "pi_rebooking", Amount: 300, Status: RefaundStatusCompleted}}
+		parts := SplitRefund(ticket, 1000, existing, RefaundReasonCancellation)
+		assertParts(t, parts, map[string]int{"pi_rebooking": 200, "pi_purchase": 800})
+	})
+
+	t.Run("rejected refunds are not taken off", func(t *testing.T) {
+		existing := []Refaund{{PaymentIntentID: "pi_rebooking", Amount: 500, Status: RefaundStatusRejected}}
+		parts := SplitRefund(ticket, 500, existing, RefaundReasonCancellation)
+		assertParts(t, parts, map[string]int{"pi_rebooking": 500})
+	})
+
+	t.Run("nothing to give back", func(t *testing.T) {
+		parts := SplitRefund(ticket, 0, nil, RefaundReasonCancellation)
+		assertParts(t, parts, map[string]int{"pi_purchase": 0})
+	})
+}
+
+func assertParts(t *testing.T, parts []Refaund, want map[string]int) {
+	t.Helper()
+
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d: %+v", len(parts), len(want), parts)
+	}
+
+	for _, part := range parts {
+		amount, ok := want[part.PaymentIntentID]
+		if !ok || part.Amount != amount {
+			t.Errorf("part of %s = %d, want %d", part.PaymentIntentID, part.Amount, amount)
+		}
+	}
+}
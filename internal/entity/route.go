@@ -0,0 +1,279 @@
+package entity
+
+import (
+	"fmt"
+	"maryan_api/config"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+const MaxRouteStations = 30
+
+// Route is the ordered list of stations a connection calls at. The offsets of
+// a station are minutes since the connection leaves the first one and its
+// distance is kilometres from the first one; the fare of a segment is the
+// share of the connection price its distance makes up.
+type Route struct {
+	ID        uuid.UUID      `gorm:"type:binary(16);primaryKey" json:"id"`
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
+	Stations  []RouteStation `gorm:"foreignKey:RouteID"         json:"stations"`
+	CreatedAt time.Time      `gorm:"not null"                   json:"createdAt"`
+}
+
+type RouteStation struct {
+	ID              uuid.UUID `gorm:"type:binary(16);primaryKey"                                       json:"id"`
+	RouteID         uuid.UUID `gorm:"type:binary(16);not null;uniqueIndex:idx_route_stations_position" json:"-"`
+	Position        int       `gorm:"type:TINYINT;not null;uniqueIndex:idx_route_stations_position"    json:"position"`
+	City            string    `gorm:"type:varchar(100);not null;index"                                 json:"city"`
+	CountryID       uuid.UUID `gorm:"type:binary(16);not null"                                         json:"-"`
+	Country         Country   `gorm:"foreignKey:CountryID"                                             json:"country"`
+	ArrivalOffset   int       `gorm:"type:INT;not null"                                                json:"arrivalOffset"`
+	DepartureOffset int       `gorm:"type:INT;not null"                                                json:"departureOffset"`
+	Distance        int       `gorm:"type:INT;not null"                                                json:"distance"`
+}
+
+func (r *Route) AfterFind(tx *gorm.DB) (err error) {
+	slices.SortFunc(r.Stations, func(a, b RouteStation) int { return a.Position - b.Position })
+	return
+}
+
+// Length is the distance between the first and the last station.
+func (r Route) Length() int {
+	if len(r.Stations) == 0 {
+		return 0
+	}
+
+	return r.Stations[len(r.Stations)-1].Distance
+}
+
+// Duration is the time between leaving the first station and reaching the
+// last one.
+func (r Route) Duration() time.Duration {
+	if len(r.Stations) == 0 {
+		return 0
+	}
+
+	return time.Duration(r.Stations[len(r.Stations)-1].ArrivalOffset) * time.Minute
+}
+
+type NewRouteJSON struct {
+	Name     string            `json:"name"`
+	Stations []NewRouteStation `json:"stations"`
+}
+
+type NewRouteStation struct {
+	City            string `json:"city"`
+	Country         string `json:"country"`
+	ArrivalOffset   int    `json:"arrivalOffset"`
+	DepartureOffset int    `json:"departureOffset"`
+	Distance        int    `json:"distance"`
+}
+
+func (r NewRouteJSON) Parse() (Route, error) {
+	var params rfc7807.InvalidParams
+
+	name := strings.TrimSpace(r.Name)
+	if name == "" || len(name) > 100 {
+		params.SetInvalidParam("name", "Must contain from 1 to 100 characters.")
+	}
+
+	if len(r.Stations) < 2 || len(r.Stations) > MaxRouteStations {
+		params.SetInvalidParam("stations", fmt.Sprintf("Must contain from 2 to %d stations.", MaxRouteStations))
+	}
+
+	route := Route{ID: uuid.New(), Name: name}
+	var cities = make([]string, 0, len(r.Stations))
+	for i, newStation := range r.Stations {
+		param := fmt.Sprintf("stations[%d]", i)
+
+		city := strings.TrimSpace(newStation.City)
+		if city == "" || len(city) > 100 {
+			params.SetInvalidParam(param+".city", "Must contain from 1 to 100 characters.")
+		} else if slices.ContainsFunc(cities, func(c string) bool { return strings.EqualFold(c, city) }) {
+			params.SetInvalidParam(param+".city", "The route already calls at the city.")
+		}
+		cities = append(cities, city)
+
+		countryID, exists := config.CountryExists(newStation.Country)
+		if !exists {
+			params.SetInvalidParam(param+".country", "Non-existing country.")
+		}
+
+		if newStation.DepartureOffset < newStation.ArrivalOffset {
+			params.SetInvalidParam(param+".departureOffset", "Cannot be before the arrival.")
+		}
+
+		if i == 0 {
+			if newStation.ArrivalOffset != 0 || newStation.DepartureOffset != 0 || newStation.Distance != 0 {
+				params.SetInvalidParam(param, "The offsets and the distance of the first station have to be 0.")
+			}
+		} else {
+			previous := r.Stations[i-1]
+			if newStation.ArrivalOffset <= previous.DepartureOffset {
+				params.SetInvalidParam(param+".arrivalOffset", "Has to be after the departure from the previous station.")
+			}
+
+			if newStation.Distance <= previous.Distance {
+				params.SetInvalidParam(param+".distance", "Has to be greater than the distance of the previous station.")
+			}
+		}
+
+		route.Stations = append(route.Stations, RouteStation{
+			ID:              uuid.New(),
+			RouteID:         route.ID,
+			Position:        i,
+			City:            city,
+			CountryID:       countryID,
+			ArrivalOffset:   newStation.ArrivalOffset,
+			DepartureOffset: newStation.DepartureOffset,
+			Distance:        newStation.Distance,
+		})
+	}
+
+	if params != nil {
+		return Route{}, rfc7807.BadRequest("route-invalid-data", "Route Data Error", "Provided data is not valid.", params...)
+	}
+
+	return route, nil
+}
+
+// Segment is the part of a connection between two stations of its route,
+// given by their positions; a connection without a route has the single
+// segment from 0 to 1.
+type Segment struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (s Segment) Overlaps(other Segment) bool {
+	return s.From < other.To && other.From < s.To
+}
+
+func (c *Connection) HasRoute() bool {
+	return c.RouteID.Valid && len(c.Route.Stations) > 1
+}
+
+func (c *Connection) FullSegment() Segment {
+	if !c.HasRoute() {
+		return Segment{0, 1}
+	}
+
+	return Segment{0, len(c.Route.Stations) - 1}
+}
+
+// FindSegment looks up the segment between the cities; leaving both of them
+// empty picks the whole connection.
+func (c *Connection) FindSegment(from, to string) (Segment, bool) {
+	if from == "" && to == "" {
+		return c.FullSegment(), true
+	}
+
+	if !c.HasRoute() {
+		return Segment{}, false
+	}
+
+	segment := Segment{c.StationIndex(from), c.StationIndex(to)}
+	return segment, segment.From != -1 && segment.To != -1 && segment.From < segment.To
+}
+
+// StationIndex returns the position of the city on the route or -1 if the
+// connection does not call at it.
+func (c *Connection) StationIndex(city string) int {
+	return slices.IndexFunc(c.Route.Stations, func(station RouteStation) bool {
+		return strings.EqualFold(station.City, strings.TrimSpace(city))
+	})
+}
+
+// StationDeparture returns the time the connection leaves the station at
+// the position.
+func (c *Connection) StationDeparture(position int) time.Time {
+	return c.DepartureTime.Add(time.Duration(c.Route.Stations[position].DepartureOffset) * time.Minute)
+}
+
+// SegmentCities returns the cities the segment starts and ends at, or empty
+// strings for a connection without a route.
+func (c *Connection) SegmentCities(s Segment) (string, string) {
+	if !c.HasRoute() {
+		return "", ""
+	}
+
+	return c.Route.Stations[s.From].City, c.Route.Stations[s.To].City
+}
+
+// OnSegment returns the connection as seen by a passenger travelling the
+// segment: its times, countries and price are those of the segment.
+func (c Connection) OnSegment(s Segment) Connection {
+	c.Segment = s
+	if !c.HasRoute() {
+		return c
+	}
+
+	from, to := c.Route.Stations[s.From], c.Route.Stations[s.To]
+	start := c.DepartureTime
+
+	c.DepartureTime = start.Add(time.Duration(from.DepartureOffset) * time.Minute)
+	c.ArrivalTime = start.Add(time.Duration(to.ArrivalOffset) * time.Minute)
+	c.EstimatedDuration = int(c.ArrivalTime.Sub(c.DepartureTime).Minutes())
+
+	c.DepartureCountryID, c.DepartureCountry = from.CountryID, from.Country
+	c.DestinationCountryID, c.DestinationCountry = to.CountryID, to.Country
+	c.LocalDepartureTime, c.LocalArrivalTime = c.LocalTimes()
+
+	if length := c.Route.Length(); length > 0 {
+		c.Price = c.Price * (to.Distance - from.Distance) / length
+	}
+
+	return c
+}
+
+// ApplyRoute takes the countries and the arrival time of the connection
+// from its route.
+func (c *Connection) ApplyRoute(route Route) {
+	c.RouteID = uuid.NullUUID{UUID: route.ID, Valid: true}
+
+	first, last := route.Stations[0], route.Stations[len(route.Stations)-1]
+	c.DepartureCountryID, c.DepartureCountry = first.CountryID, first.Country
+	c.DestinationCountryID, c.DestinationCountry = last.CountryID, last.Country
+	c.ArrivalTime = c.DepartureTime.Add(route.Duration())
+}
+
+// CountryRoute is the route of a connection between two countries that does
+// not say which cities it calls at: a station named after each country, so
+// searching for the countries finds it. The distance of 1 keeps the whole
+// price on its only segment.
+func CountryRoute(departure, destination Country, duration time.Duration) Route {
+	route := Route{ID: uuid.New(), Name: departure.Name + " - " + destination.Name}
+	route.Stations = []RouteStation{
+		{
+			ID:        uuid.New(),
+			RouteID:   route.ID,
+			Position:  0,
+			City:      departure.Name,
+			CountryID: departure.ID,
+		},
+		{
+			ID:              uuid.New(),
+			RouteID:         route.ID,
+			Position:        1,
+			City:            destination.Name,
+			CountryID:       destination.ID,
+			ArrivalOffset:   int(duration.Minutes()),
+			DepartureOffset: int(duration.Minutes()),
+			Distance:        1,
+		},
+	}
+
+	return route
+}
+
+func MigrateRoute(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&Route{},
+		&RouteStation{},
+	)
+}
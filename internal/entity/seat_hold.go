@@ -0,0 +1,149 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// Stripe does not accept checkout sessions that expire sooner than 30 minutes,
+// so a checkout runs a little longer than that. Its seats stay held for
+// SeatHoldGrace after it expires, so a payment whose webhook arrives late
+// still finds its hold.
+const (
+	CheckoutDuration = 35 * time.Minute
+	SeatHoldGrace    = 15 * time.Minute
+	SeatHoldDuration = CheckoutDuration + SeatHoldGrace
+)
+
+// Stripe keeps retrying a webhook for up to three days, so a released hold is
+// kept that long for a payment reported late.
+const ReleasedSeatHoldRetention = 72 * time.Hour
+
+type SeatHold struct {
+	ID              uuid.UUID `gorm:"type:binary(16);primaryKey"                                                                       json:"id"`
+	ConnectionID    uuid.UUID `gorm:"type:binary(16);not null;uniqueIndex:idx_seat_holds_seat_from;uniqueIndex:idx_seat_holds_seat_to" json:"connectionId"`
+	SeatID          uuid.UUID `gorm:"type:binary(16);not null;uniqueIndex:idx_seat_holds_seat_from;uniqueIndex:idx_seat_holds_seat_to" json:"seatId"`
+	FromStation     int       `gorm:"type:TINYINT;not null;default:0;uniqueIndex:idx_seat_holds_seat_from"                             json:"fromStation"`
+	ToStation       int       `gorm:"type:TINYINT;not null;default:1;uniqueIndex:idx_seat_holds_seat_to"                               json:"toStation"`
+	UserID          uuid.UUID `gorm:"type:binary(16);not null"                                                                         json:"-"`
+	PassengerID     uuid.UUID `gorm:"type:binary(16);not null"                                                                         json:"-"`
+	PickUpAdressID  uuid.UUID `gorm:"type:binary(16);not null"                                                                         json:"-"`
+	DropOffAdressID uuid.UUID `gorm:"type:binary(16);not null"                                                                         json:"-"`
+	PhoneNumber     string    `gorm:"type:varchar(15);not null"                                                                        json:"-"`
+	Email           string    `gorm:"type:varchar(255);not null"                                                                       json:"-"`
+	Price           int       `gorm:"type:MEDIUMINT;not null"                                                                          json:"price"`
+	Discount        int       `gorm:"type:MEDIUMINT;not null;default:0"                                                                json:"discount"`
+	SessionID       string    `gorm:"type:varchar(500);index"                                                                          json:"-"`
+	ExpiresAt       time.Time `gorm:"not null;index"                                                                                   json:"expiresAt"`
+	CreatedAt       time.Time `gorm:"not null"                                                                                         json:"createdAt"`
+
+	PromoCodeRedemptionID uuid.NullUUID `gorm:"type:binary(16);index" json:"-"`
+
+	// LoyaltyPoints are the points spent on the seat; they are part of the
+	// discount.
+	LoyaltyPoints        int           `gorm:"type:INT;not null;default:0" json:"loyaltyPoints"`
+	LoyaltyTransactionID uuid.NullUUID `gorm:"type:binary(16);index"       json:"-"`
+
+	// RebookedTicketID is set when the hold reserves the new seat of a ticket
+	// being moved to another connection; paying for it moves the ticket
+	// instead of issuing a new one.
+	RebookedTicketID uuid.NullUUID `gorm:"type:binary(16);index" json:"-"`
+
+	// WaitlistEntryID is set when the hold was bought with the offer of the
+	// waitlist entry; the entry is fulfilled once the tickets are issued.
+	WaitlistEntryID uuid.NullUUID `gorm:"type:binary(16);index" json:"-"`
+
+	Extras []SeatHoldExtra `gorm:"foreignKey:SeatHoldID" json:"extras"`
+}
+
+func (h SeatHold) Segment() Segment {
+	return Segment{h.FromStation, h.ToStation}
+}
+
+// CheckoutExpiresAt is when the checkout paying for the hold expires.
+func (h SeatHold) CheckoutExpiresAt() time.Time {
+	return h.ExpiresAt.Add(-SeatHoldGrace)
+}
+
+func (h SeatHold) IsActive() bool {
+	return h.ExpiresAt.After(time.Now())
+}
+
+func (h SeatHold) ToTicket() Ticket {
+	ticketID := uuid.New()
+
+	var extras = make([]TicketExtra, len(h.Extras))
+	for i, extra := range h.Extras {
+		extras[i] = extra.toTicketExtra(ticketID)
+	}
+
+	return Ticket{
+		ID:              ticketID,
+		UserID:          h.UserID,
+		ConnectionID:    h.ConnectionID,
+		FromStation:     h.FromStation,
+		ToStation:       h.ToStation,
+		SeatID:          h.SeatID,
+		PhoneNumber:     h.PhoneNumber,
+		Email:           h.Email,
+		PassengerID:     h.PassengerID,
+		PickUpAdressID:  h.PickUpAdressID,
+		DropOffAdressID: h.DropOffAdressID,
+		Status:          TicketStatusPaid,
+		Updates:         []TicketUpdate{{TicketID: ticketID, Status: TicketStatusPaid}},
+		Extras:          extras,
+		TicketPayment: TicketPayment{
+			TicketID:  ticketID,
+			Price:     h.Price,
+			Method:    PaymentMethodCard,
+			SessionID: h.SessionID,
+			Discount:  h.Discount,
+
+			PromoCodeRedemptionID: h.PromoCodeRedemptionID,
+			LoyaltyPoints:         h.LoyaltyPoints,
+			LoyaltyTransactionID:  h.LoyaltyTransactionID,
+		},
+	}
+}
+
+// ReleasedSeatHold keeps a hold released before the provider reported back on
+// its checkout, together with the passenger, the addresses, the promo code
+// redemption and the loyalty points it was made with, so a payment reported
+// late can still be given its seat.
+type ReleasedSeatHold struct {
+	ID         uuid.UUID `gorm:"type:binary(16);primaryKey"`
+	SessionID  string    `gorm:"type:varchar(500);not null;index"`
+	Hold       SeatHold  `gorm:"serializer:gob;type:blob;not null"`
+	ReleasedAt time.Time `gorm:"not null;index"`
+}
+
+func NewReleasedSeatHold(hold SeatHold, now time.Time) ReleasedSeatHold {
+	return ReleasedSeatHold{
+		ID:         hold.ID,
+		SessionID:  hold.SessionID,
+		Hold:       hold,
+		ReleasedAt: now,
+	}
+}
+
+func MigrateSeatHold(db *gorm.DB) error {
+	// A seat used to be held once per connection; with segments it can be
+	// held on as many of them as do not overlap. Two holds of a seat starting
+	// or ending at the same station always overlap, so the unique indexes
+	// keep those apart and the rest is checked under the connection lock.
+	for _, index := range []string{"idx_seat_holds_connection_seat", "idx_seat_holds_connection_seat_segment"} {
+		if db.Migrator().HasIndex(&SeatHold{}, index) {
+			err := db.Migrator().DropIndex(&SeatHold{}, index)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.AutoMigrate(
+		&SeatHold{},
+		&ReleasedSeatHold{},
+	)
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeatHoldExpiry(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		active    bool
+	}{
+		{"new hold", now.Add(SeatHoldDuration), true},
+		{"within the grace period", now.Add(SeatHoldGrace / 2), true},
+		{"expired", now.Add(-time.Second), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hold := SeatHold{ExpiresAt: test.expiresAt}
+			if hold.IsActive() != test.active {
+				t.Errorf("IsActive() = %v, want %v", hold.IsActive(), test.active)
+			}
+		})
+	}
+}
+
+func TestSeatHoldOutlivesCheckout(t *testing.T) {
+	expiresAt := time.Now().Add(SeatHoldDuration)
+	hold := SeatHold{ExpiresAt: expiresAt}
+
+	if got := hold.CheckoutExpiresAt(); !got.Equal(expiresAt.Add(-SeatHoldGrace)) {
+		t.Errorf("CheckoutExpiresAt() = %v, want %v", got, expiresAt.Add(-SeatHoldGrace))
+	}
+
+	if !hold.CheckoutExpiresAt().Before(hold.ExpiresAt) {
+		t.Error("the checkout must expire before the hold")
+	}
+
+	// Stripe rejects checkout sessions expiring sooner than 30 minutes.
+	if CheckoutDuration < 30*time.Minute {
+		t.Errorf("CheckoutDuration = %v, want at least 30m", CheckoutDuration)
+	}
+}
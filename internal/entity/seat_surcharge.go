@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"slices"
+	"time"
+
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// SeatSurcharge is added to the fare of every seat having the feature, either
+// on one bus or on all the buses of a line. A bus surcharge takes precedence
+// over the line one for the same feature.
+type SeatSurcharge struct {
+	ID        uuid.UUID     `gorm:"type:binary(16);primaryKey"                                                              json:"id"`
+	BusID     uuid.NullUUID `gorm:"type:binary(16);index"                                                                   json:"busId"`
+	Line      *int          `gorm:"type:SMALLINT;index"                                                                     json:"line"`
+	Feature   seatFeature   `gorm:"type:enum('Window','Single','Single-Window','Aisle','Middle','Backward','Table');not null" json:"feature"`
+	Amount    int           `gorm:"type:MEDIUMINT;not null"                                                                 json:"amount"`
+	CreatedAt time.Time     `gorm:"not null"                                                                                json:"createdAt"`
+}
+
+type seatFeature string
+
+const (
+	SeatFeatureBackward seatFeature = "Backward"
+	SeatFeatureTable    seatFeature = "Table"
+)
+
+func parseSeatFeature(v string) (seatFeature, bool) {
+	if _, ok := defineSeatType(v); ok {
+		return seatFeature(v), true
+	}
+
+	switch seatFeature(v) {
+	case SeatFeatureBackward, SeatFeatureTable:
+		return seatFeature(v), true
+	default:
+		return "", false
+	}
+}
+
+type NewSeatSurcharge struct {
+	BusID   uuid.NullUUID `json:"busId"`
+	Line    *int          `json:"line"`
+	Feature string        `json:"feature"`
+	Amount  int           `json:"amount"`
+}
+
+func (ns NewSeatSurcharge) Parse() (SeatSurcharge, rfc7807.InvalidParams) {
+	var params rfc7807.InvalidParams
+
+	if ns.BusID.Valid == (ns.Line != nil) {
+		params.SetInvalidParam("busId", "Exactly one of busId and line has to be provided.")
+	}
+
+	feature, ok := parseSeatFeature(ns.Feature)
+	if !ok {
+		params.SetInvalidParam("feature", "Must be a seat type, Backward or Table.")
+	}
+
+	if ns.Amount < 0 {
+		params.SetInvalidParam("amount", "Cannot be less than 0.")
+	}
+
+	return SeatSurcharge{
+		ID:      uuid.New(),
+		BusID:   ns.BusID,
+		Line:    ns.Line,
+		Feature: feature,
+		Amount:  ns.Amount,
+	}, params
+}
+
+// SeatSurcharges returns the surcharge of every seat of the bus.
+func (b Bus) SeatSurcharges(surcharges []SeatSurcharge) map[uuid.UUID]int {
+	var byFeature = map[seatFeature]int{}
+	for _, surcharge := range surcharges {
+		if _, set := byFeature[surcharge.Feature]; !set || surcharge.BusID.Valid {
+			byFeature[surcharge.Feature] = surcharge.Amount
+		}
+	}
+
+	var tableSeats []int
+	for _, row := range b.Structure {
+		if slices.ContainsFunc(row.Positions, func(p SeatPosition) bool { return p.Type == SeatPossitionTypeTable }) {
+			for _, position := range row.Positions {
+				if position.Type == SeatPossitionTypeSeat {
+					tableSeats = append(tableSeats, position.SeatNumber)
+				}
+			}
+		}
+	}
+
+	var result = make(map[uuid.UUID]int, len(b.Seats))
+	for _, seat := range b.Seats {
+		amount := byFeature[seatFeature(seat.Type)]
+		if seat.Direction == SeatDirectionBackward {
+			amount += byFeature[SeatFeatureBackward]
+		}
+		if slices.Contains(tableSeats, seat.Number) {
+			amount += byFeature[SeatFeatureTable]
+		}
+		result[seat.ID] = amount
+	}
+
+	return result
+}
+
+func MigrateSeatSurcharge(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&SeatSurcharge{},
+	)
+}
@@ -2,6 +2,8 @@ package entity
 
 import (
 	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/security"
+	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -13,6 +15,8 @@ type Ticket struct {
 	ID              uuid.UUID      `gorm:"type:binary(16);primaryKey"         json:"id"`
 	UserID          uuid.UUID      `gorm:"type:binary(16);not null"           json:"userId"`
 	ConnectionID    uuid.UUID      `gorm:"type:binary(16);not null"           json:"connectionID"`
+	FromStation     int            `gorm:"type:TINYINT;not null;default:0"    json:"fromStation"`
+	ToStation       int            `gorm:"type:TINYINT;not null;default:1"    json:"toStation"`
 	Seat            Seat           `json:"seat"`
 	SeatID          uuid.UUID      `gorm:"type:binary(16);not null"           json:"-"`
 	PhoneNumber     string         `gorm:"type:varchar(15);not null"                                                  json:"phoneNumber"`
@@ -25,10 +29,74 @@ type Ticket struct {
 	DropOffAdress   Address        `gorm:"foreignKey:DropOffAdressID"   json:"dropOffAddress"`
 	CreatedAt       time.Time      `gorm:"not null"                     json:"createdAt"`
 	CompletedAt     time.Time      `                                    json:"completedAt"`
+	Status          ticketStatus   `gorm:"type:enum('Pending','Paid','Cancelled','Refunded','Boarded','Completed','No Show');not null;default:'Paid';index" json:"status"`
+	Updates         []TicketUpdate `gorm:"foreignKey:TicketID"          json:"updates"`
+	Extras          []TicketExtra  `gorm:"foreignKey:TicketID"          json:"extras"`
 	TicketPayment   TicketPayment  `gorm:"foreignKey:TicketID"    `
+	Charges         []TicketCharge `gorm:"foreignKey:TicketID"          json:"charges"`
 	DeletedAt       gorm.DeletedAt `                                    json:"deletedAt"`
 }
 
+func (t Ticket) Segment() Segment {
+	return Segment{t.FromStation, t.ToStation}
+}
+
+// BoardingToken is encoded in the ticket QR code, so drivers can check it
+// offline with the signing key.
+func (t Ticket) BoardingToken(key []byte) string {
+	return security.Sign(t.ID.String(), key)
+}
+
+func ParseBoardingToken(token string, key []byte) (uuid.UUID, bool) {
+	return parseSignedID(token, key, "")
+}
+
+func parseSignedID(token string, key []byte, prefix string) (uuid.UUID, bool) {
+	payload, ok := security.Verify(token, key)
+	if !ok || !strings.HasPrefix(payload, prefix) {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(strings.TrimPrefix(payload, prefix))
+	return id, err == nil
+}
+
+type RebookJSON struct {
+	ConnectionID uuid.UUID `json:"connectionId"`
+	SeatID       uuid.UUID `json:"seatId"`
+}
+
+// Rebooking describes the result of moving a ticket; a positive difference
+// has to be paid through PaymentURL before the ticket moves, a negative one
+// is refunded through the payments of the ticket.
+type Rebooking struct {
+	TicketID     uuid.UUID `json:"ticketId"`
+	ConnectionID uuid.UUID `json:"connectionId"`
+	SeatID       uuid.UUID `json:"seatId"`
+	Price        int       `json:"price"`
+	Difference   int       `json:"difference"`
+	PaymentURL   string    `json:"paymentUrl,omitempty"`
+	Refunds      []Refaund `json:"refunds,omitempty"`
+}
+
+// AdminTicket is the full history of a ticket: its payment and status
+// updates, the stops it still has on the connection and its refunds.
+type AdminTicket struct {
+	Ticket     Ticket               `json:"ticket"`
+	Connection ConnectionSimplified `json:"connection"`
+	Stops      []Stop               `json:"stops"`
+	Refunds    []Refaund            `json:"refunds"`
+}
+
+type ChangeSeatJSON struct {
+	SeatID uuid.UUID `json:"seatId"`
+}
+
+type CancelTicketJSON struct {
+	Refund  bool   `json:"refund"`
+	Comment string `json:"comment"`
+}
+
 type CustomerTicket struct {
 	Ticket     Ticket               `json:"ticket"`
 	Connection ConnectionSimplified `json:"connection"`
@@ -40,6 +108,28 @@ type TicketPayment struct {
 	Method    paymentMethod `gorm:"type:enum('Apple Pay','Card','Cash','Google Pay');not null"        json:"method"`
 	CreatedAt time.Time     `gorm:"not null"                                                          json:"createdAt"`
 	SessionID string        `gorm:"type:varchar(500);not null"                                                          json:"sessionID"`
+
+	PaymentIntentID string `gorm:"type:varchar(255);index" json:"-"`
+
+	Discount              int           `gorm:"type:MEDIUMINT;not null;default:0" json:"discount"`
+	PromoCodeRedemptionID uuid.NullUUID `gorm:"type:binary(16);index"             json:"promoCodeRedemptionId"`
+	LoyaltyPoints         int           `gorm:"type:INT;not null;default:0"       json:"loyaltyPoints"`
+	LoyaltyTransactionID  uuid.NullUUID `gorm:"type:binary(16);index"             json:"-"`
+
+	// CollectedByID is the employee who took the cash for the ticket.
+	CollectedByID uuid.NullUUID `gorm:"type:binary(16);index" json:"collectedById"`
+}
+
+// TicketCharge is a payment taken for the ticket after its purchase, the
+// fare difference of moving it to a dearer connection. The price of the
+// ticket payment includes it, but it is refunded through its own payment.
+type TicketCharge struct {
+	ID              uuid.UUID `gorm:"type:binary(16);primaryKey"     json:"id"`
+	TicketID        uuid.UUID `gorm:"type:binary(16);not null;index" json:"-"`
+	Amount          int       `gorm:"type:MEDIUMINT;not null"        json:"amount"`
+	SessionID       string    `gorm:"type:varchar(500);not null"     json:"sessionId"`
+	PaymentIntentID string    `gorm:"type:varchar(255);index"        json:"-"`
+	CreatedAt       time.Time `gorm:"not null"                       json:"createdAt"`
 }
 
 type paymentMethod string
@@ -61,21 +151,46 @@ func DefinePaymentMethod(v string) (paymentMethod, bool) {
 }
 
 func MigrateTicket(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&Ticket{},
 		&TicketPayment{},
+		&TicketUpdate{},
+		&TicketCharge{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// Tickets cancelled before the status existed were only soft-deleted.
+	err = db.Unscoped().
+		Model(&Ticket{}).
+		Where("deleted_at IS NOT NULL AND status = ?", TicketStatusPaid).
+		Update("status", TicketStatusRefunded).Error
+	if err != nil {
+		return err
+	}
 
+	// Completed ones only got their completion time.
+	return db.Unscoped().
+		Model(&Ticket{}).
+		Where("completed_at > ? AND status IN (?)", time.Unix(0, 0), []any{TicketStatusPaid, TicketStatusBoarded}).
+		Update("status", TicketStatusCompleted).Error
 }
 
 type NewTicketJSON struct {
-	ConnectionID  uuid.UUID      `json:"connectionId"`
-	SeatIDs       []uuid.UUID    `json:"seatIDs"`
-	Passengers    []NewPassenger `json:"passengers"`
-	DropOffAdress NewAddress     `json:"dropOffAdress"`
-	PickUpAdress  NewAddress     `json:"pickUpAdress"`
-	Email         string         `json:"email"`
-	PhoneNumber   string         `json:"phoneNumber"`
+	ConnectionID  uuid.UUID        `json:"connectionId"`
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	SeatIDs       []uuid.UUID      `json:"seatIDs"`
+	Passengers    []NewPassenger   `json:"passengers"`
+	DropOffAdress NewAddress       `json:"dropOffAdress"`
+	PickUpAdress  NewAddress       `json:"pickUpAdress"`
+	Email         string           `json:"email"`
+	PhoneNumber   string           `json:"phoneNumber"`
+	PromoCode     string           `json:"promoCode"`
+	WaitlistToken string           `json:"waitlistToken"`
+	Extras        []NewTicketExtra `json:"extras"`
+	LoyaltyPoints int              `json:"loyaltyPoints"`
 }
 
 func (t NewTicketJSON) ParseContaanctInfo() (email string, phoneNumber string, err error) {
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+)
+
+type ticketStatus string
+
+const (
+	TicketStatusPending   ticketStatus = "Pending"
+	TicketStatusPaid      ticketStatus = "Paid"
+	TicketStatusCancelled ticketStatus = "Cancelled"
+	TicketStatusRefunded  ticketStatus = "Refunded"
+	TicketStatusBoarded   ticketStatus = "Boarded"
+	TicketStatusCompleted ticketStatus = "Completed"
+	TicketStatusNoShow    ticketStatus = "No Show"
+)
+
+// ticketTransitions lists the statuses a ticket may move to from each status;
+// cancelled, refunded, completed and no-show tickets are final. A paid ticket
+// becomes a no-show when its connection finishes without the passenger.
+var ticketTransitions = map[ticketStatus][]ticketStatus{
+	TicketStatusPending: {TicketStatusPaid, TicketStatusCancelled},
+	TicketStatusPaid:    {TicketStatusBoarded, TicketStatusCancelled, TicketStatusRefunded, TicketStatusNoShow},
+	TicketStatusBoarded: {TicketStatusCompleted},
+}
+
+// ActiveTicketStatuses are the statuses of tickets that still hold their seat.
+var ActiveTicketStatuses = []ticketStatus{TicketStatusPending, TicketStatusPaid, TicketStatusBoarded, TicketStatusCompleted, TicketStatusNoShow}
+
+func ParseTicketStatus(v string) (ticketStatus, bool) {
+	switch ticketStatus(v) {
+	case TicketStatusPending, TicketStatusPaid, TicketStatusCancelled, TicketStatusRefunded, TicketStatusBoarded, TicketStatusCompleted, TicketStatusNoShow:
+		return ticketStatus(v), true
+	default:
+		return "", false
+	}
+}
+
+func (s ticketStatus) CanChangeTo(to ticketStatus) bool {
+	return slices.Contains(ticketTransitions[s], to)
+}
+
+// TicketStatusesFrom returns the statuses a ticket may leave to reach to.
+func TicketStatusesFrom(to ticketStatus) []ticketStatus {
+	var from []ticketStatus
+	for status, next := range ticketTransitions {
+		if slices.Contains(next, to) {
+			from = append(from, status)
+		}
+	}
+
+	return from
+}
+
+type TicketUpdate struct {
+	TicketID  uuid.UUID    `json:"-"         gorm:"type:binary(16);not null;index"`
+	Status    ticketStatus `json:"status"    gorm:"type:enum('Pending','Paid','Cancelled','Refunded','Boarded','Completed','No Show');not null"`
+	CreatedAt time.Time    `json:"createdAt" gorm:"not null"`
+	Comment   string       `json:"comment"   gorm:"type:varchar(500)"`
+}
+
+// ChangeStatus moves the ticket to the status and returns the update to be
+// stored with it.
+func (t *Ticket) ChangeStatus(to ticketStatus, comment string) (TicketUpdate, error) {
+	if !t.Status.CanChangeTo(to) {
+		return TicketUpdate{}, rfc7807.New(http.StatusConflict, "invalid-ticket-status", "Invalid Ticket Status Error", "A ticket that is "+string(t.Status)+" cannot become "+string(to)+".")
+	}
+
+	t.Status = to
+	return TicketUpdate{
+		TicketID: t.ID,
+		Status:   to,
+		Comment:  comment,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	rfc7807 "maryan_api/pkg/problem"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketTransferDuration is how long a transfer invitation stays valid unless
+// the departure cutoff comes first.
+const TicketTransferDuration = 48 * time.Hour
+
+// TicketTransfer is an invitation to take over a ticket; once accepted it is
+// the transfer record both the sender and the recipient see.
+type TicketTransfer struct {
+	ID         uuid.UUID            `gorm:"type:binary(16);primaryKey"                                     json:"id"`
+	TicketID   uuid.UUID            `gorm:"type:binary(16);not null;index"                                 json:"ticketId"`
+	FromUserID uuid.UUID            `gorm:"type:binary(16);not null;index"                                 json:"fromUserId"`
+	ToEmail    string               `gorm:"type:varchar(255);not null;index"                               json:"toEmail"`
+	ToUserID   uuid.NullUUID        `gorm:"type:binary(16);index"                                          json:"toUserId"`
+	Status     ticketTransferStatus `gorm:"type:enum('Pending','Accepted','Cancelled');not null;index"     json:"status"`
+	ExpiresAt  time.Time            `gorm:"not null"                                                       json:"expiresAt"`
+	AcceptedAt *time.Time           `                                                                      json:"acceptedAt"`
+	CreatedAt  time.Time            `gorm:"not null"                                                       json:"createdAt"`
+	UpdatedAt  time.Time            `gorm:"not null"                                                       json:"updatedAt"`
+}
+
+type ticketTransferStatus string
+
+// A newer invitation for the same ticket cancels the pending one.
+const (
+	TicketTransferStatusPending   ticketTransferStatus = "Pending"
+	TicketTransferStatusAccepted  ticketTransferStatus = "Accepted"
+	TicketTransferStatusCancelled ticketTransferStatus = "Cancelled"
+)
+
+func NewTicketTransfer(ticketID, fromUserID uuid.UUID, email string, expiresAt time.Time) (TicketTransfer, error) {
+	email = strings.TrimSpace(email)
+	if !govalidator.IsEmail(email) {
+		return TicketTransfer{}, rfc7807.BadRequest("invalid-email", "Invalid Email Error", "Contains invalid characters or is not an email.")
+	}
+
+	return TicketTransfer{
+		ID:         uuid.New(),
+		TicketID:   ticketID,
+		FromUserID: fromUserID,
+		ToEmail:    strings.ToLower(email),
+		Status:     TicketTransferStatusPending,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (t TicketTransfer) IsPending(now time.Time) bool {
+	return t.Status == TicketTransferStatusPending && now.Before(t.ExpiresAt)
+}
+
+type TicketTransferJSON struct {
+	Email string `json:"email"`
+}
+
+type AcceptTicketTransferJSON struct {
+	Token string `json:"token"`
+}
+
+type TransferPolicy struct {
+	CutoffBefore time.Duration
+}
+
+// CanTransfer reports whether a ticket departing at departure may still
+// change hands.
+func (p TransferPolicy) CanTransfer(departure, now time.Time) bool {
+	return departure.Sub(now) > p.CutoffBefore
+}
+
+// InvitationExpiry returns when an invitation sent now stops being valid.
+func (p TransferPolicy) InvitationExpiry(departure, now time.Time) time.Time {
+	expiresAt := now.Add(TicketTransferDuration)
+	if cutoff := departure.Add(-p.CutoffBefore); cutoff.Before(expiresAt) {
+		return cutoff
+	}
+
+	return expiresAt
+}
+
+func MigrateTicketTransfer(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&TicketTransfer{},
+	)
+}
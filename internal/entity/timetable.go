@@ -0,0 +1,281 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// The trips of a timetable are generated DefaultTimetableWeeks ahead unless
+// the admin asks for up to MaxTimetableWeeks.
+const (
+	DefaultTimetableWeeks = 12
+	MaxTimetableWeeks     = 52
+)
+
+// Timetable is a recurring trip. The outbound connection leaves the first
+// station of the route at the departure time, local to the country of the
+// station, on the weekdays of the validity period but the exceptions; the
+// return connection leaves the return offset in minutes later.
+type Timetable struct {
+	ID               uuid.UUID            `gorm:"type:binary(16);primaryKey"            json:"id"`
+	Line             int                  `gorm:"type:SMALLINT;not null"                json:"line"`
+	RouteID          uuid.UUID            `gorm:"type:binary(16);not null"              json:"routeId"`
+	Route            Route                `gorm:"foreignKey:RouteID"                    json:"-"`
+	ReturnRouteID    uuid.UUID            `gorm:"type:binary(16);not null"              json:"returnRouteId"`
+	ReturnRoute      Route                `gorm:"foreignKey:ReturnRouteID"              json:"-"`
+	BusID            uuid.UUID            `gorm:"type:binary(16);not null"              json:"busId"`
+	Price            int                  `gorm:"type:MEDIUMINT;not null"               json:"price"`
+	TeenagerDiscount int                  `gorm:"type:TINYINT;not null;default:0"       json:"teenagerDiscount"`
+	ChildDiscount    int                  `gorm:"type:TINYINT;not null;default:0"       json:"childDiscount"`
+	Weekdays         Weekdays             `gorm:"type:TINYINT UNSIGNED;not null"        json:"weekdays"`
+	DepartureTime    string               `gorm:"type:char(5);not null"                 json:"departureTime"`
+	ReturnOffset     int                  `gorm:"type:INT;not null"                     json:"returnOffset"`
+	ValidFrom        time.Time            `gorm:"type:date;not null"                    json:"validFrom"`
+	ValidTo          time.Time            `gorm:"type:date;not null"                    json:"validTo"`
+	Exceptions       []TimetableException `gorm:"foreignKey:TimetableID"                json:"exceptions"`
+	CreatedAt        time.Time            `gorm:"not null"                              json:"createdAt"`
+}
+
+// TimetableException is a date, e.g. a holiday, the timetable has no trip on.
+type TimetableException struct {
+	TimetableID uuid.UUID `gorm:"type:binary(16);primaryKey" json:"-"`
+	Date        time.Time `gorm:"type:date;primaryKey"       json:"date"`
+}
+
+// Weekdays is the set of the days of the week, one bit per time.Weekday.
+type Weekdays uint8
+
+func (w Weekdays) Has(day time.Weekday) bool {
+	return w&(1<<day) != 0
+}
+
+func (w Weekdays) MarshalJSON() ([]byte, error) {
+	var days = []string{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if w.Has(day) {
+			days = append(days, day.String())
+		}
+	}
+
+	return json.Marshal(days)
+}
+
+// ParseWeekdays parses the English names of the days of the week.
+func ParseWeekdays(values []string) (Weekdays, rfc7807.InvalidParams) {
+	var params rfc7807.InvalidParams
+	var weekdays Weekdays
+	for i, v := range values {
+		day := time.Sunday
+		for day <= time.Saturday && day.String() != v {
+			day++
+		}
+
+		if day > time.Saturday {
+			params.SetInvalidParam(fmt.Sprintf("weekdays[%d]", i), "Non-existing day of the week '"+v+"'.")
+			continue
+		}
+		weekdays |= 1 << day
+	}
+
+	if weekdays == 0 && params == nil {
+		params.SetInvalidParam("weekdays", "Has to contain at least one day of the week.")
+	}
+
+	return weekdays, params
+}
+
+// Dates returns the dates from from to to, both included, the timetable has a
+// trip on.
+func (t Timetable) Dates(from, to time.Time) []time.Time {
+	if from.Before(t.ValidFrom) {
+		from = t.ValidFrom
+	}
+
+	if to.After(t.ValidTo) {
+		to = t.ValidTo
+	}
+
+	var dates []time.Time
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if !t.Weekdays.Has(date.Weekday()) {
+			continue
+		}
+
+		if slices.ContainsFunc(t.Exceptions, func(e TimetableException) bool { return e.Date.Equal(date) }) {
+			continue
+		}
+
+		dates = append(dates, date)
+	}
+
+	return dates
+}
+
+// Location is the time zone the departure time is local to.
+func (t Timetable) Location() *time.Location {
+	if len(t.Route.Stations) == 0 {
+		return time.UTC
+	}
+
+	return countryLocation(t.Route.Stations[0].Country)
+}
+
+// Trip builds the trip of the timetable leaving on the date; the routes of
+// the timetable have to be loaded with the countries of their stations.
+func (t Timetable) Trip(date time.Time) Trip {
+	clock, _ := time.Parse("15:04", t.DepartureTime)
+	departure := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, t.Location()).UTC()
+
+	connection := func(route Route, departureTime time.Time) Connection {
+		connection := Connection{
+			Line:             t.Line,
+			Price:            t.Price,
+			TeenagerDiscount: t.TeenagerDiscount,
+			ChildDiscount:    t.ChildDiscount,
+			DepartureTime:    departureTime,
+			BusID:            t.BusID,
+			Type:             ComertialConnectionType,
+		}
+		connection.ApplyRoute(route)
+		return connection
+	}
+
+	return Trip{
+		TimetableID:        uuid.NullUUID{UUID: t.ID, Valid: true},
+		TimetableDate:      &date,
+		OutboundConnection: connection(t.Route, departure),
+		ReturnConnection:   connection(t.ReturnRoute, departure.Add(time.Duration(t.ReturnOffset)*time.Minute)),
+	}
+}
+
+type NewTimetableJSON struct {
+	Line             int       `json:"line"`
+	RouteID          uuid.UUID `json:"routeId"`
+	ReturnRouteID    uuid.UUID `json:"returnRouteId"`
+	BusID            uuid.UUID `json:"busId"`
+	Price            int       `json:"price"`
+	TeenagerDiscount int       `json:"teenagerDiscount"`
+	ChildDiscount    int       `json:"childDiscount"`
+	Weekdays         []string  `json:"weekdays"`
+	// DepartureTime is "15:04" in the time zone of the first station.
+	DepartureTime string `json:"departureTime"`
+	// ReturnOffset is the time in minutes from the outbound departure to the
+	// return one.
+	ReturnOffset int `json:"returnOffset"`
+	// The dates are "2006-01-02".
+	ValidFrom  string   `json:"validFrom"`
+	ValidTo    string   `json:"validTo"`
+	Exceptions []string `json:"exceptions"`
+}
+
+func (t NewTimetableJSON) Parse() (Timetable, error) {
+	var params rfc7807.InvalidParams
+
+	if t.Line <= 0 {
+		params.SetInvalidParam("line", "Has to be greater than 0.")
+	}
+
+	if t.Price <= 0 {
+		params.SetInvalidParam("price", "Has to be greater than 0.")
+	}
+
+	if t.TeenagerDiscount < 0 || t.TeenagerDiscount > 100 {
+		params.SetInvalidParam("teenagerDiscount", "Must be a percentage between 0 and 100.")
+	}
+
+	if t.ChildDiscount < 0 || t.ChildDiscount > 100 {
+		params.SetInvalidParam("childDiscount", "Must be a percentage between 0 and 100.")
+	}
+
+	weekdays, weekdaysParams := ParseWeekdays(t.Weekdays)
+	params = append(params, weekdaysParams...)
+
+	if _, err := time.Parse("15:04", t.DepartureTime); err != nil {
+		params.SetInvalidParam("departureTime", "Has to be in the 15:04 format.")
+	}
+
+	if t.ReturnOffset <= 0 {
+		params.SetInvalidParam("returnOffset", "Has to be greater than 0.")
+	}
+
+	validFrom, err := time.Parse("2006-01-02", t.ValidFrom)
+	if err != nil {
+		params.SetInvalidParam("validFrom", err.Error())
+	}
+
+	validTo, err := time.Parse("2006-01-02", t.ValidTo)
+	if err != nil {
+		params.SetInvalidParam("validTo", err.Error())
+	} else if validTo.Before(validFrom) {
+		params.SetInvalidParam("validTo", "Cannot be before the start of the validity.")
+	}
+
+	timetable := Timetable{
+		ID:               uuid.New(),
+		Line:             t.Line,
+		RouteID:          t.RouteID,
+		ReturnRouteID:    t.ReturnRouteID,
+		BusID:            t.BusID,
+		Price:            t.Price,
+		TeenagerDiscount: t.TeenagerDiscount,
+		ChildDiscount:    t.ChildDiscount,
+		Weekdays:         weekdays,
+		DepartureTime:    t.DepartureTime,
+		ReturnOffset:     t.ReturnOffset,
+		ValidFrom:        validFrom,
+		ValidTo:          validTo,
+	}
+
+	for i, exception := range t.Exceptions {
+		date, err := time.Parse("2006-01-02", exception)
+		if err != nil {
+			params.SetInvalidParam(fmt.Sprintf("exceptions[%d]", i), err.Error())
+			continue
+		}
+
+		if !slices.ContainsFunc(timetable.Exceptions, func(e TimetableException) bool { return e.Date.Equal(date) }) {
+			timetable.Exceptions = append(timetable.Exceptions, TimetableException{TimetableID: timetable.ID, Date: date})
+		}
+	}
+
+	if params != nil {
+		return Timetable{}, rfc7807.BadRequest("timetable-invalid-data", "Timetable Data Error", "Provided data is not valid.", params...)
+	}
+
+	return timetable, nil
+}
+
+// TimetableConflict is a date the trip of a timetable could not be generated
+// on and why.
+type TimetableConflict struct {
+	Date   time.Time `json:"date"`
+	Reason string    `json:"reason"`
+}
+
+const (
+	TimetableConflictBusUnavailable = "The bus is unavailable."
+	TimetableConflictBusBusy        = "The bus drives another connection."
+	TimetableConflictNoDriver       = "The bus has no lead driver."
+	TimetableConflictDriverBusy     = "A driver of the bus drives another connection."
+)
+
+// TimetableGeneration reports a run of the generator: the trips it created,
+// the dates it had generated before and the conflicts it left alone.
+type TimetableGeneration struct {
+	Created   []uuid.UUID         `json:"created"`
+	Existing  int                 `json:"existing"`
+	Conflicts []TimetableConflict `json:"conflicts"`
+}
+
+func MigrateTimetable(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&Timetable{},
+		&TimetableException{},
+	)
+}
@@ -16,6 +16,10 @@ type Trip struct {
 	ReturnConnectionID   uuid.UUID    `gorm:"type:binary(16);not null"                           json:"-"`
 	ReturnConnection     Connection   `gorm:"foreignKey:ReturnConnectionID;references:ID"        json:"returnConnection"`
 	Updates              []TripUpdate `                                                    json:"updates"`
+	// The trips generated from a timetable keep the date they were generated
+	// for, so the timetable never generates a date twice.
+	TimetableID   uuid.NullUUID `gorm:"type:binary(16);uniqueIndex:idx_trips_timetable_date" json:"timetableId"`
+	TimetableDate *time.Time    `gorm:"type:date;uniqueIndex:idx_trips_timetable_date"       json:"timetableDate,omitempty"`
 }
 
 type tripStatus string
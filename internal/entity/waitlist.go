@@ -0,0 +1,89 @@
+package entity
+
+import (
+	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/security"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// WaitlistOfferDuration is how long an offered entry keeps its seats reserved.
+const WaitlistOfferDuration = 2 * time.Hour
+
+const MaxWaitlistPartySize = 10
+
+type WaitlistEntry struct {
+	ID             uuid.UUID      `gorm:"type:binary(16);primaryKey"                                                   json:"id"`
+	ConnectionID   uuid.UUID      `gorm:"type:binary(16);not null;index"                                               json:"connectionId"`
+	UserID         uuid.UUID      `gorm:"type:binary(16);not null;index"                                               json:"-"`
+	User           User           `gorm:"foreignKey:UserID"                                                            json:"-"`
+	PartySize      int            `gorm:"type:TINYINT;not null"                                                        json:"partySize"`
+	Status         waitlistStatus `gorm:"type:enum('Waiting','Offered','Fulfilled','Expired');not null;index"          json:"status"`
+	OfferExpiresAt *time.Time     `                                                                                    json:"offerExpiresAt"`
+	ExpiresAt      time.Time      `gorm:"not null;index"                                                               json:"expiresAt"`
+	CreatedAt      time.Time      `gorm:"not null"                                                                     json:"createdAt"`
+	UpdatedAt      time.Time      `gorm:"not null"                                                                     json:"updatedAt"`
+}
+
+type waitlistStatus string
+
+const (
+	WaitlistStatusWaiting   waitlistStatus = "Waiting"
+	WaitlistStatusOffered   waitlistStatus = "Offered"
+	WaitlistStatusFulfilled waitlistStatus = "Fulfilled"
+	WaitlistStatusExpired   waitlistStatus = "Expired"
+)
+
+// NewWaitlistEntry puts the party on the waitlist of the connection until its departure.
+func NewWaitlistEntry(connection *Connection, userID uuid.UUID, partySize int) (WaitlistEntry, error) {
+	if partySize < 1 || partySize > MaxWaitlistPartySize {
+		return WaitlistEntry{}, rfc7807.BadRequest("invalid-party-size", "Invalid Party Size Error", "The party size has to be between 1 and 10.")
+	}
+
+	if !connection.DepartureTime.After(time.Now()) {
+		return WaitlistEntry{}, rfc7807.BadRequest("departed-connection", "Departed Connection Error", "The connection has already departed.")
+	}
+
+	return WaitlistEntry{
+		ID:           uuid.New(),
+		ConnectionID: connection.ID,
+		UserID:       userID,
+		PartySize:    partySize,
+		Status:       WaitlistStatusWaiting,
+		ExpiresAt:    connection.DepartureTime,
+	}, nil
+}
+
+// IsOffered reports whether the entry holds an offer that can still be used.
+func (e WaitlistEntry) IsOffered(at time.Time) bool {
+	return e.Status == WaitlistStatusOffered && e.OfferExpiresAt != nil && e.OfferExpiresAt.After(at) && e.ExpiresAt.After(at)
+}
+
+const waitlistTokenPrefix = "waitlist:"
+
+// OfferToken authorises the purchase of the reserved seats.
+func (e WaitlistEntry) OfferToken(key []byte) string {
+	return security.Sign(waitlistTokenPrefix+e.ID.String(), key)
+}
+
+func ParseWaitlistOfferToken(token string, key []byte) (uuid.UUID, bool) {
+	return parseSignedID(token, key, waitlistTokenPrefix)
+}
+
+type WaitlistDepth struct {
+	Entries    int `json:"entries"`
+	Passengers int `json:"passengers"`
+}
+
+type AdminConnection struct {
+	Connection
+	Waitlist WaitlistDepth `json:"waitlist"`
+}
+
+func MigrateWaitlist(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&WaitlistEntry{},
+	)
+}
@@ -0,0 +1,16 @@
+package notification
+
+import (
+	"log"
+	"time"
+)
+
+func TicketConfirmation(email, ticketID, link string) error {
+	log.Printf("ticket %s confirmation for %s: %s", ticketID, email, link)
+	return nil
+}
+
+func TicketTransferInvitation(email, link string, expiresAt time.Time) error {
+	log.Printf("ticket transfer invitation for %s valid until %s: %s", email, expiresAt.Format(time.RFC3339), link)
+	return nil
+}
@@ -0,0 +1,11 @@
+package notification
+
+import (
+	"log"
+	"time"
+)
+
+func WaitlistOffer(email, link string, expiresAt time.Time) error {
+	log.Printf("waitlist offer for %s valid until %s: %s", email, expiresAt.Format(time.RFC3339), link)
+	return nil
+}
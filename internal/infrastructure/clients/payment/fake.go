@@ -0,0 +1,307 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"maryan_api/config"
+	"maryan_api/internal/entity"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/d3code/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+const fakeSignatureHeader = "X-Fake-Signature"
+
+type fakeSession struct {
+	ID              string
+	PaymentIntentID string
+	Checkout        Checkout
+	Status          Status
+	Refunded        int64
+	PendingRefunds  []entity.PaymentEvent
+}
+
+type Fake struct {
+	mu       sync.Mutex
+	sessions map[string]*fakeSession
+	secret   []byte
+	apiURL   string
+	client   *http.Client
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateSession(ctx context.Context, checkout Checkout) (Session, error) {
+	s := &fakeSession{
+		ID:              "fake_cs_" + uuid.New().String(),
+		PaymentIntentID: "fake_pi_" + uuid.New().String(),
+		Checkout:        checkout,
+		Status:          StatusOpen,
+	}
+
+	f.mu.Lock()
+	f.sessions[s.ID] = s
+	f.mu.Unlock()
+
+	return Session{ID: s.ID, URL: f.apiURL + "/payments/fake/" + s.ID}, nil
+}
+
+func (f *Fake) FetchStatus(ctx context.Context, sessionID string) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[sessionID]
+	if !ok {
+		return "", errors.New("unknown session " + sessionID)
+	}
+
+	if s.Status == StatusOpen && time.Now().After(s.Checkout.ExpiresAt) {
+		s.Status = StatusExpired
+	}
+
+	return s.Status, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, paymentIntentID string, amount int64) (string, error) {
+	f.mu.Lock()
+	var refunded *fakeSession
+	for _, s := range f.sessions {
+		if s.PaymentIntentID == paymentIntentID {
+			refunded = s
+			break
+		}
+	}
+
+	if refunded == nil || refunded.Status != StatusPaid && refunded.Status != StatusRefunded {
+		f.mu.Unlock()
+		return "", errors.New("no paid session for payment intent " + paymentIntentID)
+	}
+
+	if refunded.Refunded+amount > refunded.Checkout.Amount() {
+		f.mu.Unlock()
+		return "", errors.New("refund amount exceeds the paid amount")
+	}
+
+	refundID := "fake_re_" + uuid.New().String()
+	refunded.Refunded += amount
+	if refunded.Refunded == refunded.Checkout.Amount() {
+		refunded.Status = StatusRefunded
+	}
+	refunded.PendingRefunds = append(refunded.PendingRefunds, entity.NewRefundEvent("fake_evt_"+uuid.New().String(), paymentIntentID, refundID, amount))
+	f.mu.Unlock()
+
+	return refundID, nil
+}
+
+func (f *Fake) ParseWebhook(payload []byte, header http.Header) (entity.PaymentEvent, error) {
+	signature, err := hex.DecodeString(header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.sign(payload)) {
+		return entity.PaymentEvent{}, errors.New("invalid signature")
+	}
+
+	var event entity.PaymentEvent
+	err = json.Unmarshal(payload, &event)
+	return event, err
+}
+
+// Complete marks the session as paid and delivers the webhook the same way a
+// real provider would.
+func (f *Fake) Complete(sessionID string) error {
+	s, err := f.transition(sessionID, StatusPaid)
+	if err != nil {
+		return err
+	}
+
+	event := entity.NewPaymentEvent("fake_evt_"+uuid.New().String(), string(entity.PaymentEventCheckoutCompleted), s.ID, s.PaymentIntentID)
+	event.AmountTotal = s.Checkout.Amount()
+	return f.send(event)
+}
+
+func (f *Fake) Expire(sessionID string) error {
+	s, err := f.transition(sessionID, StatusExpired)
+	if err != nil {
+		return err
+	}
+
+	return f.send(entity.NewPaymentEvent("fake_evt_"+uuid.New().String(), string(entity.PaymentEventCheckoutExpired), s.ID, ""))
+}
+
+// SettleRefunds pays out the refunds of the session and delivers their
+// webhooks. Refund only queues them, so the caller has recorded the refund id
+// by the time the webhook arrives, unless the simulator settles it sooner.
+func (f *Fake) SettleRefunds(sessionID string) error {
+	f.mu.Lock()
+	s, ok := f.sessions[sessionID]
+	var pending []entity.PaymentEvent
+	if ok {
+		pending, s.PendingRefunds = s.PendingRefunds, nil
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return errors.New("unknown session " + sessionID)
+	}
+
+	for i, event := range pending {
+		err := f.send(event)
+		if err != nil {
+			f.mu.Lock()
+			s.PendingRefunds = append(pending[i:], s.PendingRefunds...)
+			f.mu.Unlock()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *Fake) transition(sessionID string, status Status) (fakeSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[sessionID]
+	if !ok {
+		return fakeSession{}, errors.New("unknown session " + sessionID)
+	}
+
+	if s.Status != StatusOpen {
+		return fakeSession{}, fmt.Errorf("the session is already %s", s.Status)
+	}
+
+	s.Status = status
+	return *s, nil
+}
+
+func (f *Fake) send(event entity.PaymentEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.apiURL+"/webhooks/"+f.Name(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fakeSignatureHeader, hex.EncodeToString(f.sign(payload)))
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("the webhook responded with %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (f *Fake) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+var simulatorPage = template.Must(template.New("simulator").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Fake payment {{.ID}}</title></head>
+<body>
+	<h1>Fake payment</h1>
+	<p>Session: {{.ID}}</p>
+	<p>Status: {{.Status}}</p>
+	<ul>
+	{{range .Checkout.LineItems}}<li>{{.Quantity}} x {{.Name}} — {{.Amount}}</li>{{end}}
+	</ul>
+	<p>Total: {{.Checkout.Amount}}</p>
+	{{if eq .Status "Open"}}
+	<form method="post" action="/payments/fake/{{.ID}}/complete"><button>Pay</button></form>
+	<form method="post" action="/payments/fake/{{.ID}}/expire"><button>Expire</button></form>
+	{{end}}
+	{{if eq .Status "Paid"}}
+	<form method="post" action="/payments/fake/{{.ID}}/refund"><button>Refund</button></form>
+	{{end}}
+	{{with .PendingRefunds}}
+	<p>Pending refunds: {{len .}}</p>
+	<form method="post" action="/payments/fake/{{$.ID}}/settle"><button>Settle refunds</button></form>
+	{{end}}
+</body>
+</html>`))
+
+// RegisterRoutes exposes a simulator page that plays the role of the hosted
+// checkout, so the whole purchase flow can be exercised without a real provider.
+func (f *Fake) RegisterRoutes(s *gin.Engine) {
+	s.GET("/payments/fake/:id", func(ctx *gin.Context) {
+		f.mu.Lock()
+		session, ok := f.sessions[ctx.Param("id")]
+		var view fakeSession
+		if ok {
+			view = *session
+		}
+		f.mu.Unlock()
+
+		if !ok {
+			ctx.String(http.StatusNotFound, "unknown session")
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		simulatorPage.Execute(ctx.Writer, view)
+	})
+
+	s.POST("/payments/fake/:id/:action", func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		var err error
+		switch ctx.Param("action") {
+		case "complete":
+			err = f.Complete(id)
+		case "expire":
+			err = f.Expire(id)
+		case "refund":
+			f.mu.Lock()
+			session, ok := f.sessions[id]
+			var intentID string
+			var amount int64
+			if ok {
+				intentID, amount = session.PaymentIntentID, session.Checkout.Amount()-session.Refunded
+			}
+			f.mu.Unlock()
+			_, err = f.Refund(ctx.Request.Context(), intentID, amount)
+		case "settle":
+			err = f.SettleRefunds(id)
+		default:
+			err = errors.New("unknown action")
+		}
+
+		if err != nil {
+			ctx.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx.Redirect(http.StatusSeeOther, "/payments/fake/"+id)
+	})
+}
+
+func NewFake(client *http.Client) *Fake {
+	return &Fake{
+		sessions: map[string]*fakeSession{},
+		secret:   config.FakePaymentSecretKey(),
+		apiURL:   config.APIURL(),
+		client:   client,
+	}
+}
@@ -0,0 +1,199 @@
+package payment
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"maryan_api/internal/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestFake starts a fake whose webhooks are checked and handed over to the
+// returned channel.
+func newTestFake(t *testing.T) (*Fake, <-chan entity.PaymentEvent) {
+	t.Helper()
+
+	var fake *Fake
+	events := make(chan entity.PaymentEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/webhooks/fake" {
+			http.NotFound(w, r)
+			return
+		}
+
+		payload, _ := io.ReadAll(r.Body)
+		event, err := fake.ParseWebhook(payload, r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("API_URL", server.URL)
+	t.Setenv("FAKE_PAYMENT_SECRET_KEY", "test-secret")
+	fake = NewFake(server.Client())
+
+	return fake, events
+}
+
+func waitEvent(t *testing.T, events <-chan entity.PaymentEvent) entity.PaymentEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivered")
+		return entity.PaymentEvent{}
+	}
+}
+
+func testCheckout(expiresAt time.Time) Checkout {
+	return Checkout{
+		LineItems: []LineItem{
+			{Name: "Adult ticket", Amount: 1000, Quantity: 1},
+			{Name: "Suitcase", Amount: 250, Quantity: 2},
+		},
+		ExpiresAt: expiresAt,
+	}
+}
+
+func TestFakePurchaseAndRefund(t *testing.T) {
+	fake, events := newTestFake(t)
+	ctx := context.Background()
+
+	session, err := fake.CreateSession(ctx, testCheckout(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.Complete(session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	completed := waitEvent(t, events)
+	if completed.Type != entity.PaymentEventCheckoutCompleted || completed.SessionID != session.ID || completed.PaymentIntentID == "" {
+		t.Fatalf("unexpected event %+v", completed)
+	}
+
+	if status, _ := fake.FetchStatus(ctx, session.ID); status != StatusPaid {
+		t.Fatalf("status = %s, want %s", status, StatusPaid)
+	}
+
+	if err := fake.Complete(session.ID); err == nil {
+		t.Error("a paid session was completed again")
+	}
+
+	refundID, err := fake.Refund(ctx, completed.PaymentIntentID, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("the refund webhook %+v was delivered before the refunds were settled", event)
+	default:
+	}
+
+	if err := fake.SettleRefunds(session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	refunded := waitEvent(t, events)
+	if refunded.Type != entity.PaymentEventRefundSucceeded || refunded.RefundID != refundID || refunded.AmountRefunded != 500 {
+		t.Fatalf("unexpected event %+v", refunded)
+	}
+
+	if status, _ := fake.FetchStatus(ctx, session.ID); status != StatusPaid {
+		t.Fatalf("status after a partial refund = %s, want %s", status, StatusPaid)
+	}
+
+	if _, err := fake.Refund(ctx, completed.PaymentIntentID, 1001); err == nil {
+		t.Error("more than the paid amount was refunded")
+	}
+
+	if _, err := fake.Refund(ctx, completed.PaymentIntentID, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.SettleRefunds(session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := waitEvent(t, events); event.AmountRefunded != 1000 {
+		t.Errorf("amount refunded = %d, want 1000", event.AmountRefunded)
+	}
+
+	if status, _ := fake.FetchStatus(ctx, session.ID); status != StatusRefunded {
+		t.Errorf("status = %s, want %s", status, StatusRefunded)
+	}
+}
+
+func TestFakeRefundUnpaid(t *testing.T) {
+	fake, _ := newTestFake(t)
+
+	if _, err := fake.Refund(context.Background(), "fake_pi_unknown", 100); err == nil {
+		t.Error("an unknown payment was refunded")
+	}
+}
+
+func TestFakeExpiry(t *testing.T) {
+	fake, events := newTestFake(t)
+	ctx := context.Background()
+
+	t.Run("on the simulator", func(t *testing.T) {
+		session, _ := fake.CreateSession(ctx, testCheckout(time.Now().Add(time.Hour)))
+		if err := fake.Expire(session.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		event := waitEvent(t, events)
+		if event.Type != entity.PaymentEventCheckoutExpired || event.SessionID != session.ID {
+			t.Fatalf("unexpected event %+v", event)
+		}
+
+		if err := fake.Complete(session.ID); err == nil {
+			t.Error("an expired session was paid")
+		}
+	})
+
+	t.Run("after the expiry time", func(t *testing.T) {
+		session, _ := fake.CreateSession(ctx, testCheckout(time.Now().Add(-time.Second)))
+		if status, _ := fake.FetchStatus(ctx, session.ID); status != StatusExpired {
+			t.Errorf("status = %s, want %s", status, StatusExpired)
+		}
+	})
+}
+
+func TestFakeWebhookSignature(t *testing.T) {
+	fake, _ := newTestFake(t)
+	payload := []byte(`{"id":"fake_evt_1","type":"checkout.session.completed","sessionId":"fake_cs_1"}`)
+
+	header := http.Header{}
+	header.Set(fakeSignatureHeader, "00")
+	if _, err := fake.ParseWebhook(payload, header); err == nil {
+		t.Error("a forged webhook was accepted")
+	}
+
+	other := &Fake{secret: []byte("other-secret")}
+	header.Set(fakeSignatureHeader, hexSignature(other, payload))
+	if _, err := fake.ParseWebhook(payload, header); err == nil {
+		t.Error("a webhook signed with another secret was accepted")
+	}
+
+	header.Set(fakeSignatureHeader, hexSignature(fake, payload))
+	event, err := fake.ParseWebhook(payload, header)
+	if err != nil || event.SessionID != "fake_cs_1" {
+		t.Errorf("ParseWebhook() = %+v, %v", event, err)
+	}
+}
+
+func hexSignature(f *Fake, payload []byte) string {
+	return hex.EncodeToString(f.sign(payload))
+}
@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"net/http"
+	"time"
+)
+
+type Provider interface {
+	Name() string
+	CreateSession(ctx context.Context, checkout Checkout) (Session, error)
+	FetchStatus(ctx context.Context, sessionID string) (Status, error)
+	Refund(ctx context.Context, paymentIntentID string, amount int64) (string, error)
+	ParseWebhook(payload []byte, header http.Header) (entity.PaymentEvent, error)
+}
+
+type Checkout struct {
+	LineItems []LineItem
+	ExpiresAt time.Time
+}
+
+func (c Checkout) Amount() int64 {
+	var amount int64
+	for _, item := range c.LineItems {
+		amount += item.Amount * item.Quantity
+	}
+	return amount
+}
+
+type LineItem struct {
+	Name     string
+	Amount   int64
+	Quantity int64
+}
+
+type Session struct {
+	ID  string
+	URL string
+}
+
+type Status string
+
+const (
+	StatusOpen     Status = "Open"
+	StatusPaid     Status = "Paid"
+	StatusExpired  Status = "Expired"
+	StatusRefunded Status = "Refunded"
+)
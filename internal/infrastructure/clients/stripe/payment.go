@@ -1,40 +1,153 @@
 package stripe
 
 import (
+	"context"
+	"encoding/json"
 	"maryan_api/config"
+	"maryan_api/internal/entity"
+	"maryan_api/internal/infrastructure/clients/payment"
+	"net/http"
 
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/checkout/session"
+	"github.com/stripe/stripe-go/v76/refund"
+	"github.com/stripe/stripe-go/v76/webhook"
 )
 
-func InitStripe() {
+type provider struct {
+	currency      string
+	successURL    string
+	cancelURL     string
+	webhookSecret string
+}
 
-	stripe.Key = config.StripSekretKey()
+func (p *provider) Name() string {
+	return "stripe"
 }
 
-func CreateStripeCheckoutSession(amount int64) (string, string, error) {
-	params := &stripe.CheckoutSessionParams{
-		Mode:       stripe.String("payment"),
-		SuccessURL: stripe.String("http://localhost:8080/connection/purchase-ticket/succeded/{CHECKOUT_SESSION_ID}"),
-		CancelURL:  stripe.String("http://localhost:8080/connection/purchase-ticket/failed/{CHECKOUT_SESSION_ID}"),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					Currency: stripe.String("eur"),
-					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-						Name: stripe.String("Ticket"),
-					},
-					UnitAmount: stripe.Int64(amount),
+func (p *provider) CreateSession(ctx context.Context, checkout payment.Checkout) (payment.Session, error) {
+	var lineItems = make([]*stripe.CheckoutSessionLineItemParams, len(checkout.LineItems))
+	for i, item := range checkout.LineItems {
+		lineItems[i] = &stripe.CheckoutSessionLineItemParams{
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency: stripe.String(p.currency),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(item.Name),
 				},
-				Quantity: stripe.Int64(1),
+				UnitAmount: stripe.Int64(item.Amount),
 			},
-		},
+			Quantity: stripe.Int64(item.Quantity),
+		}
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String("payment"),
+		ExpiresAt:  stripe.Int64(checkout.ExpiresAt.Unix()),
+		SuccessURL: stripe.String(p.successURL),
+		CancelURL:  stripe.String(p.cancelURL),
+		LineItems:  lineItems,
 	}
+	params.Context = ctx
 
 	s, err := session.New(params)
 	if err != nil {
-		return "", "", err
+		return payment.Session{}, err
+	}
+
+	return payment.Session{ID: s.ID, URL: s.URL}, nil
+}
+
+func (p *provider) FetchStatus(ctx context.Context, sessionID string) (payment.Status, error) {
+	params := &stripe.CheckoutSessionParams{}
+	params.Context = ctx
+	params.AddExpand("payment_intent.latest_charge")
+
+	s, err := session.Get(sessionID, params)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case s.PaymentIntent != nil && s.PaymentIntent.LatestCharge != nil && s.PaymentIntent.LatestCharge.Refunded:
+		return payment.StatusRefunded, nil
+	case s.Status == stripe.CheckoutSessionStatusComplete && s.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid:
+		return payment.StatusPaid, nil
+	case s.Status == stripe.CheckoutSessionStatusExpired:
+		return payment.StatusExpired, nil
+	default:
+		return payment.StatusOpen, nil
 	}
+}
 
-	return s.URL, s.ID, nil
+func (p *provider) Refund(ctx context.Context, paymentIntentID string, amount int64) (string, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentIntentID),
+		Amount:        stripe.Int64(amount),
+	}
+	params.Context = ctx
+
+	r, err := refund.New(params)
+	if err != nil {
+		return "", err
+	}
+
+	return r.ID, nil
+}
+
+func (p *provider) ParseWebhook(payload []byte, header http.Header) (entity.PaymentEvent, error) {
+	event, err := webhook.ConstructEventWithOptions(payload, header.Get("Stripe-Signature"), p.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return entity.PaymentEvent{}, err
+	}
+
+	var sessionID, paymentIntentID string
+	var amountTotal int64
+
+	switch event.Type {
+	case stripe.EventTypeCheckoutSessionCompleted, stripe.EventTypeCheckoutSessionExpired:
+		var checkoutSession stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &checkoutSession); err != nil {
+			return entity.PaymentEvent{}, err
+		}
+
+		sessionID = checkoutSession.ID
+		amountTotal = checkoutSession.AmountTotal
+		if checkoutSession.PaymentIntent != nil {
+			paymentIntentID = checkoutSession.PaymentIntent.ID
+		}
+	case stripe.EventTypeRefundCreated, stripe.EventTypeRefundUpdated, stripe.EventTypeChargeRefundUpdated:
+		// Refunds of some payment methods stay pending for a while, so the
+		// refund only counts once an update reports it succeeded.
+		var providerRefund stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &providerRefund); err != nil {
+			return entity.PaymentEvent{}, err
+		}
+
+		if providerRefund.Status != stripe.RefundStatusSucceeded {
+			break
+		}
+
+		if providerRefund.PaymentIntent != nil {
+			paymentIntentID = providerRefund.PaymentIntent.ID
+		}
+
+		return entity.NewRefundEvent(event.ID, paymentIntentID, providerRefund.ID, providerRefund.Amount), nil
+	}
+
+	paymentEvent := entity.NewPaymentEvent(event.ID, string(event.Type), sessionID, paymentIntentID)
+	paymentEvent.AmountTotal = amountTotal
+	return paymentEvent, nil
+}
+
+func NewProvider() payment.Provider {
+	stripe.Key = config.StripSekretKey()
+
+	return &provider{
+		currency:      config.PaymentCurrency(),
+		successURL:    config.FrontendURL() + "/profile/tickets?session_id={CHECKOUT_SESSION_ID}",
+		cancelURL:     config.FrontendURL() + "/connections",
+		webhookSecret: config.StripeWebhookSecretKey(),
+	}
 }
@@ -34,7 +34,7 @@ func (ads *adminMySQL) GetFreeDrivers(ctx context.Context, p dbutil.Pagination)
 }
 
 func (ads *adminMySQL) NewUser(ctx context.Context, user *entity.User) error {
-	return dbutil.PossibleCreateVaiolationError(ads.db.WithContext(ctx).Create(user), "user-email-uniqueness", "user-data")
+	return dbutil.PossibleCreateVaiolationError(fromContext(ctx, ads.db).Create(user), "user-email-uniqueness", "user-data")
 }
 
 func (ads *adminMySQL) GetAvailableUsers(ctx context.Context, dates []time.Time, pagination dbutil.Pagination) ([]entity.User, int, error, bool) {
@@ -47,11 +47,11 @@ func (ads *adminMySQL) GetAvailableUsers(ctx context.Context, dates []time.Time,
 
 func (ads *adminMySQL) IsDriverAvailable(ctx context.Context, dates []time.Time, driverID uuid.UUID) (bool, error) {
 	var available bool
-	return available, dbutil.PossibleDbError(ads.db.WithContext(ctx).Select("SELECT EXISTS(SELECT 1 FROM  employee_availabilities WHERE driverID = ? AND date NOT IN (?))", driverID, dates).Scan(&available))
+	return available, dbutil.PossibleDbError(fromContext(ctx, ads.db).Select("SELECT EXISTS(SELECT 1 FROM  employee_availabilities WHERE driverID = ? AND date NOT IN (?))", driverID, dates).Scan(&available))
 }
 
 func (ads *adminMySQL) SetEmployeeAvailability(ctx context.Context, schedule []entity.EmployeeAvailability) error {
-	return dbutil.PossibleRawsAffectedError(ads.db.WithContext(ctx).Create(schedule), "non-existing-employee")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ads.db).Create(schedule), "non-existing-employee")
 }
 
 // Declaration function
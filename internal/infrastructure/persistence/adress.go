@@ -27,7 +27,7 @@ type addressMySQL struct {
 
 func (ams *addressMySQL) Create(ctx context.Context, address *entity.Address) error {
 	return dbutil.PossibleCreateError(
-		ams.db.WithContext(ctx).Create(address),
+		fromContext(ctx, ams.db).Create(address),
 		"invalid-address-data",
 	)
 }
@@ -35,21 +35,21 @@ func (ams *addressMySQL) Create(ctx context.Context, address *entity.Address) er
 func (ams *addressMySQL) Update(ctx context.Context, address *entity.Address) error {
 	fmt.Println(*address)
 	return dbutil.PossibleRawsAffectedError(
-		ams.db.WithContext(ctx).Updates(*address),
+		fromContext(ctx, ams.db).Updates(*address),
 		"invalid-address-data",
 	)
 }
 
 func (ams *addressMySQL) ForseDelete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		ams.db.WithContext(ctx).Unscoped().Delete(&entity.Address{ID: id}),
+		fromContext(ctx, ams.db).Unscoped().Delete(&entity.Address{ID: id}),
 		"invalid-address-data",
 	)
 }
 
 func (ams *addressMySQL) SoftDelete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		ams.db.WithContext(ctx).Delete(&entity.Address{ID: id}),
+		fromContext(ctx, ams.db).Delete(&entity.Address{ID: id}),
 		"invalid-address-data",
 	)
 }
@@ -58,13 +58,13 @@ func (ams *addressMySQL) Status(ctx context.Context, id uuid.UUID) (bool, bool,
 	var exists bool
 	var usedByTicket bool
 
-	if err := ams.db.WithContext(ctx).
+	if err := fromContext(ctx, ams.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM addresses WHERE id = ?)", id).
 		Scan(&exists).Error; err != nil {
 		return false, false, rfc7807.DB(err.Error())
 	}
 
-	if err := ams.db.WithContext(ctx).
+	if err := fromContext(ctx, ams.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM tickets WHERE pick_up_address_id = ? OR drop_off_address_id = ?)", id, id).
 		Scan(&usedByTicket).Error; err != nil {
 		return false, false, rfc7807.DB(err.Error())
@@ -76,7 +76,7 @@ func (ams *addressMySQL) Status(ctx context.Context, id uuid.UUID) (bool, bool,
 func (ams *addressMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Address, error) {
 	address := entity.Address{ID: id}
 	return address, dbutil.PossibleFirstError(
-		ams.db.WithContext(ctx).First(&address),
+		fromContext(ctx, ams.db).First(&address),
 		"non-existing-address",
 	)
 }
@@ -24,7 +24,9 @@ type Bus interface {
 	GetAvailable(ctx context.Context, dates []time.Time, pagination dbutil.Pagination) ([]entity.Bus, int, error, bool)
 	SetSchedule(ctx context.Context, schedule []entity.BusAvailability) error
 	IsAvailable(ctx context.Context, id uuid.UUID, dates []time.Time) (bool, error)
+	IsBusy(ctx context.Context, id uuid.UUID, from, to time.Time) (bool, error)
 	GetAll(ctx context.Context) ([]entity.Bus, error)
+	SetAmenities(ctx context.Context, id uuid.UUID, amenities []entity.BusAmenity) error
 }
 
 type busMySQL struct {
@@ -32,13 +34,13 @@ type busMySQL struct {
 }
 
 func (bds *busMySQL) Create(ctx context.Context, bus *entity.Bus) error {
-	return dbutil.PossibleCreateError(bds.db.WithContext(ctx).Create(&bus), "invalid-bus-params")
+	return dbutil.PossibleCreateError(fromContext(ctx, bds.db).Create(&bus), "invalid-bus-params")
 }
 
 func (bds *busMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Bus, error) {
 	var bus = entity.Bus{ID: id}
 	return bus, dbutil.PossibleFirstError(
-		bds.db.WithContext(ctx).
+		fromContext(ctx, bds.db).
 			Preload("Structure.Positions").
 			Preload(clause.Associations).
 			First(&bus),
@@ -51,7 +53,7 @@ func (bds *busMySQL) GetBuses(ctx context.Context, p dbutil.Pagination) ([]entit
 
 func (bds *busMySQL) Delete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		bds.db.WithContext(ctx).
+		fromContext(ctx, bds.db).
 			Delete(&entity.Bus{ID: id}),
 		"non-existing-bus")
 }
@@ -59,7 +61,7 @@ func (bds *busMySQL) Delete(ctx context.Context, id uuid.UUID) error {
 func (bds *busMySQL) IsActive(ctx context.Context, id uuid.UUID) (bool, error) {
 	var isActive bool
 	return isActive, dbutil.PossibleRawsAffectedError(
-		bds.db.WithContext(ctx).
+		fromContext(ctx, bds.db).
 			Model(&entity.Bus{}).
 			Where("id = ?", id).
 			Select("is_active").
@@ -69,7 +71,7 @@ func (bds *busMySQL) IsActive(ctx context.Context, id uuid.UUID) (bool, error) {
 
 func (bds *busMySQL) MakeActive(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		bds.db.WithContext(ctx).
+		fromContext(ctx, bds.db).
 			Model(&entity.Bus{}).
 			Where("id = ?", id).
 			Update("is_active", true),
@@ -78,7 +80,7 @@ func (bds *busMySQL) MakeActive(ctx context.Context, id uuid.UUID) error {
 
 func (bds *busMySQL) MakeInactive(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		bds.db.WithContext(ctx).
+		fromContext(ctx, bds.db).
 			Model(&entity.Bus{}).
 			Where("id = ?", id).
 			Update("is_active", false),
@@ -87,7 +89,7 @@ func (bds *busMySQL) MakeInactive(ctx context.Context, id uuid.UUID) error {
 
 func (bds *busMySQL) RegistrationNumberExists(ctx context.Context, registrationNumber string) (bool, error) {
 	var exists bool
-	err := bds.db.WithContext(ctx).
+	err := fromContext(ctx, bds.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM buses WHERE registration_number = ?)", registrationNumber).
 		Scan(&exists).Error
 	return exists, err
@@ -95,7 +97,7 @@ func (bds *busMySQL) RegistrationNumberExists(ctx context.Context, registrationN
 
 func (bds *busMySQL) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
 	var exists bool
-	err := bds.db.WithContext(ctx).
+	err := fromContext(ctx, bds.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM buses WHERE id = ?)", id).
 		Scan(&exists).Error
 	return exists, err
@@ -110,21 +112,26 @@ func (bds *busMySQL) GetAvailable(ctx context.Context, dates []time.Time, pagina
 }
 
 func (dbs *busMySQL) ChangeLeadDriver(ctx context.Context, busID uuid.UUID, driverID uuid.UUID) error {
-	return dbutil.PossibleForeignKeyError(dbs.db.WithContext(ctx).Table("buses").Where("id = ?", busID).Update("lead_driver", driverID), "non-existing-bus", "non-existing-driver", "invalid-id")
+	return dbutil.PossibleForeignKeyError(fromContext(ctx, dbs.db).Table("buses").Where("id = ?", busID).Update("lead_driver", driverID), "non-existing-bus", "non-existing-driver", "invalid-id")
 }
 
 func (dbs *busMySQL) ChangeAssistantDriver(ctx context.Context, busID uuid.UUID, driverID uuid.UUID) error {
-	return dbutil.PossibleForeignKeyError(dbs.db.WithContext(ctx).Table("buses").Where("id = ?", busID).Update("assistant_driver", driverID), "non-existing-bus", "non-existing-driver", "invalid-id")
+	return dbutil.PossibleForeignKeyError(fromContext(ctx, dbs.db).Table("buses").Where("id = ?", busID).Update("assistant_driver", driverID), "non-existing-bus", "non-existing-driver", "invalid-id")
 }
 
 func (dbs *busMySQL) SetSchedule(ctx context.Context, schedule []entity.BusAvailability) error {
-	return dbutil.PossibleForeignKeyCreateError(dbs.db.WithContext(ctx).Create(schedule), "non-existing-bus", "bus-schedule-data")
+	return dbutil.PossibleForeignKeyCreateError(fromContext(ctx, dbs.db).Create(schedule), "non-existing-bus", "bus-schedule-data")
 }
 
 func (dbs *busMySQL) IsAvailable(ctx context.Context, id uuid.UUID, dates []time.Time) (bool, error) {
+	var days = make([]string, len(dates))
+	for i, date := range dates {
+		days[i] = date.Format("2006-01-02")
+	}
+
 	var available bool
 
-	err := dbs.db.WithContext(ctx).Raw("SELECT EXISTS (SELECT FROM buses AS b JOIN bus_availabilities AS ba ON ba.bus_id = b.id)  WHERE b.id = ? AND ba.date NOT IN (?) ", id, dates).Scan(&available).Error
+	err := fromContext(ctx, dbs.db).Raw("SELECT NOT EXISTS (SELECT 1 FROM bus_availabilities WHERE bus_id = ? AND DATE(date) IN (?))", id, days).Scan(&available).Error
 	if err != nil {
 		return false, rfc7807.DB(err.Error())
 	}
@@ -132,10 +139,30 @@ func (dbs *busMySQL) IsAvailable(ctx context.Context, id uuid.UUID, dates []time
 	return available, nil
 }
 
+// IsBusy reports whether the bus drives a connection between from and to.
+func (dbs *busMySQL) IsBusy(ctx context.Context, id uuid.UUID, from, to time.Time) (bool, error) {
+	var busy bool
+	return busy, dbutil.PossibleDbError(fromContext(ctx, dbs.db).
+		Raw("SELECT EXISTS (SELECT 1 FROM connections WHERE bus_id = ? AND departure_time < ? AND arrival_time > ?)", id, to, from).
+		Scan(&busy))
+}
+
 func (dbs *busMySQL) GetAll(ctx context.Context) ([]entity.Bus, error) {
 	var buses []entity.Bus
 
-	return buses, dbutil.PossibleRawsAffectedError(dbs.db.WithContext(ctx).Find(&buses), "no-buses-yet")
+	return buses, dbutil.PossibleRawsAffectedError(fromContext(ctx, dbs.db).Find(&buses), "no-buses-yet")
+}
+
+// SetAmenities replaces the amenities of the bus.
+func (dbs *busMySQL) SetAmenities(ctx context.Context, id uuid.UUID, amenities []entity.BusAmenity) error {
+	return fromContext(ctx, dbs.db).Transaction(func(tx *gorm.DB) error {
+		err := dbutil.PossibleDbError(tx.Where("bus_id = ?", id).Delete(&entity.BusAmenity{}))
+		if err != nil || len(amenities) == 0 {
+			return err
+		}
+
+		return dbutil.PossibleCreateError(tx.Create(&amenities), "invalid-bus-amenities")
+	})
 }
 
 // ------------------------Repos Initialization Functions--------------
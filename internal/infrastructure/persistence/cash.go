@@ -0,0 +1,68 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Cash interface {
+	Record(ctx context.Context, transactions []*entity.CashTransaction) error
+	GetTripLedger(ctx context.Context, tripID, employeeID uuid.UUID) ([]entity.CashTransaction, error)
+	Reconciliation(ctx context.Context, from, to time.Time) ([]entity.CashReconciliation, error)
+}
+
+type cashMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *cashMySQL) Record(ctx context.Context, transactions []*entity.CashTransaction) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(transactions), "cash-transaction-data")
+}
+
+// GetTripLedger returns the cash the employee collected and gave back on both
+// connections of the trip.
+func (ds *cashMySQL) GetTripLedger(ctx context.Context, tripID, employeeID uuid.UUID) ([]entity.CashTransaction, error) {
+	var trip = entity.Trip{ID: tripID}
+	err := dbutil.PossibleFirstError(fromContext(ctx, ds.db).Select("id", "outbound_connection_id", "return_connection_id").First(&trip), "non-existing-trip")
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []entity.CashTransaction
+	return transactions, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Where("employee_id = ? AND type IN (?) AND connection_id IN (?)",
+			employeeID,
+			[]any{entity.CashTransactionSale, entity.CashTransactionRefund},
+			[]uuid.UUID{trip.OutboundConnectionID, trip.ReturnConnectionID},
+		).
+		Order("created_at").
+		Find(&transactions))
+}
+
+// Reconciliation expects the cash of the sales less the cash given back.
+func (ds *cashMySQL) Reconciliation(ctx context.Context, from, to time.Time) ([]entity.CashReconciliation, error) {
+	var report []entity.CashReconciliation
+	return report, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Table("cash_transactions").
+		Select(
+			"cash_transactions.employee_id, users.first_name, users.last_name, "+
+				"SUM(CASE WHEN cash_transactions.type IN (?) THEN cash_transactions.amount ELSE 0 END) AS expected, "+
+				"SUM(CASE WHEN cash_transactions.type = ? THEN cash_transactions.amount ELSE 0 END) AS handed_in",
+			[]any{entity.CashTransactionSale, entity.CashTransactionRefund},
+			entity.CashTransactionHandover,
+		).
+		Joins("JOIN users ON users.id = cash_transactions.employee_id").
+		Where("cash_transactions.created_at >= ? AND cash_transactions.created_at < ?", from, to).
+		Group("cash_transactions.employee_id, users.first_name, users.last_name").
+		Order("users.last_name, users.first_name").
+		Scan(&report))
+}
+
+func NewCash(db *gorm.DB) Cash {
+	return &cashMySQL{db}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"maryan_api/internal/entity"
 	"maryan_api/pkg/dbutil"
+	"maryan_api/pkg/timezone"
+	"strings"
 	"time"
 
 	"github.com/d3code/uuid"
@@ -13,6 +15,8 @@ import (
 
 type Connection interface {
 	GetByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error)
+	LockSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
+	TakenSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error)
 	GetConnections(ctx context.Context, pagination dbutil.Pagination) ([]entity.Connection, int, error, bool)
 	ChangeDepartureTime(ctx context.Context, id uuid.UUID, departureTime time.Time) error
 	ChangeGoogleMapsURL(ctx context.Context, id uuid.UUID, url string) error
@@ -21,6 +25,10 @@ type Connection interface {
 	RegisterUpdate(ctx context.Context, update *entity.ConnectionUpdate) error
 	ChangeType(ctx context.Context, id uuid.UUID, connectionType entity.ConnectionType) error
 	FindConnections(ctx context.Context, request entity.FindConnectionsRequest) (FoundConnections, error)
+	FindLegs(ctx context.Context, cities []string, after, before time.Time) ([]entity.Connection, error)
+	DepartureDay(ctx context.Context, city string, date time.Time) (time.Time, time.Time, error)
+	SeatsLeft(ctx context.Context, id uuid.UUID, segment entity.Segment, seatType string) (int, error)
+	GetDriverConnections(ctx context.Context, driverID uuid.UUID, from, to time.Time) ([]entity.Connection, error)
 }
 
 type connectionMySQL struct {
@@ -54,23 +62,29 @@ func (ds *connectionMySQL) FindConnections(
 ) (FoundConnections, error) {
 	var foundConnections FoundConnections
 
+	// No station of the searched cities, no connections.
+	zones, err := ds.searchZones(ctx, []string{request.From, request.To}, request.Date)
+	if err != nil || len(zones) == 0 {
+		return FoundConnections{}, err
+	}
+
 	// 1. Base connections
-	if err := ds.findBaseConnections(ctx, request, &foundConnections); err != nil {
+	if err := ds.findBaseConnections(ctx, request, zones, &foundConnections); err != nil {
 		return FoundConnections{}, err
 	}
 
 	// 2. Tickets left
-	if err := ds.findTicketsLeft(ctx, &foundConnections); err != nil {
+	if err := ds.findTicketsLeft(ctx, request, &foundConnections); err != nil {
 		return FoundConnections{}, err
 	}
 
 	// 3. Left range
-	if err := ds.findLeftRange(ctx, request, &foundConnections); err != nil {
+	if err := ds.findLeftRange(ctx, request, zones, &foundConnections); err != nil {
 		return FoundConnections{}, err
 	}
 
 	// 4. Right range
-	if err := ds.findRightRange(ctx, request, &foundConnections); err != nil {
+	if err := ds.findRightRange(ctx, request, zones, &foundConnections); err != nil {
 		return FoundConnections{}, err
 	}
 
@@ -79,73 +93,268 @@ func (ds *connectionMySQL) FindConnections(
 
 // --- private helpers ---
 
+// The departure and the arrival at the searched cities in UTC and in the
+// time zones of their countries, the duration in minutes, the adult fare of
+// the segment and the local date the connection leaves the origin.
+const (
+	segmentDepartureTime      = "(connections.departure_time + INTERVAL origin.departure_offset MINUTE)"
+	segmentArrivalTime        = "(connections.departure_time + INTERVAL destination.arrival_offset MINUTE)"
+	segmentLocalDepartureTime = "(connections.departure_time + INTERVAL origin.departure_offset + origin_zone.utc_offset MINUTE)"
+	segmentLocalArrivalTime   = "(connections.departure_time + INTERVAL destination.arrival_offset + destination_zone.utc_offset MINUTE)"
+	segmentDuration           = "(destination.arrival_offset - origin.departure_offset)"
+	segmentPrice              = "(connections.price * (destination.distance - origin.distance) DIV routes.length)"
+	segmentDeparture          = "DATE" + segmentLocalDepartureTime
+)
+
+// searchZone is the time zone of a country with one of the searched cities,
+// as its UTC offset in minutes on the searched date and the bounds of that
+// date in UTC.
+type searchZone struct {
+	countryID        uuid.UUID
+	offset           int
+	dayStart, dayEnd time.Time
+}
+
+type searchZones []searchZone
+
+// searchZones returns the zones of the countries with a station in one of the
+// cities on the date.
+func (ds *connectionMySQL) searchZones(ctx context.Context, cities []string, date time.Time) (searchZones, error) {
+	var countries []struct {
+		ID   uuid.UUID
+		Name string
+	}
+
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Table("countries").
+		Distinct("countries.id", "countries.name").
+		Joins("JOIN route_stations ON route_stations.country_id = countries.id").
+		Where("route_stations.city IN ?", cities).
+		Scan(&countries))
+	if err != nil {
+		return nil, err
+	}
+
+	var zones = make(searchZones, len(countries))
+	for i, country := range countries {
+		location, ok := timezone.Location(country.Name)
+		if !ok {
+			location = time.UTC
+		}
+
+		dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+		_, offset := dayStart.Zone()
+		zones[i] = searchZone{
+			countryID: country.ID,
+			offset:    offset / 60,
+			dayStart:  dayStart.UTC(),
+			dayEnd:    dayStart.AddDate(0, 0, 1).UTC(),
+		}
+	}
+
+	return zones, nil
+}
+
+// table selects the zones as a derived table to join the stations with.
+func (zones searchZones) table() (string, []any) {
+	var selects = make([]string, len(zones))
+	var vars = make([]any, 0, 4*len(zones))
+	for i, zone := range zones {
+		selects[i] = "SELECT ? AS country_id, ? AS utc_offset, ? AS day_start, ? AS day_end"
+		vars = append(vars, zone.countryID, zone.offset, zone.dayStart, zone.dayEnd)
+	}
+
+	return "(" + strings.Join(selects, " UNION ALL ") + ")", vars
+}
+
+// DepartureDay returns the bounds in UTC of the date in the time zones of the
+// countries with a station in the city.
+func (ds *connectionMySQL) DepartureDay(ctx context.Context, city string, date time.Time) (time.Time, time.Time, error) {
+	zones, err := ds.searchZones(ctx, []string{city}, date)
+	if err != nil || len(zones) == 0 {
+		return date, date.AddDate(0, 0, 1), err
+	}
+
+	start, end := zones[0].dayStart, zones[0].dayEnd
+	for _, zone := range zones[1:] {
+		if zone.dayStart.Before(start) {
+			start = zone.dayStart
+		}
+
+		if zone.dayEnd.After(end) {
+			end = zone.dayEnd
+		}
+	}
+
+	return start, end, nil
+}
+
+// freeSeats counts the seats s of the bus nobody holds or has a ticket for on
+// the searched segment; seatCondition narrows the seats down further.
+func freeSeats(seatCondition string) string {
+	return `(
+		SELECT COUNT(s.id) FROM seats s
+		WHERE s.bus_id = connections.bus_id` + seatCondition + `
+		AND NOT EXISTS (
+			SELECT 1 FROM tickets t
+			WHERE t.connection_id = connections.id AND t.seat_id = s.id AND t.deleted_at IS NULL
+			AND t.from_station < destination.position AND t.to_station > origin.position
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM seat_holds sh
+			WHERE sh.connection_id = connections.id AND sh.seat_id = s.id AND sh.expires_at > ?
+			AND sh.from_station < destination.position AND sh.to_station > origin.position
+		)
+	)`
+}
+
+// onSegment joins the stations of the searched cities, so only the
+// connections whose routes call at the origin and later at the destination
+// are left, the length of their routes and the zones of the stations.
+func onSegment(tx *gorm.DB, request entity.FindConnectionsRequest, zones searchZones) *gorm.DB {
+	table, vars := zones.table()
+	return tx.
+		Joins("JOIN route_stations origin ON origin.route_id = connections.route_id AND origin.city = ?", request.From).
+		Joins("JOIN route_stations destination ON destination.route_id = connections.route_id AND destination.city = ? AND destination.position > origin.position", request.To).
+		Joins("JOIN (SELECT route_id, MAX(distance) AS length FROM route_stations GROUP BY route_id) routes ON routes.route_id = connections.route_id").
+		Joins("JOIN "+table+" origin_zone ON origin_zone.country_id = origin.country_id", vars...).
+		Joins("JOIN "+table+" destination_zone ON destination_zone.country_id = destination.country_id", vars...)
+}
+
+// filter applies the filters of the request to the connections joined by
+// onSegment.
+func filter(tx *gorm.DB, request entity.FindConnectionsRequest) *gorm.DB {
+	filters := request.Filters
+	for _, clock := range []struct {
+		expression string
+		window     *entity.ClockWindow
+	}{
+		{segmentLocalDepartureTime, filters.Departure},
+		{segmentLocalArrivalTime, filters.Arrival},
+	} {
+		expression, window := clock.expression, clock.window
+		if window == nil {
+			continue
+		}
+
+		if window.PastMidnight() {
+			tx = tx.Where("(TIME"+expression+" >= ? OR TIME"+expression+" <= ?)", window.From, window.To)
+		} else {
+			tx = tx.Where("TIME"+expression+" BETWEEN ? AND ?", window.From, window.To)
+		}
+	}
+
+	if filters.MaxPrice > 0 {
+		tx = tx.Where(segmentPrice+" <= ?", filters.MaxPrice)
+	}
+
+	if filters.MaxDuration > 0 {
+		tx = tx.Where(segmentDuration+" <= ?", filters.MaxDuration)
+	}
+
+	if len(filters.Amenities) > 0 {
+		tx = tx.Where(
+			"(SELECT COUNT(*) FROM bus_amenities ba WHERE ba.bus_id = connections.bus_id AND ba.amenity IN ?) = ?",
+			filters.Amenities, len(filters.Amenities),
+		)
+	}
+
+	if filters.SeatType != "" {
+		tx = tx.Where(freeSeats(" AND s.type = ?")+" >= ?", filters.SeatType, time.Now(), request.Passengers())
+	}
+
+	return tx
+}
+
 func (ds *connectionMySQL) findBaseConnections(
 	ctx context.Context,
 	request entity.FindConnectionsRequest,
+	zones searchZones,
 	foundConnections *FoundConnections,
 ) error {
-	return dbutil.PossibleDbError(
-		ds.db.WithContext(ctx).
-			Preload(clause.Associations).
-			Where(
-				"DATE(departure_time) = ? AND destination_country_id = ? AND departure_country_id = ?",
-				request.Date.Format("2006-01-02"),
-				request.To,
-				request.From,
-			).
-			Find(&foundConnections.Connections),
-	)
+	tx := filter(onSegment(fromContext(ctx, ds.db), request, zones), request).
+		Preload(clause.Associations).
+		Preload("Bus.Amenities").
+		Preload("Route.Stations").
+		Preload("Route.Stations.Country").
+		Where(segmentDepartureTime + " >= origin_zone.day_start AND " + segmentDepartureTime + " < origin_zone.day_end")
+
+	// The connections departing first come first among the equal ones.
+	switch request.Sort {
+	case entity.ConnectionsSortPrice:
+		tx = tx.Order(segmentPrice + ", " + segmentDepartureTime)
+	case entity.ConnectionsSortDuration:
+		tx = tx.Order(segmentDuration + ", " + segmentDepartureTime)
+	case entity.ConnectionsSortSeatsLeft:
+		tx = tx.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  freeSeats("") + " DESC, " + segmentDepartureTime,
+			Vars: []any{time.Now()},
+		}})
+	default:
+		tx = tx.Order(segmentDepartureTime)
+	}
+
+	return dbutil.PossibleDbError(tx.Find(&foundConnections.Connections))
 }
 
 func (ds *connectionMySQL) findTicketsLeft(
 	ctx context.Context,
+	request entity.FindConnectionsRequest,
 	foundConnections *FoundConnections,
 ) error {
+	if len(foundConnections.Connections) == 0 {
+		return nil
+	}
+
+	// A seat is free on the segment when none of the tickets and holds of
+	// the connection overlap it.
 	return dbutil.PossibleDbError(
-		ds.db.WithContext(ctx).Raw(`
-			SELECT 
+		fromContext(ctx, ds.db).Raw(`
+			SELECT
 				c.id AS id,
-				COALESCE((
+				(
 					SELECT COUNT(s.id)
-					FROM buses b
-					JOIN seats s ON b.id = s.bus_id
-					WHERE b.id = c.bus_id
-				), 0)
-				-
-				COALESCE((
-					SELECT COUNT(st.id) / 2
-					FROM stops st
-					WHERE st.connection_id = c.id
-					GROUP BY st.connection_id
-				), 0) AS tickets_left
+					FROM seats s
+					WHERE s.bus_id = c.bus_id
+					AND NOT EXISTS (
+						SELECT 1 FROM tickets t
+						WHERE t.connection_id = c.id AND t.seat_id = s.id AND t.deleted_at IS NULL
+						AND t.from_station < destination.position AND t.to_station > origin.position
+					)
+					AND NOT EXISTS (
+						SELECT 1 FROM seat_holds sh
+						WHERE sh.connection_id = c.id AND sh.seat_id = s.id AND sh.expires_at > ?
+						AND sh.from_station < destination.position AND sh.to_station > origin.position
+					)
+				) AS tickets_left
 			FROM connections c
+			JOIN route_stations origin ON origin.route_id = c.route_id AND origin.city = ?
+			JOIN route_stations destination ON destination.route_id = c.route_id AND destination.city = ?
 			WHERE c.id IN ?
-		`, foundConnections.ConnectionsIDs()).
+		`, time.Now(), request.From, request.To, foundConnections.ConnectionsIDs()).
 			Scan(&foundConnections.TicketsLeft),
 	)
 }
 
+// The neighbouring dates are told apart by the offsets of the zones on the
+// searched date, so a clock change within the range can move a departure
+// around midnight to the next or the previous date.
 func (ds *connectionMySQL) findLeftRange(
 	ctx context.Context,
 	request entity.FindConnectionsRequest,
+	zones searchZones,
 	foundConnections *FoundConnections,
 ) error {
 	return dbutil.PossibleDbError(
-		ds.db.WithContext(ctx).
-			Table("connections").
+		filter(onSegment(fromContext(ctx, ds.db).Table("connections"), request, zones), request).
 			Select(
-				"DATE(departure_time) AS date",
-				"COUNT(id) AS number",
-				"MIN(price) AS min_price",
+				segmentDeparture+" AS date",
+				"COUNT(connections.id) AS number",
+				"MIN("+segmentPrice+") AS min_price",
 			).
-			Where(
-				"DATE(departure_time) < DATE(?) AND destination_country_id = ? AND departure_country_id = ?",
-				request.Date,
-				request.To,
-				request.From,
-			).
-			Group("DATE(departure_time)").
-			Order("DATE(departure_time) DESC").
+			Where(segmentDeparture+" < DATE(?)", request.Date).
+			Group(segmentDeparture).
+			Order(segmentDeparture + " DESC").
 			Limit(request.Range).
 			Scan(&foundConnections.LeftRange),
 	)
@@ -154,39 +363,110 @@ func (ds *connectionMySQL) findLeftRange(
 func (ds *connectionMySQL) findRightRange(
 	ctx context.Context,
 	request entity.FindConnectionsRequest,
+	zones searchZones,
 	foundConnections *FoundConnections,
 ) error {
 	return dbutil.PossibleDbError(
-		ds.db.WithContext(ctx).
-			Table("connections").
+		filter(onSegment(fromContext(ctx, ds.db).Table("connections"), request, zones), request).
 			Select(
-				"DATE(departure_time) AS date",
-				"COUNT(id) AS number",
-				"MIN(price) AS min_price",
-			).
-			Where(
-				"DATE(departure_time) > ? AND destination_country_id = ? AND departure_country_id = ?",
-				request.Date,
-				request.To,
-				request.From,
+				segmentDeparture+" AS date",
+				"COUNT(connections.id) AS number",
+				"MIN("+segmentPrice+") AS min_price",
 			).
-			Group("DATE(departure_time)").
-			Order("DATE(departure_time) ASC").
+			Where(segmentDeparture+" > DATE(?)", request.Date).
+			Group(segmentDeparture).
+			Order(segmentDeparture + " ASC").
 			Limit(request.Range).
 			Scan(&foundConnections.RightRange),
 	)
 }
 
+// FindLegs returns the connections leaving one of the cities between after
+// and before, with their routes, so the itinerary search can chain them.
+func (ds *connectionMySQL) FindLegs(ctx context.Context, cities []string, after, before time.Time) ([]entity.Connection, error) {
+	var connections []entity.Connection
+	return connections, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Preload(clause.Associations).
+		Preload("Bus.Amenities").
+		Preload("Route.Stations").
+		Preload("Route.Stations.Country").
+		Where(`EXISTS (
+			SELECT 1 FROM route_stations origin
+			WHERE origin.route_id = connections.route_id AND origin.city IN ?
+			AND connections.departure_time + INTERVAL origin.departure_offset MINUTE BETWEEN ? AND ?
+		)`, cities, after, before).
+		Find(&connections))
+}
+
+// SeatsLeft counts the seats of the bus nobody holds or has a ticket for on
+// the segment; a seat type other than an empty one counts only the seats of
+// that type.
+func (ds *connectionMySQL) SeatsLeft(ctx context.Context, id uuid.UUID, segment entity.Segment, seatType string) (int, error) {
+	var seatsLeft int
+	return seatsLeft, dbutil.PossibleDbError(fromContext(ctx, ds.db).Raw(`
+		SELECT COUNT(s.id)
+		FROM seats s
+		JOIN connections c ON c.bus_id = s.bus_id
+		WHERE c.id = ? AND (? = '' OR s.type = ?) AND s.id NOT IN (
+			SELECT seat_id FROM tickets
+			WHERE connection_id = ? AND deleted_at IS NULL AND from_station < ? AND to_station > ?
+			UNION
+			SELECT seat_id FROM seat_holds
+			WHERE connection_id = ? AND expires_at > ? AND from_station < ? AND to_station > ?
+		)
+	`, id, seatType, seatType, id, segment.To, segment.From, id, time.Now(), segment.To, segment.From).Scan(&seatsLeft))
+}
+
 func (ds *connectionMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Connection, []uuid.UUID, error) {
 	var connection = entity.Connection{ID: id}
-	err := dbutil.PossibleFirstError(dbutil.Preload(ds.db, entity.PreloadConnection()...).WithContext(ctx).First(&connection), "non-existing-connection")
+	err := dbutil.PossibleFirstError(dbutil.Preload(fromContext(ctx, ds.db), entity.PreloadConnection()...).First(&connection), "non-existing-connection")
 	if err != nil {
 		return entity.Connection{}, nil, err
 	}
 
-	var takenSeatsIDs []uuid.UUID
+	takenSeatsIDs, err := takenSeats(fromContext(ctx, ds.db), id, connection.FullSegment())
+	return connection, takenSeatsIDs, err
+}
+
+// LockSeats locks the connection row until the end of the surrounding
+// transaction, so concurrent purchases of the same connection see each
+// other's seats, and returns the seats taken so far on the segment.
+func (ds *connectionMySQL) LockSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return lockSeats(fromContext(ctx, ds.db), id, segment)
+}
+
+func (ds *connectionMySQL) TakenSeats(ctx context.Context, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	return takenSeats(fromContext(ctx, ds.db), id, segment)
+}
 
-	return connection, takenSeatsIDs, dbutil.PossibleDbError(ds.db.WithContext(ctx).Table("tickets").Where("connection_id = ?", id).Select("seat_id").Scan(&takenSeatsIDs))
+// lockSeats locks the connection, so no one else takes its seats until the
+// transaction ends, and returns the seats taken on the segment.
+func lockSeats(tx *gorm.DB, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	var lockedID uuid.UUID
+	err := dbutil.PossibleRawsAffectedError(tx.
+		Model(&entity.Connection{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Select("id").
+		Scan(&lockedID), "non-existing-connection")
+	if err != nil {
+		return nil, err
+	}
+
+	return takenSeats(tx, id, segment)
+}
+
+// takenSeats returns the seats of the tickets and holds overlapping the
+// segment; a seat left by one passenger can be sold on from there.
+func takenSeats(tx *gorm.DB, id uuid.UUID, segment entity.Segment) ([]uuid.UUID, error) {
+	var takenSeatsIDs []uuid.UUID
+	return takenSeatsIDs, dbutil.PossibleDbError(tx.Raw(`
+		SELECT seat_id FROM tickets
+		WHERE connection_id = ? AND deleted_at IS NULL AND from_station < ? AND to_station > ?
+		UNION
+		SELECT seat_id FROM seat_holds
+		WHERE connection_id = ? AND expires_at > ? AND from_station < ? AND to_station > ?
+	`, id, segment.To, segment.From, id, time.Now(), segment.To, segment.From).Scan(&takenSeatsIDs))
 }
 
 func (ds *connectionMySQL) GetConnections(ctx context.Context, pagination dbutil.Pagination) ([]entity.Connection, int, error, bool) {
@@ -202,18 +482,30 @@ func (ds *connectionMySQL) GetConnections(ctx context.Context, pagination dbutil
 		entity.PreloadConnection()...)
 }
 
+func (ds *connectionMySQL) GetDriverConnections(ctx context.Context, driverID uuid.UUID, from, to time.Time) ([]entity.Connection, error) {
+	var connections []entity.Connection
+	return connections, dbutil.PossibleDbError(
+		dbutil.Preload(fromContext(ctx, ds.db), entity.PreloadDriverConnection()...).
+			Joins("JOIN buses ON buses.id = connections.bus_id").
+			Where("buses.lead_driver_id = ? OR buses.assistant_driver_id = ?", driverID, driverID).
+			Where("connections.departure_time BETWEEN ? AND ?", from, to).
+			Order("connections.departure_time").
+			Find(&connections),
+	)
+}
+
 func (ds *connectionMySQL) ChangeDepartureTime(ctx context.Context, id uuid.UUID, departureTime time.Time) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Where("id = ?", id).Update("departure_time", departureTime), "non-existing-connection")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Where("id = ?", id).Update("departure_time", departureTime), "non-existing-connection")
 }
 
 func (ds *connectionMySQL) ChangeGoogleMapsURL(ctx context.Context, id uuid.UUID, url string) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Where("id = ?", id).Update("google_maps_url", url), "non-existing-connection")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Where("id = ?", id).Update("google_maps_url", url), "non-existing-connection")
 }
 
 func (ds *connectionMySQL) GetCurrentBusID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
 	var currentBusID uuid.UUID
 	return currentBusID, dbutil.PossibleRawsAffectedError(
-		ds.db.WithContext(ctx).
+		fromContext(ctx, ds.db).
 			Model(&entity.Connection{}).
 			Where("id = ?", id).Select("bus_id").
 			Scan(&currentBusID),
@@ -223,7 +515,7 @@ func (ds *connectionMySQL) GetCurrentBusID(ctx context.Context, id uuid.UUID) (u
 
 // func (ds *connectionMySQL) ChangeBus(ctx context.Context, id, currentBusID, replasingBusID uuid.UUID) error {
 // 	return dbutil.PossibleForeignKeyError(
-// 		ds.db.WithContext(ctx).
+// 		fromContext(ctx, ds.db).
 // 			Where("id = ?", id).
 // 			Updates(&entity.Connection{BusID: replasingBusID, ReplacedBusID: currentBusID}),
 // 		"non-existing-connection",
@@ -232,12 +524,21 @@ func (ds *connectionMySQL) GetCurrentBusID(ctx context.Context, id uuid.UUID) (u
 // 	)
 // }
 
+// RegisterUpdate stores the update; a finished connection also completes the
+// tickets of the passengers who boarded it and closes those of the no-shows.
 func (ds *connectionMySQL) RegisterUpdate(ctx context.Context, update *entity.ConnectionUpdate) error {
-	return dbutil.PossibleForeignKeyCreateError(ds.db.WithContext(ctx).Create(update), "non-existing-connection", "connection-update-data")
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		err := dbutil.PossibleForeignKeyCreateError(tx.Create(update), "non-existing-connection", "connection-update-data")
+		if err != nil || update.Status != entity.FinishedConnectionStatus {
+			return err
+		}
+
+		return completeTickets(tx, update.ConnectionID)
+	})
 }
 
 func (ds *connectionMySQL) ChangeType(ctx context.Context, id uuid.UUID, connectionType entity.ConnectionType) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Where("id = ?", id).Update("type", connectionType.Val), "non-existing-connection")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Where("id = ?", id).Update("type", connectionType.Val), "non-existing-connection")
 }
 
 func NewConnection(db *gorm.DB) Connection {
@@ -248,6 +549,8 @@ type Stop interface {
 	Create(ctx context.Context, stop *entity.Stop) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	RegisterUpdate(ctx context.Context, update *entity.StopUpdate) error
+	LockByID(ctx context.Context, id uuid.UUID) (entity.Stop, error)
+	LockPickUp(ctx context.Context, ticketID uuid.UUID) (entity.Stop, error)
 }
 
 type stopMySQL struct {
@@ -255,15 +558,35 @@ type stopMySQL struct {
 }
 
 func (ds *stopMySQL) Create(ctx context.Context, stop *entity.Stop) error {
-	return dbutil.PossibleCreateError(ds.db.WithContext(ctx).Create(stop), "stop-data")
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(stop), "stop-data")
 }
 
 func (ds *stopMySQL) Delete(ctx context.Context, id uuid.UUID) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Delete(&entity.Stop{ID: id}), "non-existing-stop")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Delete(&entity.Stop{ID: id}), "non-existing-stop")
 }
 
 func (ds *stopMySQL) RegisterUpdate(ctx context.Context, update *entity.StopUpdate) error {
-	return dbutil.PossibleForeignKeyCreateError(ds.db.WithContext(ctx).Create(update), "non-existing-connection", "stop-update-data")
+	return dbutil.PossibleForeignKeyCreateError(fromContext(ctx, ds.db).Create(update), "non-existing-connection", "stop-update-data")
+}
+
+// LockByID loads the stop with its updates and locks it until the end of the
+// surrounding transaction, so concurrent scans see each other's updates.
+func (ds *stopMySQL) LockByID(ctx context.Context, id uuid.UUID) (entity.Stop, error) {
+	var stop entity.Stop
+	return stop, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Updates").
+		Where("id = ?", id).
+		First(&stop), "non-existing-stop")
+}
+
+func (ds *stopMySQL) LockPickUp(ctx context.Context, ticketID uuid.UUID) (entity.Stop, error) {
+	var stop entity.Stop
+	return stop, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Updates").
+		Where("ticket_id = ? AND type = ?", ticketID, entity.PickUpStopType).
+		First(&stop), "non-existing-stop")
 }
 
 func NewStop(db *gorm.DB) Stop {
@@ -2,27 +2,104 @@ package dataStore
 
 import (
 	"context"
+	"log"
+	"time"
 
+	"maryan_api/config"
 	"maryan_api/internal/entity"
 	"maryan_api/pkg/dbutil"
+	rfc7807 "maryan_api/pkg/problem"
+	"maryan_api/pkg/timezone"
 
+	"github.com/d3code/uuid"
 	"gorm.io/gorm"
 )
 
 type Country interface {
+	Create(ctx context.Context, country *entity.Country) error
+	Update(ctx context.Context, country *entity.Country) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Country, error)
 	GetAll(ctx context.Context) ([]entity.Country, error)
+	NameTaken(ctx context.Context, name string, id uuid.UUID) (bool, error)
+	Reload(ctx context.Context) error
 }
 
 type countryMySQL struct {
 	db *gorm.DB
 }
 
+func (cds *countryMySQL) Create(ctx context.Context, country *entity.Country) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, cds.db).Create(country), "country-data")
+}
+
+func (cds *countryMySQL) Update(ctx context.Context, country *entity.Country) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, cds.db).
+		Model(&entity.Country{ID: country.ID}).
+		Select("name", "timezone").
+		Updates(country), "non-existing-country")
+}
+
+func (cds *countryMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Country, error) {
+	var country = entity.Country{ID: id}
+	return country, dbutil.PossibleFirstError(fromContext(ctx, cds.db).First(&country), "non-existing-country")
+}
+
 func (cds *countryMySQL) GetAll(ctx context.Context) ([]entity.Country, error) {
 	var countries []entity.Country
 
 	return countries, dbutil.PossibleRawsAffectedError(cds.db.Find(&countries), "no-countries-yet")
 }
 
+// NameTaken reports whether another country than the one with the id goes by
+// the name.
+func (cds *countryMySQL) NameTaken(ctx context.Context, name string, id uuid.UUID) (bool, error) {
+	var count int64
+	err := dbutil.PossibleDbError(fromContext(ctx, cds.db).
+		Model(&entity.Country{}).
+		Where("name = ? AND id <> ?", name, id).
+		Count(&count))
+
+	return count > 0, err
+}
+
+// Reload refreshes the countries and the time zones cached by config and
+// timezone, so the changes of the countries are seen without a restart.
+func (cds *countryMySQL) Reload(ctx context.Context) error {
+	db := cds.db.WithContext(ctx)
+
+	err := config.ReloadCountries(db)
+	if err != nil {
+		return rfc7807.DB(err.Error())
+	}
+
+	err = timezone.Reload(db)
+	if err != nil {
+		return rfc7807.DB(err.Error())
+	}
+
+	return nil
+}
+
+// ReloadCountries reloads the countries every interval until ctx is done, so
+// every API instance picks up the changes made through another one.
+func ReloadCountries(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	store := NewCountry(db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := store.Reload(ctx)
+			if err != nil {
+				log.Printf("Failed to reload the countries: %v", err)
+			}
+		}
+	}
+}
+
 func NewCountry(db *gorm.DB) Country {
 	return &countryMySQL{db}
 }
@@ -37,43 +37,43 @@ type customerMySQL struct {
 }
 
 func (cds *customerMySQL) Create(ctx context.Context, u *entity.User) error {
-	return dbutil.PossibleCreateError(cds.db.WithContext(ctx).Create(u), "user-credentials-validation")
+	return dbutil.PossibleCreateError(fromContext(ctx, cds.db).Create(u), "user-credentials-validation")
 }
 
 func (cds *customerMySQL) Delete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		cds.db.WithContext(ctx).Delete(&entity.User{ID: id}),
+		fromContext(ctx, cds.db).Delete(&entity.User{ID: id}),
 		"non-existing-user",
 	)
 }
 
 func (cds *customerMySQL) StartEmailVerification(ctx context.Context, session objectvalue.EmailVerificationSession) (uuid.UUID, error) {
-	return session.ID, dbutil.PossibleCreateError(cds.db.WithContext(ctx).Create(session), "invalid-email-verification-session-data")
+	return session.ID, dbutil.PossibleCreateError(fromContext(ctx, cds.db).Create(session), "invalid-email-verification-session-data")
 }
 
 func (cds *customerMySQL) EmailVerificationSession(ctx context.Context, sessionID uuid.UUID) (objectvalue.EmailVerificationSession, error) {
 	var session = objectvalue.EmailVerificationSession{ID: sessionID}
-	return session, dbutil.PossibleFirstError(cds.db.WithContext(ctx).First(&session), "non-existing-email-verification-session")
+	return session, dbutil.PossibleFirstError(fromContext(ctx, cds.db).First(&session), "non-existing-email-verification-session")
 }
 
 func (cds *customerMySQL) CompleteEmailVerification(ctx context.Context, sessionID uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		cds.db.WithContext(ctx).Delete(&objectvalue.EmailVerificationSession{ID: sessionID}),
+		fromContext(ctx, cds.db).Delete(&objectvalue.EmailVerificationSession{ID: sessionID}),
 		"non-existing-email-verification-session")
 }
 
 func (cds *customerMySQL) StartNumberVerification(ctx context.Context, session objectvalue.NumberVerificationSession) (uuid.UUID, error) {
-	return session.ID, dbutil.PossibleCreateError(cds.db.WithContext(ctx).Create(session), "invalid-number-verification-session-data")
+	return session.ID, dbutil.PossibleCreateError(fromContext(ctx, cds.db).Create(session), "invalid-number-verification-session-data")
 }
 
 func (cds *customerMySQL) NumberVerificationSession(ctx context.Context, sessionID uuid.UUID) (objectvalue.NumberVerificationSession, error) {
 	var session = objectvalue.NumberVerificationSession{ID: sessionID}
-	return session, dbutil.PossibleFirstError(cds.db.WithContext(ctx).First(&session), "non-existing-number-verification-session")
+	return session, dbutil.PossibleFirstError(fromContext(ctx, cds.db).First(&session), "non-existing-number-verification-session")
 }
 
 func (cds *customerMySQL) CompleteNumberVerification(ctx context.Context, sessionID uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		cds.db.WithContext(ctx).Delete(&objectvalue.NumberVerificationSession{ID: sessionID}),
+		fromContext(ctx, cds.db).Delete(&objectvalue.NumberVerificationSession{ID: sessionID}),
 		"non-existing-number-verification-session")
 }
 
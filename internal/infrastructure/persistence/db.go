@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"maryan_api/config"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"gorm.io/driver/mysql"
@@ -13,8 +14,10 @@ import (
 func Init() *gorm.DB {
 
 	connection := config.DB()
+	// The times are read and written in UTC, also by the functions of the
+	// database, and shown in the time zones of the countries.
 	db, err := gorm.Open(mysql.New(mysql.Config{
-		DSN: fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		DSN: fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27",
 			connection.User,
 			connection.Password,
 			connection.Host,
@@ -23,7 +26,10 @@ func Init() *gorm.DB {
 		),
 
 		DefaultStringSize: 256,
-	}), &gorm.Config{})
+	}), &gorm.Config{
+		TranslateError: true,
+		NowFunc:        func() time.Time { return time.Now().UTC() },
+	})
 
 	if err != nil {
 		panic("Could not connect to the database")
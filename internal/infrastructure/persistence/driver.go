@@ -1,15 +1,36 @@
 package dataStore
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
 
 type Driver interface {
 	User
+	IsBusy(ctx context.Context, id, busID uuid.UUID, from, to time.Time) (bool, error)
 }
 
 type driverMySQL struct {
 	userMySQL
 }
 
+// IsBusy reports whether the driver drives a connection between from and to
+// on another bus than busID.
+func (ds *driverMySQL) IsBusy(ctx context.Context, id, busID uuid.UUID, from, to time.Time) (bool, error) {
+	var busy bool
+	return busy, dbutil.PossibleDbError(fromContext(ctx, ds.db).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM connections c
+			JOIN buses b ON b.id = c.bus_id
+			WHERE (b.lead_driver_id = ? OR b.assistant_driver_id = ?) AND b.id <> ?
+			AND c.departure_time < ? AND c.arrival_time > ?
+		)`, id, id, busID, to, from).Scan(&busy))
+}
+
 func NewDriver(db *gorm.DB) Driver {
 	return &driverMySQL{userMySQL{db}}
 }
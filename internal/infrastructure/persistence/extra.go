@@ -0,0 +1,103 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Extra interface {
+	Create(ctx context.Context, extra *entity.Extra) error
+	Update(ctx context.Context, extra *entity.Extra) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Extra, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.Extra, error)
+	GetExtras(ctx context.Context, pagination dbutil.Pagination) ([]entity.Extra, int, error, bool)
+	Booked(ctx context.Context, connectionID uuid.UUID, now time.Time) ([]entity.BookedExtra, error)
+	Manifest(ctx context.Context, connectionID uuid.UUID) ([]entity.LuggageManifestItem, error)
+}
+
+type extraMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *extraMySQL) Create(ctx context.Context, extra *entity.Extra) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(extra), "extra-data")
+}
+
+func (ds *extraMySQL) Update(ctx context.Context, extra *entity.Extra) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.Extra{ID: extra.ID}).
+		Select("name", "type", "price", "weight", "max_per_connection").
+		Updates(extra), "non-existing-extra")
+}
+
+func (ds *extraMySQL) Delete(ctx context.Context, id uuid.UUID) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Delete(&entity.Extra{ID: id}), "non-existing-extra")
+}
+
+func (ds *extraMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Extra, error) {
+	var extra = entity.Extra{ID: id}
+	return extra, dbutil.PossibleFirstError(fromContext(ctx, ds.db).First(&extra), "non-existing-extra")
+}
+
+func (ds *extraMySQL) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.Extra, error) {
+	var extras []entity.Extra
+	return extras, dbutil.PossibleDbError(fromContext(ctx, ds.db).Where("id IN (?)", ids).Find(&extras))
+}
+
+func (ds *extraMySQL) GetExtras(ctx context.Context, pagination dbutil.Pagination) ([]entity.Extra, int, error, bool) {
+	return dbutil.Paginate[entity.Extra](ctx, ds.db, pagination)
+}
+
+// Booked sums up the extras of the connection's valid tickets and of the
+// checkouts still holding a seat on it.
+func (ds *extraMySQL) Booked(ctx context.Context, connectionID uuid.UUID, now time.Time) ([]entity.BookedExtra, error) {
+	var booked []entity.BookedExtra
+	return booked, dbutil.PossibleDbError(fromContext(ctx, ds.db).Raw(`
+		SELECT extra_id, SUM(quantity) AS quantity, SUM(quantity * weight) AS weight
+		FROM (
+			SELECT te.extra_id, te.quantity, te.weight
+			FROM ticket_extras te
+			JOIN tickets t ON t.id = te.ticket_id
+			WHERE t.connection_id = ? AND t.deleted_at IS NULL
+			UNION ALL
+			SELECT she.extra_id, she.quantity, she.weight
+			FROM seat_hold_extras she
+			JOIN seat_holds sh ON sh.id = she.seat_hold_id
+			WHERE sh.connection_id = ? AND sh.expires_at > ?
+		) booked
+		GROUP BY extra_id
+	`, connectionID, connectionID, now).Scan(&booked))
+}
+
+func (ds *extraMySQL) Manifest(ctx context.Context, connectionID uuid.UUID) ([]entity.LuggageManifestItem, error) {
+	var items []entity.LuggageManifestItem
+	return items, dbutil.PossibleDbError(fromContext(ctx, ds.db).Raw(`
+		SELECT
+			t.id AS ticket_id,
+			s.number AS seat_number,
+			p.first_name,
+			p.last_name,
+			CONCAT(a.city, ', ', a.street, ' ', a.house_number) AS pick_up,
+			te.name,
+			te.type,
+			te.quantity,
+			te.quantity * te.weight AS weight
+		FROM ticket_extras te
+		JOIN tickets t ON t.id = te.ticket_id
+		JOIN seats s ON s.id = t.seat_id
+		JOIN passengers p ON p.id = t.passenger_id
+		JOIN addresses a ON a.id = t.pick_up_adress_id
+		WHERE t.connection_id = ? AND t.deleted_at IS NULL
+		ORDER BY s.number, te.name
+	`, connectionID).Scan(&items))
+}
+
+func NewExtra(db *gorm.DB) Extra {
+	return &extraMySQL{db}
+}
@@ -0,0 +1,73 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobRun interface {
+	Record(ctx context.Context, run *entity.JobRun) error
+	// LastSucceeded returns when the last successful run of the job started,
+	// the zero time if there has been none.
+	LastSucceeded(ctx context.Context, job string) (time.Time, error)
+	// TryLock takes the named MySQL lock without waiting, so only one API
+	// instance runs a job at a time; release has to be called once the job ends.
+	TryLock(ctx context.Context, name string) (release func(), locked bool, err error)
+}
+
+type jobRunMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *jobRunMySQL) Record(ctx context.Context, run *entity.JobRun) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(run), "job-run-data")
+}
+
+func (ds *jobRunMySQL) LastSucceeded(ctx context.Context, job string) (time.Time, error) {
+	var runs []entity.JobRun
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Select("started_at").
+		Where("job = ? AND failed = ?", job, false).
+		Order("started_at DESC").
+		Limit(1).
+		Find(&runs))
+	if err != nil || len(runs) == 0 {
+		return time.Time{}, err
+	}
+
+	return runs[0].StartedAt, nil
+}
+
+func (ds *jobRunMySQL) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	sqlDB, err := ds.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// GET_LOCK belongs to the session, so the lock and its release have to
+	// share one connection of the pool.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var locked int
+	err = conn.QueryRowContext(ctx, "SELECT COALESCE(GET_LOCK(?, 0), 0)", name).Scan(&locked)
+	if err != nil || locked != 1 {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		conn.Close()
+	}, true, nil
+}
+
+func NewJobRun(db *gorm.DB) JobRun {
+	return &jobRunMySQL{db}
+}
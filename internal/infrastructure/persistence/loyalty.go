@@ -0,0 +1,224 @@
+package dataStore
+
+import (
+	"context"
+	"fmt"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Loyalty interface {
+	Record(ctx context.Context, transaction *entity.LoyaltyTransaction) error
+	Balance(ctx context.Context, userID uuid.UUID) (int, error)
+	LockBalance(ctx context.Context, userID uuid.UUID) (int, error)
+	CountTrips(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	GetHistory(ctx context.Context, pagination dbutil.Pagination) ([]entity.LoyaltyTransaction, int, error, bool)
+}
+
+type loyaltyMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *loyaltyMySQL) Record(ctx context.Context, transaction *entity.LoyaltyTransaction) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(transaction), "loyalty-transaction-data")
+}
+
+func (ds *loyaltyMySQL) Balance(ctx context.Context, userID uuid.UUID) (int, error) {
+	return loyaltyBalance(fromContext(ctx, ds.db), userID)
+}
+
+// LockBalance locks the user until the end of the surrounding transaction, so
+// concurrent purchases cannot spend the same points twice.
+func (ds *loyaltyMySQL) LockBalance(ctx context.Context, userID uuid.UUID) (int, error) {
+	tx := fromContext(ctx, ds.db)
+
+	var lockedID uuid.UUID
+	err := dbutil.PossibleRawsAffectedError(tx.
+		Model(&entity.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", userID).
+		Select("id").
+		Scan(&lockedID), "non-existing-user")
+	if err != nil {
+		return 0, err
+	}
+
+	return loyaltyBalance(tx, userID)
+}
+
+func (ds *loyaltyMySQL) CountTrips(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	return countTrips(fromContext(ctx, ds.db), userID, since)
+}
+
+func (ds *loyaltyMySQL) GetHistory(ctx context.Context, pagination dbutil.Pagination) ([]entity.LoyaltyTransaction, int, error, bool) {
+	return dbutil.Paginate[entity.LoyaltyTransaction](ctx, ds.db, pagination)
+}
+
+func loyaltyBalance(tx *gorm.DB, userID uuid.UUID) (int, error) {
+	var balance int
+	return balance, dbutil.PossibleDbError(tx.
+		Model(&entity.LoyaltyTransaction{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("user_id = ?", userID).
+		Scan(&balance))
+}
+
+func countTrips(tx *gorm.DB, userID uuid.UUID, since time.Time) (int, error) {
+	var trips int64
+	return int(trips), dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Where("user_id = ? AND status = ? AND completed_at >= ?", userID, entity.TicketStatusCompleted, since).
+		Count(&trips))
+}
+
+// earnLoyaltyPoints credits the customers with the points of their completed
+// tickets; the tier counts the trips of the last year, these ones included.
+func earnLoyaltyPoints(tx *gorm.DB, ticketIDs []uuid.UUID) error {
+	var tickets []entity.Ticket
+	err := dbutil.PossibleDbError(tx.
+		Preload("TicketPayment").
+		Where("id IN (?) AND user_id IN (SELECT id FROM users WHERE role = 'Customer')", ticketIDs).
+		Find(&tickets))
+	if err != nil || len(tickets) == 0 {
+		return err
+	}
+
+	since := time.Now().AddDate(-1, 0, 0)
+	var tiers = map[uuid.UUID]entity.LoyaltyTier{}
+	var transactions = make([]entity.LoyaltyTransaction, 0, len(tickets))
+	for _, ticket := range tickets {
+		tier, ok := tiers[ticket.UserID]
+		if !ok {
+			trips, err := countTrips(tx, ticket.UserID, since)
+			if err != nil {
+				return err
+			}
+			tier = entity.LoyaltyTierFor(trips)
+			tiers[ticket.UserID] = tier
+		}
+
+		points := tier.EarnedPoints(ticket.TicketPayment.Price)
+		if points == 0 {
+			continue
+		}
+
+		transactions = append(transactions, entity.LoyaltyTransaction{
+			ID:       uuid.New(),
+			UserID:   ticket.UserID,
+			Type:     entity.LoyaltyTransactionEarned,
+			Points:   points,
+			TicketID: uuid.NullUUID{UUID: ticket.ID, Valid: true},
+			Reason:   fmt.Sprintf("%s tier", tier.Name),
+		})
+	}
+
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	return dbutil.PossibleCreateError(tx.Create(&transactions), "loyalty-transaction-data")
+}
+
+// refundLoyaltyPoints takes back the points earned by the refunded tickets and
+// returns the points spent on them; it is safe to run more than once.
+func refundLoyaltyPoints(tx *gorm.DB, ticketIDs []uuid.UUID) error {
+	var balances []struct {
+		UserID   uuid.UUID
+		TicketID uuid.UUID
+		Earned   int
+		Returned int
+	}
+	err := dbutil.PossibleDbError(tx.
+		Model(&entity.LoyaltyTransaction{}).
+		Select(
+			"user_id, ticket_id, "+
+				"COALESCE(SUM(CASE WHEN type IN (?) THEN points END), 0) AS earned, "+
+				"COALESCE(SUM(CASE WHEN type = ? THEN points END), 0) AS returned",
+			[]string{string(entity.LoyaltyTransactionEarned), string(entity.LoyaltyTransactionReversed)},
+			entity.LoyaltyTransactionReturned,
+		).
+		Where("ticket_id IN (?)", ticketIDs).
+		Group("user_id, ticket_id").
+		Scan(&balances))
+	if err != nil {
+		return err
+	}
+
+	var payments []entity.TicketPayment
+	err = dbutil.PossibleDbError(tx.
+		Where("ticket_id IN (?) AND loyalty_points > 0", ticketIDs).
+		Find(&payments))
+	if err != nil {
+		return err
+	}
+
+	var transactions []entity.LoyaltyTransaction
+	for _, balance := range balances {
+		if balance.Earned > 0 {
+			transactions = append(transactions, entity.LoyaltyTransaction{
+				ID:       uuid.New(),
+				UserID:   balance.UserID,
+				Type:     entity.LoyaltyTransactionReversed,
+				Points:   -balance.Earned,
+				TicketID: uuid.NullUUID{UUID: balance.TicketID, Valid: true},
+				Reason:   "Ticket refunded",
+			})
+		}
+	}
+
+	if len(payments) > 0 {
+		var redemptionIDs = make([]uuid.UUID, len(payments))
+		for i, payment := range payments {
+			redemptionIDs[i] = payment.LoyaltyTransactionID.UUID
+		}
+
+		// The points go back to whoever spent them, even if the ticket has
+		// been transferred since.
+		var redemptions []entity.LoyaltyTransaction
+		err = dbutil.PossibleDbError(tx.Where("id IN (?)", redemptionIDs).Find(&redemptions))
+		if err != nil {
+			return err
+		}
+
+		for _, payment := range payments {
+			redemption := slices.IndexFunc(redemptions, func(r entity.LoyaltyTransaction) bool { return r.ID == payment.LoyaltyTransactionID.UUID })
+			if redemption == -1 {
+				continue
+			}
+
+			points := payment.LoyaltyPoints
+			for _, balance := range balances {
+				if balance.TicketID == payment.TicketID {
+					points -= balance.Returned
+				}
+			}
+
+			if points > 0 {
+				transactions = append(transactions, entity.LoyaltyTransaction{
+					ID:       uuid.New(),
+					UserID:   redemptions[redemption].UserID,
+					Type:     entity.LoyaltyTransactionReturned,
+					Points:   points,
+					TicketID: uuid.NullUUID{UUID: payment.TicketID, Valid: true},
+					Reason:   "Ticket refunded",
+				})
+			}
+		}
+	}
+
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	return dbutil.PossibleCreateError(tx.Create(&transactions), "loyalty-transaction-data")
+}
+
+func NewLoyalty(db *gorm.DB) Loyalty {
+	return &loyaltyMySQL{db}
+}
@@ -1,8 +1,10 @@
 package dataStore
 
 import (
+	"maryan_api/config"
 	"maryan_api/internal/entity"
 	"maryan_api/internal/valueobject"
+	ginutil "maryan_api/pkg/ginutils"
 	"maryan_api/pkg/log"
 
 	"gorm.io/gorm"
@@ -15,14 +17,31 @@ func Migrate(db *gorm.DB) error {
 		}
 	}
 
+	// The times are converted before the migrations add any of their own.
+	errCheck(convertToUTC(db, config.LegacyTimeZone()))
+
 	errCheck(entity.MigrateUser(db))
 	errCheck(entity.MigrateBus(db))
 	errCheck(entity.MigratePassenger(db))
 	errCheck(entity.MigrateAddress(db))
+	errCheck(entity.MigrateRoute(db))
 	errCheck(entity.MigrateTrip(db))
+	errCheck(entity.MigrateTimetable(db))
 	errCheck(valueobject.MigrateVerifications(db))
 	errCheck(log.Migrate(db))
+	errCheck(ginutil.MigrateIdempotency(db))
 	errCheck(entity.MigrateTicket(db))
+	errCheck(entity.MigrateSeatHold(db))
+	errCheck(entity.MigratePaymentEvent(db))
+	errCheck(entity.MigrateRefaund(db))
+	errCheck(entity.MigrateSeatSurcharge(db))
+	errCheck(entity.MigratePromoCode(db))
+	errCheck(entity.MigrateWaitlist(db))
+	errCheck(entity.MigrateCash(db))
+	errCheck(entity.MigrateJobRun(db))
+	errCheck(entity.MigrateTicketTransfer(db))
+	errCheck(entity.MigrateExtra(db))
+	errCheck(entity.MigrateLoyalty(db))
 
 	errCheck(entity.MigrateConnection(db))
 	return nil
@@ -26,7 +26,7 @@ type passengerMySQL struct {
 
 func (pds *passengerMySQL) Create(ctx context.Context, passenger *entity.Passenger) error {
 	return dbutil.PossibleCreateError(
-		pds.db.WithContext(ctx).Create(passenger),
+		fromContext(ctx, pds.db).Create(passenger),
 		"invalid-passenger-data",
 	)
 }
@@ -34,21 +34,21 @@ func (pds *passengerMySQL) Create(ctx context.Context, passenger *entity.Passeng
 func (pds *passengerMySQL) Update(ctx context.Context, passenger *entity.Passenger) error {
 
 	return dbutil.PossibleRawsAffectedError(
-		pds.db.WithContext(ctx).Updates(passenger),
+		fromContext(ctx, pds.db).Updates(passenger),
 		"invalid-passenger-data",
 	)
 }
 
 func (pds *passengerMySQL) ForseDelete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		pds.db.WithContext(ctx).Unscoped().Delete(&entity.Passenger{ID: id}),
+		fromContext(ctx, pds.db).Unscoped().Delete(&entity.Passenger{ID: id}),
 		"invalid-passenger-data",
 	)
 }
 
 func (pds *passengerMySQL) SoftDelete(ctx context.Context, id uuid.UUID) error {
 	return dbutil.PossibleRawsAffectedError(
-		pds.db.WithContext(ctx).Delete(&entity.Passenger{ID: id}),
+		fromContext(ctx, pds.db).Delete(&entity.Passenger{ID: id}),
 		"invalid-passenger-data",
 	)
 }
@@ -57,13 +57,13 @@ func (pds *passengerMySQL) Status(ctx context.Context, id uuid.UUID) (bool, bool
 	var exists bool
 	var usedByTicket bool
 
-	if err := pds.db.WithContext(ctx).
+	if err := fromContext(ctx, pds.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM passengers WHERE id = ?)", id).
 		Scan(&exists).Error; err != nil {
 		return false, false, rfc7807.DB(err.Error())
 	}
 
-	if err := pds.db.WithContext(ctx).
+	if err := fromContext(ctx, pds.db).
 		Raw("SELECT EXISTS(SELECT 1 FROM tickets WHERE passenger_id = ?)", id).
 		Scan(&usedByTicket).Error; err != nil {
 		return false, false, rfc7807.DB(err.Error())
@@ -75,7 +75,7 @@ func (pds *passengerMySQL) Status(ctx context.Context, id uuid.UUID) (bool, bool
 func (pds *passengerMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Passenger, error) {
 	passenger := entity.Passenger{ID: id}
 	return passenger, dbutil.PossibleFirstError(
-		pds.db.WithContext(ctx).First(&passenger),
+		fromContext(ctx, pds.db).First(&passenger),
 		"non-existing-passenger",
 	)
 }
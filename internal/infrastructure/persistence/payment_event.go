@@ -0,0 +1,44 @@
+package dataStore
+
+import (
+	"context"
+	"errors"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+
+	"gorm.io/gorm"
+)
+
+type PaymentEvent interface {
+	Register(ctx context.Context, event *entity.PaymentEvent) (bool, error)
+	RecordUnfulfilled(ctx context.Context, payment *entity.UnfulfilledPayment) error
+	AttachUnfulfilledRefund(ctx context.Context, sessionID, providerRefundID string) error
+}
+
+type paymentEventMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *paymentEventMySQL) Register(ctx context.Context, event *entity.PaymentEvent) (bool, error) {
+	result := fromContext(ctx, ds.db).Create(event)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return false, nil
+	}
+
+	return true, dbutil.PossibleCreateError(result, "payment-event-data")
+}
+
+func (ds *paymentEventMySQL) RecordUnfulfilled(ctx context.Context, payment *entity.UnfulfilledPayment) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(payment), "unfulfilled-payment-data")
+}
+
+func (ds *paymentEventMySQL) AttachUnfulfilledRefund(ctx context.Context, sessionID, providerRefundID string) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.UnfulfilledPayment{}).
+		Where("session_id = ?", sessionID).
+		Update("provider_refund_id", providerRefundID), "non-existing-unfulfilled-payment")
+}
+
+func NewPaymentEvent(db *gorm.DB) PaymentEvent {
+	return &paymentEventMySQL{db}
+}
@@ -0,0 +1,96 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromoCode interface {
+	Create(ctx context.Context, promoCode *entity.PromoCode) error
+	Update(ctx context.Context, promoCode *entity.PromoCode) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.PromoCode, error)
+	GetPromoCodes(ctx context.Context, pagination dbutil.Pagination) ([]entity.PromoCode, int, error, bool)
+	LockByCode(ctx context.Context, code string) (entity.PromoCode, error)
+	CountRedemptions(ctx context.Context, id, userID uuid.UUID) (int, int, error)
+	Redeem(ctx context.Context, redemption *entity.PromoCodeRedemption) error
+}
+
+type promoCodeMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *promoCodeMySQL) Create(ctx context.Context, promoCode *entity.PromoCode) error {
+	return dbutil.ErrDuplicatedKey(fromContext(ctx, ds.db).Create(promoCode), "promo-code-exists", "promo-code-data")
+}
+
+func (ds *promoCodeMySQL) Update(ctx context.Context, promoCode *entity.PromoCode) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		err := dbutil.ErrDuplicatedKey(tx.
+			Model(&entity.PromoCode{ID: promoCode.ID}).
+			Select("code", "discount_type", "discount_value", "valid_from", "valid_until", "max_redemptions", "per_user_limit").
+			Updates(promoCode), "promo-code-exists", "promo-code-data")
+		if err != nil {
+			return err
+		}
+
+		err = dbutil.PossibleDbError(tx.Where("promo_code_id = ?", promoCode.ID).Delete(&entity.PromoCodeRestriction{}))
+		if err != nil || len(promoCode.Restrictions) == 0 {
+			return err
+		}
+
+		return dbutil.PossibleCreateError(tx.Create(promoCode.Restrictions), "promo-code-data")
+	})
+}
+
+func (ds *promoCodeMySQL) Delete(ctx context.Context, id uuid.UUID) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Delete(&entity.PromoCode{ID: id}), "non-existing-promo-code")
+}
+
+func (ds *promoCodeMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.PromoCode, error) {
+	var promoCode = entity.PromoCode{ID: id}
+	return promoCode, dbutil.PossibleFirstError(fromContext(ctx, ds.db).Preload(clause.Associations).First(&promoCode), "non-existing-promo-code")
+}
+
+func (ds *promoCodeMySQL) GetPromoCodes(ctx context.Context, pagination dbutil.Pagination) ([]entity.PromoCode, int, error, bool) {
+	return dbutil.Paginate[entity.PromoCode](ctx, ds.db, pagination, clause.Associations)
+}
+
+// LockByCode locks the code until the end of the surrounding transaction, so
+// concurrent purchases cannot redeem it past its limits.
+func (ds *promoCodeMySQL) LockByCode(ctx context.Context, code string) (entity.PromoCode, error) {
+	var promoCode entity.PromoCode
+	return promoCode, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload(clause.Associations).
+		Where("code = ?", code).
+		First(&promoCode), "non-existing-promo-code")
+}
+
+func (ds *promoCodeMySQL) CountRedemptions(ctx context.Context, id, userID uuid.UUID) (int, int, error) {
+	var counts struct {
+		Total  int
+		ByUser int
+	}
+
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.PromoCodeRedemption{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(user_id = ?), 0) AS by_user", userID).
+		Where("promo_code_id = ?", id).
+		Scan(&counts))
+
+	return counts.Total, counts.ByUser, err
+}
+
+func (ds *promoCodeMySQL) Redeem(ctx context.Context, redemption *entity.PromoCodeRedemption) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(redemption), "promo-code-redemption-data")
+}
+
+func NewPromoCode(db *gorm.DB) PromoCode {
+	return &promoCodeMySQL{db}
+}
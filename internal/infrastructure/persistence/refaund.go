@@ -1 +1,209 @@
 package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Refaund interface {
+	Create(ctx context.Context, refaund *entity.Refaund) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Refaund, error)
+	GetRefaunds(ctx context.Context, pagination dbutil.Pagination) ([]entity.Refaund, int, error, bool)
+	GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error)
+	HasActive(ctx context.Context, ticketID uuid.UUID) (bool, error)
+	StartProcessing(ctx context.Context, id uuid.UUID) error
+	AttachProviderRefund(ctx context.Context, id uuid.UUID, providerRefundID string) error
+	StopProcessing(ctx context.Context, id uuid.UUID) error
+	Reject(ctx context.Context, id uuid.UUID) error
+	Complete(ctx context.Context, paymentIntentID, providerRefundID string) ([]uuid.UUID, error)
+	CompleteWithoutPayment(ctx context.Context, id uuid.UUID, comment string) error
+}
+
+type refaundMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *refaundMySQL) Create(ctx context.Context, refaund *entity.Refaund) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(refaund), "refund-data")
+}
+
+func (ds *refaundMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Refaund, error) {
+	var refaund = entity.Refaund{ID: id}
+	return refaund, dbutil.PossibleFirstError(fromContext(ctx, ds.db).Preload("Ticket.TicketPayment").First(&refaund), "non-existing-refund")
+}
+
+func (ds *refaundMySQL) GetRefaunds(ctx context.Context, pagination dbutil.Pagination) ([]entity.Refaund, int, error, bool) {
+	return dbutil.Paginate[entity.Refaund](ctx, ds.db, pagination, clause.Associations)
+}
+
+func (ds *refaundMySQL) GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]entity.Refaund, error) {
+	var refaunds []entity.Refaund
+	return refaunds, dbutil.PossibleDbError(fromContext(ctx, ds.db).Where("ticket_id = ?", ticketID).Order("created_at").Find(&refaunds))
+}
+
+func (ds *refaundMySQL) HasActive(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	var count int64
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.Refaund{}).
+		Where("ticket_id = ? AND reason = ? AND status <> ?", ticketID, entity.RefaundReasonCancellation, entity.RefaundStatusRejected).
+		Count(&count))
+
+	return count > 0, err
+}
+
+// StartProcessing only succeeds for a requested refund, so concurrent
+// approvals cannot trigger the provider refund twice.
+func (ds *refaundMySQL) StartProcessing(ctx context.Context, id uuid.UUID) error {
+	return ds.changeStatus(ctx, id, entity.RefaundStatusRequested, entity.RefaundStatusProcessing)
+}
+
+func (ds *refaundMySQL) StopProcessing(ctx context.Context, id uuid.UUID) error {
+	return ds.changeStatus(ctx, id, entity.RefaundStatusProcessing, entity.RefaundStatusRequested)
+}
+
+func (ds *refaundMySQL) Reject(ctx context.Context, id uuid.UUID) error {
+	return ds.changeStatus(ctx, id, entity.RefaundStatusRequested, entity.RefaundStatusRejected)
+}
+
+// AttachProviderRefund records the id the provider gave the refund. The
+// webhook of the refund may arrive before the provider's response does, in
+// which case the refund is completed right away. Both lock the refund row
+// first, so whichever comes second sees what the other one stored.
+func (ds *refaundMySQL) AttachProviderRefund(ctx context.Context, id uuid.UUID, providerRefundID string) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var refaund entity.Refaund
+		err := dbutil.PossibleFirstError(tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&refaund), "non-existing-refund")
+		if err != nil {
+			return err
+		}
+
+		err = dbutil.PossibleDbError(tx.
+			Model(&entity.Refaund{}).
+			Where("id = ?", id).
+			Update("provider_refund_id", providerRefundID))
+		if err != nil {
+			return err
+		}
+
+		var received int64
+		err = dbutil.PossibleDbError(tx.
+			Model(&entity.PaymentEvent{}).
+			Where("type = ? AND refund_id = ?", entity.PaymentEventRefundSucceeded, providerRefundID).
+			Count(&received))
+		if err != nil || received == 0 {
+			return err
+		}
+
+		_, err = completeRefund(tx, refaund.PaymentIntentID, providerRefundID)
+		return err
+	})
+}
+
+func (ds *refaundMySQL) changeStatus(ctx context.Context, id uuid.UUID, from, to any) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.Refaund{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to), "invalid-refund-status")
+}
+
+// Complete completes the processing refund of the payment the provider gave
+// the id to; a cancellation also refunds its ticket. It returns the
+// connections the seats of the refunded tickets were given back to.
+func (ds *refaundMySQL) Complete(ctx context.Context, paymentIntentID, providerRefundID string) ([]uuid.UUID, error) {
+	var freed []uuid.UUID
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		freed, err = completeRefund(tx, paymentIntentID, providerRefundID)
+		return err
+	})
+
+	return freed, err
+}
+
+// completeRefund locks the processing refunds of the payment, including the
+// one whose provider id may still be being attached, and completes the one
+// the provider id belongs to.
+func completeRefund(tx *gorm.DB, paymentIntentID, providerRefundID string) ([]uuid.UUID, error) {
+	if paymentIntentID == "" || providerRefundID == "" {
+		return nil, nil
+	}
+
+	var processing []entity.Refaund
+	err := dbutil.PossibleDbError(tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_intent_id = ? AND status = ?", paymentIntentID, entity.RefaundStatusProcessing).
+		Find(&processing))
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(processing, func(refaund entity.Refaund) bool {
+		return refaund.ProviderRefundID == providerRefundID
+	})
+	if i < 0 {
+		return nil, nil
+	}
+	refaund := processing[i]
+
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Refaund{}).
+		Where("id = ?", refaund.ID).
+		Updates(map[string]any{"status": entity.RefaundStatusCompleted, "completed_at": time.Now()}))
+	if err != nil {
+		return nil, err
+	}
+
+	if refaund.Reason != entity.RefaundReasonCancellation {
+		return nil, nil
+	}
+
+	// A cancellation refunded through several payments cancels the ticket
+	// once the last of them completes.
+	var pending int64
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Refaund{}).
+		Where("ticket_id = ? AND reason = ? AND status IN (?)", refaund.TicketID, entity.RefaundReasonCancellation, []any{entity.RefaundStatusRequested, entity.RefaundStatusProcessing}).
+		Count(&pending))
+	if err != nil || pending > 0 {
+		return nil, err
+	}
+
+	return cancelTickets(tx, []uuid.UUID{refaund.TicketID}, entity.TicketUpdate{Status: entity.TicketStatusRefunded})
+}
+
+// CompleteWithoutPayment completes a requested refund that gives nothing back
+// and cancels its ticket, there being nothing for the provider to do.
+func (ds *refaundMySQL) CompleteWithoutPayment(ctx context.Context, id uuid.UUID, comment string) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var refaund = entity.Refaund{ID: id}
+		err := dbutil.PossibleFirstError(tx.First(&refaund), "non-existing-refund")
+		if err != nil {
+			return err
+		}
+
+		err = dbutil.PossibleRawsAffectedError(tx.
+			Model(&entity.Refaund{}).
+			Where("id = ? AND status = ? AND amount = 0", id, entity.RefaundStatusRequested).
+			Updates(map[string]any{"status": entity.RefaundStatusCompleted, "completed_at": time.Now()}), "invalid-refund-status")
+		if err != nil {
+			return err
+		}
+
+		_, err = cancelTickets(tx, []uuid.UUID{refaund.TicketID}, entity.TicketUpdate{Status: entity.TicketStatusCancelled, Comment: comment})
+		return err
+	})
+}
+
+func NewRefaund(db *gorm.DB) Refaund {
+	return &refaundMySQL{db}
+}
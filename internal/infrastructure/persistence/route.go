@@ -0,0 +1,85 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	rfc7807 "maryan_api/pkg/problem"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Route interface {
+	Create(ctx context.Context, route *entity.Route) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.Route, error)
+	GetRoutes(ctx context.Context, pagination dbutil.Pagination) ([]entity.Route, int, error, bool)
+}
+
+type routeMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *routeMySQL) Create(ctx context.Context, route *entity.Route) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(route), "route-data")
+}
+
+// Delete removes the route with its stations unless a connection still calls
+// at them or a timetable still generates connections calling at them.
+func (ds *routeMySQL) Delete(ctx context.Context, id uuid.UUID) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var connections int64
+		err := dbutil.PossibleDbError(tx.Model(&entity.Connection{}).Where("route_id = ?", id).Count(&connections))
+		if err != nil {
+			return err
+		}
+
+		if connections > 0 {
+			return rfc7807.BadRequest("route-in-use", "Route In Use Error", "The route is used by connections.")
+		}
+
+		var timetables int64
+		err = dbutil.PossibleDbError(tx.Model(&entity.Timetable{}).Where("route_id = ? OR return_route_id = ?", id, id).Count(&timetables))
+		if err != nil {
+			return err
+		}
+
+		if timetables > 0 {
+			return rfc7807.BadRequest("route-in-use", "Route In Use Error", "The route is used by timetables.")
+		}
+
+		err = dbutil.PossibleDbError(tx.Where("route_id = ?", id).Delete(&entity.RouteStation{}))
+		if err != nil {
+			return err
+		}
+
+		return dbutil.PossibleRawsAffectedError(tx.Delete(&entity.Route{ID: id}), "non-existing-route")
+	})
+}
+
+func (ds *routeMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Route, error) {
+	var route = entity.Route{ID: id}
+	return route, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Preload("Stations").
+		Preload("Stations.Country").
+		First(&route), "non-existing-route")
+}
+
+func (ds *routeMySQL) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.Route, error) {
+	var routes []entity.Route
+	return routes, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Preload("Stations").
+		Preload("Stations.Country").
+		Where("id IN (?)", ids).
+		Find(&routes))
+}
+
+func (ds *routeMySQL) GetRoutes(ctx context.Context, pagination dbutil.Pagination) ([]entity.Route, int, error, bool) {
+	return dbutil.Paginate[entity.Route](ctx, ds.db, pagination, "Stations", "Stations.Country")
+}
+
+func NewRoute(db *gorm.DB) Route {
+	return &routeMySQL{db}
+}
@@ -0,0 +1,390 @@
+package dataStore
+
+import (
+	"context"
+	"errors"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	rfc7807 "maryan_api/pkg/problem"
+	"slices"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SeatHold interface {
+	Hold(ctx context.Context, holds []*entity.SeatHold) error
+	AttachSession(ctx context.Context, ids []uuid.UUID, sessionID string) error
+	Release(ctx context.Context, ids []uuid.UUID) error
+	ReleaseBySession(ctx context.Context, sessionID string) ([]uuid.UUID, error)
+	ReleaseExpired(ctx context.Context, now time.Time) (int, []uuid.UUID, error)
+	Rehold(ctx context.Context, sessionID string) (bool, error)
+	HasRebooking(ctx context.Context, ticketID uuid.UUID) (bool, error)
+	HasWaitlistEntry(ctx context.Context, waitlistEntryID uuid.UUID) (bool, error)
+}
+
+type seatHoldMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *seatHoldMySQL) Hold(ctx context.Context, holds []*entity.SeatHold) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, hold := range holds {
+			_, err := parkHolds(tx.Where(
+				"connection_id = ? AND seat_id = ? AND expires_at <= ?",
+				hold.ConnectionID,
+				hold.SeatID,
+				now,
+			), now)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The seats are checked against the overlapping segments under the
+		// connection lock taken by LockSeats; the unique indexes catch the
+		// holds that get past it.
+		result := tx.Create(holds)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return rfc7807.BadRequest("taken-seat", "Taken Seat Error", "One of the seats is already taken.")
+		}
+
+		return dbutil.PossibleCreateError(result, "seat-hold-data")
+	})
+}
+
+func (ds *seatHoldMySQL) AttachSession(ctx context.Context, ids []uuid.UUID, sessionID string) error {
+	return dbutil.PossibleRawsAffectedError(
+		fromContext(ctx, ds.db).
+			Model(&entity.SeatHold{}).
+			Where("id IN (?)", ids).
+			Update("session_id", sessionID),
+		"non-existing-seat-hold",
+	)
+}
+
+func (ds *seatHoldMySQL) Release(ctx context.Context, ids []uuid.UUID) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		_, err := releaseHolds(tx.Where("id IN (?)", ids))
+		return err
+	})
+}
+
+// ReleaseBySession releases the holds of the checkout, including those
+// already released for running out of time, and returns the connections they
+// were on.
+func (ds *seatHoldMySQL) ReleaseBySession(ctx context.Context, sessionID string) ([]uuid.UUID, error) {
+	var released []entity.SeatHold
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		released, err = releaseHolds(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("session_id = ?", sessionID))
+		if err != nil {
+			return err
+		}
+
+		discarded, err := discardReleasedHolds(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("session_id = ?", sessionID))
+		released = append(released, discarded...)
+		return err
+	})
+
+	return heldConnections(released), err
+}
+
+// ReleaseExpired reclaims the holds of checkouts the provider never reported
+// back on, which expire a grace period after their checkout does, and returns
+// how many of them were released and on which connections. The released holds
+// are kept for ReleasedSeatHoldRetention in case the payment is reported late.
+func (ds *seatHoldMySQL) ReleaseExpired(ctx context.Context, now time.Time) (int, []uuid.UUID, error) {
+	var released []entity.SeatHold
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		released, err = parkHolds(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("expires_at <= ?", now), now)
+		if err != nil {
+			return err
+		}
+
+		_, err = discardReleasedHolds(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("released_at <= ?", now.Add(-entity.ReleasedSeatHoldRetention)))
+		return err
+	})
+
+	return len(released), heldConnections(released), err
+}
+
+// Rehold holds the seats of a checkout released before it was paid again if
+// they are all still free, and reports whether the checkout holds its seats.
+// The extras go along without their capacity being checked again, as they
+// have already been paid for.
+func (ds *seatHoldMySQL) Rehold(ctx context.Context, sessionID string) (bool, error) {
+	var held bool
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var heldIDs []uuid.UUID
+		err := dbutil.PossibleDbError(tx.
+			Model(&entity.SeatHold{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ?", sessionID).
+			Pluck("id", &heldIDs))
+		if err != nil {
+			return err
+		}
+
+		var released []entity.ReleasedSeatHold
+		err = dbutil.PossibleDbError(tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ?", sessionID).
+			Find(&released))
+		if err != nil || len(released) == 0 {
+			held = len(heldIDs) > 0
+			return err
+		}
+
+		// The holds only have to last until the tickets are issued.
+		expiresAt := time.Now().Add(entity.SeatHoldGrace)
+		var holds = make([]*entity.SeatHold, len(released))
+		var ids = make([]uuid.UUID, len(released))
+		for i := range released {
+			hold := released[i].Hold
+			taken, err := lockSeats(tx, hold.ConnectionID, hold.Segment())
+			if err != nil || slices.Contains(taken, hold.SeatID) {
+				return err
+			}
+
+			hold.ExpiresAt = expiresAt
+			holds[i] = &hold
+			ids[i] = released[i].ID
+		}
+
+		result := tx.Create(holds)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return nil
+		}
+
+		err = dbutil.PossibleCreateError(result, "seat-hold-data")
+		if err != nil {
+			return err
+		}
+
+		held = true
+		return dbutil.PossibleDbError(tx.Where("id IN (?)", ids).Delete(&entity.ReleasedSeatHold{}))
+	})
+
+	return held, err
+}
+
+// HasRebooking reports whether the ticket has a rebooking waiting for its
+// fare difference to be paid.
+func (ds *seatHoldMySQL) HasRebooking(ctx context.Context, ticketID uuid.UUID) (bool, error) {
+	var count int64
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.SeatHold{}).
+		Where("rebooked_ticket_id = ?", ticketID).
+		Count(&count))
+
+	return count > 0, err
+}
+
+// HasWaitlistEntry reports whether a checkout paying for the seats offered
+// to the waitlist entry is still open.
+func (ds *seatHoldMySQL) HasWaitlistEntry(ctx context.Context, waitlistEntryID uuid.UUID) (bool, error) {
+	var count int64
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.SeatHold{}).
+		Where("waitlist_entry_id = ?", waitlistEntryID).
+		Count(&count))
+
+	return count > 0, err
+}
+
+// releaseHolds deletes the holds matched by the query together with what was
+// created for them at checkout and returns the deleted holds.
+func releaseHolds(query *gorm.DB) ([]entity.SeatHold, error) {
+	var holds []entity.SeatHold
+	err := dbutil.PossibleDbError(query.Find(&holds))
+	if err != nil || len(holds) == 0 {
+		return nil, err
+	}
+
+	tx := query.Session(&gorm.Session{NewDB: true})
+
+	err = deleteHolds(tx, holdIDs(holds))
+	if err != nil {
+		return holds, err
+	}
+
+	return holds, releaseCheckoutData(tx, holds)
+}
+
+// parkHolds releases the holds matched by the query, which ran out of time
+// without the provider reporting back on their checkouts, and returns them.
+// The holds of an opened checkout, all of them at once, are kept as released
+// seat holds together with what was created for them, so a payment reported
+// late can take them back; the others are released outright.
+func parkHolds(query *gorm.DB, now time.Time) ([]entity.SeatHold, error) {
+	var holds []entity.SeatHold
+	err := dbutil.PossibleDbError(query.Find(&holds))
+	if err != nil || len(holds) == 0 {
+		return nil, err
+	}
+
+	tx := query.Session(&gorm.Session{NewDB: true})
+
+	var unopened []entity.SeatHold
+	var sessionIDs []string
+	for _, hold := range holds {
+		if hold.SessionID == "" {
+			unopened = append(unopened, hold)
+		} else if !slices.Contains(sessionIDs, hold.SessionID) {
+			sessionIDs = append(sessionIDs, hold.SessionID)
+		}
+	}
+
+	var parked []entity.SeatHold
+	if len(sessionIDs) > 0 {
+		err = dbutil.PossibleDbError(tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Extras").
+			Where("session_id IN (?)", sessionIDs).
+			Find(&parked))
+		if err != nil {
+			return nil, err
+		}
+
+		var released = make([]entity.ReleasedSeatHold, len(parked))
+		for i, hold := range parked {
+			released[i] = entity.NewReleasedSeatHold(hold, now)
+		}
+
+		err = dbutil.PossibleCreateError(tx.Create(&released), "released-seat-hold-data")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	holds = append(unopened, parked...)
+	err = deleteHolds(tx, holdIDs(holds))
+	if err != nil || len(unopened) == 0 {
+		return holds, err
+	}
+
+	return holds, releaseCheckoutData(tx, unopened)
+}
+
+// discardReleasedHolds deletes the released seat holds matched by the query
+// together with what was created for them at checkout and returns the holds.
+func discardReleasedHolds(query *gorm.DB) ([]entity.SeatHold, error) {
+	var released []entity.ReleasedSeatHold
+	err := dbutil.PossibleDbError(query.Find(&released))
+	if err != nil || len(released) == 0 {
+		return nil, err
+	}
+
+	tx := query.Session(&gorm.Session{NewDB: true})
+
+	var ids = make([]uuid.UUID, len(released))
+	var holds = make([]entity.SeatHold, len(released))
+	for i, hold := range released {
+		ids[i] = hold.ID
+		holds[i] = hold.Hold
+	}
+
+	err = dbutil.PossibleDbError(tx.Where("id IN (?)", ids).Delete(&entity.ReleasedSeatHold{}))
+	if err != nil {
+		return holds, err
+	}
+
+	return holds, releaseCheckoutData(tx, holds)
+}
+
+// releaseCheckoutData deletes the passengers, addresses, promo code
+// redemptions and loyalty points redemptions created for the holds at
+// checkout. The waitlist offer a hold was bought with stays open until it
+// runs out.
+func releaseCheckoutData(tx *gorm.DB, holds []entity.SeatHold) error {
+	var passengerIDs, adressIDs = make([]uuid.UUID, 0, len(holds)), make([]uuid.UUID, 0, len(holds)*2)
+	var redemptionIDs, loyaltyIDs []uuid.UUID
+	for _, hold := range holds {
+		// A rebooking hold shares the passenger and the addresses of a ticket.
+		if hold.RebookedTicketID.Valid {
+			continue
+		}
+
+		passengerIDs = append(passengerIDs, hold.PassengerID)
+		adressIDs = append(adressIDs, hold.PickUpAdressID, hold.DropOffAdressID)
+		if hold.PromoCodeRedemptionID.Valid {
+			redemptionIDs = append(redemptionIDs, hold.PromoCodeRedemptionID.UUID)
+		}
+		if hold.LoyaltyTransactionID.Valid {
+			loyaltyIDs = append(loyaltyIDs, hold.LoyaltyTransactionID.UUID)
+		}
+	}
+
+	if len(passengerIDs) == 0 {
+		return nil
+	}
+
+	err := dbutil.PossibleDbError(tx.Where("id IN (?)", passengerIDs).Unscoped().Delete(&entity.Passenger{}))
+	if err != nil {
+		return err
+	}
+
+	err = dbutil.PossibleDbError(tx.Where("id IN (?)", adressIDs).Unscoped().Delete(&entity.Address{}))
+	if err != nil {
+		return err
+	}
+
+	// A promo code redemption of an abandoned checkout no longer counts.
+	if len(redemptionIDs) > 0 {
+		err = dbutil.PossibleDbError(tx.
+			Where("id IN (?) AND id NOT IN (SELECT promo_code_redemption_id FROM seat_holds WHERE promo_code_redemption_id IS NOT NULL)", redemptionIDs).
+			Delete(&entity.PromoCodeRedemption{}))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Neither do the loyalty points it spent.
+	if len(loyaltyIDs) > 0 {
+		err = dbutil.PossibleDbError(tx.
+			Where("id IN (?) AND id NOT IN (SELECT loyalty_transaction_id FROM seat_holds WHERE loyalty_transaction_id IS NOT NULL)", loyaltyIDs).
+			Delete(&entity.LoyaltyTransaction{}))
+	}
+
+	return err
+}
+
+// deleteHolds deletes the holds together with the extras reserved with them.
+func deleteHolds(tx *gorm.DB, holdIDs []uuid.UUID) error {
+	err := dbutil.PossibleDbError(tx.Where("seat_hold_id IN (?)", holdIDs).Delete(&entity.SeatHoldExtra{}))
+	if err != nil {
+		return err
+	}
+
+	return dbutil.PossibleDbError(tx.Where("id IN (?)", holdIDs).Delete(&entity.SeatHold{}))
+}
+
+func holdIDs(holds []entity.SeatHold) []uuid.UUID {
+	var ids = make([]uuid.UUID, len(holds))
+	for i, hold := range holds {
+		ids[i] = hold.ID
+	}
+
+	return ids
+}
+
+// heldConnections returns the connections of the holds, each once.
+func heldConnections(holds []entity.SeatHold) []uuid.UUID {
+	var connectionIDs []uuid.UUID
+	for _, hold := range holds {
+		if !slices.Contains(connectionIDs, hold.ConnectionID) {
+			connectionIDs = append(connectionIDs, hold.ConnectionID)
+		}
+	}
+
+	return connectionIDs
+}
+
+func NewSeatHold(db *gorm.DB) SeatHold {
+	return &seatHoldMySQL{db}
+}
@@ -0,0 +1,44 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type SeatSurcharge interface {
+	Create(ctx context.Context, surcharge *entity.SeatSurcharge) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetSurcharges(ctx context.Context, pagination dbutil.Pagination) ([]entity.SeatSurcharge, int, error, bool)
+	GetForBus(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error)
+}
+
+type seatSurchargeMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *seatSurchargeMySQL) Create(ctx context.Context, surcharge *entity.SeatSurcharge) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(surcharge), "seat-surcharge-data")
+}
+
+func (ds *seatSurchargeMySQL) Delete(ctx context.Context, id uuid.UUID) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Delete(&entity.SeatSurcharge{ID: id}), "non-existing-seat-surcharge")
+}
+
+func (ds *seatSurchargeMySQL) GetSurcharges(ctx context.Context, pagination dbutil.Pagination) ([]entity.SeatSurcharge, int, error, bool) {
+	return dbutil.Paginate[entity.SeatSurcharge](ctx, ds.db, pagination)
+}
+
+func (ds *seatSurchargeMySQL) GetForBus(ctx context.Context, busID uuid.UUID, line int) ([]entity.SeatSurcharge, error) {
+	var surcharges []entity.SeatSurcharge
+	return surcharges, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Where("bus_id = ? OR line = ?", busID, line).
+		Find(&surcharges))
+}
+
+func NewSeatSurcharge(db *gorm.DB) SeatSurcharge {
+	return &seatSurchargeMySQL{db}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"maryan_api/internal/entity"
 	"maryan_api/pkg/dbutil"
+	"slices"
 	"time"
 
 	"github.com/d3code/uuid"
@@ -16,22 +17,135 @@ type Ticket interface {
 	GetByID(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
 	GetTickets(ctx context.Context, pagination dbutil.Pagination) ([]entity.Ticket, []entity.Connection, int, error, bool)
 	Delete(ctx context.Context, id uuid.UUID) error
-	ChangeConnection(ctx context.Context, id, connectionID uuid.UUID) error
+	ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error
 	ChangePassenger(ctx context.Context, id, passengerID uuid.UUID) error
 	Complete(ctx context.Context, id uuid.UUID) error
-	DeleteTickets(ctx context.Context, paymentSessionID string) error
-	AddTickets(ctx context.Context, paymentSessionID string) error
+	AddTickets(ctx context.Context, paymentSessionID, paymentIntentID string) ([]uuid.UUID, error)
+	Issue(ctx context.Context, tickets []*entity.Ticket) error
+	ChangeStatus(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) error
+	Cancel(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error)
+	Refund(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error)
+	GetHistory(ctx context.Context, id uuid.UUID) (entity.Ticket, []entity.Stop, error)
+	ChangeOwner(ctx context.Context, id, userID uuid.UUID, email, phoneNumber string) error
+	LockByID(ctx context.Context, id uuid.UUID) (entity.Ticket, error)
 }
 
 type ticketMySQL struct {
 	db *gorm.DB
 }
 
-func (ds *ticketMySQL) AddTickets(ctx context.Context, paymentSessionID string) error {
-	var tickets []entity.Ticket
-	err := dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).
-		Where("id IN  (SELECT ticket_id FROM ticket_payments WHERE session_id = ?)", paymentSessionID).
-		Find(&tickets), "non-existing-session ")
+// AddTickets issues the tickets paid for in the checkout, moves the tickets
+// it rebooked and fulfils the waitlist offers it was bought with; it returns
+// the connections the rebooked tickets left.
+func (ds *ticketMySQL) AddTickets(ctx context.Context, paymentSessionID, paymentIntentID string) ([]uuid.UUID, error) {
+	var freed []uuid.UUID
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var holds []entity.SeatHold
+		err := dbutil.PossibleRawsAffectedError(tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Extras").
+			Where("session_id = ?", paymentSessionID).
+			Find(&holds), "non-existing-session")
+		if err != nil {
+			return err
+		}
+
+		var tickets = make([]*entity.Ticket, 0, len(holds))
+		var holdIDs = make([]uuid.UUID, len(holds))
+		var waitlistEntryIDs []uuid.UUID
+		for i, hold := range holds {
+			holdIDs[i] = hold.ID
+
+			if hold.RebookedTicketID.Valid {
+				left, err := rebookTicket(tx, hold, paymentIntentID)
+				if err != nil {
+					return err
+				}
+
+				if left != uuid.Nil && !slices.Contains(freed, left) {
+					freed = append(freed, left)
+				}
+				continue
+			}
+
+			if hold.WaitlistEntryID.Valid && !slices.Contains(waitlistEntryIDs, hold.WaitlistEntryID.UUID) {
+				waitlistEntryIDs = append(waitlistEntryIDs, hold.WaitlistEntryID.UUID)
+			}
+
+			ticket := hold.ToTicket()
+			ticket.TicketPayment.PaymentIntentID = paymentIntentID
+			tickets = append(tickets, &ticket)
+		}
+
+		if len(tickets) > 0 {
+			err = issueTickets(tx, tickets)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(waitlistEntryIDs) > 0 {
+			err = fulfillWaitlistEntries(tx, waitlistEntryIDs)
+			if err != nil {
+				return err
+			}
+		}
+
+		return deleteHolds(tx, holdIDs)
+	})
+
+	return freed, err
+}
+
+// rebookTicket moves the ticket to the seat of the hold, records the fare
+// difference paid for it and returns the connection the ticket left. A ticket
+// that has left Paid while the difference was being paid stays where it is,
+// and the difference is requested back.
+func rebookTicket(tx *gorm.DB, hold entity.SeatHold, paymentIntentID string) (uuid.UUID, error) {
+	var ticket entity.Ticket
+	err := dbutil.PossibleFirstError(tx.
+		Unscoped().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("TicketPayment").
+		Where("id = ?", hold.RebookedTicketID.UUID).
+		First(&ticket), "non-existing-ticket")
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	charge := entity.TicketCharge{
+		ID:              uuid.New(),
+		TicketID:        ticket.ID,
+		Amount:          hold.Price - ticket.TicketPayment.Price,
+		SessionID:       hold.SessionID,
+		PaymentIntentID: paymentIntentID,
+	}
+
+	err = dbutil.PossibleCreateError(tx.Create(&charge), "ticket-charge-data")
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if ticket.Status != entity.TicketStatusPaid || ticket.DeletedAt.Valid {
+		refund := entity.NewRefaund(ticket.ID, charge.Amount)
+		refund.Reason = entity.RefaundReasonRebooking
+		refund.PaymentIntentID = paymentIntentID
+		return uuid.Nil, dbutil.PossibleCreateError(tx.Create(&refund), "refund-data")
+	}
+
+	return ticket.ConnectionID, changeConnection(tx, ticket.ID, hold.ConnectionID, hold.SeatID, hold.Segment(), hold.Price)
+}
+
+func (ds *ticketMySQL) Issue(ctx context.Context, tickets []*entity.Ticket) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		return issueTickets(tx, tickets)
+	})
+}
+
+// issueTickets creates the tickets together with their confirmed pick-up and
+// drop-off stops.
+func issueTickets(tx *gorm.DB, tickets []*entity.Ticket) error {
+	err := dbutil.PossibleCreateError(tx.Create(tickets), "ticket-data")
 	if err != nil {
 		return err
 	}
@@ -68,70 +182,221 @@ func (ds *ticketMySQL) AddTickets(ctx context.Context, paymentSessionID string)
 		})
 	}
 
-	return dbutil.PossibleCreateError(ds.db.WithContext(ctx).Create(stops), "non-existing-connection")
+	return dbutil.PossibleCreateError(tx.Create(stops), "non-existing-connection")
 }
 
-func (ds *ticketMySQL) DeleteTickets(ctx context.Context, paymentSessionID string) error {
-	var ticketIDs []uuid.UUID
-	err := dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Table("tickets").
-		Select("id").Where("id IN  (SELECT ticket_id FROM ticket_payments WHERE session_id = ?)", paymentSessionID).
-		Find(&ticketIDs), "non-existing-session")
+// cancelTickets moves the tickets to the status of the update and
+// soft-deletes them with their stops, which gives their seats back to the
+// connection and takes them off its manifest; refunded tickets also give back
+// their loyalty points. It returns the connections the seats were given back
+// to.
+func cancelTickets(tx *gorm.DB, ticketIDs []uuid.UUID, update entity.TicketUpdate) ([]uuid.UUID, error) {
+	ticketIDs, err := changeTicketsStatus(tx, ticketIDs, update)
+	if err != nil || len(ticketIDs) == 0 {
+		return nil, err
+	}
+
+	if update.Status == entity.TicketStatusRefunded {
+		err = refundLoyaltyPoints(tx, ticketIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var connectionIDs []uuid.UUID
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Distinct("connection_id").
+		Where("id IN (?)", ticketIDs).
+		Pluck("connection_id", &connectionIDs))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var passengerIDs []uuid.UUID
-	err = dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Table("tickets").
-		Select("passenger_id").Where("id IN  (?)", ticketIDs).
-		Find(&passengerIDs), "non-existing-session")
+	err = dbutil.PossibleDbError(tx.Where("ticket_id IN (?)", ticketIDs).Delete(&entity.Stop{}))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return connectionIDs, dbutil.PossibleDbError(tx.Where("id IN (?)", ticketIDs).Delete(&entity.Ticket{}))
+}
+
+// changeTicketsStatus moves those of the tickets that may reach the status of
+// the update to it, records the update for each of them and returns their ids.
+func changeTicketsStatus(tx *gorm.DB, ticketIDs []uuid.UUID, update entity.TicketUpdate) ([]uuid.UUID, error) {
+	var changed []uuid.UUID
+	err := dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN (?) AND status IN (?)", ticketIDs, entity.TicketStatusesFrom(update.Status)).
+		Pluck("id", &changed))
+	if err != nil || len(changed) == 0 {
+		return nil, err
 	}
 
-	var adressIDs []uuid.UUID
-	err = dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Raw(`
-	 SELECT pick_up_adress_id FROM tickets WHERE id IN  (?) UNION SELECT drop_off_adress_id FROM tickets WHERE id IN  (?)
-	`, ticketIDs, ticketIDs).
-		Scan(&adressIDs), "non-existing-session")
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Where("id IN (?)", changed).
+		Update("status", update.Status))
 	if err != nil {
+		return nil, err
+	}
+
+	var updates = make([]entity.TicketUpdate, len(changed))
+	for i, id := range changed {
+		updates[i] = update
+		updates[i].TicketID = id
+	}
+
+	return changed, dbutil.PossibleCreateError(tx.Create(&updates), "ticket-update-data")
+}
+
+// Cancel cancels the ticket without a refund and returns the connection its
+// seat was given back to.
+func (ds *ticketMySQL) Cancel(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return ds.cancel(ctx, id, entity.TicketUpdate{Status: entity.TicketStatusCancelled, Comment: comment})
+}
+
+// Refund cancels the ticket whose price has been given back outside the
+// payment provider.
+func (ds *ticketMySQL) Refund(ctx context.Context, id uuid.UUID, comment string) ([]uuid.UUID, error) {
+	return ds.cancel(ctx, id, entity.TicketUpdate{Status: entity.TicketStatusRefunded, Comment: comment})
+}
+
+func (ds *ticketMySQL) cancel(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) ([]uuid.UUID, error) {
+	var freed []uuid.UUID
+	err := fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var ticket = entity.Ticket{ID: id}
+		err := dbutil.PossibleFirstError(tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket), "non-existing-ticket")
+		if err != nil {
+			return err
+		}
+
+		_, err = ticket.ChangeStatus(update.Status, update.Comment)
+		if err != nil {
+			return err
+		}
+
+		freed, err = cancelTickets(tx, []uuid.UUID{id}, update)
 		return err
+	})
+
+	return freed, err
+}
+
+// GetHistory returns the ticket, cancelled ones included, with its status and
+// payment history and its stops, those of a cancelled ticket included.
+func (ds *ticketMySQL) GetHistory(ctx context.Context, id uuid.UUID) (entity.Ticket, []entity.Stop, error) {
+	var ticket = entity.Ticket{ID: id}
+	err := dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Unscoped().
+		Preload(clause.Associations).
+		Preload("PickUpAdress.Country").
+		Preload("DropOffAdress.Country").
+		First(&ticket), "non-existing-ticket")
+	if err != nil {
+		return entity.Ticket{}, nil, err
 	}
 
-	err = dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).
-		Where("ticket_id IN  (?)", ticketIDs).Unscoped().Delete(&entity.TicketPayment{}), "non-existing-session")
+	var stops []entity.Stop
+	return ticket, stops, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Unscoped().
+		Preload("Updates").
+		Where("ticket_id = ?", id).
+		Find(&stops))
+}
+
+func (ds *ticketMySQL) ChangeStatus(ctx context.Context, id uuid.UUID, update entity.TicketUpdate) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var ticket = entity.Ticket{ID: id}
+		err := dbutil.PossibleFirstError(tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket), "non-existing-ticket")
+		if err != nil {
+			return err
+		}
+
+		update, err = ticket.ChangeStatus(update.Status, update.Comment)
+		if err != nil {
+			return err
+		}
+
+		err = dbutil.PossibleDbError(tx.Model(&ticket).Update("status", ticket.Status))
+		if err != nil {
+			return err
+		}
+
+		return dbutil.PossibleCreateError(tx.Create(&update), "ticket-update-data")
+	})
+}
+
+// completeTickets completes the tickets of the connection whose passengers
+// have boarded and marks those still paid for as no-shows.
+func completeTickets(tx *gorm.DB, connectionID uuid.UUID) error {
+	var ticketIDs []uuid.UUID
+	err := dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Where("connection_id = ? AND status = ?", connectionID, entity.TicketStatusBoarded).
+		Pluck("id", &ticketIDs))
 	if err != nil {
 		return err
 	}
 
-	err = dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Where("id IN  (?)", ticketIDs).Unscoped().Delete(&entity.Ticket{}), "non-existing-session")
-	if err != nil {
+	if len(ticketIDs) > 0 {
+		_, err = complete(tx, ticketIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	var noShowIDs []uuid.UUID
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Where("connection_id = ? AND status = ?", connectionID, entity.TicketStatusPaid).
+		Pluck("id", &noShowIDs))
+	if err != nil || len(noShowIDs) == 0 {
 		return err
 	}
 
-	err = dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).
-		Where("id IN (?)", passengerIDs).
-		Unscoped().Delete(&entity.Passenger{}), "non-existing-session")
+	_, err = changeTicketsStatus(tx, noShowIDs, entity.TicketUpdate{Status: entity.TicketStatusNoShow, Comment: "The passenger did not board."})
+	return err
+}
+
+// complete moves those of the tickets whose passengers have boarded to
+// completed, credits their loyalty points and returns their ids.
+func complete(tx *gorm.DB, ticketIDs []uuid.UUID) ([]uuid.UUID, error) {
+	ticketIDs, err := changeTicketsStatus(tx, ticketIDs, entity.TicketUpdate{Status: entity.TicketStatusCompleted})
+	if err != nil || len(ticketIDs) == 0 {
+		return nil, err
+	}
+
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Ticket{}).
+		Where("id IN (?)", ticketIDs).
+		Update("completed_at", time.Now().UTC()))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).
-		Where("id IN (?)", adressIDs).
-		Unscoped().Delete(&entity.Address{}), "non-existing-session")
+	return ticketIDs, earnLoyaltyPoints(tx, ticketIDs)
 }
 
 func (ds *ticketMySQL) Create(ctx context.Context, tickets []*entity.Ticket) error {
 
-	return dbutil.PossibleCreateError(ds.db.WithContext(ctx).Create(tickets), "ticket-data")
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(tickets), "ticket-data")
 }
 
 func (ds *ticketMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
 	var ticket = entity.Ticket{ID: id}
-	return ticket, dbutil.PossibleFirstError(ds.db.WithContext(ctx).Preload(clause.Associations).First(&ticket), "non-existing-ticket")
+	return ticket, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Preload(clause.Associations).
+		Preload("PickUpAdress.Country").
+		Preload("DropOffAdress.Country").
+		First(&ticket), "non-existing-ticket")
 }
 
 func (ds *ticketMySQL) GetTickets(ctx context.Context, pagination dbutil.Pagination) ([]entity.Ticket, []entity.Connection, int, error, bool) {
-	tickets, total, err, empty := dbutil.Paginate[entity.Ticket](ctx, ds.db, pagination, clause.Associations)
+	// Cancelled and refunded tickets are soft-deleted, so the status filter
+	// decides which of them are listed.
+	tickets, total, err, empty := dbutil.Paginate[entity.Ticket](ctx, fromContext(ctx, ds.db).Unscoped(), pagination, clause.Associations)
 	if err != nil && empty {
 		return nil, nil, 0, err, true
 	}
@@ -144,7 +409,7 @@ func (ds *ticketMySQL) GetTickets(ctx context.Context, pagination dbutil.Paginat
 	var connections []entity.Connection
 
 	return tickets, connections, total, dbutil.PossibleDbError(
-		ds.db.WithContext(ctx).
+		fromContext(ctx, ds.db).
 			Preload(clause.Associations).
 			Where(
 				"id IN (?)", connectionIDs,
@@ -155,19 +420,74 @@ func (ds *ticketMySQL) GetTickets(ctx context.Context, pagination dbutil.Paginat
 }
 
 func (ds *ticketMySQL) Delete(ctx context.Context, id uuid.UUID) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Delete(&entity.Ticket{}, id), "non-existing-ticket")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Delete(&entity.Ticket{}, id), "non-existing-ticket")
 }
 
-func (ds *ticketMySQL) ChangeConnection(ctx context.Context, id, connectionID uuid.UUID) error {
-	return dbutil.PossibleForeignKeyError(ds.db.WithContext(ctx).Where("id = ?", id).Update("connection_id", connectionID), "non-existing-ticket", "non-existing-connection", "invalid-id")
+func (ds *ticketMySQL) ChangeConnection(ctx context.Context, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		return changeConnection(tx, id, connectionID, seatID, segment, price)
+	})
+}
+
+// changeConnection moves the ticket, its stops and its price to the seat of
+// another connection.
+func changeConnection(tx *gorm.DB, id, connectionID, seatID uuid.UUID, segment entity.Segment, price int) error {
+	err := dbutil.PossibleForeignKeyError(tx.
+		Model(&entity.Ticket{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"connection_id": connectionID, "seat_id": seatID, "from_station": segment.From, "to_station": segment.To}), "non-existing-ticket", "non-existing-connection", "invalid-id")
+	if err != nil {
+		return err
+	}
+
+	err = dbutil.PossibleDbError(tx.
+		Model(&entity.Stop{}).
+		Where("ticket_id = ?", id).
+		Update("connection_id", connectionID))
+	if err != nil {
+		return err
+	}
+
+	return dbutil.PossibleDbError(tx.
+		Model(&entity.TicketPayment{}).
+		Where("ticket_id = ?", id).
+		Update("price", price))
+}
+
+func (ds *ticketMySQL) LockByID(ctx context.Context, id uuid.UUID) (entity.Ticket, error) {
+	var ticket = entity.Ticket{ID: id}
+	return ticket, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&ticket), "non-existing-ticket")
+}
+
+func (ds *ticketMySQL) ChangeOwner(ctx context.Context, id, userID uuid.UUID, email, phoneNumber string) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.Ticket{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"user_id": userID, "email": email, "phone_number": phoneNumber}), "non-existing-ticket")
 }
 
 func (ds *ticketMySQL) ChangePassenger(ctx context.Context, id uuid.UUID, passengerID uuid.UUID) error {
-	return dbutil.PossibleForeignKeyError(ds.db.WithContext(ctx).Where("id = ?", id).Update("passenger_id", passengerID), "non-existing-ticket", "non-existing-passenger", "invalid-id")
+	return dbutil.PossibleForeignKeyError(fromContext(ctx, ds.db).Model(&entity.Ticket{}).Where("id = ?", id).Update("passenger_id", passengerID), "non-existing-ticket", "non-existing-passenger", "invalid-id")
 }
 
 func (ds *ticketMySQL) Complete(ctx context.Context, id uuid.UUID) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Where("id = ?", id).Update("completed_at", time.Now().UTC()), "non-existing-ticket")
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		var ticket = entity.Ticket{ID: id}
+		err := dbutil.PossibleFirstError(tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket), "non-existing-ticket")
+		if err != nil {
+			return err
+		}
+
+		_, err = ticket.ChangeStatus(entity.TicketStatusCompleted, "")
+		if err != nil {
+			return err
+		}
+
+		_, err = complete(tx, []uuid.UUID{id})
+		return err
+	})
 }
 
 func NewTicket(db *gorm.DB) Ticket {
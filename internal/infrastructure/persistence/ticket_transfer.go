@@ -0,0 +1,70 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TicketTransfer interface {
+	Create(ctx context.Context, transfer *entity.TicketTransfer) error
+	LockByID(ctx context.Context, id uuid.UUID) (entity.TicketTransfer, error)
+	GetByUser(ctx context.Context, userID uuid.UUID, email string) ([]entity.TicketTransfer, error)
+	Accept(ctx context.Context, id, userID uuid.UUID) error
+}
+
+type ticketTransferMySQL struct {
+	db *gorm.DB
+}
+
+// Create stores the invitation and cancels the pending ones of the ticket.
+func (ds *ticketTransferMySQL) Create(ctx context.Context, transfer *entity.TicketTransfer) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		err := dbutil.PossibleDbError(tx.
+			Model(&entity.TicketTransfer{}).
+			Where("ticket_id = ? AND status = ?", transfer.TicketID, entity.TicketTransferStatusPending).
+			Update("status", entity.TicketTransferStatusCancelled))
+		if err != nil {
+			return err
+		}
+
+		return dbutil.PossibleCreateError(tx.Create(transfer), "ticket-transfer-data")
+	})
+}
+
+func (ds *ticketTransferMySQL) LockByID(ctx context.Context, id uuid.UUID) (entity.TicketTransfer, error) {
+	var transfer = entity.TicketTransfer{ID: id}
+	return transfer, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&transfer), "non-existing-ticket-transfer")
+}
+
+// GetByUser returns the transfers the user sent or accepted together with the
+// invitations sent to the user's email.
+func (ds *ticketTransferMySQL) GetByUser(ctx context.Context, userID uuid.UUID, email string) ([]entity.TicketTransfer, error) {
+	var transfers []entity.TicketTransfer
+	return transfers, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Where("from_user_id = ? OR to_user_id = ? OR (to_email = ? AND status = ?)", userID, userID, email, entity.TicketTransferStatusPending).
+		Order("created_at DESC").
+		Find(&transfers))
+}
+
+func (ds *ticketTransferMySQL) Accept(ctx context.Context, id, userID uuid.UUID) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.TicketTransfer{}).
+		Where("id = ? AND status = ?", id, entity.TicketTransferStatusPending).
+		Updates(map[string]any{
+			"status":      entity.TicketTransferStatusAccepted,
+			"to_user_id":  userID,
+			"accepted_at": time.Now(),
+		}), "invalid-ticket-transfer-status")
+}
+
+func NewTicketTransfer(db *gorm.DB) TicketTransfer {
+	return &ticketTransferMySQL{db}
+}
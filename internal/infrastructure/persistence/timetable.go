@@ -0,0 +1,67 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+type Timetable interface {
+	Create(ctx context.Context, timetable *entity.Timetable) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (entity.Timetable, error)
+	GetTimetables(ctx context.Context, pagination dbutil.Pagination) ([]entity.Timetable, int, error, bool)
+	GeneratedDates(ctx context.Context, id uuid.UUID, from, to time.Time) ([]time.Time, error)
+}
+
+type timetableMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *timetableMySQL) Create(ctx context.Context, timetable *entity.Timetable) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Omit("Route", "ReturnRoute").Create(timetable), "timetable-data")
+}
+
+// Delete removes the timetable with its exceptions; the trips generated from
+// it stay.
+func (ds *timetableMySQL) Delete(ctx context.Context, id uuid.UUID) error {
+	return fromContext(ctx, ds.db).Transaction(func(tx *gorm.DB) error {
+		err := dbutil.PossibleDbError(tx.Where("timetable_id = ?", id).Delete(&entity.TimetableException{}))
+		if err != nil {
+			return err
+		}
+
+		return dbutil.PossibleRawsAffectedError(tx.Delete(&entity.Timetable{ID: id}), "non-existing-timetable")
+	})
+}
+
+func (ds *timetableMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Timetable, error) {
+	var timetable = entity.Timetable{ID: id}
+	return timetable, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Preload("Exceptions").
+		Preload("Route.Stations.Country").
+		Preload("ReturnRoute.Stations.Country").
+		First(&timetable), "non-existing-timetable")
+}
+
+func (ds *timetableMySQL) GetTimetables(ctx context.Context, pagination dbutil.Pagination) ([]entity.Timetable, int, error, bool) {
+	return dbutil.Paginate[entity.Timetable](ctx, ds.db, pagination, "Exceptions")
+}
+
+// GeneratedDates returns the dates between from and to the timetable has
+// already generated trips for.
+func (ds *timetableMySQL) GeneratedDates(ctx context.Context, id uuid.UUID, from, to time.Time) ([]time.Time, error) {
+	var dates []time.Time
+	return dates, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.Trip{}).
+		Where("timetable_id = ? AND timetable_date BETWEEN ? AND ?", id, from, to).
+		Pluck("timetable_date", &dates))
+}
+
+func NewTimetable(db *gorm.DB) Timetable {
+	return &timetableMySQL{db}
+}
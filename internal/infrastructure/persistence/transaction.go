@@ -0,0 +1,40 @@
+package dataStore
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transactor runs a unit of work. Every data store called with the context
+// passed to fn takes part in the same database transaction.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactorMySQL struct {
+	db *gorm.DB
+}
+
+func (t *transactorMySQL) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fromContext(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// fromContext returns the transaction started by a Transactor if the context
+// carries one and falls back to db otherwise. Transactions opened on its
+// result nest as savepoints.
+func fromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return db.WithContext(ctx)
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return &transactorMySQL{db}
+}
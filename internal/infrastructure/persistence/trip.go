@@ -24,12 +24,12 @@ type tripMySQL struct {
 }
 
 func (ds *tripMySQL) Create(ctx context.Context, trip *entity.Trip) error {
-	return dbutil.PossibleCreateError(ds.db.WithContext(ctx).Create(trip), "trip-data")
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(trip), "trip-data")
 }
 
 func (ds *tripMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.Trip, error) {
 	var trip = entity.Trip{ID: id}
-	return trip, dbutil.PossibleFirstError(dbutil.Preload(ds.db.WithContext(ctx), entity.PreloadTrip()...).First(&trip), "non-existing-trip")
+	return trip, dbutil.PossibleFirstError(dbutil.Preload(fromContext(ctx, ds.db), entity.PreloadTrip()...).First(&trip), "non-existing-trip")
 }
 
 func (ds *tripMySQL) GetTrips(ctx context.Context, pagination dbutil.Pagination) ([]entity.Trip, int, error, bool) {
@@ -37,30 +37,30 @@ func (ds *tripMySQL) GetTrips(ctx context.Context, pagination dbutil.Pagination)
 }
 
 func (ds *tripMySQL) RegisterUpdate(ctx context.Context, update *entity.TripUpdate) error {
-	return dbutil.PossibleForeignKeyCreateError(ds.db.WithContext(ctx).Create(update), "non-exisitng-trip", "trip-update-data")
+	return dbutil.PossibleForeignKeyCreateError(fromContext(ctx, ds.db).Create(update), "non-exisitng-trip", "trip-update-data")
 }
 func (ds *tripMySQL) DeleteEverythingForTest(ctx context.Context) error {
-	err := ds.db.WithContext(ctx).Where("1=1").Delete(&entity.StopUpdate{}).Error
+	err := fromContext(ctx, ds.db).Where("1=1").Delete(&entity.StopUpdate{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
-	err = ds.db.WithContext(ctx).Where("1=1").Delete(&entity.Stop{}).Error
+	err = fromContext(ctx, ds.db).Where("1=1").Delete(&entity.Stop{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
-	err = ds.db.WithContext(ctx).Where("1=1").Delete(&entity.ConnectionUpdate{}).Error
+	err = fromContext(ctx, ds.db).Where("1=1").Delete(&entity.ConnectionUpdate{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
-	err = ds.db.WithContext(ctx).Where("1=1").Delete(&entity.Connection{}).Error
+	err = fromContext(ctx, ds.db).Where("1=1").Delete(&entity.Connection{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
-	err = ds.db.WithContext(ctx).Where("1=1").Delete(&entity.TripUpdate{}).Error
+	err = fromContext(ctx, ds.db).Where("1=1").Delete(&entity.TripUpdate{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
-	err = ds.db.WithContext(ctx).Where("1=1").Delete(&entity.Trip{}).Error
+	err = fromContext(ctx, ds.db).Where("1=1").Delete(&entity.Trip{}).Error
 	if err != nil {
 		return rfc7807.DB(err.Error())
 	}
@@ -68,7 +68,7 @@ func (ds *tripMySQL) DeleteEverythingForTest(ctx context.Context) error {
 }
 
 func (ds *tripMySQL) Test(ctx context.Context, trips []*entity.Trip) error {
-	return dbutil.PossibleRawsAffectedError(ds.db.WithContext(ctx).Create(trips), "invalid-trips-data")
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).Create(trips), "invalid-trips-data")
 }
 
 func NewTrip(db *gorm.DB) Trip {
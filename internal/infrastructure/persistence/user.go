@@ -25,7 +25,7 @@ type userMySQL struct {
 func (uds *userMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.User, error) {
 	user := entity.User{ID: id}
 	return user, dbutil.PossibleFirstError(
-		uds.db.WithContext(ctx).First(&user),
+		fromContext(ctx, uds.db).First(&user),
 		"non-existing-user",
 	)
 }
@@ -33,7 +33,7 @@ func (uds *userMySQL) GetByID(ctx context.Context, id uuid.UUID) (entity.User, e
 func (uds *userMySQL) Login(ctx context.Context, email string) (uuid.UUID, string, error) {
 	var user entity.User
 	err := dbutil.PossibleFirstError(
-		uds.db.WithContext(ctx).Select("id", "password").Where("email = ?", email).First(&user),
+		fromContext(ctx, uds.db).Select("id", "password").Where("email = ?", email).First(&user),
 		"non-existing-user",
 	)
 	return user.ID, user.Password, err
@@ -42,7 +42,7 @@ func (uds *userMySQL) Login(ctx context.Context, email string) (uuid.UUID, strin
 func (uds *userMySQL) EmailExists(ctx context.Context, email string) (uuid.UUID, bool, error) {
 	var user entity.User
 	err := dbutil.PossibleDbError(
-		uds.db.WithContext(ctx).Select("id").Where("email = ?", email).Find(&user),
+		fromContext(ctx, uds.db).Select("id").Where("email = ?", email).Find(&user),
 	)
 
 	return user.ID, user.ID != uuid.Nil, err
@@ -51,7 +51,7 @@ func (uds *userMySQL) EmailExists(ctx context.Context, email string) (uuid.UUID,
 func (uds *userMySQL) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
 	var exists bool
 	err := dbutil.PossibleRawsAffectedError(
-		uds.db.WithContext(ctx).Select("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(exists),
+		fromContext(ctx, uds.db).Select("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(exists),
 		"non-existing-user",
 	)
 	return exists, err
@@ -0,0 +1,91 @@
+package dataStore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// utcConversion records that the times stored in the zone of the server have
+// been converted to UTC, so it happens only once.
+type utcConversion struct {
+	Zone        string    `gorm:"type:varchar(64);primaryKey"`
+	ConvertedAt time.Time `gorm:"not null"`
+}
+
+// convertToUTC converts every DATETIME column from the zone the times used to
+// be stored in to UTC. Leaving the zone empty skips the conversion, as for the
+// databases created after the times were kept in UTC.
+func convertToUTC(db *gorm.DB, zone string) error {
+	if zone == "" {
+		return nil
+	}
+
+	err := db.AutoMigrate(&utcConversion{})
+	if err != nil {
+		return err
+	}
+
+	var converted int64
+	err = db.Model(&utcConversion{}).Count(&converted).Error
+	if err != nil || converted > 0 {
+		return err
+	}
+
+	// CONVERT_TZ gives NULL for a zone MySQL does not know, which would
+	// wipe the times out.
+	var probe sql.NullTime
+	err = db.Raw("SELECT CONVERT_TZ('2000-01-01 00:00:00', ?, '+00:00')", zone).Row().Scan(&probe)
+	if err != nil {
+		return err
+	}
+
+	if !probe.Valid {
+		return fmt.Errorf("MySQL does not know the time zone %s; load its time zone tables with mysql_tzinfo_to_sql first", zone)
+	}
+
+	var columns []struct {
+		TableName  string
+		ColumnName string
+	}
+
+	err = db.Raw(`
+		SELECT table_name AS table_name, column_name AS column_name
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND data_type = 'datetime' AND table_name <> 'utc_conversions'
+		ORDER BY table_name, ordinal_position
+	`).Scan(&columns).Error
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	var sets = map[string][]string{}
+	for _, column := range columns {
+		if _, ok := sets[column.TableName]; !ok {
+			tables = append(tables, column.TableName)
+		}
+		sets[column.TableName] = append(sets[column.TableName], fmt.Sprintf("`%[1]s` = CONVERT_TZ(`%[1]s`, @legacy_zone, '+00:00')", column.ColumnName))
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SET @legacy_zone = ?", zone).Error
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			err := tx.Exec(fmt.Sprintf("UPDATE `%s` SET %s", table, strings.Join(sets[table], ", "))).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Converted the times of %d tables from %s to UTC", len(tables), zone)
+		return tx.Create(&utcConversion{Zone: zone, ConvertedAt: time.Now()}).Error
+	})
+}
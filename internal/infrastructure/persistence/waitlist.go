@@ -0,0 +1,138 @@
+package dataStore
+
+import (
+	"context"
+	"maryan_api/internal/entity"
+	"maryan_api/pkg/dbutil"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Waitlist interface {
+	Create(ctx context.Context, entry *entity.WaitlistEntry) error
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error)
+	HasActive(ctx context.Context, connectionID, userID uuid.UUID) (bool, error)
+	LockByID(ctx context.Context, id uuid.UUID) (entity.WaitlistEntry, error)
+	LockWaiting(ctx context.Context, connectionID uuid.UUID) ([]entity.WaitlistEntry, error)
+	ConnectionsWithWaiting(ctx context.Context) ([]uuid.UUID, error)
+	ReservedSeats(ctx context.Context, connectionID, exceptID uuid.UUID) (int, error)
+	Offer(ctx context.Context, id uuid.UUID, until time.Time) error
+	Fulfill(ctx context.Context, id uuid.UUID) error
+	Expire(ctx context.Context, now time.Time) error
+	Depth(ctx context.Context, connectionID uuid.UUID) (entity.WaitlistDepth, error)
+}
+
+type waitlistMySQL struct {
+	db *gorm.DB
+}
+
+func (ds *waitlistMySQL) Create(ctx context.Context, entry *entity.WaitlistEntry) error {
+	return dbutil.PossibleCreateError(fromContext(ctx, ds.db).Create(entry), "waitlist-entry-data")
+}
+
+func (ds *waitlistMySQL) GetByUser(ctx context.Context, userID uuid.UUID) ([]entity.WaitlistEntry, error) {
+	var entries []entity.WaitlistEntry
+	return entries, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&entries))
+}
+
+func (ds *waitlistMySQL) HasActive(ctx context.Context, connectionID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Where("connection_id = ? AND user_id = ? AND status IN (?)", connectionID, userID, []any{entity.WaitlistStatusWaiting, entity.WaitlistStatusOffered}).
+		Count(&count))
+
+	return count > 0, err
+}
+
+func (ds *waitlistMySQL) LockByID(ctx context.Context, id uuid.UUID) (entity.WaitlistEntry, error) {
+	var entry entity.WaitlistEntry
+	return entry, dbutil.PossibleFirstError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&entry), "non-existing-waitlist-entry")
+}
+
+// LockWaiting returns the waiting entries of the connection, oldest first.
+func (ds *waitlistMySQL) LockWaiting(ctx context.Context, connectionID uuid.UUID) ([]entity.WaitlistEntry, error) {
+	var entries []entity.WaitlistEntry
+	return entries, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("User").
+		Where("connection_id = ? AND status = ?", connectionID, entity.WaitlistStatusWaiting).
+		Order("created_at").
+		Find(&entries))
+}
+
+func (ds *waitlistMySQL) ConnectionsWithWaiting(ctx context.Context) ([]uuid.UUID, error) {
+	var connectionIDs []uuid.UUID
+	return connectionIDs, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Distinct("connection_id").
+		Where("status = ?", entity.WaitlistStatusWaiting).
+		Pluck("connection_id", &connectionIDs))
+}
+
+// ReservedSeats counts the seats held by the live offers of the connection,
+// leaving out the entry whose offer is being used and the offers whose seats
+// are already held by their checkout.
+func (ds *waitlistMySQL) ReservedSeats(ctx context.Context, connectionID, exceptID uuid.UUID) (int, error) {
+	var reserved int
+	return reserved, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Select("COALESCE(SUM(party_size), 0)").
+		Where("connection_id = ? AND id <> ? AND status = ? AND offer_expires_at > ?", connectionID, exceptID, entity.WaitlistStatusOffered, time.Now()).
+		Where("id NOT IN (SELECT waitlist_entry_id FROM seat_holds WHERE waitlist_entry_id IS NOT NULL)").
+		Scan(&reserved))
+}
+
+func (ds *waitlistMySQL) Offer(ctx context.Context, id uuid.UUID, until time.Time) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Where("id = ? AND status = ?", id, entity.WaitlistStatusWaiting).
+		Updates(map[string]any{"status": entity.WaitlistStatusOffered, "offer_expires_at": until}), "invalid-waitlist-status")
+}
+
+func (ds *waitlistMySQL) Fulfill(ctx context.Context, id uuid.UUID) error {
+	return dbutil.PossibleRawsAffectedError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Where("id = ? AND status = ?", id, entity.WaitlistStatusOffered).
+		Update("status", entity.WaitlistStatusFulfilled), "invalid-waitlist-status")
+}
+
+// fulfillWaitlistEntries closes the offers tickets were bought with; an offer
+// that ran out while its checkout was being paid for is fulfilled as well.
+func fulfillWaitlistEntries(tx *gorm.DB, ids []uuid.UUID) error {
+	return dbutil.PossibleDbError(tx.
+		Model(&entity.WaitlistEntry{}).
+		Where("id IN (?) AND status IN (?)", ids, []any{entity.WaitlistStatusOffered, entity.WaitlistStatusExpired}).
+		Update("status", entity.WaitlistStatusFulfilled))
+}
+
+// Expire closes the entries of departed connections and the offers that were
+// not used in time.
+func (ds *waitlistMySQL) Expire(ctx context.Context, now time.Time) error {
+	return dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Where("status IN (?) AND (expires_at <= ? OR offer_expires_at <= ?)", []any{entity.WaitlistStatusWaiting, entity.WaitlistStatusOffered}, now, now).
+		Update("status", entity.WaitlistStatusExpired))
+}
+
+func (ds *waitlistMySQL) Depth(ctx context.Context, connectionID uuid.UUID) (entity.WaitlistDepth, error) {
+	var depth entity.WaitlistDepth
+	return depth, dbutil.PossibleDbError(fromContext(ctx, ds.db).
+		Model(&entity.WaitlistEntry{}).
+		Select("COUNT(*) AS entries, COALESCE(SUM(party_size), 0) AS passengers").
+		Where("connection_id = ? AND status = ? AND expires_at > ?", connectionID, entity.WaitlistStatusWaiting, time.Now()).
+		Scan(&depth))
+}
+
+func NewWaitlist(db *gorm.DB) Waitlist {
+	return &waitlistMySQL{db}
+}
@@ -4,10 +4,18 @@ import (
 	adress "maryan_api/internal/domain/adress/transport/http"
 	bus "maryan_api/internal/domain/bus/transport/http"
 	connection "maryan_api/internal/domain/connection/transport/http"
+	country "maryan_api/internal/domain/country/transport/http"
+	driver "maryan_api/internal/domain/driver/transport/http"
+	extra "maryan_api/internal/domain/extra/transport/http"
+	loyalty "maryan_api/internal/domain/loyalty/transport/http"
 	passenger "maryan_api/internal/domain/passenger/transport/http"
+	promo "maryan_api/internal/domain/promo/transport/http"
+	route "maryan_api/internal/domain/route/transport/http"
 	ticket "maryan_api/internal/domain/tickets/transport/http"
 	trip "maryan_api/internal/domain/trip/transport/http"
 	user "maryan_api/internal/domain/user/transport/http"
+	"maryan_api/internal/infrastructure/clients/payment"
+	"maryan_api/internal/infrastructure/scheduler"
 	ginutil "maryan_api/pkg/ginutils"
 	"net/http"
 
@@ -15,7 +23,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(s *gin.Engine, db *gorm.DB, client *http.Client) {
+func RegisterRoutes(s *gin.Engine, db *gorm.DB, client *http.Client, provider payment.Provider) {
 	s.Use(ginutil.LogMiddlewear(db))
 
 	passenger.RegisterRoutes(db, s, client)
@@ -24,5 +32,15 @@ func RegisterRoutes(s *gin.Engine, db *gorm.DB, client *http.Client) {
 	adress.RegisterRoutes(db, s, client)
 	connection.RegisterRoutes(db, s, client)
 	trip.RegisterRoutes(db, s, client)
-	ticket.RegisterRoutes(db, s, client)
+	ticket.RegisterRoutes(db, s, client, provider)
+	promo.RegisterRoutes(db, s, client)
+	extra.RegisterRoutes(db, s, client)
+	loyalty.RegisterRoutes(db, s, client)
+	route.RegisterRoutes(db, s, client)
+	country.RegisterRoutes(db, s, client)
+	driver.RegisterRoutes(db, s, client)
+}
+
+func RegisterJobs(db *gorm.DB, s *scheduler.Scheduler, provider payment.Provider) {
+	ticket.RegisterJobs(db, s, provider)
 }
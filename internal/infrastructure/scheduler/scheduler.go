@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maryan_api/internal/entity"
+	dataStore "maryan_api/internal/infrastructure/persistence"
+	"os"
+	"time"
+
+	"github.com/d3code/uuid"
+	"gorm.io/gorm"
+)
+
+// Job is run about every Interval by one of the API instances; Run returns
+// how many records it reclaimed. The instances tick on their own, so each
+// skips a run that follows a successful one too closely, and the lock keeps
+// two runs from overlapping.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration
+	Run      func(ctx context.Context) (int, error)
+}
+
+type Scheduler struct {
+	runs     dataStore.JobRun
+	instance string
+	jobs     []Job
+}
+
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every registered job in its own goroutine until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	release, locked, err := s.runs.TryLock(ctx, "job:"+job.Name)
+	if err != nil {
+		log.Printf("scheduler: job %s: taking the lock: %v", job.Name, err)
+		return
+	}
+
+	// Another instance is the leader for this run.
+	if !locked {
+		return
+	}
+	defer release()
+
+	// The tickers of the instances are not in step, so the run may already
+	// have been done by another instance. A little slack keeps an instance
+	// from skipping its own next tick.
+	last, err := s.runs.LastSucceeded(ctx, job.Name)
+	if err != nil {
+		log.Printf("scheduler: job %s: reading the last run: %v", job.Name, err)
+		return
+	}
+
+	if time.Since(last) < job.Interval-job.Interval/10 {
+		return
+	}
+
+	run := entity.JobRun{
+		ID:        uuid.New(),
+		Job:       job.Name,
+		Instance:  s.instance,
+		StartedAt: time.Now(),
+	}
+
+	run.Reclaimed, err = job.Run(ctx)
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Failed = true
+		run.Error = truncate(err.Error(), 500)
+	}
+
+	log.Printf("scheduler: job %s: reclaimed=%d duration=%s failed=%t %s", job.Name, run.Reclaimed, run.FinishedAt.Sub(run.StartedAt), run.Failed, run.Error)
+
+	if err := s.runs.Record(context.Background(), &run); err != nil {
+		log.Printf("scheduler: job %s: recording the run: %v", job.Name, err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+func New(db *gorm.DB) *Scheduler {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return &Scheduler{
+		runs:     dataStore.NewJobRun(db),
+		instance: fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
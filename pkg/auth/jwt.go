@@ -27,7 +27,13 @@ func generateToken(email string, userID uuid.UUID, role Role) (string, error) {
 }
 
 func GenerateAccessToken(secretKey []byte, claims jwt.MapClaims) (string, error) {
-	claims["expires"] = time.Now().Add(time.Minute * 10).Unix()
+	return GenerateAccessTokenFor(secretKey, claims, time.Minute*10)
+}
+
+// GenerateAccessTokenFor works like GenerateAccessToken for tokens that are
+// sent by email and may be used long after they have been issued.
+func GenerateAccessTokenFor(secretKey []byte, claims jwt.MapClaims, duration time.Duration) (string, error) {
+	claims["expires"] = time.Now().Add(duration).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	signedToken, err := token.SignedString(secretKey)
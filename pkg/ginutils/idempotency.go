@@ -0,0 +1,175 @@
+package ginutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	rfc7807 "maryan_api/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	idempotencyKeyTTL    = 24 * time.Hour
+)
+
+type IdempotencyRecord struct {
+	Scope       string    `gorm:"type:varchar(255);primaryKey"`
+	Key         string    `gorm:"type:varchar(255);primaryKey"`
+	RequestHash string    `gorm:"type:char(64);not null"`
+	Status      int       `gorm:"type:smallint;not null"`
+	Body        []byte    `gorm:"type:mediumblob"`
+	ContentType string    `gorm:"type:varchar(255)"`
+	Completed   bool      `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null;index"`
+}
+
+func MigrateIdempotency(db *gorm.DB) error {
+	return db.AutoMigrate(&IdempotencyRecord{})
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency stores the first response to a request carrying the
+// Idempotency-Key header and replays it for retries of the same user within
+// 24 hours. Requests without the header pass through untouched.
+func Idempotency(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			HandlerProblemAbort(c, rfc7807.BadRequest("invalid-idempotency-key", "Invalid Idempotency Key Error", "The key must not be longer than 255 characters."))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			HandlerProblemAbort(c, rfc7807.BadRequest("body-parsing", "Body Parsing Error", err.Error()))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		hash := sha256.Sum256(body)
+		record := IdempotencyRecord{
+			Scope:       fmt.Sprintf("%v %s %s", c.Value("userID"), c.Request.Method, c.FullPath()),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+		}
+
+		ctx := c.Request.Context()
+		err = db.WithContext(ctx).
+			Where("scope = ? AND `key` = ? AND created_at <= ?", record.Scope, record.Key, time.Now().Add(-idempotencyKeyTTL)).
+			Delete(&IdempotencyRecord{}).Error
+		if err != nil {
+			HandlerProblemAbort(c, rfc7807.DB(err.Error()))
+			return
+		}
+
+		result := db.WithContext(ctx).Create(&record)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			replayIdempotentResponse(c, db, record)
+			return
+		} else if result.Error != nil {
+			HandlerProblemAbort(c, rfc7807.DB(result.Error.Error()))
+			return
+		}
+
+		// A panicking handler leaves no response to store, so the key is
+		// released for the retry before the panic goes on to the recovery.
+		defer func() {
+			if r := recover(); r != nil {
+				releaseIdempotencyKey(db, record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored, so the client can retry them.
+		if recorder.Status() >= http.StatusInternalServerError {
+			releaseIdempotencyKey(db, record)
+			return
+		}
+
+		err = db.Model(&IdempotencyRecord{}).
+			Where("scope = ? AND `key` = ?", record.Scope, record.Key).
+			Updates(map[string]any{
+				"status":       recorder.Status(),
+				"body":         recorder.body.Bytes(),
+				"content_type": recorder.Header().Get("Content-Type"),
+				"completed":    true,
+			}).Error
+		if err != nil {
+			// Left incomplete, the key would answer every retry with a
+			// conflict until it expires.
+			log.Printf("idempotency: storing the response of %s %s: %v", record.Scope, record.Key, err)
+			releaseIdempotencyKey(db, record)
+		}
+	}
+}
+
+func releaseIdempotencyKey(db *gorm.DB, record IdempotencyRecord) {
+	err := db.Delete(&IdempotencyRecord{}, "scope = ? AND `key` = ?", record.Scope, record.Key).Error
+	if err != nil {
+		log.Printf("idempotency: releasing %s %s: %v", record.Scope, record.Key, err)
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, db *gorm.DB, request IdempotencyRecord) {
+	var stored IdempotencyRecord
+	err := db.WithContext(c.Request.Context()).
+		Where("scope = ? AND `key` = ?", request.Scope, request.Key).
+		First(&stored).Error
+	if err != nil {
+		HandlerProblemAbort(c, rfc7807.DB(err.Error()))
+		return
+	}
+
+	switch {
+	case stored.RequestHash != request.RequestHash:
+		HandlerProblemAbort(c, rfc7807.New(
+			http.StatusUnprocessableEntity,
+			"idempotency-key-reused",
+			"Idempotency Key Reused Error",
+			"The key has already been used for a request with a different body.",
+		))
+	case !stored.Completed:
+		HandlerProblemAbort(c, rfc7807.New(
+			http.StatusConflict,
+			"idempotency-key-in-progress",
+			"Idempotency Key In Progress Error",
+			"A request with the same key is still being processed.",
+		))
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
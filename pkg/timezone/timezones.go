@@ -1,73 +1,65 @@
 package timezone
 
 import (
-	"log"
+	"fmt"
+	"sync"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	mu          sync.RWMutex
+	countryToTZ = map[string]*time.Location{}
 )
 
-var countryToTZ = map[string]*time.Location{}
+// Load reads the time zone of every country from the countries table, so it
+// has to run once the database has been migrated.
+func Load(db *gorm.DB) {
+	err := Reload(db)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Reload replaces the time zones with those in the countries table; it runs
+// whenever a country is added or changed, and periodically.
+func Reload(db *gorm.DB) error {
+	var countries []struct {
+		Name     string
+		Timezone string
+	}
 
-func Load() {
-	loadLocation := func(tz string) *time.Location {
-		loc, err := time.LoadLocation(tz)
+	err := db.Table("countries").Select("name", "timezone").Scan(&countries).Error
+	if err != nil {
+		return err
+	}
+
+	var zones = make(map[string]*time.Location, len(countries))
+	for _, country := range countries {
+		loc, err := time.LoadLocation(country.Timezone)
 		if err != nil {
-			log.Fatalf("Failed to load location %s: %v", tz, err)
+			return fmt.Errorf("failed to load location %s of %s: %w", country.Timezone, country.Name, err)
 		}
-		return loc
+		zones[country.Name] = loc
 	}
 
-	countryToTZ = map[string]*time.Location{
-		"Albania":                loadLocation("Europe/Tirane"),
-		"Andorra":                loadLocation("Europe/Andorra"),
-		"Austria":                loadLocation("Europe/Vienna"),
-		"Belarus":                loadLocation("Europe/Minsk"),
-		"Belgium":                loadLocation("Europe/Brussels"),
-		"Bosnia and Herzegovina": loadLocation("Europe/Sarajevo"),
-		"Bulgaria":               loadLocation("Europe/Sofia"),
-		"Croatia":                loadLocation("Europe/Zagreb"),
-		"Cyprus":                 loadLocation("Asia/Nicosia"),
-		"Czech Republic":         loadLocation("Europe/Prague"),
-		"Denmark":                loadLocation("Europe/Copenhagen"),
-		"Estonia":                loadLocation("Europe/Tallinn"),
-		"Finland":                loadLocation("Europe/Helsinki"),
-		"France":                 loadLocation("Europe/Paris"),
-		"Germany":                loadLocation("Europe/Berlin"),
-		"Greece":                 loadLocation("Europe/Athens"),
-		"Hungary":                loadLocation("Europe/Budapest"),
-		"Iceland":                loadLocation("Atlantic/Reykjavik"),
-		"Ireland":                loadLocation("Europe/Dublin"),
-		"Italy":                  loadLocation("Europe/Rome"),
-		"Latvia":                 loadLocation("Europe/Riga"),
-		"Liechtenstein":          loadLocation("Europe/Vaduz"),
-		"Lithuania":              loadLocation("Europe/Vilnius"),
-		"Luxembourg":             loadLocation("Europe/Luxembourg"),
-		"Malta":                  loadLocation("Europe/Malta"),
-		"Moldova":                loadLocation("Europe/Chisinau"),
-		"Monaco":                 loadLocation("Europe/Monaco"),
-		"Montenegro":             loadLocation("Europe/Podgorica"),
-		"Netherlands":            loadLocation("Europe/Amsterdam"),
-		"North Macedonia":        loadLocation("Europe/Skopje"),
-		"Norway":                 loadLocation("Europe/Oslo"),
-		"Poland":                 loadLocation("Europe/Warsaw"),
-		"Portugal":               loadLocation("Europe/Lisbon"),
-		"Romania":                loadLocation("Europe/Bucharest"),
-		"Russia":                 loadLocation("Europe/Moscow"),
-		"San Marino":             loadLocation("Europe/San_Marino"),
-		"Serbia":                 loadLocation("Europe/Belgrade"),
-		"Slovakia":               loadLocation("Europe/Bratislava"),
-		"Slovenia":               loadLocation("Europe/Ljubljana"),
-		"Spain":                  loadLocation("Europe/Madrid"),
-		"Sweden":                 loadLocation("Europe/Stockholm"),
-		"Switzerland":            loadLocation("Europe/Zurich"),
-		"Turkey":                 loadLocation("Europe/Istanbul"),
-		"Ukraine":                loadLocation("Europe/Kyiv"),
-		"United Kingdom":         loadLocation("Europe/London"),
-		"Vatican City":           loadLocation("Europe/Vatican"),
-	}
+	mu.Lock()
+	countryToTZ = zones
+	mu.Unlock()
+	return nil
 }
 
-func Transform(time time.Time, country string) (time.Time, bool) {
+func Location(country string) (*time.Location, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	location, ok := countryToTZ[country]
+	return location, ok
+}
+
+func Transform(time time.Time, country string) (time.Time, bool) {
+	location, ok := Location(country)
 	if !ok {
 		return time, false
 	}